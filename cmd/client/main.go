@@ -3,10 +3,14 @@ package main
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"flag"
 	"fmt"
 	"go-grpc-pcbook/pb"
 	"go-grpc-pcbook/sample"
+	"go-grpc-pcbook/serializer"
 	"io"
 	"log"
 	"os"
@@ -14,11 +18,40 @@ import (
 	"strings"
 	"time"
 
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
+// Error message followed by the typed details the server attached to the status.
+func describeError(err error) string {
+	st, ok := status.FromError(err)
+	if !ok {
+		return fmt.Sprint(err)
+	}
+
+	description := fmt.Sprintf("%s: %s", st.Code(), st.Message())
+	for _, detail := range st.Details() {
+		switch detail := detail.(type) {
+		case *errdetails.ErrorInfo:
+			description += fmt.Sprintf("\n  reason: %s (%s) %v", detail.GetReason(), detail.GetDomain(), detail.GetMetadata())
+		case *errdetails.ResourceInfo:
+			description += fmt.Sprintf("\n  resource: %s %s: %s", detail.GetResourceType(), detail.GetResourceName(), detail.GetDescription())
+		case *errdetails.RetryInfo:
+			description += fmt.Sprintf("\n  retry in: %v", detail.GetRetryDelay().AsDuration())
+		case *errdetails.BadRequest:
+			for _, violation := range detail.GetFieldViolations() {
+				description += fmt.Sprintf("\n  %s: %s", violation.GetField(), violation.GetDescription())
+			}
+		}
+	}
+	return description
+}
+
 func createLaptop(laptopClient pb.LaptopServiceClient, laptop *pb.Laptop) {
 	req := &pb.CreateLaptopRequest{
 		Laptop: laptop,
@@ -33,7 +66,7 @@ func createLaptop(laptopClient pb.LaptopServiceClient, laptop *pb.Laptop) {
 		if ok && st.Code() == codes.AlreadyExists {
 			log.Print("Laptop already exists")
 		} else {
-			log.Fatal("Couldn't create laptop: ", err)
+			log.Fatal("Couldn't create laptop: ", describeError(err))
 		}
 		return
 	}
@@ -51,7 +84,7 @@ func searchLaptop(laptopClient pb.LaptopServiceClient, filter *pb.Filter) {
 
 	stream, err := laptopClient.SearchLaptop(ctx, req)
 	if err != nil {
-		log.Fatal("couldn't search laptop: ", err)
+		log.Fatal("couldn't search laptop: ", describeError(err))
 	}
 	count := 1
 	for {
@@ -60,7 +93,7 @@ func searchLaptop(laptopClient pb.LaptopServiceClient, filter *pb.Filter) {
 			return
 		}
 		if err != nil {
-			log.Fatal("couldn't receive response: ", err)
+			log.Fatal("couldn't receive response: ", describeError(err))
 		}
 		log.Printf(">>>>>> Laptop %d <<<<<<", count)
 		log.Println("brand: ", res.Laptop.GetBrand())
@@ -68,7 +101,7 @@ func searchLaptop(laptopClient pb.LaptopServiceClient, filter *pb.Filter) {
 		log.Println("cores: ", res.Laptop.GetCpu().GetCores())
 		log.Println("min freq: ", res.Laptop.GetCpu().GetMinGhz())
 		log.Println("ram: ", res.Laptop.GetMemory().GetValue(), res.Laptop.GetMemory().GetUnit())
-		log.Println("price: $", res.Laptop.GetPrice())
+		log.Println("price: ", res.Laptop.GetPrice(), res.Laptop.GetListPrice().GetCurrencyCode())
 
 		count++
 	}
@@ -86,7 +119,7 @@ func uploadImage(laptopClient pb.LaptopServiceClient, laptopId, imagePath string
 
 	stream, err := laptopClient.UploadImage(ctx)
 	if err != nil {
-		log.Fatal("couldn't upload image: ", err)
+		log.Fatal("couldn't upload image: ", describeError(err))
 	}
 
 	req := &pb.UploadImageRequest{
@@ -129,7 +162,7 @@ func uploadImage(laptopClient pb.LaptopServiceClient, laptopId, imagePath string
 
 	res, err := stream.CloseAndRecv()
 	if err != nil {
-		log.Fatal("couldn't receive response: ", err, stream.RecvMsg(nil)) // get error from sv
+		log.Fatal("couldn't receive response: ", describeError(err))
 	}
 
 	log.Printf("image uploaded with id %s and size %d", res.GetId(), res.GetSize())
@@ -141,7 +174,7 @@ func rateLaptop(laptopClient pb.LaptopServiceClient, laptopIds []string, scores
 
 	stream, err := laptopClient.RateLaptop(ctx)
 	if err != nil {
-		return fmt.Errorf("couldn't rate laptop: %v", err)
+		return fmt.Errorf("couldn't rate laptop: %s", describeError(err))
 	}
 
 	waitResponse := make(chan error)
@@ -155,7 +188,7 @@ func rateLaptop(laptopClient pb.LaptopServiceClient, laptopIds []string, scores
 				return
 			}
 			if err != nil {
-				waitResponse <- fmt.Errorf("couldn't receive response: %v", err)
+				waitResponse <- fmt.Errorf("couldn't receive response: %s", describeError(err))
 				return
 			}
 
@@ -172,7 +205,7 @@ func rateLaptop(laptopClient pb.LaptopServiceClient, laptopIds []string, scores
 
 		err := stream.Send(req)
 		if err != nil {
-			return fmt.Errorf("couldn't send stream req: %v - %v", err, stream.RecvMsg(nil))
+			return fmt.Errorf("couldn't send stream req: %v - %s", err, describeError(stream.RecvMsg(nil)))
 		}
 
 		log.Print("Sent request: ", req)
@@ -188,6 +221,246 @@ func rateLaptop(laptopClient pb.LaptopServiceClient, laptopIds []string, scores
 
 }
 
+// Opens a file of laptops as JSON array, JSON lines, CSV or length-delimited binary protobuf.
+func openLaptopFile(filename, format string, mapping serializer.CSVMapping) (serializer.MessageReader, io.Closer, error) {
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(filename), ".")
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't open file: %w", err)
+	}
+
+	switch strings.ToLower(format) {
+	case "json":
+		return serializer.NewJSONArrayReader(file), file, nil
+	case "jsonl", "ndjson":
+		return serializer.NewJSONLinesReader(file), file, nil
+	case "csv":
+		return serializer.NewCSVReader(file, mapping), file, nil
+	case "bin", "binary", "pb":
+		return serializer.NewDelimitedReader(file), file, nil
+	default:
+		file.Close()
+		return nil, nil, fmt.Errorf("unknown file format %q, use json, jsonl, csv or binary", format)
+	}
+}
+
+// Writes laptops as JSON lines, flattened CSV or length-delimited binary protobuf.
+func newLaptopWriter(w io.Writer, filename, format string, csvRepeated int) (serializer.MessageWriter, error) {
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(filename), ".")
+	}
+
+	switch strings.ToLower(format) {
+	case "jsonl", "ndjson":
+		return serializer.NewJSONLinesWriter(w), nil
+	case "csv":
+		columns := serializer.FlattenedColumns((&pb.Laptop{}).ProtoReflect().Descriptor(), csvRepeated)
+		return serializer.NewCSVWriter(w, columns), nil
+	case "bin", "binary", "pb":
+		return serializer.NewDelimitedWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unknown export format %q, use jsonl, csv or binary", format)
+	}
+}
+
+func exportLaptops(laptopClient pb.LaptopServiceClient, filter *pb.Filter, writer serializer.MessageWriter) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	stream, err := laptopClient.ExportLaptops(ctx, &pb.ExportLaptopsRequest{Filter: filter})
+	if err != nil {
+		return 0, fmt.Errorf("couldn't export laptops: %s", describeError(err))
+	}
+
+	count := 0
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, fmt.Errorf("couldn't receive laptop: %s", describeError(err))
+		}
+
+		err = writer.Write(res.GetLaptop())
+		if err != nil {
+			return count, fmt.Errorf("couldn't write laptop %s: %v", res.GetLaptop().GetId(), err)
+		}
+		count++
+	}
+}
+
+func exportLaptopsToFile(laptopClient pb.LaptopServiceClient, filename, format, filterJson string, csvRepeated int) error {
+	filter := &pb.Filter{}
+	if filterJson != "" {
+		if err := serializer.JsonToProtobuf([]byte(filterJson), filter, serializer.RejectUnknownFields); err != nil {
+			return fmt.Errorf("invalid filter: %v", err)
+		}
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("couldn't create file: %v", err)
+	}
+	defer file.Close()
+
+	buffer := bufio.NewWriter(file)
+	writer, err := newLaptopWriter(buffer, filename, format, csvRepeated)
+	if err != nil {
+		return err
+	}
+
+	count, err := exportLaptops(laptopClient, filter, writer)
+	if err != nil {
+		return err
+	}
+	if err := buffer.Flush(); err != nil {
+		return fmt.Errorf("couldn't write file: %v", err)
+	}
+	log.Printf("exported %d laptops to %s", count, filename)
+	return file.Close()
+}
+
+func importLaptops(laptopClient pb.LaptopServiceClient, reader serializer.MessageReader, dryRun bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	stream, err := laptopClient.ImportLaptops(ctx)
+	if err != nil {
+		return fmt.Errorf("couldn't import laptops: %s", describeError(err))
+	}
+
+	// file record of every laptop sent, rows that can't be read are skipped.
+	records := []int{}
+	unreadable := 0
+	for record := 1; ; record++ {
+		laptop := &pb.Laptop{}
+		err := reader.Read(laptop)
+		if err == io.EOF {
+			break
+		}
+		var recordErr *serializer.RecordError
+		if errors.As(err, &recordErr) {
+			log.Printf("skipped %v", recordErr)
+			unreadable++
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("couldn't read laptops: %v", err)
+		}
+
+		err = stream.Send(&pb.ImportLaptopsRequest{Laptop: laptop, DryRun: dryRun})
+		if err != nil {
+			return fmt.Errorf("couldn't send laptop: %v - %s", err, describeError(stream.RecvMsg(nil)))
+		}
+		records = append(records, record)
+	}
+
+	res, err := stream.CloseAndRecv()
+	if err != nil {
+		return fmt.Errorf("couldn't receive response: %s", describeError(err))
+	}
+
+	for _, result := range res.GetResults() {
+		if result.GetError() != "" {
+			log.Printf("record %d failed: %s: %s", records[result.GetIndex()-1], result.GetCode(), result.GetError())
+		}
+	}
+	if dryRun {
+		log.Printf("dry run: %d laptops are valid, %d failed, %d unreadable", res.GetImportedCount(), res.GetFailedCount(), unreadable)
+	} else {
+		log.Printf("imported %d laptops, %d failed, %d unreadable", res.GetImportedCount(), res.GetFailedCount(), unreadable)
+	}
+	return nil
+}
+
+func backupToFile(backupClient pb.BackupServiceClient, filename string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	stream, err := backupClient.Backup(ctx, &pb.BackupRequest{})
+	if err != nil {
+		return fmt.Errorf("couldn't back up: %s", describeError(err))
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("couldn't create backup file: %v", err)
+	}
+
+	size := 0
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			file.Close()
+			os.Remove(filename)
+			return fmt.Errorf("couldn't receive backup: %s", describeError(err))
+		}
+		if _, err := file.Write(res.GetChunk()); err != nil {
+			file.Close()
+			return fmt.Errorf("couldn't write backup file: %v", err)
+		}
+		size += len(res.GetChunk())
+	}
+	log.Printf("backed up %d bytes to %s", size, filename)
+	return file.Close()
+}
+
+func restoreFromFile(backupClient pb.BackupServiceClient, filename string, dryRun bool) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("couldn't open backup file: %v", err)
+	}
+	defer file.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+	stream, err := backupClient.Restore(ctx)
+	if err != nil {
+		return fmt.Errorf("couldn't restore: %s", describeError(err))
+	}
+	err = stream.Send(&pb.RestoreRequest{Data: &pb.RestoreRequest_Options{Options: &pb.RestoreOptions{DryRun: dryRun}}})
+	if err != nil {
+		return fmt.Errorf("couldn't send restore options: %v - %s", err, describeError(stream.RecvMsg(nil)))
+	}
+
+	reader := bufio.NewReader(file)
+	buffer := make([]byte, 64*1024)
+	for {
+		n, err := reader.Read(buffer)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("couldn't read backup file: %v", err)
+		}
+		err = stream.Send(&pb.RestoreRequest{Data: &pb.RestoreRequest_Chunk{Chunk: buffer[:n]}})
+		if err != nil {
+			return fmt.Errorf("couldn't send backup: %v - %s", err, describeError(stream.RecvMsg(nil)))
+		}
+	}
+
+	res, err := stream.CloseAndRecv()
+	if err != nil {
+		return fmt.Errorf("couldn't receive response: %s", describeError(err))
+	}
+	diff := res.GetDiff()
+	log.Printf("laptops: %d added, %d changed, %d removed; ratings: %d laptops changed; images: %d added, %d removed",
+		len(diff.GetAddedLaptopIds()), len(diff.GetChangedLaptopIds()), len(diff.GetRemovedLaptopIds()),
+		diff.GetChangedRatings(), len(diff.GetAddedImageIds()), len(diff.GetRemovedImageIds()))
+	if res.GetRestored() {
+		log.Printf("restored backup %s", filename)
+	} else {
+		log.Print("dry run: nothing was restored")
+	}
+	return nil
+}
+
 func testUploadImage(laptopClient pb.LaptopServiceClient) {
 	laptop := sample.NewLaptop()
 	createLaptop(laptopClient, laptop)
@@ -248,17 +521,123 @@ func testRateLaptop(laptopClient pb.LaptopServiceClient) {
 
 func main() {
 	serverAddress := flag.String("addr", "", "server address")
+	importFile := flag.String("import", "", "file of laptops to import instead of running the samples")
+	exportFile := flag.String("export", "", "file to export the catalog to instead of running the samples")
+	fileFormat := flag.String("format", "", "import or export file format: json (import only), jsonl, csv or binary. Taken from the file extension if empty")
+	csvMapping := flag.String("csv-mapping", "", "CSV column to laptop field, like Brand=brand,Cores=cpu.cores. Headers are field names if empty")
+	dryRun := flag.Bool("dry-run", false, "validate the imported laptops or restored backup without saving them")
+	exportFilter := flag.String("filter", "", `exported laptops filter as JSON, like {"min_cores": 4}`)
+	csvRepeated := flag.Int("csv-repeated", 4, "CSV columns exported for each repeated field like gpu")
+	tenantId := flag.String("tenant", "", "tenant whose catalog the calls work on, for multi-tenant servers")
+	backupFile := flag.String("backup", "", "file to back up the catalog, ratings and images to instead of running the samples")
+	restoreFile := flag.String("restore", "", "backup file to restore into an empty server, or compare with it given -dry-run")
+	tlsCA := flag.String("tls-ca", "", "CA certificate verifying the server, plaintext if empty")
+	tlsCert := flag.String("tls-cert", "", "client certificate naming the caller to servers verifying them")
+	tlsKey := flag.String("tls-key", "", "private key file of the client certificate")
 	flag.Parse()
 	log.Print("dial server ", *serverAddress)
 
-	conn, err := grpc.Dial(*serverAddress, grpc.WithInsecure())
+	transportCredentials, err := loadTransportCredentials(*tlsCA, *tlsCert, *tlsKey)
+	if err != nil {
+		log.Fatal("Couldn't load TLS credentials: ", err)
+	}
+	options := []grpc.DialOption{grpc.WithTransportCredentials(transportCredentials)}
+	if *tenantId != "" {
+		options = append(options, grpc.WithUnaryInterceptor(tenantUnary(*tenantId)), grpc.WithStreamInterceptor(tenantStream(*tenantId)))
+	}
+	conn, err := grpc.Dial(*serverAddress, options...)
 	if err != nil {
 		log.Fatal("Couldn't dial server: ", err)
 	}
 
 	laptopClient := pb.NewLaptopServiceClient(conn)
 
+	if *importFile != "" {
+		mapping, err := serializer.ParseCSVMapping(*csvMapping)
+		if err != nil {
+			log.Fatal(err)
+		}
+		reader, file, err := openLaptopFile(*importFile, *fileFormat, mapping)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+
+		err = importLaptops(laptopClient, reader, *dryRun)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *exportFile != "" {
+		err := exportLaptopsToFile(laptopClient, *exportFile, *fileFormat, *exportFilter, *csvRepeated)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *backupFile != "" {
+		err := backupToFile(pb.NewBackupServiceClient(conn), *backupFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *restoreFile != "" {
+		err := restoreFromFile(pb.NewBackupServiceClient(conn), *restoreFile, *dryRun)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	testUploadImage(laptopClient)
 	testRateLaptop(laptopClient)
 
 }
+
+// TLS verifying the server with the CA and presenting the client certificate if any,
+// plaintext without a CA.
+func loadTransportCredentials(caFile, certFile, keyFile string) (credentials.TransportCredentials, error) {
+	if caFile == "" {
+		if certFile != "" {
+			return nil, errors.New("-tls-cert needs -tls-ca")
+		}
+		return insecure.NewCredentials(), nil
+	}
+
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{RootCAs: x509.NewCertPool(), MinVersion: tls.VersionTLS12}
+	if !config.RootCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate in %s", caFile)
+	}
+	if certFile != "" {
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	return credentials.NewTLS(config), nil
+}
+
+// Names the tenant in the metadata of every call.
+func tenantUnary(tenantId string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-tenant-id", tenantId)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func tenantStream(tenantId string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-tenant-id", tenantId)
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
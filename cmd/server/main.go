@@ -1,31 +1,531 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
+	"go-grpc-pcbook/gateway"
 	"go-grpc-pcbook/pb"
+	"go-grpc-pcbook/raft"
 	"go-grpc-pcbook/service"
 	"log"
+	"log/slog"
 	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
+// Largest message between raft nodes, snapshots carry the whole catalog.
+const raftMaxMessageSize = 256 << 20
+
 func main() {
 	serverPort := flag.String("port", "", "server port")
+	httpPort := flag.String("http-port", "", "REST gateway port, disabled if empty")
+	metricsPort := flag.String("metrics-port", "", "prometheus /metrics port, disabled if empty")
+	logFormat := flag.String("log-format", "text", "log format: json or text")
+	logLevel := flag.String("log-level", "info", "log level: debug, info, warn or error")
+	defaultRate := flag.String("rate-limit", "", "default per client rate limit as rate:burst, unlimited if empty")
+	methodRates := flag.String("method-rate-limits", "RateLaptop=5:10,UploadImage=1:5", "per method rate limits as Method=rate:burst,...")
+	maxStreams := flag.Int("max-streams", 10, "max concurrent streams per client, unlimited if 0")
+	watchHistory := flag.Int("watch-history", 1000, "laptop events kept so watchers can resume")
+	watchBuffer := flag.Int("watch-buffer", 100, "laptop events buffered per watcher before dropping it")
+	webhookOutbox := flag.String("webhook-outbox", "outbox", "folder keeping pending and dead webhook deliveries")
+	webhookAttempts := flag.Int("webhook-attempts", 8, "webhook delivery attempts before giving up")
+	webhookAllowPrivate := flag.Bool("webhook-allow-private", false, "let webhooks call loopback, private and link-local addresses")
+	auditLogFile := flag.String("audit-log", "audit.log", "append only audit log of mutating calls")
+	exchangeRatesFile := flag.String("exchange-rates", "exchange_rates.json", "exchange rates file to show prices in other currencies")
+	orderJournal := flag.String("order-journal", "orders.jsonl", "journal file keeping carts and orders")
+	priceAlertBacklog := flag.Int("price-alert-backlog", 100, "price alert matches kept per client while it isn't watching")
+	maxPriceAlerts := flag.Int("max-price-alerts", 50, "price alerts a client can have")
+	recommendationInterval := flag.Duration("recommendation-interval", 10*time.Minute, "how often user recommendations are recomputed from the ratings")
+	multiTenant := flag.Bool("multi-tenant", false, "require the x-tenant-id metadata on every call and keep a separate catalog per tenant")
+	allowedTenants := flag.String("tenants", "", "comma separated tenants clients without a client certificate naming their organization can call as")
+	replicateFrom := flag.String("replicate-from", "", "address of a leader to follow as a read-only hot standby, leader if empty")
+	replicationHistory := flag.Int("replication-history", 10000, "catalog changes kept so lagging followers don't need a new snapshot")
+	replicationRetry := flag.Duration("replication-retry", 5*time.Second, "wait before a follower reconnects to its leader")
+	raftId := flag.String("raft-id", "", "address clients reach this server at, the other raft nodes dial its host at -raft-port. Runs the catalog as a node of a raft cluster if set")
+	raftMembers := flag.String("raft-members", "", "comma separated addresses of the founding raft cluster members, -raft-id included")
+	raftPort := flag.String("raft-port", "", "port the raft service listens on, apart from the public services so only it takes snapshot sized messages. Every node uses the same one")
+	raftState := flag.String("raft-state", "raft_state.jsonl", "file keeping the raft term, vote and log, so a restarted node rejoins with them")
+	maxRestoreSize := flag.Int64("max-restore-size", 1<<30, "largest backup archive a restore accepts, in bytes")
+	shards := flag.Int("shards", 1, "in memory shards the catalog is spread over")
+	remoteShards := flag.String("remote-shards", "", "comma separated addresses of servers run with -shard-backend, holding shards of the catalog")
+	shardBackend := flag.Bool("shard-backend", false, "serve the catalog store to servers using this one as a remote shard, which need the cluster role")
+	tlsCert := flag.String("tls-cert", "", "server certificate file, plaintext if empty. Also presented to the servers this one dials")
+	tlsKey := flag.String("tls-key", "", "private key file of the server certificate")
+	tlsCA := flag.String("tls-ca", "", "CA certificate verifying client certificates and the servers this one dials")
+	admins := flag.String("admins", "", "comma separated identities granted the admin role, like user:alice for a client certificate or peer:10.0.0.5")
+	operators := flag.String("operators", "", "comma separated identities granted the operator role, which manages the stock and fulfills orders")
+	clusterPeers := flag.String("cluster-peers", "", "comma separated identities of the other servers of the deployment, like user:<server certificate common name>, granted the cluster role")
 	flag.Parse()
+
+	if *priceAlertBacklog < 1 || *maxPriceAlerts < 1 {
+		log.Fatal("-price-alert-backlog and -max-price-alerts must be at least 1")
+	}
+	if *maxRestoreSize < 1 {
+		log.Fatal("-max-restore-size must be positive")
+	}
+	if *recommendationInterval <= 0 {
+		log.Fatal("-recommendation-interval must be positive")
+	}
+	if *replicateFrom != "" && *multiTenant {
+		log.Fatal("-replicate-from doesn't support -multi-tenant, only the default catalog is replicated")
+	}
+	if *raftId != "" && (*replicateFrom != "" || *multiTenant) {
+		log.Fatal("-raft-id doesn't support -replicate-from or -multi-tenant, raft nodes replicate the default catalog among themselves")
+	}
+	if *raftId != "" && *raftPort == "" {
+		log.Fatal("-raft-id needs -raft-port")
+	}
+	if *raftId != "" && !slices.Contains(strings.Split(*raftMembers, ","), *raftId) {
+		log.Fatal("-raft-members must list the founding members of the raft cluster, -raft-id included")
+	}
+	if *remoteShards != "" && *multiTenant {
+		log.Fatal("-remote-shards doesn't support -multi-tenant, remote shards only hold the default catalog")
+	}
+
+	security := loadTransportSecurity(*tlsCert, *tlsKey, *tlsCA)
+	adminIdentities, err := service.ParseIdentities(*admins)
+	if err != nil {
+		log.Fatalf("Error parsing -admins: %v", err)
+	}
+	operatorIdentities, err := service.ParseIdentities(*operators)
+	if err != nil {
+		log.Fatalf("Error parsing -operators: %v", err)
+	}
+	clusterIdentities, err := service.ParseIdentities(*clusterPeers)
+	if err != nil {
+		log.Fatalf("Error parsing -cluster-peers: %v", err)
+	}
+
+	logger, err := service.NewLogger(os.Stderr, *logFormat, *logLevel)
+	if err != nil {
+		log.Fatalf("Error creating logger: %v", err)
+	}
+	slog.SetDefault(logger)
+
 	serverAddress := fmt.Sprintf("0.0.0.0:%s", *serverPort)
 	log.Print("starting server at ", serverAddress)
-	laptopServer := service.NewLaptopServer(service.NewMemoryLaptopStore(), service.NewDiskImageStore("img"), service.NewMemoryRatingStore())
-	grpcServer := grpc.NewServer()
+
+	exchangeRates, err := service.LoadExchangeRatesFile(*exchangeRatesFile)
+	if err != nil {
+		log.Fatalf("Error loading exchange rates: %v", err)
+	}
+
+	laptopHub := service.NewLaptopHub(*watchHistory, *watchBuffer)
+	priceTracker := service.NewPriceTracker(exchangeRates, *priceAlertBacklog, *maxPriceAlerts)
+	similarityIndex := service.NewSimilarityIndex()
+	wishlistStore := service.NewMemoryWishlistStore()
+	newLaptopStore := func(store service.LaptopStore) service.LaptopStore {
+		return service.NewPublishingLaptopStore(
+			service.NewWishlistPruningLaptopStore(
+				service.NewIndexingLaptopStore(service.NewPriceTrackingLaptopStore(store, priceTracker), similarityIndex),
+				wishlistStore,
+			),
+			laptopHub,
+		)
+	}
+	replicationLog := service.NewReplicationLog(*replicationHistory)
+	catalogStore := newShardedStore(*shards, *remoteShards, security)
+	var laptopStore service.LaptopStore = service.NewReplicatingLaptopStore(newLaptopStore(catalogStore), replicationLog)
+	imageStore := service.NewDiskImageStore("img")
+	var ratingStore service.RatingStore = service.NewReplicatingRatingStore(service.NewMemoryRatingStore(), replicationLog)
+	var raftStores *service.RaftStores
+	if *raftId != "" {
+		// every node applies the committed writes to its own stores, so they all index and publish them.
+		config := raft.Config{Id: *raftId, Members: strings.Split(*raftMembers, ",")}
+		transport := service.NewRaftTransport(security.dialOption(), grpc.WithDefaultCallOptions(grpc.MaxCallSendMsgSize(raftMaxMessageSize)))
+		transport.Port = *raftPort
+		storage, err := raft.NewFileStorage(*raftState)
+		if err != nil {
+			log.Fatalf("Error opening raft state: %v", err)
+		}
+		raftStores, err = service.NewDurableRaftStores(config, storage, transport, laptopStore, ratingStore)
+		if err != nil {
+			log.Fatalf("Error restarting raft node: %v", err)
+		}
+		laptopStore, ratingStore = raftStores.LaptopStore(), raftStores.RatingStore()
+	}
+	tenants := service.NewTenantPartitions(func(tenantId string) (*service.TenantStores, error) {
+		imageFolder := filepath.Join("img", tenantId)
+		if err := os.MkdirAll(imageFolder, 0755); err != nil {
+			return nil, err
+		}
+		return &service.TenantStores{
+			LaptopStore: newLaptopStore(newShardedStore(*shards, "", security)),
+			ImageStore:  service.NewDiskImageStore(imageFolder),
+			RatingStore: service.NewMemoryRatingStore(),
+		}, nil
+	})
+	laptopServer := service.NewLaptopServer(laptopStore, imageStore, ratingStore)
+	laptopServer.LaptopHub = laptopHub
+	laptopServer.ExchangeRates = exchangeRates
+	laptopServer.Similarity = similarityIndex
+	var userScorer service.UserScorer = ratingStore
+	if *multiTenant {
+		userScorer = tenants
+	}
+	recommendationEngine := service.NewRecommendationEngine(userScorer)
+	go recommendationEngine.Run(context.Background(), *recommendationInterval)
+	laptopServer.Recommendations = recommendationEngine
+
+	authorizer := service.NewAuthorizer(map[string][]string{
+		service.RoleAdmin:    adminIdentities,
+		service.RoleOperator: operatorIdentities,
+		service.RoleCluster:  clusterIdentities,
+	})
+	authorizer.Restrict("/pcbook.WebhookService/", service.RoleAdmin)
+	authorizer.Restrict("/pcbook.BackupService/", service.RoleAdmin)
+	authorizer.Restrict("/pcbook.AuditService/", service.RoleAdmin)
+	authorizer.Restrict("/pcbook.InventoryService/AdjustStock", service.RoleOperator)
+	authorizer.Restrict("/pcbook.InventoryService/CommitReservation", service.RoleOperator)
+	authorizer.Restrict("/pcbook.ReplicationService/", service.RoleCluster)
+	authorizer.Restrict("/pcbook.RaftService/", service.RoleCluster)
+	authorizer.Restrict("/pcbook.ShardService/", service.RoleCluster)
+
+	inventoryStore := service.NewMemoryInventoryStore()
+	laptopServer.Inventory = inventoryStore
+	inventoryServer := service.NewInventoryServer(laptopStore, inventoryStore)
+
+	orderStore, err := service.NewFileOrderStore(*orderJournal)
+	if err != nil {
+		log.Fatalf("Error opening order journal: %v", err)
+	}
+	orderServer := service.NewOrderServer(laptopStore, orderStore)
+	orderServer.ExchangeRates = exchangeRates
+	orderServer.Inventory = inventoryStore
+	orderServer.Authorizer = authorizer
+	wishlistServer := service.NewWishlistServer(laptopStore, wishlistStore)
+	wishlistServer.ExchangeRates = exchangeRates
+	priceServer := service.NewPriceServer(laptopStore, priceTracker)
+
+	outbox, err := service.NewFileOutbox(*webhookOutbox)
+	if err != nil {
+		log.Fatalf("Error opening webhook outbox: %v", err)
+	}
+	webhookStore := service.NewMemoryWebhookStore()
+	dispatcher := service.NewWebhookDispatcher(webhookStore, outbox, service.WebhookDispatcherConfig{
+		MaxAttempts:  *webhookAttempts,
+		BaseBackoff:  time.Second,
+		MaxBackoff:   10 * time.Minute,
+		PollInterval: time.Second,
+		Timeout:      10 * time.Second,
+		LaptopTenant: tenants.Owner,
+
+		WorkersPerWebhook: 4,
+
+		AllowPrivateNetworks: *webhookAllowPrivate,
+	})
+	go dispatcher.Run(context.Background())
+	if *replicateFrom == "" {
+		// followers see the same changes, the leader alone notifies webhooks.
+		go dispatcher.WatchLaptops(context.Background(), laptopHub)
+	}
+	laptopServer.Webhooks = dispatcher
+	webhookServer := service.NewWebhookServer(webhookStore, dispatcher)
+
+	metrics := service.NewMetrics(prometheus.DefaultRegisterer)
+	service.RegisterStoreMetrics(prometheus.DefaultRegisterer, laptopStore, imageStore, ratingStore)
+
+	auditLog, err := service.NewFileAuditLog(*auditLogFile)
+	if err != nil {
+		log.Fatalf("Error opening audit log: %v", err)
+	}
+	auditInterceptor := service.NewAuditInterceptor(auditLog)
+	auditServer := service.NewAuditServer(auditLog)
+	backupServer := service.NewBackupServer(laptopStore, ratingStore, imageStore)
+	backupServer.Log = replicationLog
+	backupServer.MaxArchiveSize = *maxRestoreSize
+
+	requestLogger := service.NewRequestLogger(logger)
+	rateLimiter := service.NewRateLimiter(rateLimiterConfig(*defaultRate, *methodRates, *maxStreams))
+
+	// only the server's own gateway knows the token, so only it can tell which client it calls for.
+	gatewayToken := newGatewayToken()
+	forwardedInterceptor := service.NewForwardedInterceptor(string(gatewayToken))
+
+	unaryInterceptors := []grpc.UnaryServerInterceptor{forwardedInterceptor.Unary(), requestLogger.Unary(), metrics.Unary()}
+	streamInterceptors := []grpc.StreamServerInterceptor{forwardedInterceptor.Stream(), requestLogger.Stream(), metrics.Stream()}
+	if *multiTenant {
+		// before auditing, which tells callers apart by tenant.
+		var tenantIds []string
+		if *allowedTenants != "" {
+			tenantIds = strings.Split(*allowedTenants, ",")
+		}
+		tenantInterceptor := service.NewTenantInterceptor(tenants, tenantIds)
+		unaryInterceptors = append(unaryInterceptors, tenantInterceptor.Unary())
+		streamInterceptors = append(streamInterceptors, tenantInterceptor.Stream())
+	}
+	if *replicateFrom != "" {
+		followerInterceptor := service.NewFollowerInterceptor(*replicateFrom)
+		unaryInterceptors = append(unaryInterceptors, followerInterceptor.Unary())
+		streamInterceptors = append(streamInterceptors, followerInterceptor.Stream())
+		go runFollower(*replicateFrom, security, laptopStore, ratingStore, *replicationRetry)
+	}
+	if raftStores != nil {
+		raftInterceptor := service.NewRaftInterceptor(raftStores.Node)
+		unaryInterceptors = append(unaryInterceptors, raftInterceptor.Unary())
+		streamInterceptors = append(streamInterceptors, raftInterceptor.Stream())
+	}
+	// denied calls are audited and rate limited too.
+	unaryInterceptors = append(unaryInterceptors, auditInterceptor.Unary(), rateLimiter.Unary(), authorizer.Unary())
+	streamInterceptors = append(streamInterceptors, auditInterceptor.Stream(), rateLimiter.Stream(), authorizer.Stream())
+
+	serverOptions := []grpc.ServerOption{
+		security.serverOption(),
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	}
+	grpcServer := grpc.NewServer(serverOptions...)
 	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+	pb.RegisterWebhookServiceServer(grpcServer, webhookServer)
+	pb.RegisterAuditServiceServer(grpcServer, auditServer)
+	pb.RegisterPriceServiceServer(grpcServer, priceServer)
+	pb.RegisterInventoryServiceServer(grpcServer, inventoryServer)
+	pb.RegisterOrderServiceServer(grpcServer, orderServer)
+	pb.RegisterWishlistServiceServer(grpcServer, wishlistServer)
+	pb.RegisterBackupServiceServer(grpcServer, backupServer)
+	if *shardBackend {
+		// writes of the frontends are published, indexed and replicated like the backend's own.
+		pb.RegisterShardServiceServer(grpcServer, service.NewShardServer(laptopStore))
+	}
+	if raftStores != nil {
+		go runRaftServer(fmt.Sprintf("0.0.0.0:%s", *raftPort), security, raftStores.Node, authorizer, requestLogger, metrics)
+		raftStores.Node.Start()
+		log.Printf("raft node %s of cluster %s", *raftId, *raftMembers)
+	}
+	if !*multiTenant {
+		pb.RegisterReplicationServiceServer(grpcServer, service.NewReplicationServer(laptopStore, ratingStore, replicationLog))
+	}
 
 	listener, err := net.Listen("tcp", serverAddress)
 	if err != nil {
 		log.Fatalf("Error wiring server: %v", err)
 	}
+
+	if *httpPort != "" {
+		go runGateway(listener.Addr().String(), fmt.Sprintf("0.0.0.0:%s", *httpPort), security, gatewayToken)
+	}
+	if *metricsPort != "" {
+		go runMetrics(fmt.Sprintf("0.0.0.0:%s", *metricsPort))
+	}
+
 	err = grpcServer.Serve(listener)
 	if err != nil {
 		log.Fatalf("Error wiring server: %v", err)
 	}
 }
+
+func rateLimiterConfig(defaultRate string, methodRates string, maxStreams int) service.RateLimiterConfig {
+	config := service.RateLimiterConfig{MaxStreams: maxStreams}
+
+	var err error
+	if defaultRate != "" {
+		config.Default, err = service.ParseRateLimit(defaultRate)
+		if err != nil {
+			log.Fatalf("Error parsing -rate-limit: %v", err)
+		}
+	}
+	config.Methods, err = service.ParseRateLimits(methodRates)
+	if err != nil {
+		log.Fatalf("Error parsing -method-rate-limits: %v", err)
+	}
+	return config
+}
+
+// Laptop store spread over the local and remote shards, a plain memory store if there's only one.
+func newShardedStore(localShards int, remoteShards string, security *transportSecurity) service.LaptopStore {
+	shards := map[string]service.LaptopStore{}
+	for i := 0; i < localShards; i++ {
+		shards[fmt.Sprintf("local-%d", i)] = service.NewMemoryLaptopStore()
+	}
+	if remoteShards != "" {
+		for _, address := range strings.Split(remoteShards, ",") {
+			conn, err := grpc.Dial(address, security.dialOption())
+			if err != nil {
+				log.Fatalf("Error dialing remote shard: %v", err)
+			}
+			shards[address] = service.NewRemoteLaptopStore(pb.NewShardServiceClient(conn))
+		}
+	}
+
+	if len(shards) == 0 {
+		log.Fatal("the catalog needs at least one local or remote shard")
+	}
+	if len(shards) == 1 && localShards == 1 {
+		return shards["local-0"]
+	}
+	return service.NewShardedLaptopStore(shards)
+}
+
+// Keeps the stores a copy of the leader's.
+func runFollower(leaderAddress string, security *transportSecurity, laptopStore service.LaptopStore, ratingStore service.RatingStore, retry time.Duration) {
+	conn, err := grpc.Dial(leaderAddress, security.dialOption())
+	if err != nil {
+		log.Fatalf("Error dialing leader: %v", err)
+	}
+
+	log.Print("following leader at ", leaderAddress)
+	follower := service.NewFollower(pb.NewReplicationServiceClient(conn), laptopStore, ratingStore)
+	follower.Run(context.Background(), retry)
+}
+
+// Serves the REST gateway, forwarding every call to the gRPC server.
+func runGateway(grpcAddress string, httpAddress string, security *transportSecurity, token gatewayToken) {
+	conn, err := grpc.Dial(grpcAddress, security.gatewayDialOption(), grpc.WithPerRPCCredentials(token))
+	if err != nil {
+		log.Fatalf("Error dialing gRPC server from gateway: %v", err)
+	}
+
+	log.Print("starting REST gateway at ", httpAddress)
+	err = http.ListenAndServe(httpAddress, gateway.NewGateway(pb.NewLaptopServiceClient(conn)))
+	if err != nil {
+		log.Fatalf("Error wiring gateway: %v", err)
+	}
+}
+
+// Serves the RaftService apart from the public services: only messages between nodes
+// may be as large as a snapshot of the catalog.
+func runRaftServer(address string, security *transportSecurity, node *raft.Node, authorizer *service.Authorizer, requestLogger *service.RequestLogger, metrics *service.Metrics) {
+	raftServer := grpc.NewServer(
+		security.serverOption(),
+		grpc.MaxRecvMsgSize(raftMaxMessageSize),
+		grpc.ChainUnaryInterceptor(requestLogger.Unary(), metrics.Unary(), authorizer.Unary()),
+		grpc.ChainStreamInterceptor(requestLogger.Stream(), metrics.Stream(), authorizer.Stream()),
+	)
+	pb.RegisterRaftServiceServer(raftServer, service.NewRaftServer(node))
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Fatalf("Error wiring raft server: %v", err)
+	}
+	log.Print("serving raft at ", address)
+	err = raftServer.Serve(listener)
+	if err != nil {
+		log.Fatalf("Error wiring raft server: %v", err)
+	}
+}
+
+// TLS settings of the server and of its calls to other servers, nil for plaintext.
+type transportSecurity struct {
+	certificate tls.Certificate
+	// verifies client certificates and dialed servers, the system roots if nil.
+	ca *x509.CertPool
+}
+
+func loadTransportSecurity(certFile string, keyFile string, caFile string) *transportSecurity {
+	if certFile == "" {
+		if caFile != "" {
+			log.Fatal("-tls-ca needs -tls-cert")
+		}
+		return nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		log.Fatalf("Error loading server certificate: %v", err)
+	}
+	security := &transportSecurity{certificate: certificate}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			log.Fatalf("Error loading CA certificate: %v", err)
+		}
+		security.ca = x509.NewCertPool()
+		if !security.ca.AppendCertsFromPEM(pem) {
+			log.Fatalf("Error loading CA certificate: no certificate in %s", caFile)
+		}
+	}
+	return security
+}
+
+// Clients without a certificate can still call, a verified one names them, see service.CallerIdentity.
+func (s *transportSecurity) serverOption() grpc.ServerOption {
+	if s == nil {
+		return grpc.EmptyServerOption{}
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{s.certificate}, MinVersion: tls.VersionTLS12}
+	if s.ca != nil {
+		config.ClientCAs = s.ca
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return grpc.Creds(credentials.NewTLS(config))
+}
+
+// Dials another server of the cluster, presenting the server certificate.
+func (s *transportSecurity) dialOption() grpc.DialOption {
+	if s == nil {
+		return grpc.WithTransportCredentials(insecure.NewCredentials())
+	}
+	return grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{s.certificate},
+		RootCAs:      s.ca,
+		MinVersion:   tls.VersionTLS12,
+	}))
+}
+
+// Dials this server from its own gateway, which only accepts the server certificate.
+func (s *transportSecurity) gatewayDialOption() grpc.DialOption {
+	if s == nil {
+		return grpc.WithTransportCredentials(insecure.NewCredentials())
+	}
+	expected := s.certificate.Certificate[0]
+	return grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+		// the server certificate is pinned rather than checked against a CA and the listen address.
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, chains [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 || !bytes.Equal(rawCerts[0], expected) {
+				return errors.New("gateway dialed a server with another certificate")
+			}
+			return nil
+		},
+		MinVersion: tls.VersionTLS12,
+	}))
+}
+
+// Secret the gateway sends with every call, see service.ForwardedInterceptor.
+type gatewayToken string
+
+func newGatewayToken() gatewayToken {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatalf("Error creating gateway token: %v", err)
+	}
+	return gatewayToken(hex.EncodeToString(secret))
+}
+
+func (t gatewayToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{service.GatewayTokenKey: string(t)}, nil
+}
+
+func (t gatewayToken) RequireTransportSecurity() bool {
+	return false
+}
+
+// Exposes the prometheus metrics in the text exposition format.
+func runMetrics(address string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	log.Print("serving metrics at ", address)
+	err := http.ListenAndServe(address, mux)
+	if err != nil {
+		log.Fatalf("Error wiring metrics: %v", err)
+	}
+}
@@ -0,0 +1,491 @@
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"go-grpc-pcbook/pb"
+	"go-grpc-pcbook/serializer"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// max size of a multipart upload kept in memory, the rest goes to temp files.
+	maxMultipartMemory = 1 << 20
+	// max size of a JSON request body.
+	maxBodySize = 1 << 20
+	// max size of a multipart upload, the image plus the form overhead.
+	maxUploadSize  = 2 << 20
+	chunkSize      = 1024
+	requestTimeout = 5 * time.Second
+)
+
+// Translates HTTP/JSON requests into LaptopService calls.
+type Gateway struct {
+	laptopClient pb.LaptopServiceClient
+	mux          *http.ServeMux
+}
+
+func NewGateway(laptopClient pb.LaptopServiceClient) *Gateway {
+	g := &Gateway{laptopClient: laptopClient, mux: http.NewServeMux()}
+
+	g.mux.HandleFunc("/v1/laptops", g.handleCreateLaptop)
+	g.mux.HandleFunc("/v1/laptops:search", g.handleSearchLaptop)
+	g.mux.HandleFunc("/v1/laptops:compare", g.handleCompareLaptops)
+	g.mux.HandleFunc("/v1/laptops/", g.handleLaptopResource)
+	g.mux.HandleFunc("/v1/openapi.json", g.handleOpenAPI)
+	return g
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mux.ServeHTTP(w, r)
+}
+
+// POST /v1/laptops
+func (g *Gateway) handleCreateLaptop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	req := &pb.CreateLaptopRequest{}
+	if err := readJSONBody(w, r, req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(requestContext(r), requestTimeout)
+	defer cancel()
+
+	res, err := g.laptopClient.CreateLaptop(ctx, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, res)
+}
+
+// GET /v1/laptops:search?max_price=...&min_cores=...&min_ghz=...&min_ram.value=...&min_ram.unit=...&currency_code=...&sort=...&in_stock_only=...
+// POST /v1/laptops:search with a SearchLaptopRequest body.
+//
+// Results are streamed as newline delimited JSON, or as server sent events
+// when the client asks for text/event-stream.
+func (g *Gateway) handleSearchLaptop(w http.ResponseWriter, r *http.Request) {
+	req := &pb.SearchLaptopRequest{}
+	switch r.Method {
+	case http.MethodGet:
+		filter, err := filterFromQuery(r.URL.Query())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		req.Filter = filter
+		req.CurrencyCode = r.URL.Query().Get("currency_code")
+		if v := r.URL.Query().Get("sort"); v != "" {
+			order, ok := pb.SearchLaptopRequest_Sort_value[strings.ToUpper(v)]
+			if !ok {
+				writeError(w, status.Errorf(codes.InvalidArgument, "invalid sort: %q", v))
+				return
+			}
+			req.Sort = pb.SearchLaptopRequest_Sort(order)
+		}
+		if v := r.URL.Query().Get("in_stock_only"); v != "" {
+			inStockOnly, err := strconv.ParseBool(v)
+			if err != nil {
+				writeError(w, status.Errorf(codes.InvalidArgument, "invalid in_stock_only: %q", v))
+				return
+			}
+			req.InStockOnly = inStockOnly
+		}
+	case http.MethodPost:
+		if err := readJSONBody(w, r, req); err != nil {
+			writeError(w, err)
+			return
+		}
+	default:
+		writeMethodNotAllowed(w, http.MethodGet, http.MethodPost)
+		return
+	}
+
+	stream, err := g.laptopClient.SearchLaptop(requestContext(r), req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// first message is received before writing headers, so early errors still get a proper status.
+	res, err := stream.Recv()
+	if err != nil && err != io.EOF {
+		writeError(w, err)
+		return
+	}
+
+	sse := strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+
+	for err == nil {
+		data, marshalErr := serializer.ProtobufToCompactJson(res)
+		if marshalErr != nil {
+			slog.Error("gateway couldn't marshal search response", "request_id", r.Header.Get("X-Request-Id"), "error", marshalErr)
+			return
+		}
+		if sse {
+			fmt.Fprintf(w, "event: laptop\ndata: %s\n\n", data)
+		} else {
+			fmt.Fprintf(w, "%s\n", data)
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		res, err = stream.Recv()
+	}
+
+	if err != io.EOF {
+		// headers are already sent, so the error travels in the body.
+		slog.Warn("gateway search stream failed", "request_id", r.Header.Get("X-Request-Id"), "error", err)
+		data, _ := serializer.ProtobufToCompactJson(status.Convert(err).Proto())
+		if sse {
+			fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+		} else {
+			fmt.Fprintf(w, "{\"error\":%s}\n", data)
+		}
+	}
+}
+
+// GET /v1/laptops:compare?laptop_ids=...&laptop_ids=...&currency_code=...
+// POST /v1/laptops:compare with a CompareLaptopsRequest body.
+func (g *Gateway) handleCompareLaptops(w http.ResponseWriter, r *http.Request) {
+	req := &pb.CompareLaptopsRequest{}
+	switch r.Method {
+	case http.MethodGet:
+		req.LaptopIds = r.URL.Query()["laptop_ids"]
+		req.CurrencyCode = r.URL.Query().Get("currency_code")
+	case http.MethodPost:
+		if err := readJSONBody(w, r, req); err != nil {
+			writeError(w, err)
+			return
+		}
+	default:
+		writeMethodNotAllowed(w, http.MethodGet, http.MethodPost)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(requestContext(r), requestTimeout)
+	defer cancel()
+
+	res, err := g.laptopClient.CompareLaptops(ctx, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// Routes /v1/laptops/{id}/image and /v1/laptops/{id}/ratings.
+func (g *Gateway) handleLaptopResource(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/laptops/"), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		writeError(w, status.Errorf(codes.NotFound, "unknown path: %s", r.URL.Path))
+		return
+	}
+
+	laptopId := parts[0]
+	switch parts[1] {
+	case "image":
+		if r.Method != http.MethodPost {
+			writeMethodNotAllowed(w, http.MethodPost)
+			return
+		}
+		g.uploadImage(w, r, laptopId)
+	case "ratings":
+		if r.Method != http.MethodPost {
+			writeMethodNotAllowed(w, http.MethodPost)
+			return
+		}
+		g.rateLaptop(w, r, laptopId)
+	default:
+		writeError(w, status.Errorf(codes.NotFound, "unknown path: %s", r.URL.Path))
+	}
+}
+
+// POST /v1/laptops/{id}/image as multipart/form-data with the file in the "image" field.
+func (g *Gateway) uploadImage(w http.ResponseWriter, r *http.Request, laptopId string) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	err := r.ParseMultipartForm(maxMultipartMemory)
+	if err != nil {
+		writeError(w, status.Errorf(codes.InvalidArgument, "couldn't parse multipart form: %v", err))
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("image")
+	if err != nil {
+		writeError(w, status.Errorf(codes.InvalidArgument, "missing image file: %v", err))
+		return
+	}
+	defer file.Close()
+
+	ctx, cancel := context.WithTimeout(requestContext(r), requestTimeout)
+	defer cancel()
+
+	res, err := g.sendImage(ctx, laptopId, filepath.Ext(header.Filename), file)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, res)
+}
+
+func (g *Gateway) sendImage(ctx context.Context, laptopId string, imageType string, file multipart.File) (*pb.UploadImageResponse, error) {
+	stream, err := g.laptopClient.UploadImage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &pb.UploadImageRequest{
+		Data: &pb.UploadImageRequest_Info{
+			Info: &pb.ImageInfo{LaptopId: laptopId, ImageType: imageType},
+		},
+	}
+	if err := stream.Send(req); err != nil {
+		// real error comes from the server side of the stream.
+		_, err = stream.CloseAndRecv()
+		return nil, err
+	}
+
+	reader := bufio.NewReader(file)
+	buffer := make([]byte, chunkSize)
+	for {
+		n, err := reader.Read(buffer)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "couldn't read image chunk: %v", err)
+		}
+
+		req := &pb.UploadImageRequest{
+			Data: &pb.UploadImageRequest_ChunkData{ChunkData: buffer[:n]},
+		}
+		if err := stream.Send(req); err != nil {
+			_, err = stream.CloseAndRecv()
+			return nil, err
+		}
+	}
+
+	return stream.CloseAndRecv()
+}
+
+// POST /v1/laptops/{id}/ratings with a {"score": ...} body.
+func (g *Gateway) rateLaptop(w http.ResponseWriter, r *http.Request, laptopId string) {
+	req := &pb.RateLaptopRequest{}
+	if err := readJSONBody(w, r, req); err != nil {
+		writeError(w, err)
+		return
+	}
+	req.LaptopId = laptopId
+
+	ctx, cancel := context.WithTimeout(requestContext(r), requestTimeout)
+	defer cancel()
+
+	stream, err := g.laptopClient.RateLaptop(ctx)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := stream.Send(req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := stream.CloseSend(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	res, err := stream.Recv()
+	if err != nil {
+		if err == io.EOF {
+			err = status.Error(codes.Internal, "no rating response received")
+		}
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// GET /v1/openapi.json
+func (g *Gateway) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	data, err := OpenAPIDocument()
+	if err != nil {
+		writeError(w, status.Errorf(codes.Internal, "couldn't build openapi document: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// Builds a filter from query params named after the proto fields.
+func filterFromQuery(query map[string][]string) (*pb.Filter, error) {
+	filter := &pb.Filter{}
+	get := func(key string) string {
+		if values := query[key]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	var err error
+	if v := get("max_price"); v != "" {
+		if filter.MaxPrice, err = strconv.ParseFloat(v, 64); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid max_price: %v", err)
+		}
+	}
+	if v := get("min_cores"); v != "" {
+		cores, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid min_cores: %v", err)
+		}
+		filter.MinCores = uint32(cores)
+	}
+	if v := get("min_ghz"); v != "" {
+		if filter.MinGhz, err = strconv.ParseFloat(v, 64); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid min_ghz: %v", err)
+		}
+	}
+	if v := get("min_ram.value"); v != "" {
+		value, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid min_ram.value: %v", err)
+		}
+		unit, ok := pb.Memory_Unit_value[strings.ToUpper(get("min_ram.unit"))]
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid min_ram.unit: %q", get("min_ram.unit"))
+		}
+		filter.MinRam = &pb.Memory{Value: value, Unit: pb.Memory_Unit(unit)}
+	}
+	return filter, nil
+}
+
+// Forwards the caller X-Request-Id so gateway calls can be traced in the server logs,
+// and the X-Tenant-Id naming the catalog of multi-tenant servers. The address of the
+// HTTP client goes in x-forwarded-for, taken from the connection rather than from
+// headers the client could set.
+func requestContext(r *http.Request) context.Context {
+	ctx := r.Context()
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-forwarded-for", host)
+	}
+	if id := r.Header.Get("X-Request-Id"); id != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-request-id", id)
+	}
+	if tenantId := r.Header.Get("X-Tenant-Id"); tenantId != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-tenant-id", tenantId)
+	}
+	return ctx
+}
+
+func readJSONBody(w http.ResponseWriter, r *http.Request, message proto.Message) error {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return status.Errorf(codes.InvalidArgument, "request body is larger than %d bytes", maxBodySize)
+	}
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "couldn't read request body: %v", err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if err := protojson.Unmarshal(data, message); err != nil {
+		return status.Errorf(codes.InvalidArgument, "couldn't parse request body: %v", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, message proto.Message) {
+	data, err := serializer.ProtobufToJson(message)
+	if err != nil {
+		writeError(w, status.Errorf(codes.Internal, "couldn't marshal response: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(data)
+}
+
+// Writes the gRPC status as JSON with the matching HTTP status code.
+func writeError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	data, marshalErr := serializer.ProtobufToJson(st.Proto())
+	if marshalErr != nil {
+		http.Error(w, st.Message(), HTTPStatusFromCode(st.Code()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(HTTPStatusFromCode(st.Code()))
+	w.Write(data)
+}
+
+func writeMethodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusMethodNotAllowed)
+	fmt.Fprintf(w, "{\"code\": %d, \"message\": \"method not allowed\"}", codes.Unimplemented)
+}
+
+// Maps gRPC status codes to HTTP ones, following google.rpc.Code docs.
+func HTTPStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,254 @@
+package gateway_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go-grpc-pcbook/gateway"
+	"go-grpc-pcbook/pb"
+	"go-grpc-pcbook/sample"
+	"go-grpc-pcbook/serializer"
+	"go-grpc-pcbook/service"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestGatewayCreateLaptop(t *testing.T) {
+	laptopStore := service.NewMemoryLaptopStore()
+	gatewayServer := startTestGateway(t, laptopStore, nil, nil)
+
+	laptop := sample.NewLaptop()
+	body, err := serializer.ProtobufToJson(&pb.CreateLaptopRequest{Laptop: laptop})
+	require.NoError(t, err)
+
+	res, err := http.Post(gatewayServer.URL+"/v1/laptops", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+
+	created := &pb.CreateLaptopResponse{}
+	requireJSONBody(t, res.Body, created)
+	require.Equal(t, laptop.Id, created.Id)
+
+	// same laptop again must map AlreadyExists to conflict.
+	res, err = http.Post(gatewayServer.URL+"/v1/laptops", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusConflict, res.StatusCode)
+
+	res, err = http.Post(gatewayServer.URL+"/v1/laptops", "application/json", strings.NewReader(strings.Repeat(" ", 2<<20)))
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestGatewaySearchLaptop(t *testing.T) {
+	laptopStore := service.NewMemoryLaptopStore()
+	expectedIds := make(map[string]bool)
+	for i := 0; i < 4; i++ {
+		laptop := sample.NewLaptop()
+		laptop.Price = 1000
+		if i%2 == 0 {
+			laptop.Price = 5000
+		} else {
+			expectedIds[laptop.Id] = true
+		}
+		require.NoError(t, laptopStore.Save(laptop))
+	}
+
+	gatewayServer := startTestGateway(t, laptopStore, nil, nil)
+
+	t.Run("Newline delimited JSON", func(t *testing.T) {
+		res, err := http.Get(gatewayServer.URL + "/v1/laptops:search?max_price=2000&min_ram.value=1&min_ram.unit=bit")
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, "application/x-ndjson", res.Header.Get("Content-Type"))
+
+		found := 0
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			laptopRes := &pb.SearchLaptopResponse{}
+			require.NoError(t, protojson.Unmarshal(scanner.Bytes(), laptopRes))
+			require.Contains(t, expectedIds, laptopRes.GetLaptop().GetId())
+			found++
+		}
+		require.Equal(t, len(expectedIds), found)
+	})
+
+	t.Run("Server sent events", func(t *testing.T) {
+		body := `{"filter": {"max_price": 2000}}`
+		req, err := http.NewRequest(http.MethodPost, gatewayServer.URL+"/v1/laptops:search", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Accept", "text/event-stream")
+
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+		found := 0
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+			laptopRes := &pb.SearchLaptopResponse{}
+			require.NoError(t, protojson.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), laptopRes))
+			require.Contains(t, expectedIds, laptopRes.GetLaptop().GetId())
+			found++
+		}
+		require.Equal(t, len(expectedIds), found)
+	})
+
+	t.Run("Invalid query", func(t *testing.T) {
+		res, err := http.Get(gatewayServer.URL + "/v1/laptops:search?min_cores=many")
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+}
+
+func TestGatewayUploadImage(t *testing.T) {
+	testImageFolder := "../tmp"
+
+	laptopStore := service.NewMemoryLaptopStore()
+	imageStore := service.NewDiskImageStore(testImageFolder)
+	laptop := sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(laptop))
+
+	gatewayServer := startTestGateway(t, laptopStore, imageStore, nil)
+
+	image, err := os.ReadFile(testImageFolder + "/laptop.jpg")
+	require.NoError(t, err)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("image", "laptop.jpg")
+	require.NoError(t, err)
+	_, err = part.Write(image)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	res, err := http.Post(gatewayServer.URL+"/v1/laptops/"+laptop.Id+"/image", writer.FormDataContentType(), body)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+
+	uploaded := &pb.UploadImageResponse{}
+	requireJSONBody(t, res.Body, uploaded)
+	require.EqualValues(t, len(image), uploaded.GetSize())
+
+	savedImagePath := fmt.Sprintf("%s/%s.jpg", testImageFolder, uploaded.GetId())
+	require.FileExists(t, savedImagePath)
+	require.NoError(t, os.Remove(savedImagePath))
+}
+
+func TestGatewayRateLaptop(t *testing.T) {
+	laptopStore := service.NewMemoryLaptopStore()
+	ratingStore := service.NewMemoryRatingStore()
+	laptop := sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(laptop))
+
+	gatewayServer := startTestGateway(t, laptopStore, nil, ratingStore)
+
+	scores := []float64{8, 7.5, 10}
+	averages := []float64{8, 7.75, 8.5}
+	for i := range scores {
+		body := fmt.Sprintf(`{"score": %v}`, scores[i])
+		res, err := http.Post(gatewayServer.URL+"/v1/laptops/"+laptop.Id+"/ratings", "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		rated := &pb.RateLaptopResponse{}
+		requireJSONBody(t, res.Body, rated)
+		require.Equal(t, laptop.Id, rated.GetLaptopId())
+		require.Equal(t, uint32(i+1), rated.GetRatedCount())
+		require.Equal(t, averages[i], rated.GetAverageScore())
+	}
+}
+
+func TestGatewayOpenAPI(t *testing.T) {
+	gatewayServer := startTestGateway(t, service.NewMemoryLaptopStore(), nil, nil)
+
+	res, err := http.Get(gatewayServer.URL + "/v1/openapi.json")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	doc := struct {
+		Paths      map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}{}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&doc))
+	require.Contains(t, doc.Paths, "/v1/laptops")
+	require.Contains(t, doc.Paths, "/v1/laptops:search")
+	require.Contains(t, doc.Paths, "/v1/laptops/{laptop_id}/image")
+	require.Contains(t, doc.Paths, "/v1/laptops/{laptop_id}/ratings")
+	require.Contains(t, doc.Components.Schemas, "pcbook.Laptop")
+	require.Contains(t, doc.Components.Schemas, "pcbook.Memory")
+}
+
+func TestGatewayCompareLaptops(t *testing.T) {
+	laptopStore := service.NewMemoryLaptopStore()
+	gatewayServer := startTestGateway(t, laptopStore, nil, nil)
+
+	laptop1, laptop2 := sample.NewLaptop(), sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(laptop1))
+	require.NoError(t, laptopStore.Save(laptop2))
+
+	res, err := http.Get(gatewayServer.URL + "/v1/laptops:compare?laptop_ids=" + laptop1.Id + "&laptop_ids=" + laptop2.Id)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	compared := &pb.CompareLaptopsResponse{}
+	requireJSONBody(t, res.Body, compared)
+	require.Len(t, compared.GetLaptops(), 2)
+	require.NotEmpty(t, compared.GetRows())
+
+	res, err = http.Get(gatewayServer.URL + "/v1/laptops:compare?laptop_ids=" + laptop1.Id)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func startTestGateway(t *testing.T, laptopStore service.LaptopStore, imageStore service.ImageStore, ratingStore service.RatingStore) *httptest.Server {
+	laptopServer := service.NewLaptopServer(laptopStore, imageStore, ratingStore)
+
+	grpcServer := grpc.NewServer()
+	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+
+	gatewayServer := httptest.NewServer(gateway.NewGateway(pb.NewLaptopServiceClient(conn)))
+	t.Cleanup(gatewayServer.Close)
+	return gatewayServer
+}
+
+func requireJSONBody(t *testing.T, body io.Reader, message proto.Message) {
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+	require.NoError(t, protojson.Unmarshal(data, message))
+}
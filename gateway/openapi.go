@@ -0,0 +1,228 @@
+package gateway
+
+import (
+	"encoding/json"
+	"go-grpc-pcbook/pb"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type object = map[string]interface{}
+
+// Builds the OpenAPI 3 document for the gateway routes.
+// Schemas come from the proto descriptors so they never drift from the .proto files.
+func OpenAPIDocument() ([]byte, error) {
+	schemas := object{}
+	for _, message := range []protoreflect.ProtoMessage{
+		&pb.CreateLaptopRequest{},
+		&pb.CreateLaptopResponse{},
+		&pb.SearchLaptopRequest{},
+		&pb.SearchLaptopResponse{},
+		&pb.CompareLaptopsRequest{},
+		&pb.CompareLaptopsResponse{},
+		&pb.UploadImageResponse{},
+		&pb.RateLaptopRequest{},
+		&pb.RateLaptopResponse{},
+		&spb.Status{},
+	} {
+		addSchema(schemas, message.ProtoReflect().Descriptor())
+	}
+
+	doc := object{
+		"openapi": "3.0.3",
+		"info": object{
+			"title":   "pcbook LaptopService gateway",
+			"version": "v1",
+		},
+		"paths": object{
+			"/v1/laptops": object{
+				"post": operation("CreateLaptop", "Creates a laptop.",
+					jsonBody("pcbook.CreateLaptopRequest"),
+					"201", jsonResponse("pcbook.CreateLaptopResponse")),
+			},
+			"/v1/laptops:search": object{
+				"get": withParameters(
+					operation("SearchLaptopQuery", "Streams laptops matching the filter given as query params.",
+						nil, "200", streamResponse("pcbook.SearchLaptopResponse")),
+					queryParam("max_price", "number"),
+					queryParam("min_cores", "integer"),
+					queryParam("min_ghz", "number"),
+					queryParam("min_ram.value", "integer"),
+					queryParam("min_ram.unit", "string"),
+					queryParam("currency_code", "string"),
+					queryParam("sort", "string"),
+					queryParam("in_stock_only", "boolean"),
+				),
+				"post": operation("SearchLaptop", "Streams laptops matching the filter in the body.",
+					jsonBody("pcbook.SearchLaptopRequest"),
+					"200", streamResponse("pcbook.SearchLaptopResponse")),
+			},
+			"/v1/laptops:compare": object{
+				"get": withParameters(
+					operation("CompareLaptopsQuery", "Compares the laptops given as repeated laptop_ids query params.",
+						nil, "200", jsonResponse("pcbook.CompareLaptopsResponse")),
+					object{"name": "laptop_ids", "in": "query", "required": true, "schema": object{"type": "array", "items": object{"type": "string"}}},
+					queryParam("currency_code", "string"),
+				),
+				"post": operation("CompareLaptops", "Compares the laptops in the body spec by spec.",
+					jsonBody("pcbook.CompareLaptopsRequest"),
+					"200", jsonResponse("pcbook.CompareLaptopsResponse")),
+			},
+			"/v1/laptops/{laptop_id}/image": object{
+				"post": withParameters(
+					operation("UploadImage", "Uploads a laptop image as multipart/form-data.",
+						object{
+							"required": true,
+							"content": object{
+								"multipart/form-data": object{
+									"schema": object{
+										"type":     "object",
+										"required": []string{"image"},
+										"properties": object{
+											"image": object{"type": "string", "format": "binary"},
+										},
+									},
+								},
+							},
+						},
+						"201", jsonResponse("pcbook.UploadImageResponse")),
+					pathParam("laptop_id"),
+				),
+			},
+			"/v1/laptops/{laptop_id}/ratings": object{
+				"post": withParameters(
+					operation("RateLaptop", "Rates a laptop. laptop_id in the body is ignored.",
+						jsonBody("pcbook.RateLaptopRequest"),
+						"200", jsonResponse("pcbook.RateLaptopResponse")),
+					pathParam("laptop_id"),
+				),
+			},
+		},
+		"components": object{"schemas": schemas},
+	}
+	return json.MarshalIndent(doc, "", " ")
+}
+
+func operation(id string, summary string, body object, code string, response object) object {
+	op := object{
+		"operationId": id,
+		"summary":     summary,
+		"responses": object{
+			code: response,
+			"default": object{
+				"description": "gRPC status of the failed call.",
+				"content":     object{"application/json": object{"schema": ref("google.rpc.Status")}},
+			},
+		},
+	}
+	if body != nil {
+		op["requestBody"] = body
+	}
+	return op
+}
+
+func withParameters(op object, params ...object) object {
+	op["parameters"] = params
+	return op
+}
+
+func queryParam(name string, kind string) object {
+	return object{"name": name, "in": "query", "schema": object{"type": kind}}
+}
+
+func pathParam(name string) object {
+	return object{"name": name, "in": "path", "required": true, "schema": object{"type": "string"}}
+}
+
+func jsonBody(schema string) object {
+	return object{
+		"required": true,
+		"content":  object{"application/json": object{"schema": ref(schema)}},
+	}
+}
+
+func jsonResponse(schema string) object {
+	return object{
+		"description": "OK",
+		"content":     object{"application/json": object{"schema": ref(schema)}},
+	}
+}
+
+func streamResponse(schema string) object {
+	return object{
+		"description": "One JSON message per line, or one server sent event per message.",
+		"content": object{
+			"application/x-ndjson": object{"schema": ref(schema)},
+			"text/event-stream":    object{"schema": object{"type": "string"}},
+		},
+	}
+}
+
+func ref(name string) object {
+	return object{"$ref": "#/components/schemas/" + name}
+}
+
+// Adds the message schema and all the messages it references.
+func addSchema(schemas object, message protoreflect.MessageDescriptor) {
+	name := string(message.FullName())
+	if _, ok := schemas[name]; ok {
+		return
+	}
+	if name == string((&timestamppb.Timestamp{}).ProtoReflect().Descriptor().FullName()) {
+		schemas[name] = object{"type": "string", "format": "date-time"}
+		return
+	}
+
+	properties := object{}
+	schemas[name] = object{"type": "object", "properties": properties}
+
+	fields := message.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		schema := fieldSchema(schemas, field)
+		if field.IsList() {
+			schema = object{"type": "array", "items": schema}
+		}
+		properties[string(field.Name())] = schema
+	}
+}
+
+func fieldSchema(schemas object, field protoreflect.FieldDescriptor) object {
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		return object{"type": "boolean"}
+	case protoreflect.StringKind:
+		return object{"type": "string"}
+	case protoreflect.BytesKind:
+		return object{"type": "string", "format": "byte"}
+	case protoreflect.FloatKind:
+		return object{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return object{"type": "number", "format": "double"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return object{"type": "integer", "format": "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return object{"type": "integer", "format": "int64", "minimum": 0}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		// protojson writes 64 bit integers as strings.
+		return object{"type": "string", "format": "int64"}
+	case protoreflect.EnumKind:
+		values := field.Enum().Values()
+		names := make([]string, values.Len())
+		for i := range names {
+			names[i] = string(values.Get(i).Name())
+		}
+		return object{"type": "string", "enum": names}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if field.Message().FullName() == "google.protobuf.Any" {
+			return object{"type": "object"}
+		}
+		addSchema(schemas, field.Message())
+		return ref(string(field.Message().FullName()))
+	default:
+		return object{}
+	}
+}
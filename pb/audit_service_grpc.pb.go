@@ -0,0 +1,130 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.6.1
+// source: proto/audit_service.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// AuditServiceClient is the client API for AuditService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AuditServiceClient interface {
+	QueryAuditLog(ctx context.Context, in *QueryAuditLogRequest, opts ...grpc.CallOption) (AuditService_QueryAuditLogClient, error)
+}
+
+type auditServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAuditServiceClient(cc grpc.ClientConnInterface) AuditServiceClient {
+	return &auditServiceClient{cc}
+}
+
+func (c *auditServiceClient) QueryAuditLog(ctx context.Context, in *QueryAuditLogRequest, opts ...grpc.CallOption) (AuditService_QueryAuditLogClient, error) {
+	stream, err := c.cc.NewStream(ctx, &AuditService_ServiceDesc.Streams[0], "/pcbook.AuditService/QueryAuditLog", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &auditServiceQueryAuditLogClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type AuditService_QueryAuditLogClient interface {
+	Recv() (*QueryAuditLogResponse, error)
+	grpc.ClientStream
+}
+
+type auditServiceQueryAuditLogClient struct {
+	grpc.ClientStream
+}
+
+func (x *auditServiceQueryAuditLogClient) Recv() (*QueryAuditLogResponse, error) {
+	m := new(QueryAuditLogResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// AuditServiceServer is the server API for AuditService service.
+// All implementations should embed UnimplementedAuditServiceServer
+// for forward compatibility
+type AuditServiceServer interface {
+	QueryAuditLog(*QueryAuditLogRequest, AuditService_QueryAuditLogServer) error
+}
+
+// UnimplementedAuditServiceServer should be embedded to have forward compatible implementations.
+type UnimplementedAuditServiceServer struct {
+}
+
+func (UnimplementedAuditServiceServer) QueryAuditLog(*QueryAuditLogRequest, AuditService_QueryAuditLogServer) error {
+	return status.Errorf(codes.Unimplemented, "method QueryAuditLog not implemented")
+}
+
+// UnsafeAuditServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AuditServiceServer will
+// result in compilation errors.
+type UnsafeAuditServiceServer interface {
+	mustEmbedUnimplementedAuditServiceServer()
+}
+
+func RegisterAuditServiceServer(s grpc.ServiceRegistrar, srv AuditServiceServer) {
+	s.RegisterService(&AuditService_ServiceDesc, srv)
+}
+
+func _AuditService_QueryAuditLog_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(QueryAuditLogRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AuditServiceServer).QueryAuditLog(m, &auditServiceQueryAuditLogServer{stream})
+}
+
+type AuditService_QueryAuditLogServer interface {
+	Send(*QueryAuditLogResponse) error
+	grpc.ServerStream
+}
+
+type auditServiceQueryAuditLogServer struct {
+	grpc.ServerStream
+}
+
+func (x *auditServiceQueryAuditLogServer) Send(m *QueryAuditLogResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// AuditService_ServiceDesc is the grpc.ServiceDesc for AuditService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AuditService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pcbook.AuditService",
+	HandlerType: (*AuditServiceServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "QueryAuditLog",
+			Handler:       _AuditService_QueryAuditLog_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "proto/audit_service.proto",
+}
@@ -0,0 +1,278 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.28.0
+// 	protoc        v3.6.1
+// source: proto/comparison_message.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ComparisonCell struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	LaptopId          string  `protobuf:"bytes,1,opt,name=laptop_id,json=laptopId,proto3" json:"laptop_id,omitempty"`
+	Value             float64 `protobuf:"fixed64,2,opt,name=value,proto3" json:"value,omitempty"`
+	Available         bool    `protobuf:"varint,3,opt,name=available,proto3" json:"available,omitempty"`                                           // false if the laptop doesn't have the spec, like an unknown weight.
+	Best              bool    `protobuf:"varint,4,opt,name=best,proto3" json:"best,omitempty"`                                                     // every laptop sharing the best value is marked.
+	DifferencePercent float64 `protobuf:"fixed64,5,opt,name=difference_percent,json=differencePercent,proto3" json:"difference_percent,omitempty"` // relative to the best value, 0 for the best laptops.
+}
+
+func (x *ComparisonCell) Reset() {
+	*x = ComparisonCell{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_comparison_message_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ComparisonCell) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ComparisonCell) ProtoMessage() {}
+
+func (x *ComparisonCell) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_comparison_message_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ComparisonCell.ProtoReflect.Descriptor instead.
+func (*ComparisonCell) Descriptor() ([]byte, []int) {
+	return file_proto_comparison_message_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ComparisonCell) GetLaptopId() string {
+	if x != nil {
+		return x.LaptopId
+	}
+	return ""
+}
+
+func (x *ComparisonCell) GetValue() float64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+func (x *ComparisonCell) GetAvailable() bool {
+	if x != nil {
+		return x.Available
+	}
+	return false
+}
+
+func (x *ComparisonCell) GetBest() bool {
+	if x != nil {
+		return x.Best
+	}
+	return false
+}
+
+func (x *ComparisonCell) GetDifferencePercent() float64 {
+	if x != nil {
+		return x.DifferencePercent
+	}
+	return 0
+}
+
+// One spec of the compared laptops, in a common unit.
+type ComparisonRow struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Spec           string            `protobuf:"bytes,1,opt,name=spec,proto3" json:"spec,omitempty"` // like cpu.cores or storage.ssd.
+	Unit           string            `protobuf:"bytes,2,opt,name=unit,proto3" json:"unit,omitempty"`
+	HigherIsBetter bool              `protobuf:"varint,3,opt,name=higher_is_better,json=higherIsBetter,proto3" json:"higher_is_better,omitempty"`
+	Cells          []*ComparisonCell `protobuf:"bytes,4,rep,name=cells,proto3" json:"cells,omitempty"` // in the order of the compared laptops.
+}
+
+func (x *ComparisonRow) Reset() {
+	*x = ComparisonRow{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_comparison_message_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ComparisonRow) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ComparisonRow) ProtoMessage() {}
+
+func (x *ComparisonRow) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_comparison_message_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ComparisonRow.ProtoReflect.Descriptor instead.
+func (*ComparisonRow) Descriptor() ([]byte, []int) {
+	return file_proto_comparison_message_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ComparisonRow) GetSpec() string {
+	if x != nil {
+		return x.Spec
+	}
+	return ""
+}
+
+func (x *ComparisonRow) GetUnit() string {
+	if x != nil {
+		return x.Unit
+	}
+	return ""
+}
+
+func (x *ComparisonRow) GetHigherIsBetter() bool {
+	if x != nil {
+		return x.HigherIsBetter
+	}
+	return false
+}
+
+func (x *ComparisonRow) GetCells() []*ComparisonCell {
+	if x != nil {
+		return x.Cells
+	}
+	return nil
+}
+
+var File_proto_comparison_message_proto protoreflect.FileDescriptor
+
+var file_proto_comparison_message_proto_rawDesc = []byte{
+	0x0a, 0x1e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x63, 0x6f, 0x6d, 0x70, 0x61, 0x72, 0x69, 0x73,
+	0x6f, 0x6e, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x12, 0x06, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x22, 0xa4, 0x01, 0x0a, 0x0e, 0x43, 0x6f, 0x6d,
+	0x70, 0x61, 0x72, 0x69, 0x73, 0x6f, 0x6e, 0x43, 0x65, 0x6c, 0x6c, 0x12, 0x1b, 0x0a, 0x09, 0x6c,
+	0x61, 0x70, 0x74, 0x6f, 0x70, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08,
+	0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x1c,
+	0x0a, 0x09, 0x61, 0x76, 0x61, 0x69, 0x6c, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x08, 0x52, 0x09, 0x61, 0x76, 0x61, 0x69, 0x6c, 0x61, 0x62, 0x6c, 0x65, 0x12, 0x12, 0x0a, 0x04,
+	0x62, 0x65, 0x73, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x04, 0x62, 0x65, 0x73, 0x74,
+	0x12, 0x2d, 0x0a, 0x12, 0x64, 0x69, 0x66, 0x66, 0x65, 0x72, 0x65, 0x6e, 0x63, 0x65, 0x5f, 0x70,
+	0x65, 0x72, 0x63, 0x65, 0x6e, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x01, 0x52, 0x11, 0x64, 0x69,
+	0x66, 0x66, 0x65, 0x72, 0x65, 0x6e, 0x63, 0x65, 0x50, 0x65, 0x72, 0x63, 0x65, 0x6e, 0x74, 0x22,
+	0x8f, 0x01, 0x0a, 0x0d, 0x43, 0x6f, 0x6d, 0x70, 0x61, 0x72, 0x69, 0x73, 0x6f, 0x6e, 0x52, 0x6f,
+	0x77, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x70, 0x65, 0x63, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x04, 0x73, 0x70, 0x65, 0x63, 0x12, 0x12, 0x0a, 0x04, 0x75, 0x6e, 0x69, 0x74, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x04, 0x75, 0x6e, 0x69, 0x74, 0x12, 0x28, 0x0a, 0x10, 0x68, 0x69, 0x67,
+	0x68, 0x65, 0x72, 0x5f, 0x69, 0x73, 0x5f, 0x62, 0x65, 0x74, 0x74, 0x65, 0x72, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x0e, 0x68, 0x69, 0x67, 0x68, 0x65, 0x72, 0x49, 0x73, 0x42, 0x65, 0x74,
+	0x74, 0x65, 0x72, 0x12, 0x2c, 0x0a, 0x05, 0x63, 0x65, 0x6c, 0x6c, 0x73, 0x18, 0x04, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x16, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e, 0x43, 0x6f, 0x6d, 0x70,
+	0x61, 0x72, 0x69, 0x73, 0x6f, 0x6e, 0x43, 0x65, 0x6c, 0x6c, 0x52, 0x05, 0x63, 0x65, 0x6c, 0x6c,
+	0x73, 0x42, 0x06, 0x5a, 0x04, 0x2e, 0x2f, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x33,
+}
+
+var (
+	file_proto_comparison_message_proto_rawDescOnce sync.Once
+	file_proto_comparison_message_proto_rawDescData = file_proto_comparison_message_proto_rawDesc
+)
+
+func file_proto_comparison_message_proto_rawDescGZIP() []byte {
+	file_proto_comparison_message_proto_rawDescOnce.Do(func() {
+		file_proto_comparison_message_proto_rawDescData = protoimpl.X.CompressGZIP(file_proto_comparison_message_proto_rawDescData)
+	})
+	return file_proto_comparison_message_proto_rawDescData
+}
+
+var file_proto_comparison_message_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_proto_comparison_message_proto_goTypes = []interface{}{
+	(*ComparisonCell)(nil), // 0: pcbook.ComparisonCell
+	(*ComparisonRow)(nil),  // 1: pcbook.ComparisonRow
+}
+var file_proto_comparison_message_proto_depIdxs = []int32{
+	0, // 0: pcbook.ComparisonRow.cells:type_name -> pcbook.ComparisonCell
+	1, // [1:1] is the sub-list for method output_type
+	1, // [1:1] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_proto_comparison_message_proto_init() }
+func file_proto_comparison_message_proto_init() {
+	if File_proto_comparison_message_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_proto_comparison_message_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ComparisonCell); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_comparison_message_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ComparisonRow); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_proto_comparison_message_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_proto_comparison_message_proto_goTypes,
+		DependencyIndexes: file_proto_comparison_message_proto_depIdxs,
+		MessageInfos:      file_proto_comparison_message_proto_msgTypes,
+	}.Build()
+	File_proto_comparison_message_proto = out.File
+	file_proto_comparison_message_proto_rawDesc = nil
+	file_proto_comparison_message_proto_goTypes = nil
+	file_proto_comparison_message_proto_depIdxs = nil
+}
@@ -26,6 +26,15 @@ type LaptopServiceClient interface {
 	SearchLaptop(ctx context.Context, in *SearchLaptopRequest, opts ...grpc.CallOption) (LaptopService_SearchLaptopClient, error)
 	UploadImage(ctx context.Context, opts ...grpc.CallOption) (LaptopService_UploadImageClient, error)
 	RateLaptop(ctx context.Context, opts ...grpc.CallOption) (LaptopService_RateLaptopClient, error)
+	UpdateLaptop(ctx context.Context, in *UpdateLaptopRequest, opts ...grpc.CallOption) (*UpdateLaptopResponse, error)
+	DeleteLaptop(ctx context.Context, in *DeleteLaptopRequest, opts ...grpc.CallOption) (*DeleteLaptopResponse, error)
+	WatchLaptops(ctx context.Context, in *WatchLaptopsRequest, opts ...grpc.CallOption) (LaptopService_WatchLaptopsClient, error)
+	ImportLaptops(ctx context.Context, opts ...grpc.CallOption) (LaptopService_ImportLaptopsClient, error)
+	ExportLaptops(ctx context.Context, in *ExportLaptopsRequest, opts ...grpc.CallOption) (LaptopService_ExportLaptopsClient, error)
+	CompareLaptops(ctx context.Context, in *CompareLaptopsRequest, opts ...grpc.CallOption) (*CompareLaptopsResponse, error)
+	RecommendSimilar(ctx context.Context, in *RecommendSimilarRequest, opts ...grpc.CallOption) (*RecommendSimilarResponse, error)
+	// Recommends laptops to the caller from the ratings of users with similar taste.
+	RecommendForUser(ctx context.Context, in *RecommendForUserRequest, opts ...grpc.CallOption) (*RecommendForUserResponse, error)
 }
 
 type laptopServiceClient struct {
@@ -142,6 +151,149 @@ func (x *laptopServiceRateLaptopClient) Recv() (*RateLaptopResponse, error) {
 	return m, nil
 }
 
+func (c *laptopServiceClient) UpdateLaptop(ctx context.Context, in *UpdateLaptopRequest, opts ...grpc.CallOption) (*UpdateLaptopResponse, error) {
+	out := new(UpdateLaptopResponse)
+	err := c.cc.Invoke(ctx, "/pcbook.LaptopService/UpdateLaptop", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) DeleteLaptop(ctx context.Context, in *DeleteLaptopRequest, opts ...grpc.CallOption) (*DeleteLaptopResponse, error) {
+	out := new(DeleteLaptopResponse)
+	err := c.cc.Invoke(ctx, "/pcbook.LaptopService/DeleteLaptop", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) WatchLaptops(ctx context.Context, in *WatchLaptopsRequest, opts ...grpc.CallOption) (LaptopService_WatchLaptopsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &LaptopService_ServiceDesc.Streams[3], "/pcbook.LaptopService/WatchLaptops", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &laptopServiceWatchLaptopsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type LaptopService_WatchLaptopsClient interface {
+	Recv() (*WatchLaptopsResponse, error)
+	grpc.ClientStream
+}
+
+type laptopServiceWatchLaptopsClient struct {
+	grpc.ClientStream
+}
+
+func (x *laptopServiceWatchLaptopsClient) Recv() (*WatchLaptopsResponse, error) {
+	m := new(WatchLaptopsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *laptopServiceClient) ImportLaptops(ctx context.Context, opts ...grpc.CallOption) (LaptopService_ImportLaptopsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &LaptopService_ServiceDesc.Streams[4], "/pcbook.LaptopService/ImportLaptops", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &laptopServiceImportLaptopsClient{stream}
+	return x, nil
+}
+
+type LaptopService_ImportLaptopsClient interface {
+	Send(*ImportLaptopsRequest) error
+	CloseAndRecv() (*ImportLaptopsResponse, error)
+	grpc.ClientStream
+}
+
+type laptopServiceImportLaptopsClient struct {
+	grpc.ClientStream
+}
+
+func (x *laptopServiceImportLaptopsClient) Send(m *ImportLaptopsRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *laptopServiceImportLaptopsClient) CloseAndRecv() (*ImportLaptopsResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(ImportLaptopsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *laptopServiceClient) ExportLaptops(ctx context.Context, in *ExportLaptopsRequest, opts ...grpc.CallOption) (LaptopService_ExportLaptopsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &LaptopService_ServiceDesc.Streams[5], "/pcbook.LaptopService/ExportLaptops", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &laptopServiceExportLaptopsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type LaptopService_ExportLaptopsClient interface {
+	Recv() (*ExportLaptopsResponse, error)
+	grpc.ClientStream
+}
+
+type laptopServiceExportLaptopsClient struct {
+	grpc.ClientStream
+}
+
+func (x *laptopServiceExportLaptopsClient) Recv() (*ExportLaptopsResponse, error) {
+	m := new(ExportLaptopsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *laptopServiceClient) CompareLaptops(ctx context.Context, in *CompareLaptopsRequest, opts ...grpc.CallOption) (*CompareLaptopsResponse, error) {
+	out := new(CompareLaptopsResponse)
+	err := c.cc.Invoke(ctx, "/pcbook.LaptopService/CompareLaptops", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) RecommendSimilar(ctx context.Context, in *RecommendSimilarRequest, opts ...grpc.CallOption) (*RecommendSimilarResponse, error) {
+	out := new(RecommendSimilarResponse)
+	err := c.cc.Invoke(ctx, "/pcbook.LaptopService/RecommendSimilar", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *laptopServiceClient) RecommendForUser(ctx context.Context, in *RecommendForUserRequest, opts ...grpc.CallOption) (*RecommendForUserResponse, error) {
+	out := new(RecommendForUserResponse)
+	err := c.cc.Invoke(ctx, "/pcbook.LaptopService/RecommendForUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // LaptopServiceServer is the server API for LaptopService service.
 // All implementations should embed UnimplementedLaptopServiceServer
 // for forward compatibility
@@ -150,6 +302,15 @@ type LaptopServiceServer interface {
 	SearchLaptop(*SearchLaptopRequest, LaptopService_SearchLaptopServer) error
 	UploadImage(LaptopService_UploadImageServer) error
 	RateLaptop(LaptopService_RateLaptopServer) error
+	UpdateLaptop(context.Context, *UpdateLaptopRequest) (*UpdateLaptopResponse, error)
+	DeleteLaptop(context.Context, *DeleteLaptopRequest) (*DeleteLaptopResponse, error)
+	WatchLaptops(*WatchLaptopsRequest, LaptopService_WatchLaptopsServer) error
+	ImportLaptops(LaptopService_ImportLaptopsServer) error
+	ExportLaptops(*ExportLaptopsRequest, LaptopService_ExportLaptopsServer) error
+	CompareLaptops(context.Context, *CompareLaptopsRequest) (*CompareLaptopsResponse, error)
+	RecommendSimilar(context.Context, *RecommendSimilarRequest) (*RecommendSimilarResponse, error)
+	// Recommends laptops to the caller from the ratings of users with similar taste.
+	RecommendForUser(context.Context, *RecommendForUserRequest) (*RecommendForUserResponse, error)
 }
 
 // UnimplementedLaptopServiceServer should be embedded to have forward compatible implementations.
@@ -168,6 +329,30 @@ func (UnimplementedLaptopServiceServer) UploadImage(LaptopService_UploadImageSer
 func (UnimplementedLaptopServiceServer) RateLaptop(LaptopService_RateLaptopServer) error {
 	return status.Errorf(codes.Unimplemented, "method RateLaptop not implemented")
 }
+func (UnimplementedLaptopServiceServer) UpdateLaptop(context.Context, *UpdateLaptopRequest) (*UpdateLaptopResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateLaptop not implemented")
+}
+func (UnimplementedLaptopServiceServer) DeleteLaptop(context.Context, *DeleteLaptopRequest) (*DeleteLaptopResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteLaptop not implemented")
+}
+func (UnimplementedLaptopServiceServer) WatchLaptops(*WatchLaptopsRequest, LaptopService_WatchLaptopsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchLaptops not implemented")
+}
+func (UnimplementedLaptopServiceServer) ImportLaptops(LaptopService_ImportLaptopsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ImportLaptops not implemented")
+}
+func (UnimplementedLaptopServiceServer) ExportLaptops(*ExportLaptopsRequest, LaptopService_ExportLaptopsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExportLaptops not implemented")
+}
+func (UnimplementedLaptopServiceServer) CompareLaptops(context.Context, *CompareLaptopsRequest) (*CompareLaptopsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CompareLaptops not implemented")
+}
+func (UnimplementedLaptopServiceServer) RecommendSimilar(context.Context, *RecommendSimilarRequest) (*RecommendSimilarResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RecommendSimilar not implemented")
+}
+func (UnimplementedLaptopServiceServer) RecommendForUser(context.Context, *RecommendForUserRequest) (*RecommendForUserResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RecommendForUser not implemented")
+}
 
 // UnsafeLaptopServiceServer may be embedded to opt out of forward compatibility for this service.
 // Use of this interface is not recommended, as added methods to LaptopServiceServer will
@@ -271,6 +456,164 @@ func (x *laptopServiceRateLaptopServer) Recv() (*RateLaptopRequest, error) {
 	return m, nil
 }
 
+func _LaptopService_UpdateLaptop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateLaptopRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).UpdateLaptop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pcbook.LaptopService/UpdateLaptop",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).UpdateLaptop(ctx, req.(*UpdateLaptopRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_DeleteLaptop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteLaptopRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).DeleteLaptop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pcbook.LaptopService/DeleteLaptop",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).DeleteLaptop(ctx, req.(*DeleteLaptopRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_WatchLaptops_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchLaptopsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LaptopServiceServer).WatchLaptops(m, &laptopServiceWatchLaptopsServer{stream})
+}
+
+type LaptopService_WatchLaptopsServer interface {
+	Send(*WatchLaptopsResponse) error
+	grpc.ServerStream
+}
+
+type laptopServiceWatchLaptopsServer struct {
+	grpc.ServerStream
+}
+
+func (x *laptopServiceWatchLaptopsServer) Send(m *WatchLaptopsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _LaptopService_ImportLaptops_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(LaptopServiceServer).ImportLaptops(&laptopServiceImportLaptopsServer{stream})
+}
+
+type LaptopService_ImportLaptopsServer interface {
+	SendAndClose(*ImportLaptopsResponse) error
+	Recv() (*ImportLaptopsRequest, error)
+	grpc.ServerStream
+}
+
+type laptopServiceImportLaptopsServer struct {
+	grpc.ServerStream
+}
+
+func (x *laptopServiceImportLaptopsServer) SendAndClose(m *ImportLaptopsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *laptopServiceImportLaptopsServer) Recv() (*ImportLaptopsRequest, error) {
+	m := new(ImportLaptopsRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _LaptopService_ExportLaptops_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportLaptopsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LaptopServiceServer).ExportLaptops(m, &laptopServiceExportLaptopsServer{stream})
+}
+
+type LaptopService_ExportLaptopsServer interface {
+	Send(*ExportLaptopsResponse) error
+	grpc.ServerStream
+}
+
+type laptopServiceExportLaptopsServer struct {
+	grpc.ServerStream
+}
+
+func (x *laptopServiceExportLaptopsServer) Send(m *ExportLaptopsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _LaptopService_CompareLaptops_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompareLaptopsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).CompareLaptops(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pcbook.LaptopService/CompareLaptops",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).CompareLaptops(ctx, req.(*CompareLaptopsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_RecommendSimilar_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RecommendSimilarRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).RecommendSimilar(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pcbook.LaptopService/RecommendSimilar",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).RecommendSimilar(ctx, req.(*RecommendSimilarRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LaptopService_RecommendForUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RecommendForUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LaptopServiceServer).RecommendForUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pcbook.LaptopService/RecommendForUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LaptopServiceServer).RecommendForUser(ctx, req.(*RecommendForUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // LaptopService_ServiceDesc is the grpc.ServiceDesc for LaptopService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -282,6 +625,26 @@ var LaptopService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "CreateLaptop",
 			Handler:    _LaptopService_CreateLaptop_Handler,
 		},
+		{
+			MethodName: "UpdateLaptop",
+			Handler:    _LaptopService_UpdateLaptop_Handler,
+		},
+		{
+			MethodName: "DeleteLaptop",
+			Handler:    _LaptopService_DeleteLaptop_Handler,
+		},
+		{
+			MethodName: "CompareLaptops",
+			Handler:    _LaptopService_CompareLaptops_Handler,
+		},
+		{
+			MethodName: "RecommendSimilar",
+			Handler:    _LaptopService_RecommendSimilar_Handler,
+		},
+		{
+			MethodName: "RecommendForUser",
+			Handler:    _LaptopService_RecommendForUser_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -300,6 +663,21 @@ var LaptopService_ServiceDesc = grpc.ServiceDesc{
 			ServerStreams: true,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "WatchLaptops",
+			Handler:       _LaptopService_WatchLaptops_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ImportLaptops",
+			Handler:       _LaptopService_ImportLaptops_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "ExportLaptops",
+			Handler:       _LaptopService_ExportLaptops_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "proto/laptop_service.proto",
 }
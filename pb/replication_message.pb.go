@@ -0,0 +1,735 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.28.0
+// 	protoc        v3.6.1
+// source: proto/replication_message.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Ratings of a laptop, as kept by a rating store.
+type LaptopRatingState struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	LaptopId   string             `protobuf:"bytes,1,opt,name=laptop_id,json=laptopId,proto3" json:"laptop_id,omitempty"`
+	Count      uint32             `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	ScoreSum   float64            `protobuf:"fixed64,3,opt,name=score_sum,json=scoreSum,proto3" json:"score_sum,omitempty"`
+	UserScores map[string]float64 `protobuf:"bytes,4,rep,name=user_scores,json=userScores,proto3" json:"user_scores,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"fixed64,2,opt,name=value,proto3"` // latest score of each user.
+}
+
+func (x *LaptopRatingState) Reset() {
+	*x = LaptopRatingState{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_replication_message_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LaptopRatingState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LaptopRatingState) ProtoMessage() {}
+
+func (x *LaptopRatingState) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_replication_message_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LaptopRatingState.ProtoReflect.Descriptor instead.
+func (*LaptopRatingState) Descriptor() ([]byte, []int) {
+	return file_proto_replication_message_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *LaptopRatingState) GetLaptopId() string {
+	if x != nil {
+		return x.LaptopId
+	}
+	return ""
+}
+
+func (x *LaptopRatingState) GetCount() uint32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *LaptopRatingState) GetScoreSum() float64 {
+	if x != nil {
+		return x.ScoreSum
+	}
+	return 0
+}
+
+func (x *LaptopRatingState) GetUserScores() map[string]float64 {
+	if x != nil {
+		return x.UserScores
+	}
+	return nil
+}
+
+type LaptopRated struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	UserId   string  `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	LaptopId string  `protobuf:"bytes,2,opt,name=laptop_id,json=laptopId,proto3" json:"laptop_id,omitempty"`
+	Score    float64 `protobuf:"fixed64,3,opt,name=score,proto3" json:"score,omitempty"`
+}
+
+func (x *LaptopRated) Reset() {
+	*x = LaptopRated{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_replication_message_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LaptopRated) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LaptopRated) ProtoMessage() {}
+
+func (x *LaptopRated) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_replication_message_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LaptopRated.ProtoReflect.Descriptor instead.
+func (*LaptopRated) Descriptor() ([]byte, []int) {
+	return file_proto_replication_message_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *LaptopRated) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *LaptopRated) GetLaptopId() string {
+	if x != nil {
+		return x.LaptopId
+	}
+	return ""
+}
+
+func (x *LaptopRated) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+// Every rating of the store replaced at once.
+type RatingsRestored struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Ratings []*LaptopRatingState `protobuf:"bytes,1,rep,name=ratings,proto3" json:"ratings,omitempty"`
+}
+
+func (x *RatingsRestored) Reset() {
+	*x = RatingsRestored{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_replication_message_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RatingsRestored) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RatingsRestored) ProtoMessage() {}
+
+func (x *RatingsRestored) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_replication_message_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RatingsRestored.ProtoReflect.Descriptor instead.
+func (*RatingsRestored) Descriptor() ([]byte, []int) {
+	return file_proto_replication_message_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *RatingsRestored) GetRatings() []*LaptopRatingState {
+	if x != nil {
+		return x.Ratings
+	}
+	return nil
+}
+
+// A write to the leader stores, applied in sequence order by followers.
+type ReplicationChange struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Sequence uint64 `protobuf:"varint,1,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	// Types that are assignable to Change:
+	//	*ReplicationChange_SavedLaptop
+	//	*ReplicationChange_DeletedLaptopId
+	//	*ReplicationChange_LaptopRated
+	//	*ReplicationChange_RatingsRestored
+	Change isReplicationChange_Change `protobuf_oneof:"change"`
+}
+
+func (x *ReplicationChange) Reset() {
+	*x = ReplicationChange{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_replication_message_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ReplicationChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReplicationChange) ProtoMessage() {}
+
+func (x *ReplicationChange) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_replication_message_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReplicationChange.ProtoReflect.Descriptor instead.
+func (*ReplicationChange) Descriptor() ([]byte, []int) {
+	return file_proto_replication_message_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ReplicationChange) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (m *ReplicationChange) GetChange() isReplicationChange_Change {
+	if m != nil {
+		return m.Change
+	}
+	return nil
+}
+
+func (x *ReplicationChange) GetSavedLaptop() *Laptop {
+	if x, ok := x.GetChange().(*ReplicationChange_SavedLaptop); ok {
+		return x.SavedLaptop
+	}
+	return nil
+}
+
+func (x *ReplicationChange) GetDeletedLaptopId() string {
+	if x, ok := x.GetChange().(*ReplicationChange_DeletedLaptopId); ok {
+		return x.DeletedLaptopId
+	}
+	return ""
+}
+
+func (x *ReplicationChange) GetLaptopRated() *LaptopRated {
+	if x, ok := x.GetChange().(*ReplicationChange_LaptopRated); ok {
+		return x.LaptopRated
+	}
+	return nil
+}
+
+func (x *ReplicationChange) GetRatingsRestored() *RatingsRestored {
+	if x, ok := x.GetChange().(*ReplicationChange_RatingsRestored); ok {
+		return x.RatingsRestored
+	}
+	return nil
+}
+
+type isReplicationChange_Change interface {
+	isReplicationChange_Change()
+}
+
+type ReplicationChange_SavedLaptop struct {
+	SavedLaptop *Laptop `protobuf:"bytes,2,opt,name=saved_laptop,json=savedLaptop,proto3,oneof"` // created or updated.
+}
+
+type ReplicationChange_DeletedLaptopId struct {
+	DeletedLaptopId string `protobuf:"bytes,3,opt,name=deleted_laptop_id,json=deletedLaptopId,proto3,oneof"`
+}
+
+type ReplicationChange_LaptopRated struct {
+	LaptopRated *LaptopRated `protobuf:"bytes,4,opt,name=laptop_rated,json=laptopRated,proto3,oneof"`
+}
+
+type ReplicationChange_RatingsRestored struct {
+	RatingsRestored *RatingsRestored `protobuf:"bytes,5,opt,name=ratings_restored,json=ratingsRestored,proto3,oneof"`
+}
+
+func (*ReplicationChange_SavedLaptop) isReplicationChange_Change() {}
+
+func (*ReplicationChange_DeletedLaptopId) isReplicationChange_Change() {}
+
+func (*ReplicationChange_LaptopRated) isReplicationChange_Change() {}
+
+func (*ReplicationChange_RatingsRestored) isReplicationChange_Change() {}
+
+// Write committed through the Raft log of a cluster.
+type ClusterCommand struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Command:
+	//	*ClusterCommand_SaveLaptop
+	//	*ClusterCommand_UpdateLaptop
+	//	*ClusterCommand_DeleteLaptopId
+	//	*ClusterCommand_RateLaptop
+	//	*ClusterCommand_RestoreRatings
+	Command isClusterCommand_Command `protobuf_oneof:"command"`
+}
+
+func (x *ClusterCommand) Reset() {
+	*x = ClusterCommand{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_replication_message_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClusterCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClusterCommand) ProtoMessage() {}
+
+func (x *ClusterCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_replication_message_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClusterCommand.ProtoReflect.Descriptor instead.
+func (*ClusterCommand) Descriptor() ([]byte, []int) {
+	return file_proto_replication_message_proto_rawDescGZIP(), []int{4}
+}
+
+func (m *ClusterCommand) GetCommand() isClusterCommand_Command {
+	if m != nil {
+		return m.Command
+	}
+	return nil
+}
+
+func (x *ClusterCommand) GetSaveLaptop() *Laptop {
+	if x, ok := x.GetCommand().(*ClusterCommand_SaveLaptop); ok {
+		return x.SaveLaptop
+	}
+	return nil
+}
+
+func (x *ClusterCommand) GetUpdateLaptop() *Laptop {
+	if x, ok := x.GetCommand().(*ClusterCommand_UpdateLaptop); ok {
+		return x.UpdateLaptop
+	}
+	return nil
+}
+
+func (x *ClusterCommand) GetDeleteLaptopId() string {
+	if x, ok := x.GetCommand().(*ClusterCommand_DeleteLaptopId); ok {
+		return x.DeleteLaptopId
+	}
+	return ""
+}
+
+func (x *ClusterCommand) GetRateLaptop() *LaptopRated {
+	if x, ok := x.GetCommand().(*ClusterCommand_RateLaptop); ok {
+		return x.RateLaptop
+	}
+	return nil
+}
+
+func (x *ClusterCommand) GetRestoreRatings() *RatingsRestored {
+	if x, ok := x.GetCommand().(*ClusterCommand_RestoreRatings); ok {
+		return x.RestoreRatings
+	}
+	return nil
+}
+
+type isClusterCommand_Command interface {
+	isClusterCommand_Command()
+}
+
+type ClusterCommand_SaveLaptop struct {
+	SaveLaptop *Laptop `protobuf:"bytes,1,opt,name=save_laptop,json=saveLaptop,proto3,oneof"`
+}
+
+type ClusterCommand_UpdateLaptop struct {
+	UpdateLaptop *Laptop `protobuf:"bytes,2,opt,name=update_laptop,json=updateLaptop,proto3,oneof"`
+}
+
+type ClusterCommand_DeleteLaptopId struct {
+	DeleteLaptopId string `protobuf:"bytes,3,opt,name=delete_laptop_id,json=deleteLaptopId,proto3,oneof"`
+}
+
+type ClusterCommand_RateLaptop struct {
+	RateLaptop *LaptopRated `protobuf:"bytes,4,opt,name=rate_laptop,json=rateLaptop,proto3,oneof"`
+}
+
+type ClusterCommand_RestoreRatings struct {
+	RestoreRatings *RatingsRestored `protobuf:"bytes,5,opt,name=restore_ratings,json=restoreRatings,proto3,oneof"`
+}
+
+func (*ClusterCommand_SaveLaptop) isClusterCommand_Command() {}
+
+func (*ClusterCommand_UpdateLaptop) isClusterCommand_Command() {}
+
+func (*ClusterCommand_DeleteLaptopId) isClusterCommand_Command() {}
+
+func (*ClusterCommand_RateLaptop) isClusterCommand_Command() {}
+
+func (*ClusterCommand_RestoreRatings) isClusterCommand_Command() {}
+
+// Stores of a cluster node, to compact its log.
+type ClusterSnapshot struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Laptops []*Laptop            `protobuf:"bytes,1,rep,name=laptops,proto3" json:"laptops,omitempty"`
+	Ratings []*LaptopRatingState `protobuf:"bytes,2,rep,name=ratings,proto3" json:"ratings,omitempty"`
+}
+
+func (x *ClusterSnapshot) Reset() {
+	*x = ClusterSnapshot{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_replication_message_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClusterSnapshot) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClusterSnapshot) ProtoMessage() {}
+
+func (x *ClusterSnapshot) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_replication_message_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClusterSnapshot.ProtoReflect.Descriptor instead.
+func (*ClusterSnapshot) Descriptor() ([]byte, []int) {
+	return file_proto_replication_message_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ClusterSnapshot) GetLaptops() []*Laptop {
+	if x != nil {
+		return x.Laptops
+	}
+	return nil
+}
+
+func (x *ClusterSnapshot) GetRatings() []*LaptopRatingState {
+	if x != nil {
+		return x.Ratings
+	}
+	return nil
+}
+
+var File_proto_replication_message_proto protoreflect.FileDescriptor
+
+var file_proto_replication_message_proto_rawDesc = []byte{
+	0x0a, 0x1f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x72, 0x65, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x12, 0x06, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x1a, 0x1a, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x2f, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xee, 0x01, 0x0a, 0x11, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70,
+	0x52, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x6c,
+	0x61, 0x70, 0x74, 0x6f, 0x70, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08,
+	0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x6f, 0x75, 0x6e,
+	0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x1b,
+	0x0a, 0x09, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x5f, 0x73, 0x75, 0x6d, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x01, 0x52, 0x08, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x53, 0x75, 0x6d, 0x12, 0x4a, 0x0a, 0x0b, 0x75,
+	0x73, 0x65, 0x72, 0x5f, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x29, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70,
+	0x52, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x53, 0x74, 0x61, 0x74, 0x65, 0x2e, 0x55, 0x73, 0x65, 0x72,
+	0x53, 0x63, 0x6f, 0x72, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x0a, 0x75, 0x73, 0x65,
+	0x72, 0x53, 0x63, 0x6f, 0x72, 0x65, 0x73, 0x1a, 0x3d, 0x0a, 0x0f, 0x55, 0x73, 0x65, 0x72, 0x53,
+	0x63, 0x6f, 0x72, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65,
+	0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x52, 0x05, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x59, 0x0a, 0x0b, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70,
+	0x52, 0x61, 0x74, 0x65, 0x64, 0x12, 0x17, 0x0a, 0x07, 0x75, 0x73, 0x65, 0x72, 0x5f, 0x69, 0x64,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x75, 0x73, 0x65, 0x72, 0x49, 0x64, 0x12, 0x1b,
+	0x0a, 0x09, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x08, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x73,
+	0x63, 0x6f, 0x72, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01, 0x52, 0x05, 0x73, 0x63, 0x6f, 0x72,
+	0x65, 0x22, 0x46, 0x0a, 0x0f, 0x52, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x73, 0x52, 0x65, 0x73, 0x74,
+	0x6f, 0x72, 0x65, 0x64, 0x12, 0x33, 0x0a, 0x07, 0x72, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x73, 0x18,
+	0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e, 0x4c,
+	0x61, 0x70, 0x74, 0x6f, 0x70, 0x52, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x53, 0x74, 0x61, 0x74, 0x65,
+	0x52, 0x07, 0x72, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x73, 0x22, 0x9c, 0x02, 0x0a, 0x11, 0x52, 0x65,
+	0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x12,
+	0x1a, 0x0a, 0x08, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x08, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x12, 0x33, 0x0a, 0x0c, 0x73,
+	0x61, 0x76, 0x65, 0x64, 0x5f, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x0e, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e, 0x4c, 0x61, 0x70, 0x74, 0x6f,
+	0x70, 0x48, 0x00, 0x52, 0x0b, 0x73, 0x61, 0x76, 0x65, 0x64, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70,
+	0x12, 0x2c, 0x0a, 0x11, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x64, 0x5f, 0x6c, 0x61, 0x70, 0x74,
+	0x6f, 0x70, 0x5f, 0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x48, 0x00, 0x52, 0x0f, 0x64,
+	0x65, 0x6c, 0x65, 0x74, 0x65, 0x64, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x49, 0x64, 0x12, 0x38,
+	0x0a, 0x0c, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x5f, 0x72, 0x61, 0x74, 0x65, 0x64, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e, 0x4c, 0x61,
+	0x70, 0x74, 0x6f, 0x70, 0x52, 0x61, 0x74, 0x65, 0x64, 0x48, 0x00, 0x52, 0x0b, 0x6c, 0x61, 0x70,
+	0x74, 0x6f, 0x70, 0x52, 0x61, 0x74, 0x65, 0x64, 0x12, 0x44, 0x0a, 0x10, 0x72, 0x61, 0x74, 0x69,
+	0x6e, 0x67, 0x73, 0x5f, 0x72, 0x65, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x64, 0x18, 0x05, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x17, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e, 0x52, 0x61, 0x74, 0x69,
+	0x6e, 0x67, 0x73, 0x52, 0x65, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x64, 0x48, 0x00, 0x52, 0x0f, 0x72,
+	0x61, 0x74, 0x69, 0x6e, 0x67, 0x73, 0x52, 0x65, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x64, 0x42, 0x08,
+	0x0a, 0x06, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x22, 0xad, 0x02, 0x0a, 0x0e, 0x43, 0x6c, 0x75,
+	0x73, 0x74, 0x65, 0x72, 0x43, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x12, 0x31, 0x0a, 0x0b, 0x73,
+	0x61, 0x76, 0x65, 0x5f, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x0e, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70,
+	0x48, 0x00, 0x52, 0x0a, 0x73, 0x61, 0x76, 0x65, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x12, 0x35,
+	0x0a, 0x0d, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x5f, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e, 0x4c,
+	0x61, 0x70, 0x74, 0x6f, 0x70, 0x48, 0x00, 0x52, 0x0c, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x4c,
+	0x61, 0x70, 0x74, 0x6f, 0x70, 0x12, 0x2a, 0x0a, 0x10, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x5f,
+	0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x5f, 0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x48,
+	0x00, 0x52, 0x0e, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x49,
+	0x64, 0x12, 0x36, 0x0a, 0x0b, 0x72, 0x61, 0x74, 0x65, 0x5f, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e,
+	0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x52, 0x61, 0x74, 0x65, 0x64, 0x48, 0x00, 0x52, 0x0a, 0x72,
+	0x61, 0x74, 0x65, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x12, 0x42, 0x0a, 0x0f, 0x72, 0x65, 0x73,
+	0x74, 0x6f, 0x72, 0x65, 0x5f, 0x72, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x73, 0x18, 0x05, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x17, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e, 0x52, 0x61, 0x74, 0x69,
+	0x6e, 0x67, 0x73, 0x52, 0x65, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x64, 0x48, 0x00, 0x52, 0x0e, 0x72,
+	0x65, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x52, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x73, 0x42, 0x09, 0x0a,
+	0x07, 0x63, 0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x22, 0x70, 0x0a, 0x0f, 0x43, 0x6c, 0x75, 0x73,
+	0x74, 0x65, 0x72, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x12, 0x28, 0x0a, 0x07, 0x6c,
+	0x61, 0x70, 0x74, 0x6f, 0x70, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x70,
+	0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x52, 0x07, 0x6c, 0x61,
+	0x70, 0x74, 0x6f, 0x70, 0x73, 0x12, 0x33, 0x0a, 0x07, 0x72, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x73,
+	0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e,
+	0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x52, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x53, 0x74, 0x61, 0x74,
+	0x65, 0x52, 0x07, 0x72, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x73, 0x42, 0x06, 0x5a, 0x04, 0x2e, 0x2f,
+	0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_proto_replication_message_proto_rawDescOnce sync.Once
+	file_proto_replication_message_proto_rawDescData = file_proto_replication_message_proto_rawDesc
+)
+
+func file_proto_replication_message_proto_rawDescGZIP() []byte {
+	file_proto_replication_message_proto_rawDescOnce.Do(func() {
+		file_proto_replication_message_proto_rawDescData = protoimpl.X.CompressGZIP(file_proto_replication_message_proto_rawDescData)
+	})
+	return file_proto_replication_message_proto_rawDescData
+}
+
+var file_proto_replication_message_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_proto_replication_message_proto_goTypes = []interface{}{
+	(*LaptopRatingState)(nil), // 0: pcbook.LaptopRatingState
+	(*LaptopRated)(nil),       // 1: pcbook.LaptopRated
+	(*RatingsRestored)(nil),   // 2: pcbook.RatingsRestored
+	(*ReplicationChange)(nil), // 3: pcbook.ReplicationChange
+	(*ClusterCommand)(nil),    // 4: pcbook.ClusterCommand
+	(*ClusterSnapshot)(nil),   // 5: pcbook.ClusterSnapshot
+	nil,                       // 6: pcbook.LaptopRatingState.UserScoresEntry
+	(*Laptop)(nil),            // 7: pcbook.Laptop
+}
+var file_proto_replication_message_proto_depIdxs = []int32{
+	6,  // 0: pcbook.LaptopRatingState.user_scores:type_name -> pcbook.LaptopRatingState.UserScoresEntry
+	0,  // 1: pcbook.RatingsRestored.ratings:type_name -> pcbook.LaptopRatingState
+	7,  // 2: pcbook.ReplicationChange.saved_laptop:type_name -> pcbook.Laptop
+	1,  // 3: pcbook.ReplicationChange.laptop_rated:type_name -> pcbook.LaptopRated
+	2,  // 4: pcbook.ReplicationChange.ratings_restored:type_name -> pcbook.RatingsRestored
+	7,  // 5: pcbook.ClusterCommand.save_laptop:type_name -> pcbook.Laptop
+	7,  // 6: pcbook.ClusterCommand.update_laptop:type_name -> pcbook.Laptop
+	1,  // 7: pcbook.ClusterCommand.rate_laptop:type_name -> pcbook.LaptopRated
+	2,  // 8: pcbook.ClusterCommand.restore_ratings:type_name -> pcbook.RatingsRestored
+	7,  // 9: pcbook.ClusterSnapshot.laptops:type_name -> pcbook.Laptop
+	0,  // 10: pcbook.ClusterSnapshot.ratings:type_name -> pcbook.LaptopRatingState
+	11, // [11:11] is the sub-list for method output_type
+	11, // [11:11] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
+}
+
+func init() { file_proto_replication_message_proto_init() }
+func file_proto_replication_message_proto_init() {
+	if File_proto_replication_message_proto != nil {
+		return
+	}
+	file_proto_laptop_message_proto_init()
+	if !protoimpl.UnsafeEnabled {
+		file_proto_replication_message_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LaptopRatingState); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_replication_message_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LaptopRated); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_replication_message_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RatingsRestored); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_replication_message_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ReplicationChange); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_replication_message_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClusterCommand); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_replication_message_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClusterSnapshot); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_proto_replication_message_proto_msgTypes[3].OneofWrappers = []interface{}{
+		(*ReplicationChange_SavedLaptop)(nil),
+		(*ReplicationChange_DeletedLaptopId)(nil),
+		(*ReplicationChange_LaptopRated)(nil),
+		(*ReplicationChange_RatingsRestored)(nil),
+	}
+	file_proto_replication_message_proto_msgTypes[4].OneofWrappers = []interface{}{
+		(*ClusterCommand_SaveLaptop)(nil),
+		(*ClusterCommand_UpdateLaptop)(nil),
+		(*ClusterCommand_DeleteLaptopId)(nil),
+		(*ClusterCommand_RateLaptop)(nil),
+		(*ClusterCommand_RestoreRatings)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_proto_replication_message_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   7,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_proto_replication_message_proto_goTypes,
+		DependencyIndexes: file_proto_replication_message_proto_depIdxs,
+		MessageInfos:      file_proto_replication_message_proto_msgTypes,
+	}.Build()
+	File_proto_replication_message_proto = out.File
+	file_proto_replication_message_proto_rawDesc = nil
+	file_proto_replication_message_proto_goTypes = nil
+	file_proto_replication_message_proto_depIdxs = nil
+}
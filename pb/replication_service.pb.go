@@ -0,0 +1,330 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.28.0
+// 	protoc        v3.6.1
+// source: proto/replication_service.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Followers reconnecting resume after the last change they applied.
+type ReplicateRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	LogId        string `protobuf:"bytes,1,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"`                       // of the snapshot the follower started from, empty for a new follower.
+	FromSequence uint64 `protobuf:"varint,2,opt,name=from_sequence,json=fromSequence,proto3" json:"from_sequence,omitempty"` // of the last change the follower applied.
+}
+
+func (x *ReplicateRequest) Reset() {
+	*x = ReplicateRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_replication_service_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ReplicateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReplicateRequest) ProtoMessage() {}
+
+func (x *ReplicateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_replication_service_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReplicateRequest.ProtoReflect.Descriptor instead.
+func (*ReplicateRequest) Descriptor() ([]byte, []int) {
+	return file_proto_replication_service_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ReplicateRequest) GetLogId() string {
+	if x != nil {
+		return x.LogId
+	}
+	return ""
+}
+
+func (x *ReplicateRequest) GetFromSequence() uint64 {
+	if x != nil {
+		return x.FromSequence
+	}
+	return 0
+}
+
+// The stream starts with a snapshot of the leader stores, ended by snapshot_sequence,
+// followed by every change made after that sequence. A follower resuming from a
+// sequence of the leader's log, which the leader still has the later changes of,
+// gets these changes without a snapshot.
+type ReplicateResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Message:
+	//	*ReplicateResponse_SnapshotLaptop
+	//	*ReplicateResponse_SnapshotRating
+	//	*ReplicateResponse_SnapshotSequence
+	//	*ReplicateResponse_Change
+	Message isReplicateResponse_Message `protobuf_oneof:"message"`
+	LogId   string                      `protobuf:"bytes,5,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"` // set with snapshot_sequence, a new one each time the leader starts.
+}
+
+func (x *ReplicateResponse) Reset() {
+	*x = ReplicateResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_replication_service_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ReplicateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReplicateResponse) ProtoMessage() {}
+
+func (x *ReplicateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_replication_service_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReplicateResponse.ProtoReflect.Descriptor instead.
+func (*ReplicateResponse) Descriptor() ([]byte, []int) {
+	return file_proto_replication_service_proto_rawDescGZIP(), []int{1}
+}
+
+func (m *ReplicateResponse) GetMessage() isReplicateResponse_Message {
+	if m != nil {
+		return m.Message
+	}
+	return nil
+}
+
+func (x *ReplicateResponse) GetSnapshotLaptop() *Laptop {
+	if x, ok := x.GetMessage().(*ReplicateResponse_SnapshotLaptop); ok {
+		return x.SnapshotLaptop
+	}
+	return nil
+}
+
+func (x *ReplicateResponse) GetSnapshotRating() *LaptopRatingState {
+	if x, ok := x.GetMessage().(*ReplicateResponse_SnapshotRating); ok {
+		return x.SnapshotRating
+	}
+	return nil
+}
+
+func (x *ReplicateResponse) GetSnapshotSequence() uint64 {
+	if x, ok := x.GetMessage().(*ReplicateResponse_SnapshotSequence); ok {
+		return x.SnapshotSequence
+	}
+	return 0
+}
+
+func (x *ReplicateResponse) GetChange() *ReplicationChange {
+	if x, ok := x.GetMessage().(*ReplicateResponse_Change); ok {
+		return x.Change
+	}
+	return nil
+}
+
+func (x *ReplicateResponse) GetLogId() string {
+	if x != nil {
+		return x.LogId
+	}
+	return ""
+}
+
+type isReplicateResponse_Message interface {
+	isReplicateResponse_Message()
+}
+
+type ReplicateResponse_SnapshotLaptop struct {
+	SnapshotLaptop *Laptop `protobuf:"bytes,1,opt,name=snapshot_laptop,json=snapshotLaptop,proto3,oneof"`
+}
+
+type ReplicateResponse_SnapshotRating struct {
+	SnapshotRating *LaptopRatingState `protobuf:"bytes,2,opt,name=snapshot_rating,json=snapshotRating,proto3,oneof"`
+}
+
+type ReplicateResponse_SnapshotSequence struct {
+	SnapshotSequence uint64 `protobuf:"varint,3,opt,name=snapshot_sequence,json=snapshotSequence,proto3,oneof"`
+}
+
+type ReplicateResponse_Change struct {
+	Change *ReplicationChange `protobuf:"bytes,4,opt,name=change,proto3,oneof"`
+}
+
+func (*ReplicateResponse_SnapshotLaptop) isReplicateResponse_Message() {}
+
+func (*ReplicateResponse_SnapshotRating) isReplicateResponse_Message() {}
+
+func (*ReplicateResponse_SnapshotSequence) isReplicateResponse_Message() {}
+
+func (*ReplicateResponse_Change) isReplicateResponse_Message() {}
+
+var File_proto_replication_service_proto protoreflect.FileDescriptor
+
+var file_proto_replication_service_proto_rawDesc = []byte{
+	0x0a, 0x1f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x72, 0x65, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x5f, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x12, 0x06, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x1a, 0x1a, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x2f, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x1f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x72, 0x65, 0x70,
+	0x6c, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x4e, 0x0a, 0x10, 0x52, 0x65, 0x70, 0x6c, 0x69, 0x63,
+	0x61, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x15, 0x0a, 0x06, 0x6c, 0x6f,
+	0x67, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x6c, 0x6f, 0x67, 0x49,
+	0x64, 0x12, 0x23, 0x0a, 0x0d, 0x66, 0x72, 0x6f, 0x6d, 0x5f, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e,
+	0x63, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0c, 0x66, 0x72, 0x6f, 0x6d, 0x53, 0x65,
+	0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x22, 0x9a, 0x02, 0x0a, 0x11, 0x52, 0x65, 0x70, 0x6c, 0x69,
+	0x63, 0x61, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x39, 0x0a, 0x0f,
+	0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x5f, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e, 0x4c,
+	0x61, 0x70, 0x74, 0x6f, 0x70, 0x48, 0x00, 0x52, 0x0e, 0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f,
+	0x74, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x12, 0x44, 0x0a, 0x0f, 0x73, 0x6e, 0x61, 0x70, 0x73,
+	0x68, 0x6f, 0x74, 0x5f, 0x72, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x19, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70,
+	0x52, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x53, 0x74, 0x61, 0x74, 0x65, 0x48, 0x00, 0x52, 0x0e, 0x73,
+	0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x52, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x12, 0x2d, 0x0a,
+	0x11, 0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x5f, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e,
+	0x63, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x48, 0x00, 0x52, 0x10, 0x73, 0x6e, 0x61, 0x70,
+	0x73, 0x68, 0x6f, 0x74, 0x53, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x12, 0x33, 0x0a, 0x06,
+	0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x70,
+	0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e, 0x52, 0x65, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x48, 0x00, 0x52, 0x06, 0x63, 0x68, 0x61, 0x6e, 0x67,
+	0x65, 0x12, 0x15, 0x0a, 0x06, 0x6c, 0x6f, 0x67, 0x5f, 0x69, 0x64, 0x18, 0x05, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x05, 0x6c, 0x6f, 0x67, 0x49, 0x64, 0x42, 0x09, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73,
+	0x61, 0x67, 0x65, 0x32, 0x5a, 0x0a, 0x12, 0x52, 0x65, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x44, 0x0a, 0x09, 0x52, 0x65, 0x70,
+	0x6c, 0x69, 0x63, 0x61, 0x74, 0x65, 0x12, 0x18, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e,
+	0x52, 0x65, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x19, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e, 0x52, 0x65, 0x70, 0x6c, 0x69, 0x63,
+	0x61, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x30, 0x01, 0x42,
+	0x06, 0x5a, 0x04, 0x2e, 0x2f, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_proto_replication_service_proto_rawDescOnce sync.Once
+	file_proto_replication_service_proto_rawDescData = file_proto_replication_service_proto_rawDesc
+)
+
+func file_proto_replication_service_proto_rawDescGZIP() []byte {
+	file_proto_replication_service_proto_rawDescOnce.Do(func() {
+		file_proto_replication_service_proto_rawDescData = protoimpl.X.CompressGZIP(file_proto_replication_service_proto_rawDescData)
+	})
+	return file_proto_replication_service_proto_rawDescData
+}
+
+var file_proto_replication_service_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_proto_replication_service_proto_goTypes = []interface{}{
+	(*ReplicateRequest)(nil),  // 0: pcbook.ReplicateRequest
+	(*ReplicateResponse)(nil), // 1: pcbook.ReplicateResponse
+	(*Laptop)(nil),            // 2: pcbook.Laptop
+	(*LaptopRatingState)(nil), // 3: pcbook.LaptopRatingState
+	(*ReplicationChange)(nil), // 4: pcbook.ReplicationChange
+}
+var file_proto_replication_service_proto_depIdxs = []int32{
+	2, // 0: pcbook.ReplicateResponse.snapshot_laptop:type_name -> pcbook.Laptop
+	3, // 1: pcbook.ReplicateResponse.snapshot_rating:type_name -> pcbook.LaptopRatingState
+	4, // 2: pcbook.ReplicateResponse.change:type_name -> pcbook.ReplicationChange
+	0, // 3: pcbook.ReplicationService.Replicate:input_type -> pcbook.ReplicateRequest
+	1, // 4: pcbook.ReplicationService.Replicate:output_type -> pcbook.ReplicateResponse
+	4, // [4:5] is the sub-list for method output_type
+	3, // [3:4] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_proto_replication_service_proto_init() }
+func file_proto_replication_service_proto_init() {
+	if File_proto_replication_service_proto != nil {
+		return
+	}
+	file_proto_laptop_message_proto_init()
+	file_proto_replication_message_proto_init()
+	if !protoimpl.UnsafeEnabled {
+		file_proto_replication_service_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ReplicateRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_replication_service_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ReplicateResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_proto_replication_service_proto_msgTypes[1].OneofWrappers = []interface{}{
+		(*ReplicateResponse_SnapshotLaptop)(nil),
+		(*ReplicateResponse_SnapshotRating)(nil),
+		(*ReplicateResponse_SnapshotSequence)(nil),
+		(*ReplicateResponse_Change)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_proto_replication_service_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_proto_replication_service_proto_goTypes,
+		DependencyIndexes: file_proto_replication_service_proto_depIdxs,
+		MessageInfos:      file_proto_replication_service_proto_msgTypes,
+	}.Build()
+	File_proto_replication_service_proto = out.File
+	file_proto_replication_service_proto_rawDesc = nil
+	file_proto_replication_service_proto_goTypes = nil
+	file_proto_replication_service_proto_depIdxs = nil
+}
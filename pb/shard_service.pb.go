@@ -0,0 +1,846 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.28.0
+// 	protoc        v3.6.1
+// source: proto/shard_service.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ShardSaveRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Laptop *Laptop `protobuf:"bytes,1,opt,name=laptop,proto3" json:"laptop,omitempty"`
+}
+
+func (x *ShardSaveRequest) Reset() {
+	*x = ShardSaveRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_shard_service_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ShardSaveRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShardSaveRequest) ProtoMessage() {}
+
+func (x *ShardSaveRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_shard_service_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShardSaveRequest.ProtoReflect.Descriptor instead.
+func (*ShardSaveRequest) Descriptor() ([]byte, []int) {
+	return file_proto_shard_service_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ShardSaveRequest) GetLaptop() *Laptop {
+	if x != nil {
+		return x.Laptop
+	}
+	return nil
+}
+
+type ShardSaveResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *ShardSaveResponse) Reset() {
+	*x = ShardSaveResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_shard_service_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ShardSaveResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShardSaveResponse) ProtoMessage() {}
+
+func (x *ShardSaveResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_shard_service_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShardSaveResponse.ProtoReflect.Descriptor instead.
+func (*ShardSaveResponse) Descriptor() ([]byte, []int) {
+	return file_proto_shard_service_proto_rawDescGZIP(), []int{1}
+}
+
+type ShardFindRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+}
+
+func (x *ShardFindRequest) Reset() {
+	*x = ShardFindRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_shard_service_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ShardFindRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShardFindRequest) ProtoMessage() {}
+
+func (x *ShardFindRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_shard_service_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShardFindRequest.ProtoReflect.Descriptor instead.
+func (*ShardFindRequest) Descriptor() ([]byte, []int) {
+	return file_proto_shard_service_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ShardFindRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type ShardFindResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Laptop *Laptop `protobuf:"bytes,1,opt,name=laptop,proto3" json:"laptop,omitempty"`
+}
+
+func (x *ShardFindResponse) Reset() {
+	*x = ShardFindResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_shard_service_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ShardFindResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShardFindResponse) ProtoMessage() {}
+
+func (x *ShardFindResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_shard_service_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShardFindResponse.ProtoReflect.Descriptor instead.
+func (*ShardFindResponse) Descriptor() ([]byte, []int) {
+	return file_proto_shard_service_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ShardFindResponse) GetLaptop() *Laptop {
+	if x != nil {
+		return x.Laptop
+	}
+	return nil
+}
+
+type ShardUpdateRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Laptop *Laptop `protobuf:"bytes,1,opt,name=laptop,proto3" json:"laptop,omitempty"`
+}
+
+func (x *ShardUpdateRequest) Reset() {
+	*x = ShardUpdateRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_shard_service_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ShardUpdateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShardUpdateRequest) ProtoMessage() {}
+
+func (x *ShardUpdateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_shard_service_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShardUpdateRequest.ProtoReflect.Descriptor instead.
+func (*ShardUpdateRequest) Descriptor() ([]byte, []int) {
+	return file_proto_shard_service_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ShardUpdateRequest) GetLaptop() *Laptop {
+	if x != nil {
+		return x.Laptop
+	}
+	return nil
+}
+
+type ShardUpdateResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *ShardUpdateResponse) Reset() {
+	*x = ShardUpdateResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_shard_service_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ShardUpdateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShardUpdateResponse) ProtoMessage() {}
+
+func (x *ShardUpdateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_shard_service_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShardUpdateResponse.ProtoReflect.Descriptor instead.
+func (*ShardUpdateResponse) Descriptor() ([]byte, []int) {
+	return file_proto_shard_service_proto_rawDescGZIP(), []int{5}
+}
+
+type ShardDeleteRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+}
+
+func (x *ShardDeleteRequest) Reset() {
+	*x = ShardDeleteRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_shard_service_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ShardDeleteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShardDeleteRequest) ProtoMessage() {}
+
+func (x *ShardDeleteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_shard_service_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShardDeleteRequest.ProtoReflect.Descriptor instead.
+func (*ShardDeleteRequest) Descriptor() ([]byte, []int) {
+	return file_proto_shard_service_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ShardDeleteRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type ShardDeleteResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *ShardDeleteResponse) Reset() {
+	*x = ShardDeleteResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_shard_service_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ShardDeleteResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShardDeleteResponse) ProtoMessage() {}
+
+func (x *ShardDeleteResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_shard_service_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShardDeleteResponse.ProtoReflect.Descriptor instead.
+func (*ShardDeleteResponse) Descriptor() ([]byte, []int) {
+	return file_proto_shard_service_proto_rawDescGZIP(), []int{7}
+}
+
+type ShardSearchRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Filter *Filter `protobuf:"bytes,1,opt,name=filter,proto3" json:"filter,omitempty"`
+}
+
+func (x *ShardSearchRequest) Reset() {
+	*x = ShardSearchRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_shard_service_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ShardSearchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShardSearchRequest) ProtoMessage() {}
+
+func (x *ShardSearchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_shard_service_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShardSearchRequest.ProtoReflect.Descriptor instead.
+func (*ShardSearchRequest) Descriptor() ([]byte, []int) {
+	return file_proto_shard_service_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ShardSearchRequest) GetFilter() *Filter {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+type ShardSearchResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Laptop *Laptop `protobuf:"bytes,1,opt,name=laptop,proto3" json:"laptop,omitempty"`
+}
+
+func (x *ShardSearchResponse) Reset() {
+	*x = ShardSearchResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_shard_service_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ShardSearchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShardSearchResponse) ProtoMessage() {}
+
+func (x *ShardSearchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_shard_service_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShardSearchResponse.ProtoReflect.Descriptor instead.
+func (*ShardSearchResponse) Descriptor() ([]byte, []int) {
+	return file_proto_shard_service_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ShardSearchResponse) GetLaptop() *Laptop {
+	if x != nil {
+		return x.Laptop
+	}
+	return nil
+}
+
+type ShardCountRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *ShardCountRequest) Reset() {
+	*x = ShardCountRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_shard_service_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ShardCountRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShardCountRequest) ProtoMessage() {}
+
+func (x *ShardCountRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_shard_service_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShardCountRequest.ProtoReflect.Descriptor instead.
+func (*ShardCountRequest) Descriptor() ([]byte, []int) {
+	return file_proto_shard_service_proto_rawDescGZIP(), []int{10}
+}
+
+type ShardCountResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Count int64 `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+}
+
+func (x *ShardCountResponse) Reset() {
+	*x = ShardCountResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_shard_service_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ShardCountResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShardCountResponse) ProtoMessage() {}
+
+func (x *ShardCountResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_shard_service_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShardCountResponse.ProtoReflect.Descriptor instead.
+func (*ShardCountResponse) Descriptor() ([]byte, []int) {
+	return file_proto_shard_service_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ShardCountResponse) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+var File_proto_shard_service_proto protoreflect.FileDescriptor
+
+var file_proto_shard_service_proto_rawDesc = []byte{
+	0x0a, 0x19, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x73, 0x68, 0x61, 0x72, 0x64, 0x5f, 0x73, 0x65,
+	0x72, 0x76, 0x69, 0x63, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x06, 0x70, 0x63, 0x62,
+	0x6f, 0x6f, 0x6b, 0x1a, 0x1a, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x6c, 0x61, 0x70, 0x74, 0x6f,
+	0x70, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a,
+	0x1a, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x5f, 0x6d, 0x65,
+	0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x3a, 0x0a, 0x10, 0x53,
+	0x68, 0x61, 0x72, 0x64, 0x53, 0x61, 0x76, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
+	0x26, 0x0a, 0x06, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x0e, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e, 0x4c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x52,
+	0x06, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x22, 0x13, 0x0a, 0x11, 0x53, 0x68, 0x61, 0x72, 0x64,
+	0x53, 0x61, 0x76, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x22, 0x0a, 0x10,
+	0x53, 0x68, 0x61, 0x72, 0x64, 0x46, 0x69, 0x6e, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64,
+	0x22, 0x3b, 0x0a, 0x11, 0x53, 0x68, 0x61, 0x72, 0x64, 0x46, 0x69, 0x6e, 0x64, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x26, 0x0a, 0x06, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e, 0x4c,
+	0x61, 0x70, 0x74, 0x6f, 0x70, 0x52, 0x06, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x22, 0x3c, 0x0a,
+	0x12, 0x53, 0x68, 0x61, 0x72, 0x64, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x12, 0x26, 0x0a, 0x06, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e, 0x4c, 0x61, 0x70,
+	0x74, 0x6f, 0x70, 0x52, 0x06, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x22, 0x15, 0x0a, 0x13, 0x53,
+	0x68, 0x61, 0x72, 0x64, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x22, 0x24, 0x0a, 0x12, 0x53, 0x68, 0x61, 0x72, 0x64, 0x44, 0x65, 0x6c, 0x65, 0x74,
+	0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x22, 0x15, 0x0a, 0x13, 0x53, 0x68, 0x61, 0x72,
+	0x64, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22,
+	0x3c, 0x0a, 0x12, 0x53, 0x68, 0x61, 0x72, 0x64, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x26, 0x0a, 0x06, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e, 0x46,
+	0x69, 0x6c, 0x74, 0x65, 0x72, 0x52, 0x06, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x22, 0x3d, 0x0a,
+	0x13, 0x53, 0x68, 0x61, 0x72, 0x64, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x26, 0x0a, 0x06, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e, 0x4c, 0x61,
+	0x70, 0x74, 0x6f, 0x70, 0x52, 0x06, 0x6c, 0x61, 0x70, 0x74, 0x6f, 0x70, 0x22, 0x13, 0x0a, 0x11,
+	0x53, 0x68, 0x61, 0x72, 0x64, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x22, 0x2a, 0x0a, 0x12, 0x53, 0x68, 0x61, 0x72, 0x64, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x32, 0x9f, 0x03,
+	0x0a, 0x0c, 0x53, 0x68, 0x61, 0x72, 0x64, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x3d,
+	0x0a, 0x04, 0x53, 0x61, 0x76, 0x65, 0x12, 0x18, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e,
+	0x53, 0x68, 0x61, 0x72, 0x64, 0x53, 0x61, 0x76, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x19, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e, 0x53, 0x68, 0x61, 0x72, 0x64, 0x53,
+	0x61, 0x76, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x3d, 0x0a,
+	0x04, 0x46, 0x69, 0x6e, 0x64, 0x12, 0x18, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e, 0x53,
+	0x68, 0x61, 0x72, 0x64, 0x46, 0x69, 0x6e, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x19, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e, 0x53, 0x68, 0x61, 0x72, 0x64, 0x46, 0x69,
+	0x6e, 0x64, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x43, 0x0a, 0x06,
+	0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x12, 0x1a, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e,
+	0x53, 0x68, 0x61, 0x72, 0x64, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e, 0x53, 0x68, 0x61, 0x72,
+	0x64, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22,
+	0x00, 0x12, 0x43, 0x0a, 0x06, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x12, 0x1a, 0x2e, 0x70, 0x63,
+	0x62, 0x6f, 0x6f, 0x6b, 0x2e, 0x53, 0x68, 0x61, 0x72, 0x64, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b,
+	0x2e, 0x53, 0x68, 0x61, 0x72, 0x64, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x45, 0x0a, 0x06, 0x53, 0x65, 0x61, 0x72, 0x63, 0x68,
+	0x12, 0x1a, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e, 0x53, 0x68, 0x61, 0x72, 0x64, 0x53,
+	0x65, 0x61, 0x72, 0x63, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x70,
+	0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e, 0x53, 0x68, 0x61, 0x72, 0x64, 0x53, 0x65, 0x61, 0x72, 0x63,
+	0x68, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x30, 0x01, 0x12, 0x40, 0x0a,
+	0x05, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x19, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e,
+	0x53, 0x68, 0x61, 0x72, 0x64, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x1a, 0x2e, 0x70, 0x63, 0x62, 0x6f, 0x6f, 0x6b, 0x2e, 0x53, 0x68, 0x61, 0x72, 0x64,
+	0x43, 0x6f, 0x75, 0x6e, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x42,
+	0x06, 0x5a, 0x04, 0x2e, 0x2f, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_proto_shard_service_proto_rawDescOnce sync.Once
+	file_proto_shard_service_proto_rawDescData = file_proto_shard_service_proto_rawDesc
+)
+
+func file_proto_shard_service_proto_rawDescGZIP() []byte {
+	file_proto_shard_service_proto_rawDescOnce.Do(func() {
+		file_proto_shard_service_proto_rawDescData = protoimpl.X.CompressGZIP(file_proto_shard_service_proto_rawDescData)
+	})
+	return file_proto_shard_service_proto_rawDescData
+}
+
+var file_proto_shard_service_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_proto_shard_service_proto_goTypes = []interface{}{
+	(*ShardSaveRequest)(nil),    // 0: pcbook.ShardSaveRequest
+	(*ShardSaveResponse)(nil),   // 1: pcbook.ShardSaveResponse
+	(*ShardFindRequest)(nil),    // 2: pcbook.ShardFindRequest
+	(*ShardFindResponse)(nil),   // 3: pcbook.ShardFindResponse
+	(*ShardUpdateRequest)(nil),  // 4: pcbook.ShardUpdateRequest
+	(*ShardUpdateResponse)(nil), // 5: pcbook.ShardUpdateResponse
+	(*ShardDeleteRequest)(nil),  // 6: pcbook.ShardDeleteRequest
+	(*ShardDeleteResponse)(nil), // 7: pcbook.ShardDeleteResponse
+	(*ShardSearchRequest)(nil),  // 8: pcbook.ShardSearchRequest
+	(*ShardSearchResponse)(nil), // 9: pcbook.ShardSearchResponse
+	(*ShardCountRequest)(nil),   // 10: pcbook.ShardCountRequest
+	(*ShardCountResponse)(nil),  // 11: pcbook.ShardCountResponse
+	(*Laptop)(nil),              // 12: pcbook.Laptop
+	(*Filter)(nil),              // 13: pcbook.Filter
+}
+var file_proto_shard_service_proto_depIdxs = []int32{
+	12, // 0: pcbook.ShardSaveRequest.laptop:type_name -> pcbook.Laptop
+	12, // 1: pcbook.ShardFindResponse.laptop:type_name -> pcbook.Laptop
+	12, // 2: pcbook.ShardUpdateRequest.laptop:type_name -> pcbook.Laptop
+	13, // 3: pcbook.ShardSearchRequest.filter:type_name -> pcbook.Filter
+	12, // 4: pcbook.ShardSearchResponse.laptop:type_name -> pcbook.Laptop
+	0,  // 5: pcbook.ShardService.Save:input_type -> pcbook.ShardSaveRequest
+	2,  // 6: pcbook.ShardService.Find:input_type -> pcbook.ShardFindRequest
+	4,  // 7: pcbook.ShardService.Update:input_type -> pcbook.ShardUpdateRequest
+	6,  // 8: pcbook.ShardService.Delete:input_type -> pcbook.ShardDeleteRequest
+	8,  // 9: pcbook.ShardService.Search:input_type -> pcbook.ShardSearchRequest
+	10, // 10: pcbook.ShardService.Count:input_type -> pcbook.ShardCountRequest
+	1,  // 11: pcbook.ShardService.Save:output_type -> pcbook.ShardSaveResponse
+	3,  // 12: pcbook.ShardService.Find:output_type -> pcbook.ShardFindResponse
+	5,  // 13: pcbook.ShardService.Update:output_type -> pcbook.ShardUpdateResponse
+	7,  // 14: pcbook.ShardService.Delete:output_type -> pcbook.ShardDeleteResponse
+	9,  // 15: pcbook.ShardService.Search:output_type -> pcbook.ShardSearchResponse
+	11, // 16: pcbook.ShardService.Count:output_type -> pcbook.ShardCountResponse
+	11, // [11:17] is the sub-list for method output_type
+	5,  // [5:11] is the sub-list for method input_type
+	5,  // [5:5] is the sub-list for extension type_name
+	5,  // [5:5] is the sub-list for extension extendee
+	0,  // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_proto_shard_service_proto_init() }
+func file_proto_shard_service_proto_init() {
+	if File_proto_shard_service_proto != nil {
+		return
+	}
+	file_proto_laptop_message_proto_init()
+	file_proto_filter_message_proto_init()
+	if !protoimpl.UnsafeEnabled {
+		file_proto_shard_service_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ShardSaveRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_shard_service_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ShardSaveResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_shard_service_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ShardFindRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_shard_service_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ShardFindResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_shard_service_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ShardUpdateRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_shard_service_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ShardUpdateResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_shard_service_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ShardDeleteRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_shard_service_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ShardDeleteResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_shard_service_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ShardSearchRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_shard_service_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ShardSearchResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_shard_service_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ShardCountRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_shard_service_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ShardCountResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_proto_shard_service_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_proto_shard_service_proto_goTypes,
+		DependencyIndexes: file_proto_shard_service_proto_depIdxs,
+		MessageInfos:      file_proto_shard_service_proto_msgTypes,
+	}.Build()
+	File_proto_shard_service_proto = out.File
+	file_proto_shard_service_proto_rawDesc = nil
+	file_proto_shard_service_proto_goTypes = nil
+	file_proto_shard_service_proto_depIdxs = nil
+}
@@ -0,0 +1,311 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.6.1
+// source: proto/shard_service.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// ShardServiceClient is the client API for ShardService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ShardServiceClient interface {
+	Save(ctx context.Context, in *ShardSaveRequest, opts ...grpc.CallOption) (*ShardSaveResponse, error)
+	Find(ctx context.Context, in *ShardFindRequest, opts ...grpc.CallOption) (*ShardFindResponse, error)
+	Update(ctx context.Context, in *ShardUpdateRequest, opts ...grpc.CallOption) (*ShardUpdateResponse, error)
+	Delete(ctx context.Context, in *ShardDeleteRequest, opts ...grpc.CallOption) (*ShardDeleteResponse, error)
+	Search(ctx context.Context, in *ShardSearchRequest, opts ...grpc.CallOption) (ShardService_SearchClient, error)
+	Count(ctx context.Context, in *ShardCountRequest, opts ...grpc.CallOption) (*ShardCountResponse, error)
+}
+
+type shardServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewShardServiceClient(cc grpc.ClientConnInterface) ShardServiceClient {
+	return &shardServiceClient{cc}
+}
+
+func (c *shardServiceClient) Save(ctx context.Context, in *ShardSaveRequest, opts ...grpc.CallOption) (*ShardSaveResponse, error) {
+	out := new(ShardSaveResponse)
+	err := c.cc.Invoke(ctx, "/pcbook.ShardService/Save", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shardServiceClient) Find(ctx context.Context, in *ShardFindRequest, opts ...grpc.CallOption) (*ShardFindResponse, error) {
+	out := new(ShardFindResponse)
+	err := c.cc.Invoke(ctx, "/pcbook.ShardService/Find", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shardServiceClient) Update(ctx context.Context, in *ShardUpdateRequest, opts ...grpc.CallOption) (*ShardUpdateResponse, error) {
+	out := new(ShardUpdateResponse)
+	err := c.cc.Invoke(ctx, "/pcbook.ShardService/Update", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shardServiceClient) Delete(ctx context.Context, in *ShardDeleteRequest, opts ...grpc.CallOption) (*ShardDeleteResponse, error) {
+	out := new(ShardDeleteResponse)
+	err := c.cc.Invoke(ctx, "/pcbook.ShardService/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shardServiceClient) Search(ctx context.Context, in *ShardSearchRequest, opts ...grpc.CallOption) (ShardService_SearchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ShardService_ServiceDesc.Streams[0], "/pcbook.ShardService/Search", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &shardServiceSearchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ShardService_SearchClient interface {
+	Recv() (*ShardSearchResponse, error)
+	grpc.ClientStream
+}
+
+type shardServiceSearchClient struct {
+	grpc.ClientStream
+}
+
+func (x *shardServiceSearchClient) Recv() (*ShardSearchResponse, error) {
+	m := new(ShardSearchResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *shardServiceClient) Count(ctx context.Context, in *ShardCountRequest, opts ...grpc.CallOption) (*ShardCountResponse, error) {
+	out := new(ShardCountResponse)
+	err := c.cc.Invoke(ctx, "/pcbook.ShardService/Count", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ShardServiceServer is the server API for ShardService service.
+// All implementations should embed UnimplementedShardServiceServer
+// for forward compatibility
+type ShardServiceServer interface {
+	Save(context.Context, *ShardSaveRequest) (*ShardSaveResponse, error)
+	Find(context.Context, *ShardFindRequest) (*ShardFindResponse, error)
+	Update(context.Context, *ShardUpdateRequest) (*ShardUpdateResponse, error)
+	Delete(context.Context, *ShardDeleteRequest) (*ShardDeleteResponse, error)
+	Search(*ShardSearchRequest, ShardService_SearchServer) error
+	Count(context.Context, *ShardCountRequest) (*ShardCountResponse, error)
+}
+
+// UnimplementedShardServiceServer should be embedded to have forward compatible implementations.
+type UnimplementedShardServiceServer struct {
+}
+
+func (UnimplementedShardServiceServer) Save(context.Context, *ShardSaveRequest) (*ShardSaveResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Save not implemented")
+}
+func (UnimplementedShardServiceServer) Find(context.Context, *ShardFindRequest) (*ShardFindResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Find not implemented")
+}
+func (UnimplementedShardServiceServer) Update(context.Context, *ShardUpdateRequest) (*ShardUpdateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Update not implemented")
+}
+func (UnimplementedShardServiceServer) Delete(context.Context, *ShardDeleteRequest) (*ShardDeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedShardServiceServer) Search(*ShardSearchRequest, ShardService_SearchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedShardServiceServer) Count(context.Context, *ShardCountRequest) (*ShardCountResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Count not implemented")
+}
+
+// UnsafeShardServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ShardServiceServer will
+// result in compilation errors.
+type UnsafeShardServiceServer interface {
+	mustEmbedUnimplementedShardServiceServer()
+}
+
+func RegisterShardServiceServer(s grpc.ServiceRegistrar, srv ShardServiceServer) {
+	s.RegisterService(&ShardService_ServiceDesc, srv)
+}
+
+func _ShardService_Save_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ShardSaveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShardServiceServer).Save(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pcbook.ShardService/Save",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShardServiceServer).Save(ctx, req.(*ShardSaveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShardService_Find_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ShardFindRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShardServiceServer).Find(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pcbook.ShardService/Find",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShardServiceServer).Find(ctx, req.(*ShardFindRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShardService_Update_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ShardUpdateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShardServiceServer).Update(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pcbook.ShardService/Update",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShardServiceServer).Update(ctx, req.(*ShardUpdateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShardService_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ShardDeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShardServiceServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pcbook.ShardService/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShardServiceServer).Delete(ctx, req.(*ShardDeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShardService_Search_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ShardSearchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ShardServiceServer).Search(m, &shardServiceSearchServer{stream})
+}
+
+type ShardService_SearchServer interface {
+	Send(*ShardSearchResponse) error
+	grpc.ServerStream
+}
+
+type shardServiceSearchServer struct {
+	grpc.ServerStream
+}
+
+func (x *shardServiceSearchServer) Send(m *ShardSearchResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _ShardService_Count_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ShardCountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShardServiceServer).Count(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pcbook.ShardService/Count",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShardServiceServer).Count(ctx, req.(*ShardCountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ShardService_ServiceDesc is the grpc.ServiceDesc for ShardService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ShardService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pcbook.ShardService",
+	HandlerType: (*ShardServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Save",
+			Handler:    _ShardService_Save_Handler,
+		},
+		{
+			MethodName: "Find",
+			Handler:    _ShardService_Find_Handler,
+		},
+		{
+			MethodName: "Update",
+			Handler:    _ShardService_Update_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _ShardService_Delete_Handler,
+		},
+		{
+			MethodName: "Count",
+			Handler:    _ShardService_Count_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Search",
+			Handler:       _ShardService_Search_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "proto/shard_service.proto",
+}
@@ -0,0 +1,66 @@
+package raft
+
+type EntryType int
+
+const (
+	// Command for the state machine.
+	EntryCommand EntryType = iota
+	// New cluster members.
+	EntryConfig
+	// Appended by new leaders to commit the entries of previous terms.
+	EntryNoop
+)
+
+type Entry struct {
+	Index   uint64
+	Term    uint64
+	Type    EntryType
+	Data    []byte
+	Members []string
+}
+
+// State machine and members as of an index, replacing the log up to it.
+type Snapshot struct {
+	Index   uint64
+	Term    uint64
+	Members []string
+	Data    []byte
+}
+
+type MessageType int
+
+const (
+	MsgVote MessageType = iota
+	MsgVoteResponse
+	MsgAppend
+	// Answers both MsgAppend and MsgSnapshot.
+	MsgAppendResponse
+	MsgSnapshot
+)
+
+// Message between nodes. Receivers must not modify the entries and snapshot, the
+// sender keeps them.
+type Message struct {
+	Type MessageType
+	From string
+	To   string
+	Term uint64
+
+	// MsgVote: the candidate's log.
+	LastLogIndex uint64
+	LastLogTerm  uint64
+
+	// MsgAppend: entries following the previous one, and the leader commit index.
+	PrevLogIndex uint64
+	PrevLogTerm  uint64
+	Entries      []Entry
+	Commit       uint64
+
+	// MsgSnapshot
+	Snapshot *Snapshot
+
+	// Responses: whether the vote or entries were accepted, and the last index
+	// known to match the leader's log, a hint to retry from when rejected.
+	Success    bool
+	MatchIndex uint64
+}
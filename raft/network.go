@@ -0,0 +1,69 @@
+package raft
+
+import "sync"
+
+// In process network between nodes, which can be partitioned and healed to
+// simulate failures.
+type Network struct {
+	mutex sync.RWMutex
+	nodes map[string]*Node
+	// partition of each node, nodes in different ones can't talk. 0 for nodes not in any.
+	partitions map[string]int
+}
+
+func NewNetwork() *Network {
+	return &Network{nodes: make(map[string]*Node), partitions: make(map[string]int)}
+}
+
+// Connects the node, which must use the network's transport.
+func (n *Network) Add(node *Node) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.nodes[node.Id()] = node
+}
+
+// Disconnects the node.
+func (n *Network) Remove(id string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	delete(n.nodes, id)
+}
+
+// Splits the network: nodes in the same group only talk among themselves,
+// nodes not in any group talk with each other.
+func (n *Network) Partition(groups ...[]string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.partitions = make(map[string]int)
+	for i, group := range groups {
+		for _, id := range group {
+			n.partitions[id] = i + 1
+		}
+	}
+}
+
+// Reconnects every node.
+func (n *Network) Heal() {
+	n.Partition()
+}
+
+// Transport delivering the messages of a node through the network.
+func (n *Network) Transport() Transport {
+	return networkTransport{n}
+}
+
+type networkTransport struct {
+	network *Network
+}
+
+func (t networkTransport) Send(msg Message) {
+	t.network.mutex.RLock()
+	defer t.network.mutex.RUnlock()
+
+	to, ok := t.network.nodes[msg.To]
+	if !ok || t.network.partitions[msg.From] != t.network.partitions[msg.To] {
+		return
+	}
+	to.Step(msg)
+}
@@ -0,0 +1,825 @@
+// Package raft replicates a state machine across a cluster of nodes with the Raft
+// consensus algorithm: leader election, log replication, log compaction through
+// snapshots and single server membership changes.
+//
+// Nodes keep their state in memory, and in a Storage to survive restarts, and talk
+// through a Transport, like the in process Network used by tests.
+package raft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	ErrProposalDropped        = errors.New("proposal was not committed, the node lost its leadership.")
+	ErrConfigChangeInProgress = errors.New("another membership change is not committed yet.")
+	ErrNodeStopped            = errors.New("node stopped.")
+	// The node caught up from a snapshot covering the proposal, which may or may not
+	// have been committed before, so the proposer should check before retrying.
+	ErrProposalOutcomeUnknown = errors.New("proposal outcome is unknown, a snapshot replaced its entry.")
+	// The node couldn't save its state and stopped taking part in the cluster.
+	ErrStorageFailed = errors.New("raft storage failed.")
+)
+
+// Returned by calls that only the leader can serve.
+type NotLeaderError struct {
+	// Last known leader, empty if there's none.
+	Leader string
+}
+
+func (e *NotLeaderError) Error() string {
+	if e.Leader == "" {
+		return "not the leader, no leader known."
+	}
+	return fmt.Sprintf("not the leader, the leader is %s.", e.Leader)
+}
+
+// Deterministic state machine the log is applied to, the same on every node.
+type StateMachine interface {
+	// Applies a committed command, the result goes back to the proposer
+	Apply(command []byte) interface{}
+	// Serializes the state, to compact the log
+	Snapshot() ([]byte, error)
+	// Replaces the state with a snapshot
+	Restore(data []byte) error
+}
+
+// Delivers messages to other nodes. Messages may be lost, delayed or reordered,
+// Send must not block.
+type Transport interface {
+	Send(msg Message)
+}
+
+type Role int
+
+const (
+	Follower Role = iota
+	Candidate
+	Leader
+)
+
+func (r Role) String() string {
+	switch r {
+	case Leader:
+		return "leader"
+	case Candidate:
+		return "candidate"
+	default:
+		return "follower"
+	}
+}
+
+type Config struct {
+	// Id of the node, unique in the cluster.
+	Id string
+	// Founding members of the cluster, empty for a node waiting to be added.
+	Members []string
+	// Duration of a tick, the unit of the timeouts below.
+	TickInterval time.Duration
+	// Followers start an election after this many ticks without a leader, plus a random part as long.
+	ElectionTicks int
+	// Leaders send heartbeats every this many ticks.
+	HeartbeatTicks int
+	// Applied entries kept in the log before they're compacted into a snapshot.
+	SnapshotThreshold int
+	// Entries sent in a single append message.
+	MaxAppendEntries int
+}
+
+func (c Config) withDefaults() Config {
+	if c.TickInterval == 0 {
+		c.TickInterval = 50 * time.Millisecond
+	}
+	if c.ElectionTicks == 0 {
+		c.ElectionTicks = 10
+	}
+	if c.HeartbeatTicks == 0 {
+		c.HeartbeatTicks = 2
+	}
+	if c.SnapshotThreshold == 0 {
+		c.SnapshotThreshold = 1000
+	}
+	if c.MaxAppendEntries == 0 {
+		c.MaxAppendEntries = 100
+	}
+	return c
+}
+
+// State of a node, as seen from outside.
+type Status struct {
+	Id            string
+	Role          Role
+	Term          uint64
+	Leader        string
+	Members       []string
+	LastIndex     uint64
+	CommitIndex   uint64
+	AppliedIndex  uint64
+	SnapshotIndex uint64
+	// Why the node stopped taking part in the cluster, nil while it does.
+	Failure error
+}
+
+type proposalResult struct {
+	value interface{}
+	err   error
+}
+
+type waiter struct {
+	term   uint64
+	result chan proposalResult
+}
+
+// A member of a Raft cluster. All its state is guarded by one mutex, the loop
+// started by Start handles ticks and incoming messages one at a time.
+type Node struct {
+	config    Config
+	machine   StateMachine
+	transport Transport
+	// nil for nodes keeping their state in memory only.
+	storage Storage
+	inbox   chan Message
+	stop    chan struct{}
+	done    chan struct{}
+
+	mutex       sync.Mutex
+	role        Role
+	term        uint64
+	votedFor    string
+	leader      string
+	members     []string
+	log         []Entry // log[0] stands for the last compacted entry
+	snapshot    *Snapshot
+	commitIndex uint64
+	lastApplied uint64
+	waiters     map[uint64]*waiter
+	failure     error
+
+	elapsed         int // ticks since the last heartbeat or election
+	electionTimeout int
+	votes           map[string]bool
+	nextIndex       map[string]uint64
+	matchIndex      map[string]uint64
+}
+
+func NewNode(config Config, machine StateMachine, transport Transport) *Node {
+	config = config.withDefaults()
+	n := &Node{
+		config:    config,
+		machine:   machine,
+		transport: transport,
+		inbox:     make(chan Message, 1024),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+		members:   append([]string(nil), config.Members...),
+		log:       []Entry{{}},
+		snapshot:  &Snapshot{Members: append([]string(nil), config.Members...)},
+		waiters:   make(map[uint64]*waiter),
+	}
+	n.resetElectionTimeout()
+	return n
+}
+
+// Node keeping its state in the storage, or restarted from the state saved there.
+// The state machine must start empty: a restarted node restores its snapshot and
+// applies the committed entries following it again.
+func NewDurableNode(config Config, storage Storage, machine StateMachine, transport Transport) (*Node, error) {
+	n := NewNode(config, machine, transport)
+	n.storage = storage
+	state, snapshot, entries, err := storage.Load()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't load raft state: %w", err)
+	}
+
+	n.term, n.votedFor = state.Term, state.VotedFor
+	if snapshot != nil {
+		if err := machine.Restore(snapshot.Data); err != nil {
+			return nil, fmt.Errorf("couldn't restore raft snapshot: %w", err)
+		}
+		n.snapshot = snapshot
+		n.log = []Entry{{Index: snapshot.Index, Term: snapshot.Term}}
+		n.commitIndex, n.lastApplied = snapshot.Index, snapshot.Index
+	}
+	n.log = append(n.log, entries...)
+	n.members = n.configAt(n.lastIndex())
+	return n, nil
+}
+
+func (n *Node) Id() string {
+	return n.config.Id
+}
+
+// Runs the node until Stop.
+func (n *Node) Start() {
+	go n.run()
+}
+
+// Stops the node, pending proposals fail with ErrNodeStopped.
+func (n *Node) Stop() {
+	close(n.stop)
+	<-n.done
+}
+
+// Hands a message from another node, dropped if the node is too busy.
+func (n *Node) Step(msg Message) {
+	select {
+	case n.inbox <- msg:
+	default:
+	}
+}
+
+func (n *Node) Status() Status {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return Status{
+		Id:            n.config.Id,
+		Role:          n.role,
+		Term:          n.term,
+		Leader:        n.leader,
+		Members:       append([]string(nil), n.members...),
+		LastIndex:     n.lastIndex(),
+		CommitIndex:   n.commitIndex,
+		AppliedIndex:  n.lastApplied,
+		SnapshotIndex: n.log[0].Index,
+		Failure:       n.failure,
+	}
+}
+
+// Commits the command through the log and returns what the state machine made of it.
+// Only the leader takes proposals, others return a NotLeaderError.
+func (n *Node) Propose(ctx context.Context, command []byte) (interface{}, error) {
+	return n.propose(ctx, func() (*Entry, error) {
+		return &Entry{Type: EntryCommand, Data: command}, nil
+	})
+}
+
+// Adds a node to the cluster. The node must be running, with no founding members.
+func (n *Node) AddMember(ctx context.Context, id string) error {
+	return n.changeMembers(ctx, func(members []string) []string {
+		if contains(members, id) {
+			return nil
+		}
+		return append(members, id)
+	})
+}
+
+// Removes a node from the cluster. A leader removing itself steps down once it's done.
+func (n *Node) RemoveMember(ctx context.Context, id string) error {
+	return n.changeMembers(ctx, func(members []string) []string {
+		if !contains(members, id) {
+			return nil
+		}
+		return remove(members, id)
+	})
+}
+
+// Membership changes one server at a time, each new configuration is used as soon
+// as it's in the log, and only once the previous one is committed.
+func (n *Node) changeMembers(ctx context.Context, change func(members []string) []string) error {
+	_, err := n.propose(ctx, func() (*Entry, error) {
+		if n.lastConfigIndex() > n.commitIndex {
+			return nil, ErrConfigChangeInProgress
+		}
+		members := change(append([]string(nil), n.members...))
+		if members == nil {
+			return nil, nil
+		}
+		return &Entry{Type: EntryConfig, Members: members}, nil
+	})
+	return err
+}
+
+// Appends the entry made by next, run under the node lock, and waits until it's applied.
+// No entry means there's nothing to do.
+func (n *Node) propose(ctx context.Context, next func() (*Entry, error)) (interface{}, error) {
+	n.mutex.Lock()
+	if n.failure != nil {
+		err := n.failure
+		n.mutex.Unlock()
+		return nil, err
+	}
+	if n.role != Leader {
+		leader := n.leader
+		n.mutex.Unlock()
+		return nil, &NotLeaderError{Leader: leader}
+	}
+	entry, err := next()
+	if entry == nil || err != nil {
+		n.mutex.Unlock()
+		return nil, err
+	}
+	entry.Index = n.lastIndex() + 1
+	entry.Term = n.term
+	if !n.appendEntries([]Entry{*entry}) {
+		err := n.failure
+		n.mutex.Unlock()
+		return nil, err
+	}
+	w := &waiter{term: n.term, result: make(chan proposalResult, 1)}
+	n.waiters[entry.Index] = w
+	n.matchIndex[n.config.Id] = entry.Index
+	n.broadcastAppend()
+	n.advanceCommit()
+	n.mutex.Unlock()
+
+	select {
+	case result := <-w.result:
+		return result.value, result.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-n.stop:
+		return nil, ErrNodeStopped
+	}
+}
+
+func (n *Node) run() {
+	defer close(n.done)
+	ticker := time.NewTicker(n.config.TickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n.mutex.Lock()
+			n.tick()
+			n.mutex.Unlock()
+		case msg := <-n.inbox:
+			n.mutex.Lock()
+			n.handle(msg)
+			n.mutex.Unlock()
+		case <-n.stop:
+			return
+		}
+	}
+}
+
+func (n *Node) tick() {
+	if n.failure != nil {
+		return
+	}
+	n.elapsed++
+	if n.role == Leader {
+		if n.elapsed >= n.config.HeartbeatTicks {
+			n.elapsed = 0
+			n.broadcastAppend()
+		}
+		return
+	}
+	if n.elapsed >= n.electionTimeout && contains(n.members, n.config.Id) {
+		n.campaign()
+	}
+}
+
+func (n *Node) campaign() {
+	n.role = Candidate
+	n.term++
+	n.votedFor = n.config.Id
+	n.leader = ""
+	n.votes = map[string]bool{n.config.Id: true}
+	n.elapsed = 0
+	n.resetElectionTimeout()
+	if !n.saveState() {
+		return
+	}
+
+	if n.hasQuorum(n.votes) {
+		n.becomeLeader()
+		return
+	}
+	for _, member := range n.members {
+		if member != n.config.Id {
+			n.send(Message{Type: MsgVote, To: member, LastLogIndex: n.lastIndex(), LastLogTerm: n.lastTerm()})
+		}
+	}
+}
+
+func (n *Node) becomeLeader() {
+	n.role = Leader
+	n.leader = n.config.Id
+	n.elapsed = 0
+	n.nextIndex = make(map[string]uint64)
+	n.matchIndex = make(map[string]uint64)
+
+	// commits the entries of previous terms along with one of this term.
+	noop := Entry{Type: EntryNoop, Index: n.lastIndex() + 1, Term: n.term}
+	if !n.appendEntries([]Entry{noop}) {
+		return
+	}
+	n.matchIndex[n.config.Id] = noop.Index
+	n.broadcastAppend()
+	n.advanceCommit()
+}
+
+func (n *Node) becomeFollower(term uint64, leader string) {
+	n.role = Follower
+	n.leader = leader
+	n.elapsed = 0
+	if term > n.term {
+		n.term = term
+		n.votedFor = ""
+		n.saveState()
+	}
+}
+
+func (n *Node) handle(msg Message) {
+	if n.failure != nil {
+		return
+	}
+	// a node that recently heard from its leader ignores votes, so that removed or
+	// partitioned nodes rejoining with a higher term don't disrupt the cluster.
+	if msg.Type == MsgVote && n.leader != "" && n.elapsed < n.config.ElectionTicks {
+		return
+	}
+
+	if msg.Term > n.term {
+		leader := ""
+		if msg.Type == MsgAppend || msg.Type == MsgSnapshot {
+			leader = msg.From
+		}
+		n.becomeFollower(msg.Term, leader)
+		if n.failure != nil {
+			return
+		}
+	}
+	if msg.Term < n.term {
+		switch msg.Type {
+		case MsgAppend, MsgSnapshot:
+			// tells the stale leader about the new term.
+			n.send(Message{Type: MsgAppendResponse, To: msg.From})
+		case MsgVote:
+			n.send(Message{Type: MsgVoteResponse, To: msg.From})
+		}
+		return
+	}
+
+	switch msg.Type {
+	case MsgVote:
+		n.handleVote(msg)
+	case MsgVoteResponse:
+		n.handleVoteResponse(msg)
+	case MsgAppend:
+		n.handleAppend(msg)
+	case MsgAppendResponse:
+		n.handleAppendResponse(msg)
+	case MsgSnapshot:
+		n.handleSnapshot(msg)
+	}
+}
+
+func (n *Node) handleVote(msg Message) {
+	upToDate := msg.LastLogTerm > n.lastTerm() || (msg.LastLogTerm == n.lastTerm() && msg.LastLogIndex >= n.lastIndex())
+	granted := (n.votedFor == "" || n.votedFor == msg.From) && upToDate
+	if granted {
+		n.votedFor = msg.From
+		n.elapsed = 0
+		if !n.saveState() {
+			return
+		}
+	}
+	n.send(Message{Type: MsgVoteResponse, To: msg.From, Success: granted})
+}
+
+func (n *Node) handleVoteResponse(msg Message) {
+	if n.role != Candidate || !msg.Success {
+		return
+	}
+	n.votes[msg.From] = true
+	if n.hasQuorum(n.votes) {
+		n.becomeLeader()
+	}
+}
+
+func (n *Node) handleAppend(msg Message) {
+	n.becomeFollower(msg.Term, msg.From)
+
+	prevIndex, prevTerm, entries := msg.PrevLogIndex, msg.PrevLogTerm, msg.Entries
+	// entries already compacted here are committed, so they match.
+	if first := n.log[0].Index; prevIndex < first {
+		for len(entries) > 0 && entries[0].Index <= first {
+			entries = entries[1:]
+		}
+		prevIndex, prevTerm = first, n.log[0].Term
+	}
+
+	if prevIndex > n.lastIndex() {
+		n.send(Message{Type: MsgAppendResponse, To: msg.From, MatchIndex: n.lastIndex()})
+		return
+	}
+	if n.termAt(prevIndex) != prevTerm {
+		n.send(Message{Type: MsgAppendResponse, To: msg.From, MatchIndex: prevIndex - 1})
+		return
+	}
+
+	for i, entry := range entries {
+		if entry.Index <= n.lastIndex() {
+			if n.termAt(entry.Index) == entry.Term {
+				continue
+			}
+			n.truncate(entry.Index)
+		}
+		if !n.appendEntries(entries[i:]) {
+			return
+		}
+		break
+	}
+
+	match := prevIndex + uint64(len(entries))
+	if commit := min(msg.Commit, match); commit > n.commitIndex {
+		n.commitIndex = commit
+		n.apply()
+	}
+	n.send(Message{Type: MsgAppendResponse, To: msg.From, Success: true, MatchIndex: match})
+}
+
+func (n *Node) handleAppendResponse(msg Message) {
+	if n.role != Leader {
+		return
+	}
+	if !msg.Success {
+		// rejected, probes back from what the follower has.
+		n.nextIndex[msg.From] = max(1, min(n.nextIndex[msg.From]-1, msg.MatchIndex+1))
+		n.sendAppend(msg.From)
+		return
+	}
+
+	if msg.MatchIndex > n.matchIndex[msg.From] {
+		n.matchIndex[msg.From] = msg.MatchIndex
+	}
+	n.nextIndex[msg.From] = n.matchIndex[msg.From] + 1
+	n.advanceCommit()
+	if n.role == Leader && n.nextIndex[msg.From] <= n.lastIndex() {
+		n.sendAppend(msg.From)
+	}
+}
+
+func (n *Node) handleSnapshot(msg Message) {
+	n.becomeFollower(msg.Term, msg.From)
+
+	snapshot := msg.Snapshot
+	if snapshot.Index <= n.commitIndex {
+		n.send(Message{Type: MsgAppendResponse, To: msg.From, Success: true, MatchIndex: n.commitIndex})
+		return
+	}
+	if err := n.machine.Restore(snapshot.Data); err != nil {
+		n.send(Message{Type: MsgAppendResponse, To: msg.From, MatchIndex: n.commitIndex})
+		return
+	}
+
+	// keeps the entries following the snapshot if the log has it.
+	suffix := []Entry{}
+	if snapshot.Index <= n.lastIndex() && n.termAt(snapshot.Index) == snapshot.Term {
+		suffix = n.log[snapshot.Index-n.log[0].Index+1:]
+	}
+	if n.storage != nil {
+		if err := n.storage.SaveSnapshot(n.hardState(), snapshot, suffix); err != nil {
+			n.fail(err)
+			return
+		}
+	}
+	n.log = append([]Entry{{Index: snapshot.Index, Term: snapshot.Term}}, suffix...)
+	n.snapshot = snapshot
+	n.commitIndex, n.lastApplied = snapshot.Index, snapshot.Index
+	n.members = n.configAt(n.lastIndex())
+	for index, w := range n.waiters {
+		if index <= snapshot.Index {
+			w.result <- proposalResult{err: ErrProposalOutcomeUnknown}
+			delete(n.waiters, index)
+		}
+	}
+	n.send(Message{Type: MsgAppendResponse, To: msg.From, Success: true, MatchIndex: snapshot.Index})
+}
+
+func (n *Node) broadcastAppend() {
+	for _, member := range n.members {
+		if member != n.config.Id {
+			n.sendAppend(member)
+		}
+	}
+}
+
+// Sends the entries the follower is missing, or the snapshot if they're compacted.
+func (n *Node) sendAppend(to string) {
+	next, ok := n.nextIndex[to]
+	if !ok {
+		next = n.lastIndex() + 1
+		n.nextIndex[to] = next
+	}
+
+	if next <= n.log[0].Index {
+		n.send(Message{Type: MsgSnapshot, To: to, Snapshot: n.snapshot})
+		return
+	}
+
+	prevIndex := next - 1
+	last := min(n.lastIndex(), prevIndex+uint64(n.config.MaxAppendEntries))
+	entries := make([]Entry, 0, last-prevIndex)
+	for i := next; i <= last; i++ {
+		entries = append(entries, n.entry(i))
+	}
+	n.send(Message{
+		Type:         MsgAppend,
+		To:           to,
+		PrevLogIndex: prevIndex,
+		PrevLogTerm:  n.termAt(prevIndex),
+		Entries:      entries,
+		Commit:       n.commitIndex,
+	})
+}
+
+// Commits the last entry of the current term a majority of members have.
+func (n *Node) advanceCommit() {
+	for index := n.lastIndex(); index > n.commitIndex; index-- {
+		if n.termAt(index) != n.term {
+			break
+		}
+		matched := map[string]bool{}
+		for _, member := range n.members {
+			if n.matchIndex[member] >= index {
+				matched[member] = true
+			}
+		}
+		if n.hasQuorum(matched) {
+			n.commitIndex = index
+			n.apply()
+			return
+		}
+	}
+}
+
+// Applies the committed entries, hands results to their proposers, and compacts the log.
+func (n *Node) apply() {
+	for n.lastApplied < n.commitIndex {
+		n.lastApplied++
+		entry := n.entry(n.lastApplied)
+
+		var value interface{}
+		if entry.Type == EntryCommand {
+			value = n.machine.Apply(entry.Data)
+		}
+		if w, ok := n.waiters[entry.Index]; ok {
+			if w.term == entry.Term {
+				w.result <- proposalResult{value: value}
+			} else {
+				w.result <- proposalResult{err: ErrProposalDropped}
+			}
+			delete(n.waiters, entry.Index)
+		}
+	}
+
+	// a leader no longer a member steps down once its removal is committed.
+	if n.role == Leader && !contains(n.members, n.config.Id) && n.lastConfigIndex() <= n.commitIndex {
+		n.becomeFollower(n.term, "")
+	}
+
+	if n.lastApplied-n.log[0].Index >= uint64(n.config.SnapshotThreshold) {
+		n.compact()
+	}
+}
+
+func (n *Node) compact() {
+	data, err := n.machine.Snapshot()
+	if err != nil {
+		return
+	}
+	index := n.lastApplied
+	snapshot := &Snapshot{Index: index, Term: n.termAt(index), Members: n.configAt(index), Data: data}
+	// the saved log is still whole if the snapshot can't be saved, compacting waits.
+	if n.storage != nil {
+		if err := n.storage.SaveSnapshot(n.hardState(), snapshot, n.log[index-n.log[0].Index+1:]); err != nil {
+			return
+		}
+	}
+	n.log = append([]Entry{{Index: snapshot.Index, Term: snapshot.Term}}, n.log[index-n.log[0].Index+1:]...)
+	n.snapshot = snapshot
+}
+
+// Saves the entries then appends them, false if they couldn't be saved.
+func (n *Node) appendEntries(entries []Entry) bool {
+	if n.storage != nil {
+		if err := n.storage.Append(entries); err != nil {
+			n.fail(err)
+			return false
+		}
+	}
+	n.log = append(n.log, entries...)
+	for _, entry := range entries {
+		if entry.Type == EntryConfig {
+			n.members = append([]string(nil), entry.Members...)
+		}
+	}
+	return true
+}
+
+// Saves the term and vote, false if they couldn't be saved.
+func (n *Node) saveState() bool {
+	if n.storage == nil {
+		return true
+	}
+	if err := n.storage.SaveState(n.hardState()); err != nil {
+		n.fail(err)
+		return false
+	}
+	return true
+}
+
+func (n *Node) hardState() HardState {
+	return HardState{Term: n.term, VotedFor: n.votedFor}
+}
+
+// Stops taking part in the cluster: acting on state a restart would lose could
+// break the guarantees of the algorithm. Pending proposals fail.
+func (n *Node) fail(err error) {
+	n.failure = fmt.Errorf("%w: %v", ErrStorageFailed, err)
+	n.role = Follower
+	n.leader = ""
+	for index, w := range n.waiters {
+		w.result <- proposalResult{err: n.failure}
+		delete(n.waiters, index)
+	}
+}
+
+// Drops the entries from the index on, they conflict with the leader's.
+func (n *Node) truncate(index uint64) {
+	n.log = n.log[:index-n.log[0].Index]
+	n.members = n.configAt(n.lastIndex())
+}
+
+// Members in effect at the index: the last configuration up to it.
+func (n *Node) configAt(index uint64) []string {
+	for i := index; i > n.log[0].Index; i-- {
+		if entry := n.entry(i); entry.Type == EntryConfig {
+			return append([]string(nil), entry.Members...)
+		}
+	}
+	return append([]string(nil), n.snapshot.Members...)
+}
+
+func (n *Node) lastConfigIndex() uint64 {
+	for i := n.lastIndex(); i > n.log[0].Index; i-- {
+		if n.entry(i).Type == EntryConfig {
+			return i
+		}
+	}
+	return n.log[0].Index
+}
+
+func (n *Node) hasQuorum(voters map[string]bool) bool {
+	count := 0
+	for _, member := range n.members {
+		if voters[member] {
+			count++
+		}
+	}
+	return count > len(n.members)/2
+}
+
+func (n *Node) send(msg Message) {
+	msg.From = n.config.Id
+	msg.Term = n.term
+	n.transport.Send(msg)
+}
+
+func (n *Node) resetElectionTimeout() {
+	n.electionTimeout = n.config.ElectionTicks + rand.Intn(n.config.ElectionTicks)
+}
+
+func (n *Node) lastIndex() uint64 {
+	return n.log[0].Index + uint64(len(n.log)) - 1
+}
+
+func (n *Node) lastTerm() uint64 {
+	return n.log[len(n.log)-1].Term
+}
+
+// Entry at the index, which must be after the compacted ones.
+func (n *Node) entry(index uint64) Entry {
+	return n.log[index-n.log[0].Index]
+}
+
+func (n *Node) termAt(index uint64) uint64 {
+	return n.entry(index).Term
+}
+
+func contains(ids []string, id string) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
+
+func remove(ids []string, id string) []string {
+	kept := ids[:0]
+	for _, other := range ids {
+		if other != id {
+			kept = append(kept, other)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,367 @@
+package raft_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-grpc-pcbook/raft"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Appends every command to a list.
+type listMachine struct {
+	mutex sync.Mutex
+	items []string
+}
+
+func (m *listMachine) Apply(command []byte) interface{} {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.items = append(m.items, string(command))
+	return len(m.items)
+}
+
+func (m *listMachine) Snapshot() ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return []byte(strings.Join(m.items, ",")), nil
+}
+
+func (m *listMachine) Restore(data []byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.items = nil
+	if len(data) > 0 {
+		m.items = strings.Split(string(data), ",")
+	}
+	return nil
+}
+
+func (m *listMachine) list() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]string(nil), m.items...)
+}
+
+type testCluster struct {
+	network  *raft.Network
+	nodes    map[string]*raft.Node
+	machines map[string]*listMachine
+}
+
+func newTestCluster(t *testing.T, ids ...string) *testCluster {
+	cluster := &testCluster{network: raft.NewNetwork(), nodes: map[string]*raft.Node{}, machines: map[string]*listMachine{}}
+	for _, id := range ids {
+		cluster.start(t, id, ids)
+	}
+	return cluster
+}
+
+func (c *testCluster) start(t *testing.T, id string, members []string) {
+	config := raft.Config{Id: id, Members: members, TickInterval: 2 * time.Millisecond, SnapshotThreshold: 5}
+	c.machines[id] = &listMachine{}
+	c.nodes[id] = raft.NewNode(config, c.machines[id], c.network.Transport())
+	c.network.Add(c.nodes[id])
+	c.nodes[id].Start()
+	t.Cleanup(c.nodes[id].Stop)
+}
+
+// Waits for one of the nodes to lead the others.
+func (c *testCluster) leader(t *testing.T, ids ...string) *raft.Node {
+	var leader *raft.Node
+	require.Eventually(t, func() bool {
+		leader = nil
+		for _, id := range ids {
+			status := c.nodes[id].Status()
+			if status.Role == raft.Leader {
+				leader = c.nodes[id]
+			}
+		}
+		if leader == nil {
+			return false
+		}
+		for _, id := range ids {
+			if c.nodes[id].Status().Leader != leader.Id() {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 5*time.Millisecond)
+	return leader
+}
+
+func propose(t *testing.T, node *raft.Node, command string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := node.Propose(ctx, []byte(command))
+	require.NoError(t, err)
+}
+
+func (c *testCluster) requireApplied(t *testing.T, expected []string, ids ...string) {
+	require.Eventually(t, func() bool {
+		for _, id := range ids {
+			if fmt.Sprint(c.machines[id].list()) != fmt.Sprint(expected) {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 5*time.Millisecond)
+}
+
+func TestReplicatesCommands(t *testing.T) {
+	cluster := newTestCluster(t, "a", "b", "c")
+	leader := cluster.leader(t, "a", "b", "c")
+
+	result, err := leader.Propose(context.Background(), []byte("x"))
+	require.NoError(t, err)
+	require.Equal(t, 1, result)
+	propose(t, leader, "y")
+	cluster.requireApplied(t, []string{"x", "y"}, "a", "b", "c")
+
+	for _, node := range cluster.nodes {
+		if node != leader {
+			_, err := node.Propose(context.Background(), []byte("z"))
+			var notLeader *raft.NotLeaderError
+			require.ErrorAs(t, err, &notLeader)
+			require.Equal(t, leader.Id(), notLeader.Leader)
+		}
+	}
+}
+
+func TestPartitionAndHeal(t *testing.T) {
+	cluster := newTestCluster(t, "a", "b", "c")
+	oldLeader := cluster.leader(t, "a", "b", "c")
+	propose(t, oldLeader, "x")
+
+	others := []string{}
+	for id := range cluster.nodes {
+		if id != oldLeader.Id() {
+			others = append(others, id)
+		}
+	}
+	cluster.network.Partition([]string{oldLeader.Id()}, others)
+
+	// the minority can't commit anything.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := oldLeader.Propose(ctx, []byte("lost"))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	newLeader := cluster.leader(t, others...)
+	require.NotEqual(t, oldLeader.Id(), newLeader.Id())
+	propose(t, newLeader, "y")
+
+	// the old leader steps down and drops its uncommitted entry.
+	cluster.network.Heal()
+	cluster.leader(t, "a", "b", "c")
+	cluster.requireApplied(t, []string{"x", "y"}, "a", "b", "c")
+}
+
+func TestSnapshotCatchUp(t *testing.T) {
+	cluster := newTestCluster(t, "a", "b", "c")
+	leader := cluster.leader(t, "a", "b", "c")
+
+	lagging := "a"
+	if leader.Id() == lagging {
+		lagging = "b"
+	}
+	online := []string{}
+	for id := range cluster.nodes {
+		if id != lagging {
+			online = append(online, id)
+		}
+	}
+	cluster.network.Partition(online)
+
+	expected := []string{}
+	for i := 0; i < 20; i++ {
+		command := fmt.Sprint(i)
+		propose(t, leader, command)
+		expected = append(expected, command)
+	}
+	require.Eventually(t, func() bool { return leader.Status().SnapshotIndex > 10 }, 5*time.Second, 5*time.Millisecond)
+
+	// the entries it misses are compacted, it gets the snapshot.
+	cluster.network.Heal()
+	cluster.requireApplied(t, expected, "a", "b", "c")
+	require.Greater(t, cluster.nodes[lagging].Status().SnapshotIndex, uint64(0))
+}
+
+func TestSnapshotOutcomeUnknown(t *testing.T) {
+	cluster := newTestCluster(t, "a", "b", "c")
+	oldLeader := cluster.leader(t, "a", "b", "c")
+	others := []string{}
+	for id := range cluster.nodes {
+		if id != oldLeader.Id() {
+			others = append(others, id)
+		}
+	}
+	cluster.network.Partition([]string{oldLeader.Id()}, others)
+
+	proposed := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err := oldLeader.Propose(ctx, []byte("unknown"))
+		proposed <- err
+	}()
+
+	newLeader := cluster.leader(t, others...)
+	for i := 0; i < 20; i++ {
+		propose(t, newLeader, fmt.Sprint(i))
+	}
+
+	// the old leader catches up from a snapshot covering its proposal, which it
+	// can't tell was committed or not.
+	cluster.network.Heal()
+	select {
+	case err := <-proposed:
+		require.ErrorIs(t, err, raft.ErrProposalOutcomeUnknown)
+	case <-time.After(5 * time.Second):
+		t.Fatal("proposal still waiting")
+	}
+}
+
+func TestMembershipChanges(t *testing.T) {
+	cluster := newTestCluster(t, "a", "b", "c")
+	leader := cluster.leader(t, "a", "b", "c")
+	for i := 0; i < 10; i++ {
+		propose(t, leader, fmt.Sprint(i))
+	}
+
+	cluster.start(t, "d", nil)
+	require.NoError(t, leader.AddMember(context.Background(), "d"))
+	propose(t, leader, "after")
+	expected := cluster.machines[leader.Id()].list()
+	cluster.requireApplied(t, expected, "a", "b", "c", "d")
+	require.ElementsMatch(t, []string{"a", "b", "c", "d"}, cluster.nodes["d"].Status().Members)
+
+	// the leader removes itself, the others carry on.
+	require.NoError(t, leader.RemoveMember(context.Background(), leader.Id()))
+	remaining := []string{}
+	for _, id := range []string{"a", "b", "c", "d"} {
+		if id != leader.Id() {
+			remaining = append(remaining, id)
+		}
+	}
+	newLeader := cluster.leader(t, remaining...)
+	require.NotEqual(t, leader.Id(), newLeader.Id())
+	require.ElementsMatch(t, remaining, newLeader.Status().Members)
+	propose(t, newLeader, "without")
+	cluster.requireApplied(t, append(expected, "without"), remaining...)
+}
+
+func TestRestartKeepsLog(t *testing.T) {
+	folder := t.TempDir()
+	ids := []string{"a", "b", "c"}
+	network := raft.NewNetwork()
+	start := func(id string) (*raft.Node, *listMachine, *raft.FileStorage) {
+		storage, err := raft.NewFileStorage(filepath.Join(folder, id+".jsonl"))
+		require.NoError(t, err)
+		machine := &listMachine{}
+		config := raft.Config{Id: id, Members: ids, TickInterval: 2 * time.Millisecond, SnapshotThreshold: 5}
+		node, err := raft.NewDurableNode(config, storage, machine, network.Transport())
+		require.NoError(t, err)
+		network.Add(node)
+		node.Start()
+		return node, machine, storage
+	}
+
+	cluster := &testCluster{network: network, nodes: map[string]*raft.Node{}, machines: map[string]*listMachine{}}
+	storages := map[string]*raft.FileStorage{}
+	for _, id := range ids {
+		cluster.nodes[id], cluster.machines[id], storages[id] = start(id)
+	}
+	leader := cluster.leader(t, ids...)
+	expected := []string{}
+	for i := 0; i < 8; i++ {
+		command := fmt.Sprint(i)
+		propose(t, leader, command)
+		expected = append(expected, command)
+	}
+	cluster.requireApplied(t, expected, ids...)
+	term := leader.Status().Term
+
+	// the whole cluster restarts with empty state machines.
+	for _, id := range ids {
+		cluster.nodes[id].Stop()
+		require.NoError(t, storages[id].Close())
+	}
+	for _, id := range ids {
+		cluster.nodes[id], cluster.machines[id], storages[id] = start(id)
+		require.GreaterOrEqual(t, cluster.nodes[id].Status().Term, term)
+		t.Cleanup(cluster.nodes[id].Stop)
+		t.Cleanup(func() { storages[id].Close() })
+	}
+	leader = cluster.leader(t, ids...)
+	require.Greater(t, leader.Status().Term, term)
+	propose(t, leader, "8")
+	cluster.requireApplied(t, append(expected, "8"), ids...)
+}
+
+// Transport keeping the messages sent.
+type recordingTransport struct {
+	messages chan raft.Message
+}
+
+func (t recordingTransport) Send(msg raft.Message) {
+	t.messages <- msg
+}
+
+func TestRestartKeepsVote(t *testing.T) {
+	storage, err := raft.NewFileStorage(filepath.Join(t.TempDir(), "raft_state.jsonl"))
+	require.NoError(t, err)
+	t.Cleanup(func() { storage.Close() })
+	require.NoError(t, storage.SaveState(raft.HardState{Term: 3, VotedFor: "b"}))
+
+	transport := recordingTransport{make(chan raft.Message, 10)}
+	config := raft.Config{Id: "a", Members: []string{"a", "b", "c"}, TickInterval: time.Hour}
+	node, err := raft.NewDurableNode(config, storage, &listMachine{}, transport)
+	require.NoError(t, err)
+	node.Start()
+	t.Cleanup(node.Stop)
+	require.Equal(t, uint64(3), node.Status().Term)
+
+	// the node voted for b before restarting, so it can't vote for c in the same term.
+	node.Step(raft.Message{Type: raft.MsgVote, From: "c", To: "a", Term: 3})
+	response := <-transport.messages
+	require.Equal(t, raft.MsgVoteResponse, response.Type)
+	require.False(t, response.Success)
+
+	node.Step(raft.Message{Type: raft.MsgVote, From: "b", To: "a", Term: 3})
+	response = <-transport.messages
+	require.True(t, response.Success)
+}
+
+// Storage failing every save.
+type failingStorage struct{}
+
+func (failingStorage) Load() (raft.HardState, *raft.Snapshot, []raft.Entry, error) {
+	return raft.HardState{}, nil, nil, nil
+}
+func (failingStorage) SaveState(state raft.HardState) error { return errors.New("disk full") }
+func (failingStorage) Append(entries []raft.Entry) error    { return errors.New("disk full") }
+func (failingStorage) SaveSnapshot(state raft.HardState, snapshot *raft.Snapshot, entries []raft.Entry) error {
+	return errors.New("disk full")
+}
+
+func TestStorageFailureStopsNode(t *testing.T) {
+	config := raft.Config{Id: "a", Members: []string{"a"}, TickInterval: 2 * time.Millisecond}
+	node, err := raft.NewDurableNode(config, failingStorage{}, &listMachine{}, raft.NewNetwork().Transport())
+	require.NoError(t, err)
+	node.Start()
+	t.Cleanup(node.Stop)
+
+	// the node can't save the vote for itself, so it never leads.
+	require.Eventually(t, func() bool { return node.Status().Failure != nil }, 5*time.Second, 5*time.Millisecond)
+	require.ErrorIs(t, node.Status().Failure, raft.ErrStorageFailed)
+	require.Equal(t, uint64(1), node.Status().Term)
+	_, err = node.Propose(context.Background(), []byte("x"))
+	require.ErrorIs(t, err, raft.ErrStorageFailed)
+}
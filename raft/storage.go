@@ -0,0 +1,222 @@
+package raft
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Term of a node and whom it voted for in it.
+type HardState struct {
+	Term     uint64
+	VotedFor string
+}
+
+// Durable state of a node, so that a restarted node neither votes twice in a term
+// nor forgets entries it acknowledged. Saves must reach stable storage before they
+// return, the node makes them before sending the messages that depend on them.
+type Storage interface {
+	// State saved so far, a zero state without snapshot nor entries for a new node.
+	Load() (HardState, *Snapshot, []Entry, error)
+	SaveState(state HardState) error
+	// Appends the entries, replacing the saved ones from the index of the first on.
+	Append(entries []Entry) error
+	// Replaces everything saved with the state, the snapshot and the entries following it.
+	SaveSnapshot(state HardState, snapshot *Snapshot, entries []Entry) error
+}
+
+// Storage keeping the state of a node in a file of JSON lines, synced on every save.
+// Saves append lines, saving a snapshot rewrites the file.
+type FileStorage struct {
+	filename string
+	file     *os.File
+
+	state    HardState
+	snapshot *Snapshot
+	entries  []Entry
+}
+
+// Line of the storage file. The last state and snapshot are the current ones, an
+// entry replaces the ones from its index on.
+type storageRecord struct {
+	State    *HardState `json:"state,omitempty"`
+	Snapshot *Snapshot  `json:"snapshot,omitempty"`
+	Entry    *Entry     `json:"entry,omitempty"`
+}
+
+// Opens the storage file, created if it doesn't exist.
+func NewFileStorage(filename string) (*FileStorage, error) {
+	storage := &FileStorage{filename: filename}
+	if err := storage.replay(); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open raft storage: %w", err)
+	}
+	storage.file = file
+	return storage, nil
+}
+
+func (s *FileStorage) Load() (HardState, *Snapshot, []Entry, error) {
+	return s.state, s.snapshot, append([]Entry(nil), s.entries...), nil
+}
+
+func (s *FileStorage) SaveState(state HardState) error {
+	return s.append(storageRecord{State: &state})
+}
+
+func (s *FileStorage) Append(entries []Entry) error {
+	records := make([]storageRecord, len(entries))
+	for i := range entries {
+		records[i] = storageRecord{Entry: &entries[i]}
+	}
+	return s.append(records...)
+}
+
+// Writes a new file next to the current one, then renames it over it, so a crash
+// leaves one or the other.
+func (s *FileStorage) SaveSnapshot(state HardState, snapshot *Snapshot, entries []Entry) error {
+	data, err := encodeRecords(storageRecord{State: &state}, storageRecord{Snapshot: snapshot})
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		line, err := encodeRecords(storageRecord{Entry: &entries[i]})
+		if err != nil {
+			return err
+		}
+		data = append(data, line...)
+	}
+
+	tmp := s.filename + ".tmp"
+	if err := writeSynced(tmp, data); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.filename); err != nil {
+		return fmt.Errorf("couldn't replace raft storage: %w", err)
+	}
+	if err := syncDir(filepath.Dir(s.filename)); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(s.filename, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("couldn't open raft storage: %w", err)
+	}
+	s.file.Close()
+	s.file = file
+	s.state, s.snapshot, s.entries = state, snapshot, append([]Entry(nil), entries...)
+	return nil
+}
+
+func (s *FileStorage) Close() error {
+	return s.file.Close()
+}
+
+// Reads the file. A crash while appending can leave a torn last line without its
+// newline, which is cut off: it was never synced, so nothing depended on it.
+func (s *FileStorage) replay() error {
+	data, err := os.ReadFile(s.filename)
+	if err != nil {
+		return err
+	}
+	complete := bytes.LastIndexByte(data, '\n') + 1
+
+	decoder := json.NewDecoder(bytes.NewReader(data[:complete]))
+	for decoder.More() {
+		record := storageRecord{}
+		if err := decoder.Decode(&record); err != nil {
+			return fmt.Errorf("couldn't read raft storage: %w", err)
+		}
+		switch {
+		case record.State != nil:
+			s.state = *record.State
+		case record.Snapshot != nil:
+			s.snapshot = record.Snapshot
+			s.entries = nil
+		case record.Entry != nil:
+			s.entries = appendEntry(s.entries, *record.Entry)
+		}
+	}
+
+	if complete < len(data) {
+		if err := os.Truncate(s.filename, int64(complete)); err != nil {
+			return fmt.Errorf("couldn't truncate raft storage: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *FileStorage) append(records ...storageRecord) error {
+	data, err := encodeRecords(records...)
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(data); err != nil {
+		return fmt.Errorf("couldn't write raft storage: %w", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("couldn't sync raft storage: %w", err)
+	}
+	for _, record := range records {
+		switch {
+		case record.State != nil:
+			s.state = *record.State
+		case record.Entry != nil:
+			s.entries = appendEntry(s.entries, *record.Entry)
+		}
+	}
+	return nil
+}
+
+// Drops the entries from the index of the new one on, then appends it.
+func appendEntry(entries []Entry, entry Entry) []Entry {
+	for len(entries) > 0 && entries[len(entries)-1].Index >= entry.Index {
+		entries = entries[:len(entries)-1]
+	}
+	return append(entries, entry)
+}
+
+func encodeRecords(records ...storageRecord) ([]byte, error) {
+	data := []byte{}
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't marshal raft storage record: %w", err)
+		}
+		data = append(append(data, line...), '\n')
+	}
+	return data, nil
+}
+
+func writeSynced(filename string, data []byte) error {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("couldn't write raft storage: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("couldn't write raft storage: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("couldn't sync raft storage: %w", err)
+	}
+	return nil
+}
+
+// Makes a rename in the folder durable.
+func syncDir(dir string) error {
+	folder, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("couldn't sync raft storage folder: %w", err)
+	}
+	defer folder.Close()
+	if err := folder.Sync(); err != nil {
+		return fmt.Errorf("couldn't sync raft storage folder: %w", err)
+	}
+	return nil
+}
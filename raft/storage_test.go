@@ -0,0 +1,77 @@
+package raft_test
+
+import (
+	"go-grpc-pcbook/raft"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStorage(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "raft_state.jsonl")
+	storage, err := raft.NewFileStorage(filename)
+	require.NoError(t, err)
+	state, snapshot, entries, err := storage.Load()
+	require.NoError(t, err)
+	require.Equal(t, raft.HardState{}, state)
+	require.Nil(t, snapshot)
+	require.Empty(t, entries)
+
+	require.NoError(t, storage.SaveState(raft.HardState{Term: 1, VotedFor: "a"}))
+	require.NoError(t, storage.Append([]raft.Entry{{Index: 1, Term: 1}, {Index: 2, Term: 1, Data: []byte("x")}, {Index: 3, Term: 1}}))
+	// a new leader replaced the last entries.
+	require.NoError(t, storage.SaveState(raft.HardState{Term: 2}))
+	require.NoError(t, storage.Append([]raft.Entry{{Index: 3, Term: 2, Data: []byte("y")}}))
+	require.NoError(t, storage.Close())
+
+	storage, err = raft.NewFileStorage(filename)
+	require.NoError(t, err)
+	state, snapshot, entries, err = storage.Load()
+	require.NoError(t, err)
+	require.Equal(t, raft.HardState{Term: 2}, state)
+	require.Nil(t, snapshot)
+	require.Equal(t, []raft.Entry{{Index: 1, Term: 1}, {Index: 2, Term: 1, Data: []byte("x")}, {Index: 3, Term: 2, Data: []byte("y")}}, entries)
+
+	saved := &raft.Snapshot{Index: 2, Term: 1, Members: []string{"a", "b", "c"}, Data: []byte("x")}
+	require.NoError(t, storage.SaveSnapshot(raft.HardState{Term: 2}, saved, entries[2:]))
+	require.NoError(t, storage.Append([]raft.Entry{{Index: 4, Term: 2}}))
+	require.NoError(t, storage.Close())
+
+	storage, err = raft.NewFileStorage(filename)
+	require.NoError(t, err)
+	t.Cleanup(func() { storage.Close() })
+	state, snapshot, entries, err = storage.Load()
+	require.NoError(t, err)
+	require.Equal(t, raft.HardState{Term: 2}, state)
+	require.Equal(t, saved, snapshot)
+	require.Equal(t, []raft.Entry{{Index: 3, Term: 2, Data: []byte("y")}, {Index: 4, Term: 2}}, entries)
+}
+
+func TestFileStorageTornLine(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "raft_state.jsonl")
+	storage, err := raft.NewFileStorage(filename)
+	require.NoError(t, err)
+	require.NoError(t, storage.Append([]raft.Entry{{Index: 1, Term: 1}}))
+	require.NoError(t, storage.Close())
+
+	// a crash cut the last line short.
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"entry":{"Index":2,"Te`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	storage, err = raft.NewFileStorage(filename)
+	require.NoError(t, err)
+	require.NoError(t, storage.Append([]raft.Entry{{Index: 2, Term: 1}}))
+	require.NoError(t, storage.Close())
+
+	storage, err = raft.NewFileStorage(filename)
+	require.NoError(t, err)
+	t.Cleanup(func() { storage.Close() })
+	_, _, entries, err := storage.Load()
+	require.NoError(t, err)
+	require.Equal(t, []raft.Entry{{Index: 1, Term: 1}, {Index: 2, Term: 1}}, entries)
+}
@@ -0,0 +1,421 @@
+package serializer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Column header to field path, like "Cores" to "cpu.cores". Repeated messages
+// take the element index as a path segment, like "gpu.0.brand".
+type CSVMapping map[string]string
+
+// Parses mappings written as "Brand=brand,Cores=cpu.cores".
+func ParseCSVMapping(s string) (CSVMapping, error) {
+	mapping := CSVMapping{}
+	if strings.TrimSpace(s) == "" {
+		return mapping, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		column, path, ok := strings.Cut(pair, "=")
+		column, path = strings.TrimSpace(column), strings.TrimSpace(path)
+		if !ok || column == "" || path == "" {
+			return nil, fmt.Errorf("invalid CSV mapping %q, expected column=field.path", pair)
+		}
+		mapping[column] = path
+	}
+	return mapping, nil
+}
+
+// Reads a message from every row of a CSV file with a header. Columns missing
+// from the mapping are skipped, and without a mapping the headers are the field
+// paths. Empty cells leave the field unset.
+type CSVReader struct {
+	reader  *csv.Reader
+	mapping CSVMapping
+	// field path of every column, empty for skipped ones.
+	paths       []string
+	headerNames []string
+	record      int
+}
+
+func NewCSVReader(r io.Reader, mapping CSVMapping) *CSVReader {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	return &CSVReader{reader: reader, mapping: mapping}
+}
+
+func (r *CSVReader) Read(message proto.Message) error {
+	if r.paths == nil {
+		if err := r.readHeader(message.ProtoReflect().Descriptor()); err != nil {
+			return err
+		}
+	}
+
+	row, err := r.reader.Read()
+	if err == io.EOF {
+		return io.EOF
+	}
+	r.record++
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) && errors.Is(parseErr.Err, csv.ErrFieldCount) {
+			return &RecordError{Record: r.record, Err: err}
+		}
+		return fmt.Errorf("couldn't read CSV: %w", err)
+	}
+
+	proto.Reset(message)
+	for column, value := range row {
+		if r.paths[column] == "" || value == "" {
+			continue
+		}
+		if err := setField(message.ProtoReflect(), r.paths[column], value); err != nil {
+			return &RecordError{Record: r.record, Err: fmt.Errorf("column %q: %w", r.header(column), err)}
+		}
+	}
+	return nil
+}
+
+func (r *CSVReader) readHeader(descriptor protoreflect.MessageDescriptor) error {
+	header, err := r.reader.Read()
+	if err != nil {
+		return fmt.Errorf("couldn't read CSV header: %w", err)
+	}
+	r.reader.FieldsPerRecord = len(header)
+
+	paths := make([]string, len(header))
+	for column, name := range header {
+		path := name
+		if len(r.mapping) > 0 {
+			path = r.mapping[name]
+		}
+		if path == "" {
+			continue
+		}
+		if err := checkFieldPath(descriptor, path, len(header)); err != nil {
+			return fmt.Errorf("column %q: %w", name, err)
+		}
+		paths[column] = path
+	}
+	for column := range r.mapping {
+		if !contains(header, column) {
+			return fmt.Errorf("column %q of the mapping is not in the CSV header", column)
+		}
+	}
+
+	r.paths = paths
+	r.headerNames = header
+	return nil
+}
+
+func (r *CSVReader) header(column int) string {
+	return r.headerNames[column]
+}
+
+// Fails early for paths naming fields the message doesn't have. Every element of a
+// repeated field needs a column, so element indexes are below the number of columns.
+func checkFieldPath(descriptor protoreflect.MessageDescriptor, path string, columns int) error {
+	segments := strings.Split(path, ".")
+	for i := 0; i < len(segments); i++ {
+		field := findField(descriptor, segments[i])
+		if field == nil {
+			return fmt.Errorf("%s has no field %q", descriptor.FullName(), segments[i])
+		}
+		last := i == len(segments)-1
+		if field.IsList() && field.Message() != nil {
+			if last || !isIndex(segments[i+1]) {
+				return fmt.Errorf("repeated field %q needs an element index, like %s.0", segments[i], segments[i])
+			}
+			if index, _ := strconv.Atoi(segments[i+1]); index >= columns {
+				return fmt.Errorf("element index %d of repeated field %q is out of range for %d columns", index, segments[i], columns)
+			}
+			i++
+			last = i == len(segments)-1
+		}
+		if last {
+			return nil
+		}
+		if field.Message() == nil || field.IsMap() {
+			return fmt.Errorf("field %q has no sub fields", segments[i])
+		}
+		descriptor = field.Message()
+	}
+	return nil
+}
+
+// Sets the field at path from its text value, creating the messages on the way.
+func setField(message protoreflect.Message, path string, value string) error {
+	segments := strings.Split(path, ".")
+	for i := 0; i < len(segments); i++ {
+		field := findField(message.Descriptor(), segments[i])
+		last := i == len(segments)-1
+
+		if field.IsList() && field.Message() != nil {
+			index, _ := strconv.Atoi(segments[i+1])
+			list := message.Mutable(field).List()
+			for list.Len() <= index {
+				list.AppendMutable()
+			}
+			i++
+			if i == len(segments)-1 {
+				return unmarshalText(list.Get(index).Message().Interface(), value)
+			}
+			message = list.Get(index).Message()
+			continue
+		}
+
+		if !last {
+			message = message.Mutable(field).Message()
+			continue
+		}
+
+		switch {
+		case field.IsList():
+			list := message.Mutable(field).List()
+			for _, item := range strings.Split(value, ";") {
+				v, err := parseScalar(field, strings.TrimSpace(item))
+				if err != nil {
+					return err
+				}
+				list.Append(v)
+			}
+		case field.Message() != nil:
+			return unmarshalText(message.Mutable(field).Message().Interface(), value)
+		default:
+			v, err := parseScalar(field, value)
+			if err != nil {
+				return err
+			}
+			message.Set(field, v)
+		}
+	}
+	return nil
+}
+
+func parseScalar(field protoreflect.FieldDescriptor, value string) (protoreflect.Value, error) {
+	switch field.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(value), nil
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes([]byte(value)), nil
+	case protoreflect.BoolKind:
+		v, err := strconv.ParseBool(value)
+		return protoreflect.ValueOfBool(v), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		v, err := strconv.ParseInt(value, 10, 32)
+		return protoreflect.ValueOfInt32(int32(v)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		v, err := strconv.ParseInt(value, 10, 64)
+		return protoreflect.ValueOfInt64(v), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		v, err := strconv.ParseUint(value, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(v)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		v, err := strconv.ParseUint(value, 10, 64)
+		return protoreflect.ValueOfUint64(v), err
+	case protoreflect.FloatKind:
+		v, err := strconv.ParseFloat(value, 32)
+		return protoreflect.ValueOfFloat32(float32(v)), err
+	case protoreflect.DoubleKind:
+		v, err := strconv.ParseFloat(value, 64)
+		return protoreflect.ValueOfFloat64(v), err
+	case protoreflect.EnumKind:
+		// by name like GIGABYTE, or by number.
+		if enumValue := field.Enum().Values().ByName(protoreflect.Name(strings.ToUpper(value))); enumValue != nil {
+			return protoreflect.ValueOfEnum(enumValue.Number()), nil
+		}
+		v, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || field.Enum().Values().ByNumber(protoreflect.EnumNumber(v)) == nil {
+			return protoreflect.Value{}, fmt.Errorf("%q is not a value of %s", value, field.Enum().FullName())
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(v)), nil
+	default:
+		return protoreflect.Value{}, fmt.Errorf("unsupported field kind %s", field.Kind())
+	}
+}
+
+// Messages in a single cell use their JSON string form, like timestamps in RFC 3339.
+func unmarshalText(message proto.Message, value string) error {
+	return protojson.Unmarshal([]byte(strconv.Quote(value)), message)
+}
+
+// Fields go by their proto or JSON name.
+func findField(descriptor protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	if field := descriptor.Fields().ByName(protoreflect.Name(name)); field != nil {
+		return field
+	}
+	return descriptor.Fields().ByJSONName(name)
+}
+
+func isIndex(segment string) bool {
+	index, err := strconv.Atoi(segment)
+	return err == nil && index >= 0
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Writes every message as a CSV row, nested fields flattened into columns named
+// by their field path like the ones CSVReader reads without a mapping.
+type CSVWriter struct {
+	writer  *csv.Writer
+	columns []string
+	index   map[string]int
+	started bool
+}
+
+// The header is written with the first message.
+func NewCSVWriter(w io.Writer, columns []string) *CSVWriter {
+	index := make(map[string]int, len(columns))
+	for column, path := range columns {
+		index[path] = column
+	}
+	return &CSVWriter{writer: csv.NewWriter(w), columns: columns, index: index}
+}
+
+// Fails for a message with values in no column, like a third GPU when only
+// gpu.0 and gpu.1 are columns, rather than dropping them.
+func (w *CSVWriter) Write(message proto.Message) error {
+	if !w.started {
+		if err := w.writer.Write(w.columns); err != nil {
+			return fmt.Errorf("couldn't write CSV header: %w", err)
+		}
+		w.started = true
+	}
+
+	values := make(map[string]string)
+	if err := flatten(message.ProtoReflect(), "", values); err != nil {
+		return err
+	}
+	row := make([]string, len(w.columns))
+	for path, value := range values {
+		column, ok := w.index[path]
+		if !ok {
+			return fmt.Errorf("field %s has no CSV column", path)
+		}
+		row[column] = value
+	}
+
+	if err := w.writer.Write(row); err != nil {
+		return fmt.Errorf("couldn't write CSV: %w", err)
+	}
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// Field paths of every value a message can hold, in field order. Repeated
+// messages get columns for their first repeated elements.
+func FlattenedColumns(descriptor protoreflect.MessageDescriptor, repeated int) []string {
+	return flattenedColumns(descriptor, "", repeated, nil)
+}
+
+func flattenedColumns(descriptor protoreflect.MessageDescriptor, prefix string, repeated int, columns []string) []string {
+	fields := descriptor.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		path := prefix + string(field.Name())
+
+		switch {
+		case field.IsMap():
+			continue
+		case field.IsList() && field.Message() != nil:
+			for element := 0; element < repeated; element++ {
+				elementPath := fmt.Sprintf("%s.%d", path, element)
+				if isSingleValue(field.Message()) {
+					columns = append(columns, elementPath)
+				} else {
+					columns = flattenedColumns(field.Message(), elementPath+".", repeated, columns)
+				}
+			}
+		case field.Message() != nil && !isSingleValue(field.Message()):
+			columns = flattenedColumns(field.Message(), path+".", repeated, columns)
+		default:
+			columns = append(columns, path)
+		}
+	}
+	return columns
+}
+
+// Adds the text value of every populated field of message to values, by field path.
+func flatten(message protoreflect.Message, prefix string, values map[string]string) error {
+	var err error
+	message.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		path := prefix + string(field.Name())
+
+		switch {
+		case field.IsMap():
+			err = fmt.Errorf("map field %s can't be written as CSV", path)
+		case field.IsList() && field.Message() != nil:
+			list := value.List()
+			for element := 0; element < list.Len() && err == nil; element++ {
+				elementPath := fmt.Sprintf("%s.%d", path, element)
+				if isSingleValue(field.Message()) {
+					values[elementPath], err = formatValue(field, list.Get(element))
+				} else {
+					err = flatten(list.Get(element).Message(), elementPath+".", values)
+				}
+			}
+		case field.IsList():
+			list := value.List()
+			items := make([]string, list.Len())
+			for element := 0; element < list.Len() && err == nil; element++ {
+				items[element], err = formatValue(field, list.Get(element))
+			}
+			values[path] = strings.Join(items, ";")
+		case field.Message() != nil && !isSingleValue(field.Message()):
+			err = flatten(value.Message(), path+".", values)
+		default:
+			values[path], err = formatValue(field, value)
+		}
+		return err == nil
+	})
+	return err
+}
+
+// Inverse of parseScalar and unmarshalText.
+func formatValue(field protoreflect.FieldDescriptor, value protoreflect.Value) (string, error) {
+	switch field.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		data, err := protojson.Marshal(value.Message().Interface())
+		if err != nil {
+			return "", fmt.Errorf("couldn't marshall %s to JSON: %w", field.Name(), err)
+		}
+		if text, err := strconv.Unquote(string(data)); err == nil {
+			return text, nil
+		}
+		return string(data), nil
+	case protoreflect.EnumKind:
+		if enumValue := field.Enum().Values().ByNumber(value.Enum()); enumValue != nil {
+			return string(enumValue.Name()), nil
+		}
+		return strconv.Itoa(int(value.Enum())), nil
+	case protoreflect.BytesKind:
+		return string(value.Bytes()), nil
+	case protoreflect.FloatKind:
+		return strconv.FormatFloat(value.Float(), 'g', -1, 32), nil
+	case protoreflect.DoubleKind:
+		return strconv.FormatFloat(value.Float(), 'g', -1, 64), nil
+	default:
+		return value.String(), nil
+	}
+}
+
+// Well known types like timestamps fit in one cell.
+func isSingleValue(descriptor protoreflect.MessageDescriptor) bool {
+	return descriptor.FullName().Parent() == "google.protobuf"
+}
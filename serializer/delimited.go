@@ -0,0 +1,79 @@
+package serializer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// Largest message a delimited reader accepts, so a broken length can't exhaust memory.
+const maxDelimitedSize = 64 << 20
+
+// Writes binary messages each preceded by its varint encoded size.
+type DelimitedWriter struct {
+	w io.Writer
+}
+
+func NewDelimitedWriter(w io.Writer) *DelimitedWriter {
+	return &DelimitedWriter{w: w}
+}
+
+func (w *DelimitedWriter) Write(message proto.Message) error {
+	data, err := proto.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("couldn't marshall proto message to binary: %w", err)
+	}
+
+	buffer := protowire.AppendVarint(make([]byte, 0, binary.MaxVarintLen64+len(data)), uint64(len(data)))
+	_, err = w.w.Write(append(buffer, data...))
+	if err != nil {
+		return fmt.Errorf("couldn't write binary data: %w", err)
+	}
+	return nil
+}
+
+// Reads messages written by a DelimitedWriter.
+type DelimitedReader struct {
+	r      *bufio.Reader
+	record int
+}
+
+func NewDelimitedReader(r io.Reader) *DelimitedReader {
+	return &DelimitedReader{r: bufio.NewReader(r)}
+}
+
+func (r *DelimitedReader) Read(message proto.Message) error {
+	size, err := binary.ReadUvarint(r.r)
+	if err == io.EOF {
+		return io.EOF
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't read message size: %w", truncated(err))
+	}
+	if size > maxDelimitedSize {
+		return fmt.Errorf("message size %d is larger than %d", size, maxDelimitedSize)
+	}
+
+	r.record++
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return fmt.Errorf("couldn't read message %d: %w", r.record, truncated(err))
+	}
+	// the size was right, so the next message can still be read.
+	if err := proto.Unmarshal(data, message); err != nil {
+		return &RecordError{Record: r.record, Err: fmt.Errorf("couldn't unmarshall binary to proto message: %w", err)}
+	}
+	return nil
+}
+
+func truncated(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -1,16 +1,144 @@
 package serializer
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 )
 
+// Options shared by every JSON writer so field names and enums look the same everywhere.
+var jsonMarshaler = protojson.MarshalOptions{
+	UseEnumNumbers:  false,
+	EmitUnpopulated: true,
+	Indent:          " ",
+	UseProtoNames:   true,
+}
+
+// How JSON readers treat fields the message doesn't have.
+type UnknownFields int
+
+const (
+	RejectUnknownFields UnknownFields = iota
+	DiscardUnknownFields
+)
+
 func ProtobufToJson(message proto.Message) ([]byte, error) {
-	marshaler := protojson.MarshalOptions{
-		UseEnumNumbers:  false,
-		EmitUnpopulated: true,
-		Indent:          " ",
-		UseProtoNames:   true,
-	}
+	return jsonMarshaler.Marshal(message)
+}
+
+// Inverse of ProtobufToJson, also reading camelCase field names and enum numbers.
+func JsonToProtobuf(data []byte, message proto.Message, unknown UnknownFields) error {
+	return jsonUnmarshaler(unknown).Unmarshal(data, message)
+}
+
+func jsonUnmarshaler(unknown UnknownFields) protojson.UnmarshalOptions {
+	return protojson.UnmarshalOptions{DiscardUnknown: unknown == DiscardUnknownFields}
+}
+
+// Same as ProtobufToJson but on a single line, for line oriented outputs.
+func ProtobufToCompactJson(message proto.Message) ([]byte, error) {
+	marshaler := jsonMarshaler
+	marshaler.Indent = ""
+	marshaler.Multiline = false
 	return marshaler.Marshal(message)
 }
+
+// Reads the messages of a JSON array, like [{"brand": "Apple"}, {"brand": "Dell"}].
+type JSONArrayReader struct {
+	Unknown UnknownFields
+	decoder *json.Decoder
+	record  int
+	started bool
+}
+
+func NewJSONArrayReader(r io.Reader) *JSONArrayReader {
+	return &JSONArrayReader{decoder: json.NewDecoder(r)}
+}
+
+func (r *JSONArrayReader) Read(message proto.Message) error {
+	if !r.started {
+		token, err := r.decoder.Token()
+		if err != nil {
+			return fmt.Errorf("couldn't read JSON array: %w", err)
+		}
+		if token != json.Delim('[') {
+			return fmt.Errorf("couldn't read JSON array: found %v instead", token)
+		}
+		r.started = true
+	}
+
+	if !r.decoder.More() {
+		if _, err := r.decoder.Token(); err != nil {
+			return fmt.Errorf("couldn't read JSON array: %w", err)
+		}
+		return io.EOF
+	}
+
+	r.record++
+	var data json.RawMessage
+	if err := r.decoder.Decode(&data); err != nil {
+		return fmt.Errorf("couldn't read JSON array: %w", err)
+	}
+	if err := JsonToProtobuf(data, message, r.Unknown); err != nil {
+		return &RecordError{Record: r.record, Err: fmt.Errorf("couldn't unmarshall JSON to proto message: %w", err)}
+	}
+	return nil
+}
+
+// Writes one compact JSON message per line.
+type JSONLinesWriter struct {
+	w io.Writer
+}
+
+func NewJSONLinesWriter(w io.Writer) *JSONLinesWriter {
+	return &JSONLinesWriter{w: w}
+}
+
+func (w *JSONLinesWriter) Write(message proto.Message) error {
+	data, err := ProtobufToCompactJson(message)
+	if err != nil {
+		return fmt.Errorf("couldn't marshall proto message to JSON: %w", err)
+	}
+	_, err = w.w.Write(append(data, '\n'))
+	if err != nil {
+		return fmt.Errorf("couldn't write JSON data: %w", err)
+	}
+	return nil
+}
+
+// Reads one JSON message per line, skipping blank lines.
+type JSONLinesReader struct {
+	Unknown UnknownFields
+	scanner *bufio.Scanner
+	record  int
+}
+
+func NewJSONLinesReader(r io.Reader) *JSONLinesReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxDelimitedSize)
+	return &JSONLinesReader{scanner: scanner}
+}
+
+func (r *JSONLinesReader) Read(message proto.Message) error {
+	for r.scanner.Scan() {
+		line := bytes.TrimSpace(r.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		r.record++
+		if err := JsonToProtobuf(line, message, r.Unknown); err != nil {
+			return &RecordError{Record: r.record, Err: fmt.Errorf("couldn't unmarshall JSON to proto message: %w", err)}
+		}
+		return nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return fmt.Errorf("couldn't read JSON lines: %w", err)
+	}
+	return io.EOF
+}
@@ -47,3 +47,70 @@ func ReadProtobufFromBinaryFile(filename string, message proto.Message) error {
 	}
 	return nil
 }
+
+func ReadProtobufFromJSONFile(filename string, message proto.Message, unknown UnknownFields) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("couldn't read JSON data from file: %w", err)
+	}
+
+	err = JsonToProtobuf(data, message, unknown)
+	if err != nil {
+		return fmt.Errorf("couldn't unmarshall JSON to proto message: %w", err)
+	}
+	return nil
+}
+
+func WriteProtobufToTextFile(message proto.Message, filename string) error {
+	data, err := ProtobufToText(message)
+	if err != nil {
+		return fmt.Errorf("couldn't marshall proto message to text: %w", err)
+	}
+
+	err = ioutil.WriteFile(filename, data, 0644)
+	if err != nil {
+		return fmt.Errorf("couldn't write text data to file: %w", err)
+	}
+
+	return nil
+}
+
+func ReadProtobufFromTextFile(filename string, message proto.Message) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("couldn't read text data from file: %w", err)
+	}
+
+	err = TextToProtobuf(data, message)
+	if err != nil {
+		return fmt.Errorf("couldn't unmarshall text to proto message: %w", err)
+	}
+	return nil
+}
+
+func WriteProtobufToYAMLFile(message proto.Message, filename string) error {
+	data, err := ProtobufToYaml(message)
+	if err != nil {
+		return fmt.Errorf("couldn't marshall proto message to YAML: %w", err)
+	}
+
+	err = ioutil.WriteFile(filename, data, 0644)
+	if err != nil {
+		return fmt.Errorf("couldn't write YAML data to file: %w", err)
+	}
+
+	return nil
+}
+
+func ReadProtobufFromYAMLFile(filename string, message proto.Message, unknown UnknownFields) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("couldn't read YAML data from file: %w", err)
+	}
+
+	err = YamlToProtobuf(data, message, unknown)
+	if err != nil {
+		return fmt.Errorf("couldn't unmarshall YAML to proto message: %w", err)
+	}
+	return nil
+}
@@ -1,9 +1,13 @@
 package serializer_test
 
 import (
+	"bytes"
+	"fmt"
 	"go-grpc-pcbook/pb"
 	"go-grpc-pcbook/sample"
 	"go-grpc-pcbook/serializer"
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -68,4 +72,199 @@ func TestFileSerializer(t *testing.T) {
 		assertNoError(t, err)
 	})
 
+	t.Run("Writing and reading the same from JSON file.", func(t *testing.T) {
+		jsonFile := "../tmp/laptop.json"
+
+		laptop1 := sample.NewLaptop()
+		laptop2 := &pb.Laptop{}
+
+		assertNoError(t, serializer.WriteProtobufToJSONFile(laptop1, jsonFile))
+		assertNoError(t, serializer.ReadProtobufFromJSONFile(jsonFile, laptop2, serializer.RejectUnknownFields))
+
+		require.True(t, proto.Equal(laptop1, laptop2))
+	})
+
+	t.Run("Reading JSON with unknown fields.", func(t *testing.T) {
+		data := []byte(`{"brand": "Apple", "colour": "silver"}`)
+
+		laptop := &pb.Laptop{}
+		require.Error(t, serializer.JsonToProtobuf(data, laptop, serializer.RejectUnknownFields))
+
+		assertNoError(t, serializer.JsonToProtobuf(data, laptop, serializer.DiscardUnknownFields))
+		require.Equal(t, "Apple", laptop.GetBrand())
+	})
+
+	t.Run("Writing and reading the same from text file.", func(t *testing.T) {
+		textFile := "../tmp/laptop.txt"
+
+		laptop1 := sample.NewLaptop()
+		laptop2 := &pb.Laptop{}
+
+		assertNoError(t, serializer.WriteProtobufToTextFile(laptop1, textFile))
+		assertNoError(t, serializer.ReadProtobufFromTextFile(textFile, laptop2))
+
+		require.True(t, proto.Equal(laptop1, laptop2))
+	})
+
+	t.Run("Writing and reading the same from YAML file.", func(t *testing.T) {
+		yamlFile := "../tmp/laptop.yaml"
+
+		laptop1 := sample.NewLaptop()
+		laptop2 := &pb.Laptop{}
+
+		assertNoError(t, serializer.WriteProtobufToYAMLFile(laptop1, yamlFile))
+		assertNoError(t, serializer.ReadProtobufFromYAMLFile(yamlFile, laptop2, serializer.RejectUnknownFields))
+
+		require.True(t, proto.Equal(laptop1, laptop2))
+	})
+
+	t.Run("Reading hand written YAML.", func(t *testing.T) {
+		data := []byte("brand: Dell\nname: \"2022\"\ncpu:\n  cores: 8\nmemory: {value: 16, unit: GIGABYTE}\nupdated_at: 2022-06-01T10:00:00Z\n")
+
+		laptop := &pb.Laptop{}
+		assertNoError(t, serializer.YamlToProtobuf(data, laptop, serializer.RejectUnknownFields))
+		require.Equal(t, "2022", laptop.GetName())
+		require.EqualValues(t, 8, laptop.GetCpu().GetCores())
+		require.Equal(t, pb.Memory_GIGABYTE, laptop.GetMemory().GetUnit())
+		require.EqualValues(t, 1654077600, laptop.GetUpdatedAt().GetSeconds())
+	})
+
+}
+
+func TestJSONArrayReader(t *testing.T) {
+	laptop1 := sample.NewLaptop()
+	json1, err := serializer.ProtobufToJson(laptop1)
+	assertNoError(t, err)
+
+	data := fmt.Sprintf(`[%s, {"brand": 42}, {"brand": "Dell"}]`, json1)
+	reader := serializer.NewJSONArrayReader(strings.NewReader(data))
+
+	laptop2 := &pb.Laptop{}
+	assertNoError(t, reader.Read(laptop2))
+	require.True(t, proto.Equal(laptop1, laptop2))
+
+	var recordErr *serializer.RecordError
+	require.ErrorAs(t, reader.Read(laptop2), &recordErr)
+	require.Equal(t, 2, recordErr.Record)
+
+	assertNoError(t, reader.Read(laptop2))
+	require.Equal(t, "Dell", laptop2.GetBrand())
+	assertError(t, reader.Read(laptop2), io.EOF)
+}
+
+func TestCSVReader(t *testing.T) {
+	data := strings.Join([]string{
+		"Brand,Model,Cores,RAM,GPU,Weight,Updated,Notes",
+		"Apple,Macbook Air,8,16 GIGABYTE,Apple,1.24,2022-06-01T10:00:00Z,ignored",
+		"Dell,XPS,eight,8 GIGABYTE,,,,",
+		"Lenovo,Thinkpad,4,,NVIDIA,,,",
+	}, "\n")
+	mapping, err := serializer.ParseCSVMapping("Brand=brand, Model=name, Cores=cpu.cores, GPU=gpu.0.brand, Weight=weight_kg, Updated=updated_at")
+	assertNoError(t, err)
+	reader := serializer.NewCSVReader(strings.NewReader(data), mapping)
+
+	laptop := &pb.Laptop{}
+	assertNoError(t, reader.Read(laptop))
+	require.Equal(t, "Macbook Air", laptop.GetName())
+	require.EqualValues(t, 8, laptop.GetCpu().GetCores())
+	require.Equal(t, "Apple", laptop.GetGpu()[0].GetBrand())
+	require.Equal(t, 1.24, laptop.GetWeightKg())
+	require.EqualValues(t, 1654077600, laptop.GetUpdatedAt().GetSeconds())
+
+	var recordErr *serializer.RecordError
+	require.ErrorAs(t, reader.Read(laptop), &recordErr)
+	require.Equal(t, 2, recordErr.Record)
+	require.Contains(t, recordErr.Error(), "Cores")
+
+	assertNoError(t, reader.Read(laptop))
+	require.Equal(t, "Lenovo", laptop.GetBrand())
+	require.Nil(t, laptop.GetUpdatedAt())
+	assertError(t, reader.Read(laptop), io.EOF)
+
+	// without a mapping the headers are field paths.
+	reader = serializer.NewCSVReader(strings.NewReader("brand,memory.value,memory.unit\nAsus,16,GIGABYTE"), nil)
+	assertNoError(t, reader.Read(laptop))
+	require.Equal(t, pb.Memory_GIGABYTE, laptop.GetMemory().GetUnit())
+
+	reader = serializer.NewCSVReader(strings.NewReader("brand,colour\nAsus,red"), nil)
+	require.ErrorContains(t, reader.Read(laptop), "colour")
+
+	reader = serializer.NewCSVReader(strings.NewReader("brand,storage.999999999.driver\nAsus,SSD"), nil)
+	require.ErrorContains(t, reader.Read(laptop), "out of range")
+}
+
+func TestDelimitedReader(t *testing.T) {
+	laptops := []*pb.Laptop{sample.NewLaptop(), sample.NewLaptop(), sample.NewLaptop()}
+
+	buffer := &bytes.Buffer{}
+	writer := serializer.NewDelimitedWriter(buffer)
+	for _, laptop := range laptops {
+		assertNoError(t, writer.Write(laptop))
+	}
+
+	reader := serializer.NewDelimitedReader(bytes.NewReader(buffer.Bytes()))
+	for _, laptop := range laptops {
+		other := &pb.Laptop{}
+		assertNoError(t, reader.Read(other))
+		require.True(t, proto.Equal(laptop, other))
+	}
+	assertError(t, reader.Read(&pb.Laptop{}), io.EOF)
+
+	truncated := serializer.NewDelimitedReader(bytes.NewReader(buffer.Bytes()[:buffer.Len()-1]))
+	for range laptops[1:] {
+		assertNoError(t, truncated.Read(&pb.Laptop{}))
+	}
+	require.ErrorIs(t, truncated.Read(&pb.Laptop{}), io.ErrUnexpectedEOF)
+}
+
+func TestJSONLines(t *testing.T) {
+	laptops := []*pb.Laptop{sample.NewLaptop(), sample.NewLaptop()}
+
+	buffer := &bytes.Buffer{}
+	writer := serializer.NewJSONLinesWriter(buffer)
+	for _, laptop := range laptops {
+		assertNoError(t, writer.Write(laptop))
+	}
+	require.Equal(t, 2, strings.Count(buffer.String(), "\n"))
+
+	reader := serializer.NewJSONLinesReader(strings.NewReader(buffer.String() + "\n{\"cpu\": 1}\n"))
+	for _, laptop := range laptops {
+		other := &pb.Laptop{}
+		assertNoError(t, reader.Read(other))
+		require.True(t, proto.Equal(laptop, other))
+	}
+
+	var recordErr *serializer.RecordError
+	require.ErrorAs(t, reader.Read(&pb.Laptop{}), &recordErr)
+	require.Equal(t, 3, recordErr.Record)
+	assertError(t, reader.Read(&pb.Laptop{}), io.EOF)
+}
+
+func TestCSVWriter(t *testing.T) {
+	laptops := []*pb.Laptop{sample.NewLaptop(), sample.NewLaptop()}
+	laptops[1].Weight = &pb.Laptop_WeightLb{WeightLb: 4.5}
+	columns := serializer.FlattenedColumns((&pb.Laptop{}).ProtoReflect().Descriptor(), 2)
+	require.Contains(t, columns, "cpu.cores")
+	require.Contains(t, columns, "gpu.1.memory.unit")
+	require.Contains(t, columns, "updated_at")
+
+	buffer := &bytes.Buffer{}
+	writer := serializer.NewCSVWriter(buffer, columns)
+	for _, laptop := range laptops {
+		assertNoError(t, writer.Write(laptop))
+	}
+
+	// the flattened columns read back without a mapping.
+	reader := serializer.NewCSVReader(bytes.NewReader(buffer.Bytes()), nil)
+	for _, laptop := range laptops {
+		other := &pb.Laptop{}
+		assertNoError(t, reader.Read(other))
+		require.True(t, proto.Equal(laptop, other), "%v\n%v", laptop, other)
+	}
+	assertError(t, reader.Read(&pb.Laptop{}), io.EOF)
+
+	laptop := sample.NewLaptop()
+	laptop.Gpu = append(laptop.Gpu, laptop.Gpu[0], laptop.Gpu[0])
+	err := serializer.NewCSVWriter(&bytes.Buffer{}, columns).Write(laptop)
+	require.ErrorContains(t, err, "gpu.2")
 }
@@ -0,0 +1,33 @@
+package serializer
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Reads messages one at a time from a file holding many of them.
+type MessageReader interface {
+	// Fills message with the next record. Returns io.EOF after the last one, or
+	// a *RecordError if only that record is wrong and reading can go on.
+	Read(message proto.Message) error
+}
+
+// Writes messages one at a time to a file holding many of them.
+type MessageWriter interface {
+	Write(message proto.Message) error
+}
+
+// A record that couldn't be turned into a message.
+type RecordError struct {
+	Record int // position in the file, from 1.
+	Err    error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("record %d: %v", e.Record, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
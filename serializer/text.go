@@ -0,0 +1,20 @@
+package serializer
+
+import (
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+var textMarshaler = prototext.MarshalOptions{
+	Multiline: true,
+	Indent:    "  ",
+}
+
+// Protobuf text format, the most readable one to edit by hand.
+func ProtobufToText(message proto.Message) ([]byte, error) {
+	return textMarshaler.Marshal(message)
+}
+
+func TextToProtobuf(data []byte, message proto.Message) error {
+	return prototext.Unmarshal(data, message)
+}
@@ -0,0 +1,60 @@
+package serializer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// YAML holding the same fields as ProtobufToJson, in block style and field order.
+func ProtobufToYaml(message proto.Message) ([]byte, error) {
+	data, err := ProtobufToJson(message)
+	if err != nil {
+		return nil, err
+	}
+
+	// JSON is valid YAML, the parsed document only needs its style reset.
+	document := &yaml.Node{}
+	if err := yaml.Unmarshal(data, document); err != nil {
+		return nil, fmt.Errorf("couldn't convert JSON to YAML: %w", err)
+	}
+	resetStyle(document)
+
+	buffer := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(buffer)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(document); err != nil {
+		return nil, fmt.Errorf("couldn't write YAML: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("couldn't write YAML: %w", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+func YamlToProtobuf(data []byte, message proto.Message, unknown UnknownFields) error {
+	var document interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("couldn't parse YAML: %w", err)
+	}
+	if document == nil {
+		document = map[string]interface{}{}
+	}
+
+	data, err := json.Marshal(document)
+	if err != nil {
+		return fmt.Errorf("couldn't convert YAML to JSON: %w", err)
+	}
+	return JsonToProtobuf(data, message, unknown)
+}
+
+// Leaves quoting to the encoder, which still quotes strings that would read as another type.
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetStyle(child)
+	}
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"go-grpc-pcbook/pb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// LaptopService calls changing the catalog.
+var auditedMethods = map[string]bool{
+	"/" + pb.LaptopService_ServiceDesc.ServiceName + "/CreateLaptop":  true,
+	"/" + pb.LaptopService_ServiceDesc.ServiceName + "/UpdateLaptop":  true,
+	"/" + pb.LaptopService_ServiceDesc.ServiceName + "/DeleteLaptop":  true,
+	"/" + pb.LaptopService_ServiceDesc.ServiceName + "/UploadImage":   true,
+	"/" + pb.LaptopService_ServiceDesc.ServiceName + "/RateLaptop":    true,
+	"/" + pb.LaptopService_ServiceDesc.ServiceName + "/ImportLaptops": true,
+}
+
+// Records every mutating LaptopService call to the audit log once it's done.
+type AuditInterceptor struct {
+	auditLog *FileAuditLog
+}
+
+func NewAuditInterceptor(auditLog *FileAuditLog) *AuditInterceptor {
+	return &AuditInterceptor{auditLog}
+}
+
+func (a *AuditInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !auditedMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		res, err := handler(ctx, req)
+
+		// created laptops may get their id from the server.
+		laptopIds := &idSet{}
+		if created, ok := res.(*pb.CreateLaptopResponse); ok && created != nil {
+			laptopIds.add(created.GetId())
+		} else {
+			laptopIds.add(requestLaptopId(req))
+		}
+		a.record(ctx, info.FullMethod, laptopIds.ids, err)
+		return res, err
+	}
+}
+
+func (a *AuditInterceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !auditedMethods[info.FullMethod] {
+			return handler(srv, stream)
+		}
+
+		audited := &auditedStream{ServerStream: stream, laptopIds: &idSet{}}
+		err := handler(srv, audited)
+		a.record(stream.Context(), info.FullMethod, audited.laptopIds.ids, err)
+		return err
+	}
+}
+
+func (a *AuditInterceptor) record(ctx context.Context, method string, laptopIds []string, err error) {
+	entry := &pb.AuditEntry{
+		Time:      timestamppb.Now(),
+		Actor:     CallerIdentity(ctx),
+		Method:    method,
+		LaptopIds: laptopIds,
+		Code:      status.Code(err).String(),
+		RequestId: requestId(ctx),
+	}
+	if err := a.auditLog.Append(entry); err != nil {
+		loggerFromContext(ctx).Error("couldn't write audit entry", "error", err)
+	}
+}
+
+func requestLaptopId(req interface{}) string {
+	switch req := req.(type) {
+	case interface{ GetLaptop() *pb.Laptop }:
+		return req.GetLaptop().GetId()
+	case interface{ GetLaptopId() string }:
+		return req.GetLaptopId()
+	case interface{ GetInfo() *pb.ImageInfo }:
+		return req.GetInfo().GetLaptopId()
+	case *pb.DeleteLaptopRequest:
+		return req.GetId()
+	default:
+		return ""
+	}
+}
+
+// Collects the laptop ids of the messages received on a stream, and of imported laptops.
+type auditedStream struct {
+	grpc.ServerStream
+	laptopIds *idSet
+}
+
+func (s *auditedStream) RecvMsg(msg interface{}) error {
+	err := s.ServerStream.RecvMsg(msg)
+	if err == nil {
+		s.laptopIds.add(requestLaptopId(msg))
+	}
+	return err
+}
+
+// Imported laptops may get their id from the server.
+func (s *auditedStream) SendMsg(msg interface{}) error {
+	if imported, ok := msg.(*pb.ImportLaptopsResponse); ok {
+		for _, result := range imported.GetResults() {
+			s.laptopIds.add(result.GetId())
+		}
+	}
+	return s.ServerStream.SendMsg(msg)
+}
+
+// Ids in first seen order, without duplicates or empty ones.
+type idSet struct {
+	ids  []string
+	seen map[string]bool
+}
+
+func (s *idSet) add(id string) {
+	if id == "" || s.seen[id] {
+		return
+	}
+	if s.seen == nil {
+		s.seen = make(map[string]bool)
+	}
+	s.seen[id] = true
+	s.ids = append(s.ids, id)
+}
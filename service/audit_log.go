@@ -0,0 +1,175 @@
+package service
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-grpc-pcbook/pb"
+	"go-grpc-pcbook/serializer"
+	"io"
+	"os"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+var ErrAuditLogTampered = errors.New("audit log hash chain is broken.")
+
+// Append only audit log file, one JSON entry per line. Each entry holds the
+// hash of the previous one, so editing or removing a line breaks the chain.
+type FileAuditLog struct {
+	mutex    sync.Mutex
+	filename string
+	file     *os.File
+	sequence uint64
+	lastHash string
+	// bytes of the entries written so far, readers don't look past them.
+	size int64
+}
+
+// Opens the log, verifying the entries already in it.
+func NewFileAuditLog(filename string) (*FileAuditLog, error) {
+	auditLog := &FileAuditLog{filename: filename}
+
+	err := auditLog.scan(-1, func(entry *pb.AuditEntry, end int64) error {
+		auditLog.sequence = entry.GetSequence()
+		auditLog.lastHash = entry.GetHash()
+		auditLog.size = end
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	auditLog.file, err = os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open audit log: %w", err)
+	}
+	return auditLog, nil
+}
+
+// Chains the entry to the log and writes it to disk before returning.
+func (a *FileAuditLog) Append(entry *pb.AuditEntry) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	entry = proto.Clone(entry).(*pb.AuditEntry)
+	entry.Sequence = a.sequence + 1
+	entry.PreviousHash = a.lastHash
+	hash, err := auditHash(entry)
+	if err != nil {
+		return err
+	}
+	entry.Hash = hash
+
+	line, err := serializer.ProtobufToCompactJson(entry)
+	if err != nil {
+		return fmt.Errorf("couldn't marshal audit entry: %w", err)
+	}
+	written, err := a.file.Write(append(line, '\n'))
+	a.size += int64(written)
+	if err != nil {
+		return fmt.Errorf("couldn't write audit entry: %w", err)
+	}
+	if err := a.file.Sync(); err != nil {
+		return fmt.Errorf("couldn't sync audit log: %w", err)
+	}
+
+	a.sequence = entry.Sequence
+	a.lastHash = entry.Hash
+	return nil
+}
+
+// Calls found with every entry matching the request as it reads them, checking the
+// chain on the way. Only the entries written before the call are read, without
+// holding the log, so queries don't block appends.
+func (a *FileAuditLog) Query(req *pb.QueryAuditLogRequest, found func(entry *pb.AuditEntry) error) error {
+	return a.scan(a.written(), func(entry *pb.AuditEntry, end int64) error {
+		if req.GetActor() != "" && entry.GetActor() != req.GetActor() {
+			return nil
+		}
+		if req.GetFrom() != nil && entry.GetTime().AsTime().Before(req.GetFrom().AsTime()) {
+			return nil
+		}
+		if req.GetTo() != nil && !entry.GetTime().AsTime().Before(req.GetTo().AsTime()) {
+			return nil
+		}
+		return found(entry)
+	})
+}
+
+// Checks the hash chain of the entries written so far.
+func (a *FileAuditLog) Verify() error {
+	return a.scan(a.written(), func(entry *pb.AuditEntry, end int64) error { return nil })
+}
+
+func (a *FileAuditLog) written() int64 {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.size
+}
+
+func (a *FileAuditLog) Close() error {
+	return a.file.Close()
+}
+
+// Visits the entries in the first size bytes of the file, the whole file if size is
+// negative, with the offset each one ends at.
+func (a *FileAuditLog) scan(size int64, visit func(entry *pb.AuditEntry, end int64) error) error {
+	file, err := os.Open(a.filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if size >= 0 {
+		r = io.LimitReader(file, size)
+	}
+	previousHash := ""
+	sequence := uint64(0)
+	end := int64(0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		end += int64(len(scanner.Bytes())) + 1
+		entry := &pb.AuditEntry{}
+		if err := protojson.Unmarshal(scanner.Bytes(), entry); err != nil {
+			return fmt.Errorf("%w: entry %d is unreadable: %v", ErrAuditLogTampered, sequence+1, err)
+		}
+
+		hash, err := auditHash(entry)
+		if err != nil {
+			return err
+		}
+		if entry.GetSequence() != sequence+1 || entry.GetPreviousHash() != previousHash || entry.GetHash() != hash {
+			return fmt.Errorf("%w: at entry %d", ErrAuditLogTampered, sequence+1)
+		}
+		sequence = entry.GetSequence()
+		previousHash = entry.GetHash()
+
+		if err := visit(entry, end); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("couldn't read audit log: %w", err)
+	}
+	return nil
+}
+
+// Hash of the deterministic binary encoding of the entry without its own hash.
+func auditHash(entry *pb.AuditEntry) (string, error) {
+	unhashed := proto.Clone(entry).(*pb.AuditEntry)
+	unhashed.Hash = ""
+
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(unhashed)
+	if err != nil {
+		return "", fmt.Errorf("couldn't marshal audit entry: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
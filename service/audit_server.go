@@ -0,0 +1,50 @@
+package service
+
+import (
+	"errors"
+	"go-grpc-pcbook/pb"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type AuditServer struct {
+	AuditLog *FileAuditLog
+}
+
+func NewAuditServer(auditLog *FileAuditLog) *AuditServer {
+	return &AuditServer{auditLog}
+}
+
+// Server streaming RPC sending audit entries matching the time range and actor.
+func (s *AuditServer) QueryAuditLog(req *pb.QueryAuditLogRequest, stream pb.AuditService_QueryAuditLogServer) error {
+	logger := loggerFromContext(stream.Context())
+	logger.Info("received query-audit-log request", "actor", req.GetActor())
+
+	if req.GetFrom() != nil && req.GetTo() != nil && !req.GetFrom().AsTime().Before(req.GetTo().AsTime()) {
+		return logError(logger, status.Error(codes.InvalidArgument, "from must be before to"))
+	}
+
+	// actors are qualified by their tenant, see CallerIdentity.
+	tenantId := TenantFromContext(stream.Context())
+	err := s.AuditLog.Query(req, func(entry *pb.AuditEntry) error {
+		if err := contextError(stream.Context(), logger); err != nil {
+			return err
+		}
+		if tenantId != "" && !strings.HasPrefix(entry.GetActor(), tenantId+"/") {
+			return nil
+		}
+		return stream.Send(&pb.QueryAuditLogResponse{Entry: entry})
+	})
+	if err != nil {
+		if errors.Is(err, ErrAuditLogTampered) {
+			return logError(logger, status.Errorf(codes.DataLoss, "%v", err))
+		}
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return logError(logger, status.Errorf(codes.Internal, "couldn't query audit log: %v", err))
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package service_test
+
+import (
+	"context"
+	"go-grpc-pcbook/pb"
+	"go-grpc-pcbook/sample"
+	"go-grpc-pcbook/service"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestAuditLog(t *testing.T) {
+	auditFile := filepath.Join(t.TempDir(), "audit.log")
+	auditLog, err := service.NewFileAuditLog(auditFile)
+	require.NoError(t, err)
+	defer auditLog.Close()
+	auditInterceptor := service.NewAuditInterceptor(auditLog)
+
+	laptopStore := service.NewMemoryLaptopStore()
+	laptopServer := service.NewLaptopServer(laptopStore, nil, service.NewMemoryRatingStore())
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(auditInterceptor.Unary()),
+		grpc.StreamInterceptor(auditInterceptor.Stream()),
+	)
+	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+	pb.RegisterAuditServiceServer(grpcServer, service.NewAuditServer(auditLog))
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	laptopClient := pb.NewLaptopServiceClient(conn)
+	auditClient := pb.NewAuditServiceClient(conn)
+
+	start := time.Now()
+	laptop := sample.NewLaptop()
+	laptop.Id = ""
+	created, err := laptopClient.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: laptop})
+	require.NoError(t, err)
+
+	stream, err := laptopClient.RateLaptop(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&pb.RateLaptopRequest{LaptopId: created.Id, Score: 8}))
+	require.NoError(t, stream.CloseSend())
+	for err == nil {
+		_, err = stream.Recv()
+	}
+	require.Equal(t, io.EOF, err)
+
+	_, err = laptopClient.DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{Id: "missing"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	// reads are not audited.
+	search, err := laptopClient.SearchLaptop(context.Background(), &pb.SearchLaptopRequest{})
+	require.NoError(t, err)
+	_, err = search.Recv()
+	require.Equal(t, io.EOF, err)
+
+	entries := queryAuditLog(t, auditClient, &pb.QueryAuditLogRequest{From: timestamppb.New(start)})
+	require.Len(t, entries, 3)
+	require.Equal(t, "/pcbook.LaptopService/CreateLaptop", entries[0].GetMethod())
+	require.Equal(t, []string{created.Id}, entries[0].GetLaptopIds())
+	require.Equal(t, "OK", entries[0].GetCode())
+	require.True(t, strings.HasPrefix(entries[0].GetActor(), "peer:"))
+	require.Equal(t, "/pcbook.LaptopService/RateLaptop", entries[1].GetMethod())
+	require.Equal(t, []string{created.Id}, entries[1].GetLaptopIds())
+	require.Equal(t, "NotFound", entries[2].GetCode())
+	require.Equal(t, entries[0].GetHash(), entries[1].GetPreviousHash())
+
+	require.Empty(t, queryAuditLog(t, auditClient, &pb.QueryAuditLogRequest{Actor: "user:nobody"}))
+	require.Empty(t, queryAuditLog(t, auditClient, &pb.QueryAuditLogRequest{To: timestamppb.New(start)}))
+
+	// reopening continues the same chain.
+	reopened, err := service.NewFileAuditLog(auditFile)
+	require.NoError(t, err)
+	require.NoError(t, reopened.Append(&pb.AuditEntry{Actor: "user:admin", Method: "manual"}))
+	require.NoError(t, reopened.Verify())
+	require.NoError(t, reopened.Close())
+
+	// editing a line breaks the chain.
+	data, err := os.ReadFile(auditFile)
+	require.NoError(t, err)
+	tampered := strings.Replace(string(data), created.Id, "00000000-0000-0000-0000-000000000000", 1)
+	require.NoError(t, os.WriteFile(auditFile, []byte(tampered), 0600))
+	require.ErrorIs(t, auditLog.Verify(), service.ErrAuditLogTampered)
+
+	query, err := auditClient.QueryAuditLog(context.Background(), &pb.QueryAuditLogRequest{})
+	require.NoError(t, err)
+	_, err = query.Recv()
+	require.Equal(t, codes.DataLoss, status.Code(err))
+
+	_, err = service.NewFileAuditLog(auditFile)
+	require.ErrorIs(t, err, service.ErrAuditLogTampered)
+}
+
+func TestAuditLogQueryDoesNotBlockAppends(t *testing.T) {
+	t.Parallel()
+
+	auditLog, err := service.NewFileAuditLog(filepath.Join(t.TempDir(), "audit.log"))
+	require.NoError(t, err)
+	defer auditLog.Close()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, auditLog.Append(&pb.AuditEntry{Actor: "user:admin", Method: "manual"}))
+	}
+
+	// entries appended while the query runs are left for the next one.
+	found := 0
+	err = auditLog.Query(&pb.QueryAuditLogRequest{}, func(entry *pb.AuditEntry) error {
+		found++
+		return auditLog.Append(&pb.AuditEntry{Actor: "user:admin", Method: "manual"})
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, found)
+	require.NoError(t, auditLog.Verify())
+
+	found = 0
+	err = auditLog.Query(&pb.QueryAuditLogRequest{}, func(entry *pb.AuditEntry) error {
+		found++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 6, found)
+}
+
+func queryAuditLog(t *testing.T, auditClient pb.AuditServiceClient, req *pb.QueryAuditLogRequest) []*pb.AuditEntry {
+	stream, err := auditClient.QueryAuditLog(context.Background(), req)
+	require.NoError(t, err)
+
+	entries := []*pb.AuditEntry{}
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return entries
+		}
+		require.NoError(t, err)
+		entries = append(entries, res.GetEntry())
+	}
+}
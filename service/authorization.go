@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// Roles callers can be granted.
+const (
+	// Manages the server: webhooks, backups and restores.
+	RoleAdmin = "admin"
+	// Runs the store: stock levels and order fulfillment.
+	RoleOperator = "operator"
+	// Servers of the deployment calling each other, like followers replicating their leader.
+	RoleCluster = "cluster"
+)
+
+// Grants roles to caller identities, like user:alice for a client certificate or
+// peer:10.0.0.5 for a client address, see CallerIdentity. Roles aren't qualified
+// by tenant, an admin is admin of every tenant. Its interceptors restrict methods
+// to the callers having their role.
+type Authorizer struct {
+	grants  map[string]map[string]bool // role -> identities
+	methods map[string]string          // full method or service prefix -> role
+}
+
+// Authorizer granting the identities of each role.
+func NewAuthorizer(grants map[string][]string) *Authorizer {
+	a := &Authorizer{grants: make(map[string]map[string]bool), methods: make(map[string]string)}
+	for role, identities := range grants {
+		a.grants[role] = make(map[string]bool)
+		for _, identity := range identities {
+			a.grants[role][identity] = true
+		}
+	}
+	return a
+}
+
+// Requires the role to call the method, a full name like /pcbook.BackupService/Backup,
+// or every method of a service given its prefix like /pcbook.BackupService/.
+func (a *Authorizer) Restrict(method string, role string) {
+	a.methods[method] = role
+}
+
+// Tells if the caller was granted the role. A nil Authorizer grants nothing.
+func (a *Authorizer) HasRole(ctx context.Context, role string) bool {
+	return a != nil && a.grants[role][peerIdentity(ctx)]
+}
+
+func (a *Authorizer) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := a.authorize(ctx, info.FullMethod); err != nil {
+			return nil, logError(loggerFromContext(ctx), err)
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (a *Authorizer) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := a.authorize(stream.Context(), info.FullMethod); err != nil {
+			return logError(loggerFromContext(stream.Context()), err)
+		}
+		return handler(srv, stream)
+	}
+}
+
+func (a *Authorizer) authorize(ctx context.Context, fullMethod string) error {
+	role, ok := a.methods[fullMethod]
+	if !ok {
+		role, ok = a.methods[fullMethod[:strings.LastIndex(fullMethod, "/")+1]]
+	}
+	if !ok || a.HasRole(ctx, role) {
+		return nil
+	}
+	return roleRequiredError(ctx, role)
+}
+
+func roleRequiredError(ctx context.Context, role string) error {
+	return statusWithDetails(codes.PermissionDenied, fmt.Sprintf("%s needs the %s role", peerIdentity(ctx), role),
+		errorInfo(ReasonRoleRequired, map[string]string{"role": role}),
+	)
+}
+
+// Parses comma separated identities, like "user:alice,peer:10.0.0.5".
+func ParseIdentities(text string) ([]string, error) {
+	identities := []string{}
+	if strings.TrimSpace(text) == "" {
+		return identities, nil
+	}
+	for _, identity := range strings.Split(text, ",") {
+		identity = strings.TrimSpace(identity)
+		if !strings.HasPrefix(identity, "user:") && !strings.HasPrefix(identity, "peer:") {
+			return nil, fmt.Errorf("invalid identity %q: expected user:<certificate common name> or peer:<address>", identity)
+		}
+		identities = append(identities, identity)
+	}
+	return identities, nil
+}
@@ -0,0 +1,51 @@
+package service_test
+
+import (
+	"context"
+	"go-grpc-pcbook/pb"
+	"go-grpc-pcbook/service"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthorizer(t *testing.T) {
+	dial := func(grants map[string][]string) (pb.WebhookServiceClient, pb.LaptopServiceClient) {
+		_, webhookServer := newTestDispatcher(t, t.TempDir())
+		authorizer := service.NewAuthorizer(grants)
+		authorizer.Restrict("/pcbook.WebhookService/", service.RoleAdmin)
+		authorizer.Restrict("/pcbook.LaptopService/DeleteLaptop", service.RoleAdmin)
+
+		grpcServer := grpc.NewServer(grpc.UnaryInterceptor(authorizer.Unary()), grpc.StreamInterceptor(authorizer.Stream()))
+		pb.RegisterWebhookServiceServer(grpcServer, webhookServer)
+		pb.RegisterLaptopServiceServer(grpcServer, service.NewLaptopServer(service.NewMemoryLaptopStore(), nil, service.NewMemoryRatingStore()))
+		conn := serveTest(t, grpcServer)
+		return pb.NewWebhookServiceClient(conn), pb.NewLaptopServiceClient(conn)
+	}
+
+	webhookClient, laptopClient := dial(map[string][]string{service.RoleAdmin: {"user:alice"}})
+	_, err := webhookClient.ListWebhooks(context.Background(), &pb.ListWebhooksRequest{})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	details := status.Convert(err).Details()
+	require.Len(t, details, 1)
+	require.Equal(t, service.ReasonRoleRequired, details[0].(*errdetails.ErrorInfo).GetReason())
+	_, err = laptopClient.DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{Id: "laptop"})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = laptopClient.CompareLaptops(context.Background(), &pb.CompareLaptopsRequest{})
+	require.NotEqual(t, codes.PermissionDenied, status.Code(err))
+
+	// plaintext callers are named by their address.
+	webhookClient, _ = dial(map[string][]string{service.RoleAdmin: {"peer:127.0.0.1", "peer:::1"}})
+	_, err = webhookClient.ListWebhooks(context.Background(), &pb.ListWebhooksRequest{})
+	require.NoError(t, err)
+
+	identities, err := service.ParseIdentities("user:alice, peer:10.0.0.5")
+	require.NoError(t, err)
+	require.Equal(t, []string{"user:alice", "peer:10.0.0.5"}, identities)
+	_, err = service.ParseIdentities("alice")
+	require.Error(t, err)
+}
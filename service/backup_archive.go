@@ -0,0 +1,288 @@
+package service
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-grpc-pcbook/pb"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+var ErrCorruptBackup = errors.New("corrupt backup archive.")
+
+const (
+	backupFormatVersion = 1
+	backupManifestFile  = "manifest.json"
+	backupCatalogFile   = "catalog.pb"
+	backupImageFolder   = "images/"
+	maxManifestSize     = 64 << 20
+)
+
+// First file of a backup archive, a tar file. Lists every other file with its checksum.
+type BackupManifest struct {
+	Version      int           `json:"version"`
+	CreatedAt    time.Time     `json:"created_at"`
+	Laptops      int           `json:"laptops"`
+	RatedLaptops int           `json:"rated_laptops"`
+	Images       []BackupImage `json:"images"`
+	Files        []BackupFile  `json:"files"`
+}
+
+type BackupImage struct {
+	Id       string `json:"id"`
+	LaptopId string `json:"laptop_id"`
+	Type     string `json:"type"`
+	File     string `json:"file"`
+}
+
+type BackupFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+// Writes the archive of a copy of the stores. Image files are read from the image
+// store, saved images never change.
+func writeBackup(w io.Writer, catalog *pb.BackupCatalog, images map[string]ImageInfo, imageStore ImageStore) error {
+	catalogData, err := proto.Marshal(catalog)
+	if err != nil {
+		return err
+	}
+	catalogSum := sha256.Sum256(catalogData)
+	manifest := &BackupManifest{
+		Version:      backupFormatVersion,
+		CreatedAt:    time.Now().UTC(),
+		Laptops:      len(catalog.GetLaptops()),
+		RatedLaptops: len(catalog.GetRatings()),
+		Images:       []BackupImage{},
+		Files:        []BackupFile{{Name: backupCatalogFile, Size: int64(len(catalogData)), Sha256: hex.EncodeToString(catalogSum[:])}},
+	}
+
+	imageIds := make([]string, 0, len(images))
+	for imageId := range images {
+		imageIds = append(imageIds, imageId)
+	}
+	sort.Strings(imageIds)
+	for _, imageId := range imageIds {
+		info := images[imageId]
+		file := backupImageFolder + imageId + info.Type
+		size, sum, err := hashImage(imageStore, imageId)
+		if err != nil {
+			return err
+		}
+		manifest.Images = append(manifest.Images, BackupImage{Id: imageId, LaptopId: info.LaptopId, Type: info.Type, File: file})
+		manifest.Files = append(manifest.Files, BackupFile{Name: file, Size: size, Sha256: sum})
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	archive := tar.NewWriter(w)
+	if err := writeTarFile(archive, backupManifestFile, int64(len(manifestData)), bytes.NewReader(manifestData)); err != nil {
+		return err
+	}
+	if err := writeTarFile(archive, backupCatalogFile, int64(len(catalogData)), bytes.NewReader(catalogData)); err != nil {
+		return err
+	}
+	for i, image := range manifest.Images {
+		data, err := imageStore.Open(image.Id)
+		if err != nil {
+			return err
+		}
+		err = writeTarFile(archive, image.File, manifest.Files[i+1].Size, data)
+		data.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+func hashImage(imageStore ImageStore, imageId string) (int64, string, error) {
+	data, err := imageStore.Open(imageId)
+	if err != nil {
+		return 0, "", err
+	}
+	defer data.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, data)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func writeTarFile(archive *tar.Writer, name string, size int64, data io.Reader) error {
+	header := &tar.Header{Name: name, Mode: 0644, Size: size, ModTime: time.Now()}
+	if err := archive.WriteHeader(header); err != nil {
+		return err
+	}
+	if _, err := io.Copy(archive, data); err != nil {
+		return fmt.Errorf("cannot write %s to archive: %w", name, err)
+	}
+	return nil
+}
+
+// Archive whose files all match its manifest.
+type backupArchive struct {
+	manifest *BackupManifest
+	catalog  *pb.BackupCatalog
+}
+
+// Reads the whole archive, checking every file against the manifest.
+// Fails with ErrCorruptBackup if any doesn't match.
+func verifyBackup(r io.Reader) (*backupArchive, error) {
+	archive := tar.NewReader(r)
+	header, err := archive.Next()
+	if err != nil || header.Name != backupManifestFile {
+		return nil, fmt.Errorf("the archive must start with %s: %w", backupManifestFile, ErrCorruptBackup)
+	}
+	manifestData, err := io.ReadAll(io.LimitReader(archive, maxManifestSize))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read manifest: %v: %w", err, ErrCorruptBackup)
+	}
+	manifest := &BackupManifest{}
+	if err := json.Unmarshal(manifestData, manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v: %w", err, ErrCorruptBackup)
+	}
+	if manifest.Version != backupFormatVersion {
+		return nil, fmt.Errorf("unsupported version %d: %w", manifest.Version, ErrCorruptBackup)
+	}
+
+	expected := make(map[string]BackupFile, len(manifest.Files))
+	for _, file := range manifest.Files {
+		expected[file.Name] = file
+	}
+	if _, ok := expected[backupCatalogFile]; !ok {
+		return nil, fmt.Errorf("manifest doesn't list %s: %w", backupCatalogFile, ErrCorruptBackup)
+	}
+	seen := make(map[string]bool, len(manifest.Files))
+	var catalogData bytes.Buffer
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", err, ErrCorruptBackup)
+		}
+		file, ok := expected[header.Name]
+		if !ok || seen[header.Name] {
+			return nil, fmt.Errorf("unexpected file %s: %w", header.Name, ErrCorruptBackup)
+		}
+		seen[header.Name] = true
+
+		hash := sha256.New()
+		w := io.Writer(hash)
+		if header.Name == backupCatalogFile {
+			w = io.MultiWriter(hash, &catalogData)
+		}
+		size, err := io.Copy(w, archive)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %s: %v: %w", header.Name, err, ErrCorruptBackup)
+		}
+		if size != file.Size || hex.EncodeToString(hash.Sum(nil)) != file.Sha256 {
+			return nil, fmt.Errorf("checksum mismatch for %s: %w", header.Name, ErrCorruptBackup)
+		}
+	}
+	for name := range expected {
+		if !seen[name] {
+			return nil, fmt.Errorf("missing file %s: %w", name, ErrCorruptBackup)
+		}
+	}
+	for _, image := range manifest.Images {
+		if _, ok := expected[image.File]; !ok {
+			return nil, fmt.Errorf("missing file %s of image %s: %w", image.File, image.Id, ErrCorruptBackup)
+		}
+	}
+
+	catalog := &pb.BackupCatalog{}
+	if err := proto.Unmarshal(catalogData.Bytes(), catalog); err != nil {
+		return nil, fmt.Errorf("invalid catalog: %v: %w", err, ErrCorruptBackup)
+	}
+	if len(catalog.GetLaptops()) != manifest.Laptops || len(catalog.GetRatings()) != manifest.RatedLaptops {
+		return nil, fmt.Errorf("catalog doesn't match the manifest counts: %w", ErrCorruptBackup)
+	}
+	backup := &backupArchive{manifest: manifest, catalog: catalog}
+	if err := backup.validate(); err != nil {
+		return nil, err
+	}
+	return backup, nil
+}
+
+// Checks the stores would take every laptop, rating and image, so a restore doesn't
+// stop half way through an archive. Ratings and images may be of deleted laptops.
+func (b *backupArchive) validate() error {
+	laptopIds := make(map[string]bool, len(b.catalog.GetLaptops()))
+	for _, laptop := range b.catalog.GetLaptops() {
+		if err := ValidateLaptop(laptop); err != nil {
+			return fmt.Errorf("laptop %s: %s: %w", laptop.GetId(), status.Convert(err).Message(), ErrCorruptBackup)
+		}
+		if _, err := uuid.Parse(laptop.GetId()); err != nil {
+			return fmt.Errorf("laptop id %q is not a valid UUID: %w", laptop.GetId(), ErrCorruptBackup)
+		}
+		if laptopIds[laptop.GetId()] {
+			return fmt.Errorf("duplicate laptop %s: %w", laptop.GetId(), ErrCorruptBackup)
+		}
+		laptopIds[laptop.GetId()] = true
+	}
+
+	ratedLaptops := make(map[string]bool, len(b.catalog.GetRatings()))
+	for _, rating := range b.catalog.GetRatings() {
+		if ratedLaptops[rating.GetLaptopId()] {
+			return fmt.Errorf("duplicate rating of laptop %s: %w", rating.GetLaptopId(), ErrCorruptBackup)
+		}
+		ratedLaptops[rating.GetLaptopId()] = true
+	}
+
+	imageIds := make(map[string]bool, len(b.manifest.Images))
+	for _, image := range b.manifest.Images {
+		if _, err := uuid.Parse(image.Id); err != nil {
+			return fmt.Errorf("image id %q is not a valid UUID: %w", image.Id, ErrCorruptBackup)
+		}
+		if !imageTypePattern.MatchString(image.Type) {
+			return fmt.Errorf("invalid type %q of image %s: %w", image.Type, image.Id, ErrCorruptBackup)
+		}
+		if imageIds[image.Id] {
+			return fmt.Errorf("duplicate image %s: %w", image.Id, ErrCorruptBackup)
+		}
+		imageIds[image.Id] = true
+	}
+	return nil
+}
+
+// Reads the archive again and hands every image of the manifest with its data.
+func (b *backupArchive) forEachImage(r io.Reader, found func(image BackupImage, data io.Reader) error) error {
+	images := make(map[string]BackupImage, len(b.manifest.Images))
+	for _, image := range b.manifest.Images {
+		images[image.File] = image
+	}
+
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if image, ok := images[header.Name]; ok {
+			if err := found(image, archive); err != nil {
+				return err
+			}
+		}
+	}
+}
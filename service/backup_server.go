@@ -0,0 +1,302 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"go-grpc-pcbook/pb"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	backupChunkSize       = 64 * 1024
+	defaultMaxArchiveSize = 1 << 30
+)
+
+// Admin RPCs to back up the catalog of a running server, and restore it.
+type BackupServer struct {
+	pb.UnimplementedBackupServiceServer
+	LaptopStore LaptopStore
+	RatingStore RatingStore
+	ImageStore  ImageStore
+	// Holds writes while the stores are copied, so laptops and ratings of a backup
+	// are from the same point in time. Optional, tenants' stores are copied without it.
+	Log *ReplicationLog
+	// Largest archive Restore accepts, in bytes.
+	MaxArchiveSize int64
+
+	// one restore at a time, from the emptiness check to the last write.
+	restoring sync.Mutex
+}
+
+func NewBackupServer(laptopStore LaptopStore, ratingStore RatingStore, imageStore ImageStore) *BackupServer {
+	return &BackupServer{LaptopStore: laptopStore, RatingStore: ratingStore, ImageStore: imageStore, MaxArchiveSize: defaultMaxArchiveSize}
+}
+
+// Streams a tar archive of the laptops, ratings and images, see BackupManifest.
+func (s *BackupServer) Backup(req *pb.BackupRequest, stream pb.BackupService_BackupServer) error {
+	ctx := stream.Context()
+	logger := loggerFromContext(ctx)
+	imageStore := tenantImageStore(ctx, s.ImageStore)
+
+	catalog := &pb.BackupCatalog{}
+	var images map[string]ImageInfo
+	copyStores := func() error {
+		var err error
+		catalog.Laptops, catalog.Ratings, images, err = s.current(ctx)
+		return err
+	}
+	var err error
+	if s.Log != nil && TenantFromContext(ctx) == "" {
+		_, err = s.Log.Snapshot(copyStores)
+	} else {
+		err = copyStores()
+	}
+	if err != nil {
+		if err := contextError(ctx, logger); err != nil {
+			return err
+		}
+		return logError(logger, status.Errorf(codes.Internal, "cannot copy stores: %v", err))
+	}
+
+	chunks := bufio.NewWriterSize(chunkWriter(func(chunk []byte) error {
+		return stream.Send(&pb.BackupResponse{Chunk: chunk})
+	}), backupChunkSize)
+	if err := writeBackup(chunks, catalog, images, imageStore); err != nil {
+		return logError(logger, status.Errorf(codes.Internal, "cannot write backup: %v", err))
+	}
+	if err := chunks.Flush(); err != nil {
+		return logError(logger, status.Errorf(codes.Unknown, "cannot send backup: %v", err))
+	}
+	logger.Info("backed up catalog", "laptops", len(catalog.Laptops), "rated_laptops", len(catalog.Ratings), "images", len(images))
+	return nil
+}
+
+// Checks an archive made by Backup and compares it with the server. Unless it's a
+// dry run, the archive is then restored, which the server must be empty for. Writes
+// made by other calls meanwhile aren't held, if one conflicts with the archive the
+// restore fails and what it wrote is removed.
+func (s *BackupServer) Restore(stream pb.BackupService_RestoreServer) error {
+	ctx := stream.Context()
+	logger := loggerFromContext(ctx)
+
+	req, err := stream.Recv()
+	if err != nil {
+		return logError(logger, status.Errorf(codes.Unknown, "cannot receive restore options: %v", err))
+	}
+	options := req.GetOptions()
+	if options == nil {
+		return logError(logger, status.Error(codes.InvalidArgument, "the first message must hold the restore options"))
+	}
+
+	// the archive is read twice, once to check it then to restore it.
+	file, err := os.CreateTemp("", "pcbook-restore-*.tar")
+	if err != nil {
+		return logError(logger, status.Errorf(codes.Internal, "cannot create temporary file: %v", err))
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	size := int64(0)
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return logError(logger, status.Errorf(codes.Unknown, "cannot receive chunk data: %v", err))
+		}
+		size += int64(len(req.GetChunk()))
+		if size > s.MaxArchiveSize {
+			return logError(logger, archiveTooLargeError(s.MaxArchiveSize))
+		}
+		if _, err := file.Write(req.GetChunk()); err != nil {
+			return logError(logger, status.Errorf(codes.Internal, "cannot write chunk data: %v", err))
+		}
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return logError(logger, status.Errorf(codes.Internal, "cannot read archive: %v", err))
+	}
+	archive, err := verifyBackup(file)
+	if errors.Is(err, ErrCorruptBackup) {
+		return logError(logger, statusWithDetails(codes.InvalidArgument, err.Error(), errorInfo(ReasonCorruptBackup, nil)))
+	}
+	if err != nil {
+		return logError(logger, status.Errorf(codes.Internal, "cannot read archive: %v", err))
+	}
+
+	s.restoring.Lock()
+	defer s.restoring.Unlock()
+	laptops, ratings, images, err := s.current(ctx)
+	if err != nil {
+		return logError(logger, status.Errorf(codes.Internal, "cannot copy stores: %v", err))
+	}
+	diff := backupDiff(archive, laptops, ratings, images)
+	if options.GetDryRun() {
+		return stream.SendAndClose(&pb.RestoreResponse{Diff: diff})
+	}
+	if len(laptops) > 0 || len(ratings) > 0 || len(images) > 0 {
+		return logError(logger, statusWithDetails(codes.FailedPrecondition, "backups can only be restored into an empty server, use a dry run to compare them",
+			errorInfo(ReasonRestoreTargetNotEmpty, nil),
+		))
+	}
+
+	err = s.restore(ctx, archive, file)
+	if errors.Is(err, ErrAlreadyExists) {
+		return logError(logger, statusWithDetails(codes.FailedPrecondition, "the server got a laptop or image of the backup while restoring it, nothing was restored",
+			errorInfo(ReasonRestoreTargetNotEmpty, nil),
+		))
+	}
+	if err != nil {
+		return logError(logger, status.Errorf(codes.Internal, "cannot restore backup: %v", err))
+	}
+	logger.Info("restored backup", "laptops", archive.manifest.Laptops, "rated_laptops", archive.manifest.RatedLaptops, "images", len(archive.manifest.Images))
+	return stream.SendAndClose(&pb.RestoreResponse{Diff: diff, Restored: true})
+}
+
+// Laptops, ratings and images of the stores the call works on.
+func (s *BackupServer) current(ctx context.Context) ([]*pb.Laptop, []*pb.LaptopRatingState, map[string]ImageInfo, error) {
+	laptops := []*pb.Laptop{}
+	err := tenantLaptopStore(ctx, s.LaptopStore).Search(ctx, unlimitedPrice(nil), func(laptop *pb.Laptop) error {
+		laptops = append(laptops, laptop)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ratings, err := tenantRatingStore(ctx, s.RatingStore).Snapshot()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	images, err := tenantImageStore(ctx, s.ImageStore).Images()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return laptops, ratings, images, nil
+}
+
+// Writes the archive to the stores, removing what it wrote if a write fails.
+func (s *BackupServer) restore(ctx context.Context, archive *backupArchive, file *os.File) error {
+	laptopStore := tenantLaptopStore(ctx, s.LaptopStore)
+	ratingStore := tenantRatingStore(ctx, s.RatingStore)
+	imageStore := tenantImageStore(ctx, s.ImageStore)
+	laptopIds, imageIds, ratingsRestored := []string{}, []string{}, false
+
+	err := func() error {
+		for _, laptop := range archive.catalog.GetLaptops() {
+			if err := laptopStore.Save(laptop); err != nil {
+				return err
+			}
+			laptopIds = append(laptopIds, laptop.GetId())
+		}
+		if err := ratingStore.Restore(archive.catalog.GetRatings()); err != nil {
+			return err
+		}
+		ratingsRestored = true
+
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		return archive.forEachImage(file, func(image BackupImage, data io.Reader) error {
+			if err := imageStore.SaveWithId(image.Id, image.LaptopId, image.Type, data); err != nil {
+				return err
+			}
+			imageIds = append(imageIds, image.Id)
+			return nil
+		})
+	}()
+	if err == nil {
+		return nil
+	}
+
+	logger := loggerFromContext(ctx)
+	for _, imageId := range imageIds {
+		if err := imageStore.Delete(imageId); err != nil {
+			logger.Error("cannot remove restored image", "image_id", imageId, "error", err)
+		}
+	}
+	if ratingsRestored {
+		if err := ratingStore.Restore(nil); err != nil {
+			logger.Error("cannot remove restored ratings", "error", err)
+		}
+	}
+	for _, laptopId := range laptopIds {
+		if err := laptopStore.Delete(laptopId); err != nil {
+			logger.Error("cannot remove restored laptop", "laptop_id", laptopId, "error", err)
+		}
+	}
+	return err
+}
+
+// What restoring the archive would change on the server.
+func backupDiff(archive *backupArchive, laptops []*pb.Laptop, ratings []*pb.LaptopRatingState, images map[string]ImageInfo) *pb.RestoreDiff {
+	diff := &pb.RestoreDiff{}
+
+	current := make(map[string]*pb.Laptop, len(laptops))
+	for _, laptop := range laptops {
+		current[laptop.GetId()] = laptop
+	}
+	for _, laptop := range archive.catalog.GetLaptops() {
+		if existing, ok := current[laptop.GetId()]; !ok {
+			diff.AddedLaptopIds = append(diff.AddedLaptopIds, laptop.GetId())
+		} else if !proto.Equal(existing, laptop) {
+			diff.ChangedLaptopIds = append(diff.ChangedLaptopIds, laptop.GetId())
+		}
+		delete(current, laptop.GetId())
+	}
+	for id := range current {
+		diff.RemovedLaptopIds = append(diff.RemovedLaptopIds, id)
+	}
+
+	currentRatings := make(map[string]*pb.LaptopRatingState, len(ratings))
+	for _, rating := range ratings {
+		currentRatings[rating.GetLaptopId()] = rating
+	}
+	for _, rating := range archive.catalog.GetRatings() {
+		if !proto.Equal(currentRatings[rating.GetLaptopId()], rating) {
+			diff.ChangedRatings++
+		}
+		delete(currentRatings, rating.GetLaptopId())
+	}
+	diff.ChangedRatings += uint32(len(currentRatings))
+
+	backupImages := make(map[string]bool, len(archive.manifest.Images))
+	for _, image := range archive.manifest.Images {
+		backupImages[image.Id] = true
+		if _, ok := images[image.Id]; !ok {
+			diff.AddedImageIds = append(diff.AddedImageIds, image.Id)
+		}
+	}
+	for imageId := range images {
+		if !backupImages[imageId] {
+			diff.RemovedImageIds = append(diff.RemovedImageIds, imageId)
+		}
+	}
+
+	for _, ids := range [][]string{diff.AddedLaptopIds, diff.ChangedLaptopIds, diff.RemovedLaptopIds, diff.AddedImageIds, diff.RemovedImageIds} {
+		sort.Strings(ids)
+	}
+	return diff
+}
+
+// Sends every write as backup chunks.
+type chunkWriter func(chunk []byte) error
+
+func (w chunkWriter) Write(p []byte) (int, error) {
+	for written := 0; written < len(p); {
+		end := min(len(p), written+backupChunkSize)
+		if err := w(p[written:end]); err != nil {
+			return written, err
+		}
+		written = end
+	}
+	return len(p), nil
+}
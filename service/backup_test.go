@@ -0,0 +1,180 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"go-grpc-pcbook/pb"
+	"go-grpc-pcbook/sample"
+	"go-grpc-pcbook/service"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type testBackupServer struct {
+	client      pb.BackupServiceClient
+	server      *service.BackupServer
+	laptopStore service.LaptopStore
+	ratingStore service.RatingStore
+	imageFolder string
+	imageStore  *service.DiskImageStore
+}
+
+func startTestBackupServer(t *testing.T) *testBackupServer {
+	replicationLog := service.NewReplicationLog(100)
+	server := &testBackupServer{
+		laptopStore: service.NewReplicatingLaptopStore(service.NewMemoryLaptopStore(), replicationLog),
+		ratingStore: service.NewReplicatingRatingStore(service.NewMemoryRatingStore(), replicationLog),
+		imageFolder: t.TempDir(),
+	}
+	server.imageStore = service.NewDiskImageStore(server.imageFolder)
+	backupServer := service.NewBackupServer(server.laptopStore, server.ratingStore, server.imageStore)
+	backupServer.Log = replicationLog
+	server.server = backupServer
+
+	grpcServer := grpc.NewServer()
+	pb.RegisterBackupServiceServer(grpcServer, backupServer)
+	server.client = pb.NewBackupServiceClient(serveTest(t, grpcServer))
+	return server
+}
+
+func backup(t *testing.T, backupClient pb.BackupServiceClient) []byte {
+	stream, err := backupClient.Backup(context.Background(), &pb.BackupRequest{})
+	require.NoError(t, err)
+	var archive bytes.Buffer
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return archive.Bytes()
+		}
+		require.NoError(t, err)
+		archive.Write(res.GetChunk())
+	}
+}
+
+func restore(t *testing.T, backupClient pb.BackupServiceClient, archive []byte, dryRun bool) (*pb.RestoreResponse, error) {
+	stream, err := backupClient.Restore(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&pb.RestoreRequest{Data: &pb.RestoreRequest_Options{Options: &pb.RestoreOptions{DryRun: dryRun}}}))
+	for len(archive) > 0 {
+		chunk := archive[:min(len(archive), 1000)]
+		require.NoError(t, stream.Send(&pb.RestoreRequest{Data: &pb.RestoreRequest_Chunk{Chunk: chunk}}))
+		archive = archive[len(chunk):]
+	}
+	return stream.CloseAndRecv()
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	source := startTestBackupServer(t)
+	first, second := sample.NewLaptop(), sample.NewLaptop()
+	require.NoError(t, source.laptopStore.Save(first))
+	require.NoError(t, source.laptopStore.Save(second))
+	_, err := source.ratingStore.Add("user:a", first.Id, 7)
+	require.NoError(t, err)
+	imageData := []byte("backed up image data")
+	imageId, err := source.imageStore.Save(first.Id, ".jpg", *bytes.NewBuffer(append([]byte(nil), imageData...)))
+	require.NoError(t, err)
+
+	archive := backup(t, source.client)
+
+	// a dry run only compares.
+	target := startTestBackupServer(t)
+	res, err := restore(t, target.client, archive, true)
+	require.NoError(t, err)
+	require.False(t, res.GetRestored())
+	require.ElementsMatch(t, []string{first.Id, second.Id}, res.GetDiff().GetAddedLaptopIds())
+	require.EqualValues(t, 1, res.GetDiff().GetChangedRatings())
+	require.Equal(t, []string{imageId}, res.GetDiff().GetAddedImageIds())
+	require.Zero(t, target.laptopStore.Count())
+
+	res, err = restore(t, target.client, archive, false)
+	require.NoError(t, err)
+	require.True(t, res.GetRestored())
+	restored, err := target.laptopStore.Find(second.Id)
+	require.NoError(t, err)
+	require.Equal(t, second.GetPrice(), restored.GetPrice())
+	scores, err := target.ratingStore.UserScores()
+	require.NoError(t, err)
+	require.Equal(t, map[string]map[string]float64{"user:a": {first.Id: 7}}, scores)
+	images, err := target.imageStore.Images()
+	require.NoError(t, err)
+	require.Equal(t, first.Id, images[imageId].LaptopId)
+	restoredImage, err := os.ReadFile(images[imageId].Path)
+	require.NoError(t, err)
+	require.Equal(t, imageData, restoredImage)
+
+	// the server now matches the backup, and isn't empty anymore.
+	res, err = restore(t, target.client, archive, true)
+	require.NoError(t, err)
+	require.Empty(t, res.GetDiff().GetAddedLaptopIds())
+	require.Empty(t, res.GetDiff().GetChangedLaptopIds())
+	require.Zero(t, res.GetDiff().GetChangedRatings())
+	require.Empty(t, res.GetDiff().GetAddedImageIds())
+	_, err = restore(t, target.client, archive, false)
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	corrupt := bytes.Replace(archive, imageData, []byte("tampered up image data"[:len(imageData)]), 1)
+	_, err = restore(t, startTestBackupServer(t).client, corrupt, true)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	details := status.Convert(err).Details()
+	require.Len(t, details, 1)
+	require.Equal(t, service.ReasonCorruptBackup, details[0].(*errdetails.ErrorInfo).GetReason())
+}
+
+func TestRestoreChecksArchive(t *testing.T) {
+	source := startTestBackupServer(t)
+	laptop := sample.NewLaptop()
+	require.NoError(t, source.laptopStore.Save(laptop))
+	_, err := source.imageStore.Save(laptop.Id, ".jpg", *bytes.NewBufferString("image data"))
+	require.NoError(t, err)
+	archive := backup(t, source.client)
+
+	target := startTestBackupServer(t)
+	target.server.MaxArchiveSize = int64(len(archive) - 1)
+	_, err = restore(t, target.client, archive, true)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.Equal(t, service.ReasonArchiveTooLarge, status.Convert(err).Details()[0].(*errdetails.ErrorInfo).GetReason())
+
+	// the store took a laptop the laptop service wouldn't, its backup can't be restored.
+	invalid := sample.NewLaptop()
+	invalid.Brand = ""
+	require.NoError(t, source.laptopStore.Save(invalid))
+	_, err = restore(t, startTestBackupServer(t).client, backup(t, source.client), true)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.Equal(t, service.ReasonCorruptBackup, status.Convert(err).Details()[0].(*errdetails.ErrorInfo).GetReason())
+
+	// nothing stays restored when a write fails.
+	target = startTestBackupServer(t)
+	require.NoError(t, os.RemoveAll(target.imageFolder))
+	_, err = restore(t, target.client, archive, false)
+	require.Equal(t, codes.Internal, status.Code(err))
+	require.Zero(t, target.laptopStore.Count())
+	require.Zero(t, target.ratingStore.Count())
+	images, err := target.imageStore.Images()
+	require.NoError(t, err)
+	require.Empty(t, images)
+}
+
+func TestImageStoreSaveWithIdFailure(t *testing.T) {
+	folder := t.TempDir()
+	imageStore := service.NewDiskImageStore(folder)
+	data := io.MultiReader(strings.NewReader("partial image"), iotest.ErrReader(errors.New("connection lost")))
+	require.Error(t, imageStore.SaveWithId(uuid.NewString(), "laptop", ".jpg", data))
+
+	images, err := imageStore.Images()
+	require.NoError(t, err)
+	require.Empty(t, images)
+	files, err := os.ReadDir(folder)
+	require.NoError(t, err)
+	require.Empty(t, files)
+}
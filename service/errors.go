@@ -0,0 +1,123 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Domain of the ErrorInfo details sent by the pcbook services.
+const ErrorDomain = "pcbook"
+
+// Reasons of the ErrorInfo details, stable for clients to switch on.
+const (
+	ReasonLaptopNotFound         = "LAPTOP_NOT_FOUND"
+	ReasonLaptopAlreadyExists    = "LAPTOP_ALREADY_EXISTS"
+	ReasonInvalidLaptop          = "INVALID_LAPTOP"
+	ReasonImageTooLarge          = "IMAGE_TOO_LARGE"
+	ReasonRateLimited            = "RATE_LIMITED"
+	ReasonTooManyStreams         = "TOO_MANY_STREAMS"
+	ReasonResumeTokenExpired     = "RESUME_TOKEN_EXPIRED"
+	ReasonWatchOverflow          = "WATCH_OVERFLOW"
+	ReasonUnsupportedCurrency    = "UNSUPPORTED_CURRENCY"
+	ReasonInvalidPriceAlert      = "INVALID_PRICE_ALERT"
+	ReasonPriceAlertNotFound     = "PRICE_ALERT_NOT_FOUND"
+	ReasonTooManyPriceAlerts     = "TOO_MANY_PRICE_ALERTS"
+	ReasonOutOfStock             = "OUT_OF_STOCK"
+	ReasonReservationNotFound    = "RESERVATION_NOT_FOUND"
+	ReasonEmptyCart              = "EMPTY_CART"
+	ReasonOrderNotFound          = "ORDER_NOT_FOUND"
+	ReasonInvalidOrderTransition = "INVALID_ORDER_TRANSITION"
+	ReasonWishlistNotFound       = "WISHLIST_NOT_FOUND"
+	ReasonSavedSearchNotFound    = "SAVED_SEARCH_NOT_FOUND"
+	ReasonNotLeader              = "NOT_LEADER"
+	ReasonCorruptBackup          = "CORRUPT_BACKUP"
+	ReasonRestoreTargetNotEmpty  = "RESTORE_TARGET_NOT_EMPTY"
+	ReasonArchiveTooLarge        = "ARCHIVE_TOO_LARGE"
+	ReasonRoleRequired           = "ROLE_REQUIRED"
+)
+
+const laptopResourceType = "pcbook.Laptop"
+
+// Status error carrying typed details. Details that can't be added are dropped
+// rather than hiding the original error.
+func statusWithDetails(code codes.Code, message string, details ...proto.Message) error {
+	st := status.New(code, message)
+	if detailed, err := st.WithDetails(details...); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+func errorInfo(reason string, metadata map[string]string) *errdetails.ErrorInfo {
+	return &errdetails.ErrorInfo{Reason: reason, Domain: ErrorDomain, Metadata: metadata}
+}
+
+func laptopResource(laptopId string, description string) *errdetails.ResourceInfo {
+	return &errdetails.ResourceInfo{ResourceType: laptopResourceType, ResourceName: laptopId, Description: description}
+}
+
+func retryInfo(delay time.Duration) *errdetails.RetryInfo {
+	return &errdetails.RetryInfo{RetryDelay: durationpb.New(delay)}
+}
+
+func laptopNotFoundError(laptopId string) error {
+	return statusWithDetails(codes.NotFound, fmt.Sprintf("laptop not found: %s", laptopId),
+		errorInfo(ReasonLaptopNotFound, map[string]string{"laptop_id": laptopId}),
+		laptopResource(laptopId, "the laptop doesn't exist or was deleted"),
+	)
+}
+
+func laptopAlreadyExistsError(laptopId string) error {
+	return statusWithDetails(codes.AlreadyExists, fmt.Sprintf("Couldn't save laptop to store. UUID already exists: %s", laptopId),
+		errorInfo(ReasonLaptopAlreadyExists, map[string]string{"laptop_id": laptopId}),
+		laptopResource(laptopId, "a laptop with this id is already in the catalog"),
+	)
+}
+
+func imageTooLargeError(laptopId string, size int) error {
+	return statusWithDetails(codes.InvalidArgument, fmt.Sprintf("image is too large: %d > %d", size, maxImageSize),
+		errorInfo(ReasonImageTooLarge, map[string]string{
+			"laptop_id": laptopId,
+			"size":      fmt.Sprint(size),
+			"max_size":  fmt.Sprint(maxImageSize),
+		}),
+	)
+}
+
+func archiveTooLargeError(maxSize int64) error {
+	return statusWithDetails(codes.InvalidArgument, fmt.Sprintf("archive is too large: more than %d bytes", maxSize),
+		errorInfo(ReasonArchiveTooLarge, map[string]string{"max_size": fmt.Sprint(maxSize)}),
+	)
+}
+
+func unsupportedCurrencyError(err error) error {
+	return statusWithDetails(codes.InvalidArgument, err.Error(), errorInfo(ReasonUnsupportedCurrency, nil))
+}
+
+// Error of a failed money conversion: an unknown currency, or an amount out of range.
+func moneyError(err error) error {
+	if errors.Is(err, ErrInvalidMoney) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return unsupportedCurrencyError(err)
+}
+
+func reservationNotFoundError(reservationId string) error {
+	return statusWithDetails(codes.NotFound, fmt.Sprintf("reservation not found: %s", reservationId),
+		errorInfo(ReasonReservationNotFound, map[string]string{"reservation_id": reservationId}),
+	)
+}
+
+func outOfStockError(laptopId string, warehouse string, err error) error {
+	return statusWithDetails(codes.FailedPrecondition, err.Error(),
+		errorInfo(ReasonOutOfStock, map[string]string{"laptop_id": laptopId, "warehouse": warehouse}),
+		laptopResource(laptopId, "not enough units of the laptop are available"),
+	)
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-grpc-pcbook/pb"
+	"math/big"
+	"os"
+
+	"google.golang.org/protobuf/proto"
+)
+
+var ErrUnsupportedCurrency = errors.New("unsupported currency")
+
+// Source of the rates used to show prices in other currencies.
+type ExchangeRateProvider interface {
+	// Amount of to that one unit of from is worth.
+	Rate(from, to string) (*big.Rat, error)
+}
+
+// Fixed rates against a base currency.
+type StaticExchangeRates struct {
+	base string
+	// units of each currency one base unit is worth.
+	rates map[string]*big.Rat
+}
+
+func NewStaticExchangeRates(base string, rates map[string]*big.Rat) *StaticExchangeRates {
+	all := map[string]*big.Rat{base: big.NewRat(1, 1)}
+	for currency, rate := range rates {
+		all[currency] = rate
+	}
+	return &StaticExchangeRates{base: base, rates: all}
+}
+
+// Loads rates from a JSON file like {"base": "USD", "rates": {"EUR": "0.92", "ARS": "350.5"}}.
+// Rates may be strings or numbers, strings keep every digit.
+func LoadExchangeRatesFile(filename string) (*StaticExchangeRates, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read exchange rates: %w", err)
+	}
+
+	file := struct {
+		Base  string                 `json:"base"`
+		Rates map[string]json.Number `json:"rates"`
+	}{}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("couldn't parse exchange rates: %w", err)
+	}
+	if !validCurrencyCode(file.Base) {
+		return nil, fmt.Errorf("invalid base currency %q", file.Base)
+	}
+
+	rates := make(map[string]*big.Rat, len(file.Rates))
+	for currency, number := range file.Rates {
+		rate, ok := new(big.Rat).SetString(number.String())
+		if !validCurrencyCode(currency) || !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate %s=%s", currency, number)
+		}
+		rates[currency] = rate
+	}
+	// prices are kept in BaseCurrency, every other currency converts from it.
+	if _, ok := rates[BaseCurrency]; !ok && file.Base != BaseCurrency {
+		return nil, fmt.Errorf("exchange rates don't define the base currency %s", BaseCurrency)
+	}
+	return NewStaticExchangeRates(file.Base, rates), nil
+}
+
+func (r *StaticExchangeRates) Rate(from, to string) (*big.Rat, error) {
+	fromRate, ok := r.rates[from]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedCurrency, from)
+	}
+	toRate, ok := r.rates[to]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedCurrency, to)
+	}
+	return new(big.Rat).Quo(toRate, fromRate), nil
+}
+
+func ConvertMoney(provider ExchangeRateProvider, money *pb.Money, currencyCode string) (*pb.Money, error) {
+	if money.GetCurrencyCode() == currencyCode {
+		return proto.Clone(money).(*pb.Money), nil
+	}
+
+	rate, err := provider.Rate(money.GetCurrencyCode(), currencyCode)
+	if err != nil {
+		return nil, err
+	}
+	return MoneyFromRat(currencyCode, new(big.Rat).Mul(MoneyToRat(money), rate))
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// Metadata the REST gateway sets to the address of the HTTP client it calls for.
+const ForwardedForKey = "x-forwarded-for"
+
+// Metadata proving a call comes from a trusted gateway, see ForwardedInterceptor.
+const GatewayTokenKey = "x-gateway-token"
+
+type forwardedKey struct{}
+
+// Identifies the caller: the verified client certificate common name when
+// the connection uses mutual TLS, the peer IP address otherwise, or the HTTP
+// client address for calls of the server's gateway. The identity
+// is qualified by the tenant of the call, like acme/user:alice, so what callers
+// own in a tenant isn't theirs in another.
+func CallerIdentity(ctx context.Context) string {
+	if tenantId := TenantFromContext(ctx); tenantId != "" {
+		return tenantId + "/" + peerIdentity(ctx)
+	}
+	return peerIdentity(ctx)
+}
+
+// CallerIdentity of callers having a verified client certificate. Addresses
+// aren't identities one can own things with, many clients share one behind a
+// NAT or the gateway.
+func authenticatedIdentity(ctx context.Context) (string, bool) {
+	if _, ok := ctx.Value(forwardedKey{}).(string); ok {
+		return "", false
+	}
+	if !strings.HasPrefix(peerIdentity(ctx), "user:") {
+		return "", false
+	}
+	return CallerIdentity(ctx), true
+}
+
+func peerIdentity(ctx context.Context) string {
+	if client, ok := ctx.Value(forwardedKey{}).(string); ok {
+		return "peer:" + client
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "unknown"
+	}
+
+	if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+		chains := tlsInfo.State.VerifiedChains
+		if len(chains) > 0 && len(chains[0]) > 0 && chains[0][0].Subject.CommonName != "" {
+			return "user:" + chains[0][0].Subject.CommonName
+		}
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return "peer:" + p.Addr.String()
+	}
+	return "peer:" + host
+}
+
+// Identifies calls the REST gateway makes by the HTTP client they're made for,
+// rather than by the gateway connection every HTTP client shares. The forwarded
+// address is only trusted on calls carrying the gateway token, which the server
+// gives its own gateway; other callers keep their peer identity.
+type ForwardedInterceptor struct {
+	token string
+}
+
+func NewForwardedInterceptor(token string) *ForwardedInterceptor {
+	return &ForwardedInterceptor{token}
+}
+
+func (f *ForwardedInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(f.forwarded(ctx), req)
+	}
+}
+
+func (f *ForwardedInterceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &contextStream{ServerStream: stream, ctx: f.forwarded(stream.Context())})
+	}
+}
+
+func (f *ForwardedInterceptor) forwarded(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	tokens, clients := md.Get(GatewayTokenKey), md.Get(ForwardedForKey)
+	if f.token == "" || len(tokens) != 1 || len(clients) != 1 || clients[0] == "" {
+		return ctx
+	}
+	if subtle.ConstantTimeCompare([]byte(tokens[0]), []byte(f.token)) != 1 {
+		return ctx
+	}
+	return context.WithValue(ctx, forwardedKey{}, clients[0])
+}
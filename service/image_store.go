@@ -2,27 +2,47 @@ package service
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"regexp"
 	"sync"
 
 	"github.com/google/uuid"
 )
 
+var ErrImageNotFound = errors.New("image not found.")
+
+// Image types are file extensions, like ".jpg".
+var imageTypePattern = regexp.MustCompile(`^\.[A-Za-z0-9]{1,10}$`)
+
 type ImageStore interface {
 	Save(laptopId string, imageType string, imageData bytes.Buffer) (string, error)
+	// Bytes of all saved images
+	TotalBytes() int64
+	// Saved images, by image id
+	Images() (map[string]ImageInfo, error)
+	// Reads a saved image, ErrImageNotFound if it's not there
+	Open(imageId string) (io.ReadCloser, error)
+	// Saves an image under a known id, to restore a backup. ErrAlreadyExists if it's taken
+	SaveWithId(imageId string, laptopId string, imageType string, imageData io.Reader) error
+	// Removes a saved image, ErrImageNotFound if it's not there
+	Delete(imageId string) error
 }
 
 type DiskImageStore struct {
 	mutex       sync.RWMutex
 	imageFolder string
 	images      map[string]*ImageInfo
+	totalBytes  int64
 }
 
 type ImageInfo struct {
 	LaptopId string
 	Type     string
 	Path     string
+	Size     int64
 }
 
 func NewDiskImageStore(imageFolder string) *DiskImageStore {
@@ -42,7 +62,9 @@ func (d *DiskImageStore) Save(laptopId string, imageType string, imageData bytes
 		return "", fmt.Errorf("Couldn't create image file: %v", err)
 	}
 
-	_, err = imageData.WriteTo(file)
+	defer file.Close()
+
+	size, err := imageData.WriteTo(file)
 	if err != nil {
 		return "", fmt.Errorf("Couldn't write image to file: %v", err)
 	}
@@ -50,7 +72,87 @@ func (d *DiskImageStore) Save(laptopId string, imageType string, imageData bytes
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
 
-	d.images[imageId.String()] = &ImageInfo{LaptopId: laptopId, Type: imageType, Path: imagePath}
+	d.images[imageId.String()] = &ImageInfo{LaptopId: laptopId, Type: imageType, Path: imagePath, Size: size}
+	d.totalBytes += size
 
 	return imageId.String(), nil
 }
+
+func (d *DiskImageStore) TotalBytes() int64 {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.totalBytes
+}
+
+func (d *DiskImageStore) Images() (map[string]ImageInfo, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	images := make(map[string]ImageInfo, len(d.images))
+	for imageId, info := range d.images {
+		images[imageId] = *info
+	}
+	return images, nil
+}
+
+func (d *DiskImageStore) Open(imageId string) (io.ReadCloser, error) {
+	d.mutex.RLock()
+	info, ok := d.images[imageId]
+	d.mutex.RUnlock()
+	if !ok {
+		return nil, ErrImageNotFound
+	}
+	return os.Open(info.Path)
+}
+
+// Ids and types become file names, so they must be a UUID and an extension.
+func (d *DiskImageStore) SaveWithId(imageId string, laptopId string, imageType string, imageData io.Reader) error {
+	if _, err := uuid.Parse(imageId); err != nil {
+		return fmt.Errorf("invalid image id %q: %v", imageId, err)
+	}
+	if !imageTypePattern.MatchString(imageType) {
+		return fmt.Errorf("invalid image type %q", imageType)
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.images[imageId] != nil {
+		return ErrAlreadyExists
+	}
+	imagePath := fmt.Sprintf("%s/%s%s", d.imageFolder, imageId, imageType)
+	file, err := os.Create(imagePath)
+	if err != nil {
+		return fmt.Errorf("Couldn't create image file: %v", err)
+	}
+
+	size, err := io.Copy(file, imageData)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// the image isn't saved, its partial file mustn't stay behind.
+		os.Remove(imagePath)
+		return fmt.Errorf("Couldn't write image to file: %v", err)
+	}
+
+	d.images[imageId] = &ImageInfo{LaptopId: laptopId, Type: imageType, Path: imagePath, Size: size}
+	d.totalBytes += size
+	return nil
+}
+
+func (d *DiskImageStore) Delete(imageId string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	info, ok := d.images[imageId]
+	if !ok {
+		return ErrImageNotFound
+	}
+	if err := os.Remove(info.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("Couldn't remove image file: %v", err)
+	}
+	delete(d.images, imageId)
+	d.totalBytes -= info.Size
+	return nil
+}
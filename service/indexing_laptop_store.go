@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"go-grpc-pcbook/pb"
+)
+
+// LaptopStore keeping a SimilarityIndex up to date with every change.
+type IndexingLaptopStore struct {
+	// serializes the writes to each laptop so the index ends with the same one as the store.
+	locks *keyedMutex
+	store LaptopStore
+	index *SimilarityIndex
+}
+
+func NewIndexingLaptopStore(store LaptopStore, index *SimilarityIndex) *IndexingLaptopStore {
+	return &IndexingLaptopStore{locks: newKeyedMutex(), store: store, index: index}
+}
+
+func (i *IndexingLaptopStore) Save(laptop *pb.Laptop) error {
+	defer i.locks.Lock(laptop.GetId())()
+
+	if err := i.store.Save(laptop); err != nil {
+		return err
+	}
+	i.index.Put(laptop)
+	return nil
+}
+
+func (i *IndexingLaptopStore) Find(id string) (*pb.Laptop, error) {
+	return i.store.Find(id)
+}
+
+func (i *IndexingLaptopStore) Update(laptop *pb.Laptop) error {
+	defer i.locks.Lock(laptop.GetId())()
+
+	if err := i.store.Update(laptop); err != nil {
+		return err
+	}
+	i.index.Put(laptop)
+	return nil
+}
+
+func (i *IndexingLaptopStore) Delete(id string) error {
+	defer i.locks.Lock(id)()
+
+	if err := i.store.Delete(id); err != nil {
+		return err
+	}
+	i.index.Remove(id)
+	return nil
+}
+
+func (i *IndexingLaptopStore) Search(ctx context.Context, filter *pb.Filter, found func(laptop *pb.Laptop) error) error {
+	return i.store.Search(ctx, filter, found)
+}
+
+func (i *IndexingLaptopStore) Count() int {
+	return i.store.Count()
+}
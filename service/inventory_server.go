@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-grpc-pcbook/pb"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultReservationTTL = 15 * time.Minute
+	maxReservationTTL     = time.Hour
+)
+
+// RPCs on the stock of the laptops in each warehouse.
+type InventoryServer struct {
+	LaptopStore    LaptopStore
+	InventoryStore InventoryStore
+}
+
+func NewInventoryServer(laptopStore LaptopStore, inventoryStore InventoryStore) *InventoryServer {
+	return &InventoryServer{laptopStore, inventoryStore}
+}
+
+func (s *InventoryServer) AdjustStock(ctx context.Context, req *pb.AdjustStockRequest) (*pb.AdjustStockResponse, error) {
+	logger := loggerFromContext(ctx)
+	logger.Info("received adjust-stock request", "laptop_id", req.GetLaptopId(), "warehouse", req.GetWarehouse(), "delta", req.GetDelta())
+
+	if req.GetWarehouse() == "" {
+		return nil, logError(logger, status.Error(codes.InvalidArgument, "warehouse is required"))
+	}
+	if err := s.checkLaptop(ctx, req.GetLaptopId()); err != nil {
+		return nil, logError(logger, err)
+	}
+
+	stock, err := s.InventoryStore.Adjust(req.GetLaptopId(), req.GetWarehouse(), req.GetDelta())
+	if errors.Is(err, ErrInsufficientStock) {
+		return nil, logError(logger, outOfStockError(req.GetLaptopId(), req.GetWarehouse(), err))
+	}
+	if err != nil {
+		return nil, logError(logger, status.Errorf(codes.Internal, "couldn't adjust stock: %v", err))
+	}
+
+	logger.Info("adjusted stock", "on_hand", stock.GetOnHand(), "available", stock.GetAvailable())
+	return &pb.AdjustStockResponse{Stock: stock}, nil
+}
+
+// Holds units of a laptop until the reservation expires. Only callers with a client
+// certificate can reserve, so anonymous callers can't hold the stock of everyone.
+func (s *InventoryServer) ReserveStock(ctx context.Context, req *pb.ReserveStockRequest) (*pb.ReserveStockResponse, error) {
+	logger := loggerFromContext(ctx)
+	logger.Info("received reserve-stock request", "laptop_id", req.GetLaptopId(), "warehouse", req.GetWarehouse(), "quantity", req.GetQuantity())
+
+	owner, ok := authenticatedIdentity(ctx)
+	if !ok {
+		return nil, logError(logger, status.Error(codes.Unauthenticated, "reservations need a client certificate"))
+	}
+
+	if req.GetQuantity() <= 0 {
+		return nil, logError(logger, status.Error(codes.InvalidArgument, "quantity must be greater than 0"))
+	}
+	ttl := defaultReservationTTL
+	if req.GetTtl() != nil {
+		ttl = req.GetTtl().AsDuration()
+		if ttl <= 0 || ttl > maxReservationTTL {
+			return nil, logError(logger, status.Errorf(codes.InvalidArgument, "ttl must be greater than 0 and at most %v", maxReservationTTL))
+		}
+	}
+	if err := s.checkLaptop(ctx, req.GetLaptopId()); err != nil {
+		return nil, logError(logger, err)
+	}
+
+	reservation, err := s.InventoryStore.Reserve(owner, req.GetLaptopId(), req.GetWarehouse(), req.GetQuantity(), time.Now().Add(ttl))
+	if errors.Is(err, ErrInsufficientStock) {
+		return nil, logError(logger, outOfStockError(req.GetLaptopId(), req.GetWarehouse(), err))
+	}
+	if err != nil {
+		return nil, logError(logger, status.Errorf(codes.Internal, "couldn't reserve stock: %v", err))
+	}
+
+	logger.Info("reserved stock", "reservation_id", reservation.GetId(), "warehouse", reservation.GetWarehouse())
+	return &pb.ReserveStockResponse{Reservation: reservation}, nil
+}
+
+func (s *InventoryServer) ReleaseReservation(ctx context.Context, req *pb.ReleaseReservationRequest) (*pb.ReleaseReservationResponse, error) {
+	logger := loggerFromContext(ctx)
+	logger.Info("received release-reservation request", "reservation_id", req.GetReservationId())
+
+	if _, err := s.callerReservation(ctx, req.GetReservationId()); err != nil {
+		return nil, logError(logger, err)
+	}
+	err := s.InventoryStore.Release(req.GetReservationId())
+	if errors.Is(err, ErrReservationNotFound) {
+		return nil, logError(logger, reservationNotFoundError(req.GetReservationId()))
+	}
+	if err != nil {
+		return nil, logError(logger, status.Errorf(codes.Internal, "couldn't release reservation: %v", err))
+	}
+	return &pb.ReleaseReservationResponse{}, nil
+}
+
+// Commits a reservation of any caller, the operator role is checked by the Authorizer.
+func (s *InventoryServer) CommitReservation(ctx context.Context, req *pb.CommitReservationRequest) (*pb.CommitReservationResponse, error) {
+	logger := loggerFromContext(ctx)
+	logger.Info("received commit-reservation request", "reservation_id", req.GetReservationId())
+
+	reservation, err := s.InventoryStore.Reservation(req.GetReservationId())
+	if err == nil {
+		err = s.InventoryStore.Commit(req.GetReservationId())
+	}
+	if errors.Is(err, ErrReservationNotFound) {
+		return nil, logError(logger, reservationNotFoundError(req.GetReservationId()))
+	}
+	if err != nil {
+		return nil, logError(logger, status.Errorf(codes.Internal, "couldn't commit reservation: %v", err))
+	}
+
+	stock := &pb.StockLevel{LaptopId: reservation.GetLaptopId(), Warehouse: reservation.GetWarehouse()}
+	for _, level := range s.InventoryStore.Availability(reservation.GetLaptopId()) {
+		if level.GetWarehouse() == reservation.GetWarehouse() {
+			stock = level
+		}
+	}
+	logger.Info("committed reservation", "laptop_id", stock.GetLaptopId(), "on_hand", stock.GetOnHand())
+	return &pb.CommitReservationResponse{Stock: stock}, nil
+}
+
+func (s *InventoryServer) GetAvailability(ctx context.Context, req *pb.GetAvailabilityRequest) (*pb.GetAvailabilityResponse, error) {
+	logger := loggerFromContext(ctx)
+	logger.Info("received get-availability request", "laptop_id", req.GetLaptopId())
+
+	if err := s.checkLaptop(ctx, req.GetLaptopId()); err != nil {
+		return nil, logError(logger, err)
+	}
+
+	res := &pb.GetAvailabilityResponse{Stock: s.InventoryStore.Availability(req.GetLaptopId())}
+	for _, stock := range res.Stock {
+		res.Available += stock.GetAvailable()
+	}
+	return res, nil
+}
+
+// Reservations of other callers don't exist for the caller.
+func (s *InventoryServer) callerReservation(ctx context.Context, reservationId string) (*pb.Reservation, error) {
+	reservation, err := s.InventoryStore.Reservation(reservationId)
+	if errors.Is(err, ErrReservationNotFound) || (err == nil && reservation.GetUserId() != CallerIdentity(ctx)) {
+		return nil, reservationNotFoundError(reservationId)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "couldn't find reservation: %v", err)
+	}
+	return reservation, nil
+}
+
+// Stock is only kept for laptops in the catalog.
+func (s *InventoryServer) checkLaptop(ctx context.Context, laptopId string) error {
+	_, err := tenantLaptopStore(ctx, s.LaptopStore).Find(laptopId)
+	if errors.Is(err, ErrNotFound) {
+		return laptopNotFoundError(laptopId)
+	}
+	if err != nil {
+		return status.Errorf(codes.Internal, "couldn't find laptop: %v", err)
+	}
+	return nil
+}
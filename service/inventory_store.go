@@ -0,0 +1,210 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-grpc-pcbook/pb"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var (
+	ErrInsufficientStock   = errors.New("insufficient stock")
+	ErrReservationNotFound = errors.New("reservation not found.")
+)
+
+type InventoryStore interface {
+	// Adds delta units on hand, ErrInsufficientStock if fewer than the reserved ones would be left
+	Adjust(laptopId string, warehouse string, delta int64) (*pb.StockLevel, error)
+	// Holds units for the user until expiresAt, in the first warehouse with enough of them if warehouse is empty.
+	// ErrInsufficientStock if no warehouse has them available
+	Reserve(userId string, laptopId string, warehouse string, quantity int64, expiresAt time.Time) (*pb.Reservation, error)
+	// Reservation with the id, ErrReservationNotFound if it expired, was released or committed
+	Reservation(id string) (*pb.Reservation, error)
+	// Gives the units of the reservation back, ErrReservationNotFound if it's not there
+	Release(id string) error
+	// Takes the units of the reservations off hand, all of them or none if one isn't there
+	Commit(ids ...string) error
+	// Stock of the laptop in every warehouse that ever had some, sorted by warehouse
+	Availability(laptopId string) []*pb.StockLevel
+	// Whether some warehouse has units of the laptop available
+	InStock(laptopId string) bool
+}
+
+// Checks and changes the stock under one lock, so concurrent reservations
+// can't hold more units than there are.
+type MemoryInventoryStore struct {
+	mutex sync.Mutex
+	// laptop id -> warehouse -> units on hand.
+	onHand map[string]map[string]int64
+	// laptop id -> reservation id -> reservation.
+	reservations map[string]map[string]*pb.Reservation
+}
+
+func NewMemoryInventoryStore() *MemoryInventoryStore {
+	return &MemoryInventoryStore{
+		onHand:       make(map[string]map[string]int64),
+		reservations: make(map[string]map[string]*pb.Reservation),
+	}
+}
+
+func (m *MemoryInventoryStore) Adjust(laptopId string, warehouse string, delta int64) (*pb.StockLevel, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.expire(laptopId)
+	level := m.level(laptopId, warehouse)
+	if level.Available+delta < 0 {
+		return nil, fmt.Errorf("%w: %d units available in %s", ErrInsufficientStock, level.Available, warehouse)
+	}
+
+	if m.onHand[laptopId] == nil {
+		m.onHand[laptopId] = make(map[string]int64)
+	}
+	m.onHand[laptopId][warehouse] += delta
+	return m.level(laptopId, warehouse), nil
+}
+
+func (m *MemoryInventoryStore) Reserve(userId string, laptopId string, warehouse string, quantity int64, expiresAt time.Time) (*pb.Reservation, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.expire(laptopId)
+	if warehouse == "" {
+		for _, level := range m.levels(laptopId) {
+			if level.Available >= quantity {
+				warehouse = level.Warehouse
+				break
+			}
+		}
+		if warehouse == "" {
+			return nil, fmt.Errorf("%w: no warehouse has %d units available", ErrInsufficientStock, quantity)
+		}
+	}
+
+	level := m.level(laptopId, warehouse)
+	if level.Available < quantity {
+		return nil, fmt.Errorf("%w: %d units available in %s", ErrInsufficientStock, level.Available, warehouse)
+	}
+
+	reservation := &pb.Reservation{
+		Id:        uuid.New().String(),
+		LaptopId:  laptopId,
+		Warehouse: warehouse,
+		Quantity:  quantity,
+		ExpiresAt: timestamppb.New(expiresAt),
+		UserId:    userId,
+	}
+	if m.reservations[laptopId] == nil {
+		m.reservations[laptopId] = make(map[string]*pb.Reservation)
+	}
+	m.reservations[laptopId][reservation.Id] = reservation
+	return proto.Clone(reservation).(*pb.Reservation), nil
+}
+
+func (m *MemoryInventoryStore) Reservation(id string) (*pb.Reservation, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	reservation := m.find(id)
+	if reservation == nil {
+		return nil, ErrReservationNotFound
+	}
+	return proto.Clone(reservation).(*pb.Reservation), nil
+}
+
+func (m *MemoryInventoryStore) Release(id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	reservation := m.find(id)
+	if reservation == nil {
+		return ErrReservationNotFound
+	}
+	delete(m.reservations[reservation.LaptopId], id)
+	return nil
+}
+
+func (m *MemoryInventoryStore) Commit(ids ...string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	reservations := make(map[string]*pb.Reservation)
+	for _, id := range ids {
+		reservation := m.find(id)
+		if reservation == nil {
+			return fmt.Errorf("%w: %s", ErrReservationNotFound, id)
+		}
+		reservations[id] = reservation
+	}
+
+	// reserved units are on hand, so taking them never makes the stock negative.
+	for id, reservation := range reservations {
+		m.onHand[reservation.LaptopId][reservation.Warehouse] -= reservation.Quantity
+		delete(m.reservations[reservation.LaptopId], id)
+	}
+	return nil
+}
+
+func (m *MemoryInventoryStore) Availability(laptopId string) []*pb.StockLevel {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.expire(laptopId)
+	return m.levels(laptopId)
+}
+
+func (m *MemoryInventoryStore) InStock(laptopId string) bool {
+	for _, level := range m.Availability(laptopId) {
+		if level.Available > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Drops the reservations of the laptop that expired, giving their units back.
+func (m *MemoryInventoryStore) expire(laptopId string) {
+	now := time.Now()
+	for id, reservation := range m.reservations[laptopId] {
+		if !reservation.ExpiresAt.AsTime().After(now) {
+			delete(m.reservations[laptopId], id)
+		}
+	}
+}
+
+// Unexpired reservation with the id, nil if there's none.
+func (m *MemoryInventoryStore) find(id string) *pb.Reservation {
+	for laptopId, reservations := range m.reservations {
+		if reservations[id] != nil {
+			m.expire(laptopId)
+			return reservations[id]
+		}
+	}
+	return nil
+}
+
+func (m *MemoryInventoryStore) levels(laptopId string) []*pb.StockLevel {
+	levels := []*pb.StockLevel{}
+	for warehouse := range m.onHand[laptopId] {
+		levels = append(levels, m.level(laptopId, warehouse))
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i].Warehouse < levels[j].Warehouse })
+	return levels
+}
+
+func (m *MemoryInventoryStore) level(laptopId string, warehouse string) *pb.StockLevel {
+	level := &pb.StockLevel{LaptopId: laptopId, Warehouse: warehouse, OnHand: m.onHand[laptopId][warehouse]}
+	for _, reservation := range m.reservations[laptopId] {
+		if reservation.Warehouse == warehouse {
+			level.Reserved += reservation.Quantity
+		}
+	}
+	level.Available = level.OnHand - level.Reserved
+	return level
+}
@@ -0,0 +1,185 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"go-grpc-pcbook/pb"
+	"go-grpc-pcbook/sample"
+	"go-grpc-pcbook/service"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestInventoryConcurrentReservations(t *testing.T) {
+	inventoryStore := service.NewMemoryInventoryStore()
+	_, err := inventoryStore.Adjust("laptop", "north", 10)
+	require.NoError(t, err)
+
+	// 50 customers race for 10 units.
+	reserved := make(chan *pb.Reservation, 50)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reservation, err := inventoryStore.Reserve("user:a", "laptop", "", 1, time.Now().Add(time.Minute))
+			if err == nil {
+				reserved <- reservation
+			} else if !errors.Is(err, service.ErrInsufficientStock) {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(reserved)
+
+	require.Len(t, reserved, 10)
+	require.False(t, inventoryStore.InStock("laptop"))
+	require.Equal(t, []*pb.StockLevel{{LaptopId: "laptop", Warehouse: "north", OnHand: 10, Reserved: 10}}, inventoryStore.Availability("laptop"))
+
+	// reserved units can't be removed.
+	_, err = inventoryStore.Adjust("laptop", "north", -1)
+	require.ErrorIs(t, err, service.ErrInsufficientStock)
+}
+
+func TestInventoryReservationExpiry(t *testing.T) {
+	inventoryStore := service.NewMemoryInventoryStore()
+	_, err := inventoryStore.Adjust("laptop", "north", 1)
+	require.NoError(t, err)
+	_, err = inventoryStore.Adjust("laptop", "south", 3)
+	require.NoError(t, err)
+
+	reservation, err := inventoryStore.Reserve("user:a", "laptop", "", 2, time.Now().Add(50*time.Millisecond))
+	require.NoError(t, err)
+	require.Equal(t, "south", reservation.GetWarehouse())
+	_, err = inventoryStore.Reserve("user:a", "laptop", "south", 2, time.Now().Add(time.Minute))
+	require.ErrorIs(t, err, service.ErrInsufficientStock)
+
+	require.Eventually(t, func() bool {
+		_, err := inventoryStore.Reserve("user:a", "laptop", "south", 2, time.Now().Add(time.Minute))
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestInventoryReleaseAndCommit(t *testing.T) {
+	inventoryStore := service.NewMemoryInventoryStore()
+	_, err := inventoryStore.Adjust("laptop", "north", 5)
+	require.NoError(t, err)
+	expiresAt := time.Now().Add(time.Minute)
+
+	released, err := inventoryStore.Reserve("user:a", "laptop", "north", 2, expiresAt)
+	require.NoError(t, err)
+	require.NoError(t, inventoryStore.Release(released.Id))
+	require.ErrorIs(t, inventoryStore.Release(released.Id), service.ErrReservationNotFound)
+
+	first, err := inventoryStore.Reserve("user:a", "laptop", "north", 2, expiresAt)
+	require.NoError(t, err)
+	second, err := inventoryStore.Reserve("user:b", "laptop", "north", 1, expiresAt)
+	require.NoError(t, err)
+	found, err := inventoryStore.Reservation(second.Id)
+	require.NoError(t, err)
+	require.Equal(t, "user:b", found.GetUserId())
+
+	// commits all the reservations or none.
+	require.ErrorIs(t, inventoryStore.Commit(first.Id, released.Id), service.ErrReservationNotFound)
+	require.Equal(t, []*pb.StockLevel{{LaptopId: "laptop", Warehouse: "north", OnHand: 5, Reserved: 3, Available: 2}}, inventoryStore.Availability("laptop"))
+	require.NoError(t, inventoryStore.Commit(first.Id, second.Id))
+	require.Equal(t, []*pb.StockLevel{{LaptopId: "laptop", Warehouse: "north", OnHand: 2, Available: 2}}, inventoryStore.Availability("laptop"))
+	_, err = inventoryStore.Reservation(first.Id)
+	require.ErrorIs(t, err, service.ErrReservationNotFound)
+}
+
+func TestInventoryServer(t *testing.T) {
+	laptopStore := service.NewMemoryLaptopStore()
+	inventoryStore := service.NewMemoryInventoryStore()
+	laptopServer := service.NewLaptopServer(laptopStore, nil, nil)
+	laptopServer.Inventory = inventoryStore
+	inventoryServer := service.NewInventoryServer(laptopStore, inventoryStore)
+
+	grpcServer := grpc.NewServer()
+	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+	laptopClient := newTestLaptopClient(t, listener.Addr().String())
+
+	stocked, soldOut := sample.NewLaptop(), sample.NewLaptop()
+	for _, laptop := range []*pb.Laptop{stocked, soldOut} {
+		laptop.Price = 1000
+		require.NoError(t, laptopStore.Save(laptop))
+	}
+	ctx := context.Background()
+
+	_, err = inventoryServer.AdjustStock(ctx, &pb.AdjustStockRequest{LaptopId: "missing", Warehouse: "north", Delta: 1})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = inventoryServer.AdjustStock(ctx, &pb.AdjustStockRequest{LaptopId: stocked.Id, Delta: 1})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = inventoryServer.AdjustStock(ctx, &pb.AdjustStockRequest{LaptopId: stocked.Id, Warehouse: "north", Delta: -1})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	for _, laptop := range []*pb.Laptop{stocked, soldOut} {
+		res, err := inventoryServer.AdjustStock(ctx, &pb.AdjustStockRequest{LaptopId: laptop.Id, Warehouse: "north", Delta: 2})
+		require.NoError(t, err)
+		require.EqualValues(t, 2, res.GetStock().GetAvailable())
+	}
+
+	// anonymous callers can't reserve.
+	_, err = inventoryServer.ReserveStock(ctx, &pb.ReserveStockRequest{LaptopId: soldOut.Id, Quantity: 2})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx = userContext("alice")
+	_, err = inventoryServer.ReserveStock(ctx, &pb.ReserveStockRequest{LaptopId: soldOut.Id, Quantity: 2, Ttl: durationpb.New(2 * time.Hour)})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	res, err := inventoryServer.ReserveStock(ctx, &pb.ReserveStockRequest{LaptopId: soldOut.Id, Quantity: 2})
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(15*time.Minute), res.GetReservation().GetExpiresAt().AsTime(), time.Minute)
+	_, err = inventoryServer.ReserveStock(ctx, &pb.ReserveStockRequest{LaptopId: soldOut.Id, Quantity: 1})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	availability, err := inventoryServer.GetAvailability(ctx, &pb.GetAvailabilityRequest{LaptopId: soldOut.Id})
+	require.NoError(t, err)
+	require.EqualValues(t, 0, availability.GetAvailable())
+	require.EqualValues(t, 2, availability.GetStock()[0].GetReserved())
+
+	// only the caller that reserved the units can release them.
+	other := userContext("bob")
+	release := &pb.ReleaseReservationRequest{ReservationId: res.GetReservation().GetId()}
+	_, err = inventoryServer.ReleaseReservation(other, release)
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = inventoryServer.ReleaseReservation(ctx, release)
+	require.NoError(t, err)
+	_, err = inventoryServer.ReleaseReservation(ctx, release)
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	res, err = inventoryServer.ReserveStock(other, &pb.ReserveStockRequest{LaptopId: soldOut.Id, Quantity: 2})
+	require.NoError(t, err)
+	committed, err := inventoryServer.CommitReservation(ctx, &pb.CommitReservationRequest{ReservationId: res.GetReservation().GetId()})
+	require.NoError(t, err)
+	require.Equal(t, &pb.StockLevel{LaptopId: soldOut.Id, Warehouse: "north"}, committed.GetStock())
+
+	search := func(inStockOnly bool) []string {
+		stream, err := laptopClient.SearchLaptop(ctx, &pb.SearchLaptopRequest{Filter: &pb.Filter{MaxPrice: 2000}, InStockOnly: inStockOnly})
+		require.NoError(t, err)
+		ids := []string{}
+		for {
+			res, err := stream.Recv()
+			if err == io.EOF {
+				return ids
+			}
+			require.NoError(t, err)
+			ids = append(ids, res.GetLaptop().GetId())
+		}
+	}
+	require.ElementsMatch(t, []string{stocked.Id, soldOut.Id}, search(false))
+	require.Equal(t, []string{stocked.Id}, search(true))
+}
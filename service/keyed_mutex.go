@@ -0,0 +1,44 @@
+package service
+
+import "sync"
+
+// Mutex per key, so writes to different laptops don't wait for each other while
+// writes to the same one keep their order. Locks are dropped once no one holds or
+// waits for them.
+type keyedMutex struct {
+	mutex sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	mutex sync.Mutex
+	// holder and waiters.
+	users int
+}
+
+func newKeyedMutex() *keyedMutex {
+	return &keyedMutex{locks: make(map[string]*keyedLock)}
+}
+
+// Locks the key, and returns the function unlocking it.
+func (k *keyedMutex) Lock(key string) func() {
+	k.mutex.Lock()
+	lock, ok := k.locks[key]
+	if !ok {
+		lock = &keyedLock{}
+		k.locks[key] = lock
+	}
+	lock.users++
+	k.mutex.Unlock()
+
+	lock.mutex.Lock()
+	return func() {
+		lock.mutex.Unlock()
+		k.mutex.Lock()
+		defer k.mutex.Unlock()
+		lock.users--
+		if lock.users == 0 {
+			delete(k.locks, key)
+		}
+	}
+}
@@ -13,9 +13,13 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestClientCreateLaptop(t *testing.T) {
@@ -248,3 +252,221 @@ func TestClientSearchLaptop(t *testing.T) {
 	}
 	require.Equal(t, len(expectedIds), found)
 }
+
+func TestClientWatchLaptops(t *testing.T) {
+	laptopHub := service.NewLaptopHub(10, 10)
+	laptopStore := service.NewPublishingLaptopStore(service.NewMemoryLaptopStore(), laptopHub)
+
+	laptopServer := service.NewLaptopServer(laptopStore, nil, nil)
+	laptopServer.LaptopHub = laptopHub
+	grpcServer := grpc.NewServer()
+	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+
+	laptopClient := newTestLaptopClient(t, listener.Addr().String())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := laptopClient.WatchLaptops(ctx, &pb.WatchLaptopsRequest{Filter: &pb.Filter{MaxPrice: 2000}})
+	require.NoError(t, err)
+	// without a max price, laptops of any price match.
+	anyPrice, err := laptopClient.WatchLaptops(ctx, &pb.WatchLaptopsRequest{Filter: &pb.Filter{MinCores: 1}})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return laptopHub.Subscribers() == 2 }, time.Second, 10*time.Millisecond)
+
+	cheap := sample.NewLaptop()
+	cheap.Price = 1000
+	expensive := sample.NewLaptop()
+	expensive.Price = 2500
+
+	_, err = laptopClient.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: cheap})
+	require.NoError(t, err)
+	_, err = laptopClient.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: expensive})
+	require.NoError(t, err)
+
+	cheap.Price = 1200
+	_, err = laptopClient.UpdateLaptop(context.Background(), &pb.UpdateLaptopRequest{Laptop: cheap})
+	require.NoError(t, err)
+	_, err = laptopClient.DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{Id: cheap.Id})
+	require.NoError(t, err)
+
+	expectedTypes := []pb.LaptopEvent_Type{pb.LaptopEvent_CREATED, pb.LaptopEvent_UPDATED, pb.LaptopEvent_DELETED}
+	var firstToken string
+	for i, expectedType := range expectedTypes {
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, expectedType, res.GetEvent().GetType())
+		require.Equal(t, cheap.Id, res.GetEvent().GetLaptop().GetId())
+		require.NotEmpty(t, res.GetEvent().GetResumeToken())
+		if i == 0 {
+			firstToken = res.GetEvent().GetResumeToken()
+		}
+	}
+	for _, laptop := range []*pb.Laptop{cheap, expensive} {
+		res, err := anyPrice.Recv()
+		require.NoError(t, err)
+		require.Equal(t, pb.LaptopEvent_CREATED, res.GetEvent().GetType())
+		require.Equal(t, laptop.Id, res.GetEvent().GetLaptop().GetId())
+	}
+	cancel()
+
+	// reconnecting after the first event replays the rest.
+	resumed, err := laptopClient.WatchLaptops(context.Background(), &pb.WatchLaptopsRequest{
+		Filter:      &pb.Filter{MaxPrice: 2000},
+		ResumeToken: firstToken,
+	})
+	require.NoError(t, err)
+	for _, expectedType := range expectedTypes[1:] {
+		res, err := resumed.Recv()
+		require.NoError(t, err)
+		require.Equal(t, expectedType, res.GetEvent().GetType())
+	}
+
+	invalid, err := laptopClient.WatchLaptops(context.Background(), &pb.WatchLaptopsRequest{ResumeToken: "garbage"})
+	require.NoError(t, err)
+	_, err = invalid.Recv()
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestClientImportLaptops(t *testing.T) {
+	laptopStore := service.NewMemoryLaptopStore()
+	existing := sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(existing))
+
+	serverAddress := startTestLaptopServer(t, laptopStore, nil, nil)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	newLaptop := sample.NewLaptop()
+	newLaptop.Id = ""
+	invalid := sample.NewLaptop()
+	invalid.Id = "invalid-uuid"
+	laptops := []*pb.Laptop{sample.NewLaptop(), newLaptop, existing, invalid}
+
+	importLaptops := func(dryRun bool) *pb.ImportLaptopsResponse {
+		stream, err := laptopClient.ImportLaptops(context.Background())
+		require.NoError(t, err)
+		for _, laptop := range laptops {
+			require.NoError(t, stream.Send(&pb.ImportLaptopsRequest{Laptop: laptop, DryRun: dryRun}))
+		}
+		res, err := stream.CloseAndRecv()
+		require.NoError(t, err)
+		return res
+	}
+
+	// a dry run reports the same failures without saving.
+	res := importLaptops(true)
+	require.EqualValues(t, 2, res.GetImportedCount())
+	require.EqualValues(t, 2, res.GetFailedCount())
+	require.Equal(t, 1, laptopStore.Count())
+
+	res = importLaptops(false)
+	require.EqualValues(t, 2, res.GetImportedCount())
+	require.EqualValues(t, 2, res.GetFailedCount())
+	require.Equal(t, 3, laptopStore.Count())
+
+	results := res.GetResults()
+	require.Len(t, results, 4)
+	require.Empty(t, results[0].GetError())
+	require.NotEmpty(t, results[1].GetId())
+	require.Equal(t, codes.AlreadyExists.String(), results[2].GetCode())
+	require.Equal(t, codes.InvalidArgument.String(), results[3].GetCode())
+	require.EqualValues(t, 4, results[3].GetIndex())
+
+	other, err := laptopStore.Find(results[1].GetId())
+	require.NoError(t, err)
+	require.Equal(t, newLaptop.GetName(), other.GetName())
+}
+
+func TestClientExportLaptops(t *testing.T) {
+	laptopStore := service.NewMemoryLaptopStore()
+	expectedIds := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		laptop := sample.NewLaptop()
+		laptop.Cpu.Cores = uint32(4 + i)
+		require.NoError(t, laptopStore.Save(laptop))
+		expectedIds[laptop.Id] = true
+	}
+
+	serverAddress := startTestLaptopServer(t, laptopStore, nil, nil)
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	exportIds := func(filter *pb.Filter) map[string]bool {
+		stream, err := laptopClient.ExportLaptops(context.Background(), &pb.ExportLaptopsRequest{Filter: filter})
+		require.NoError(t, err)
+
+		ids := make(map[string]bool)
+		for {
+			res, err := stream.Recv()
+			if err == io.EOF {
+				return ids
+			}
+			require.NoError(t, err)
+			ids[res.GetLaptop().GetId()] = true
+		}
+	}
+
+	// without a filter the whole catalog is exported.
+	require.Equal(t, expectedIds, exportIds(nil))
+	require.Len(t, exportIds(&pb.Filter{MinCores: 5}), 2)
+	require.Empty(t, exportIds(&pb.Filter{MinCores: 7}))
+}
+
+func TestClientErrorDetails(t *testing.T) {
+	laptopStore := service.NewMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(laptop))
+
+	_, err := laptopStore.Find("missing")
+	require.ErrorIs(t, err, service.ErrNotFound)
+
+	serverAddress := startTestLaptopServer(t, laptopStore, service.NewDiskImageStore(t.TempDir()), service.NewMemoryRatingStore())
+	laptopClient := newTestLaptopClient(t, serverAddress)
+
+	requireErrorInfo := func(err error, code codes.Code, reason string) *status.Status {
+		st := status.Convert(err)
+		require.Equal(t, code, st.Code())
+		for _, detail := range st.Details() {
+			if info, ok := detail.(*errdetails.ErrorInfo); ok {
+				require.Equal(t, reason, info.GetReason())
+				require.Equal(t, service.ErrorDomain, info.GetDomain())
+				return st
+			}
+		}
+		require.Fail(t, "no ErrorInfo in status details", st.String())
+		return st
+	}
+
+	_, err = laptopClient.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: laptop})
+	requireErrorInfo(err, codes.AlreadyExists, service.ReasonLaptopAlreadyExists)
+
+	_, err = laptopClient.DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{Id: "missing"})
+	st := requireErrorInfo(err, codes.NotFound, service.ReasonLaptopNotFound)
+	require.Equal(t, "missing", st.Details()[1].(*errdetails.ResourceInfo).GetResourceName())
+
+	// images for missing laptops stop before any chunk is read.
+	upload, err := laptopClient.UploadImage(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, upload.Send(&pb.UploadImageRequest{Data: &pb.UploadImageRequest_Info{Info: &pb.ImageInfo{LaptopId: "missing", ImageType: ".jpg"}}}))
+	_, err = upload.CloseAndRecv()
+	requireErrorInfo(err, codes.NotFound, service.ReasonLaptopNotFound)
+
+	upload, err = laptopClient.UploadImage(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, upload.Send(&pb.UploadImageRequest{Data: &pb.UploadImageRequest_Info{Info: &pb.ImageInfo{LaptopId: laptop.Id, ImageType: ".jpg"}}}))
+	chunk := make([]byte, 64*1024)
+	for i := 0; i < 20; i++ {
+		if err := upload.Send(&pb.UploadImageRequest{Data: &pb.UploadImageRequest_ChunkData{ChunkData: chunk}}); err != nil {
+			break
+		}
+	}
+	_, err = upload.CloseAndRecv()
+	requireErrorInfo(err, codes.InvalidArgument, service.ReasonImageTooLarge)
+
+	rate, err := laptopClient.RateLaptop(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, rate.Send(&pb.RateLaptopRequest{LaptopId: "missing", Score: 5}))
+	_, err = rate.Recv()
+	requireErrorInfo(err, codes.NotFound, service.ReasonLaptopNotFound)
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"go-grpc-pcbook/pb"
+	"math"
+	"strings"
+)
+
+const (
+	minComparedLaptops = 2
+	maxComparedLaptops = 10
+
+	bitsPerGigabyte = 1 << 33
+	kgPerLb         = 0.45359237
+)
+
+// A spec compared between laptops, in a common unit.
+type comparedSpec struct {
+	name           string
+	unit           string
+	higherIsBetter bool
+	// false if the laptop doesn't have the spec.
+	value func(laptop *pb.Laptop) (float64, bool)
+}
+
+var cpuSpecs = []comparedSpec{
+	{"cpu.cores", "cores", true, func(laptop *pb.Laptop) (float64, bool) {
+		return float64(laptop.GetCpu().GetCores()), laptop.GetCpu() != nil
+	}},
+	{"cpu.threads", "threads", true, func(laptop *pb.Laptop) (float64, bool) {
+		return float64(laptop.GetCpu().GetThreads()), laptop.GetCpu() != nil
+	}},
+	{"cpu.min_ghz", "GHz", true, func(laptop *pb.Laptop) (float64, bool) {
+		return laptop.GetCpu().GetMinGhz(), laptop.GetCpu() != nil
+	}},
+	{"cpu.max_ghz", "GHz", true, func(laptop *pb.Laptop) (float64, bool) {
+		return laptop.GetCpu().GetMaxGhz(), laptop.GetCpu() != nil
+	}},
+	{"memory", "GB", true, func(laptop *pb.Laptop) (float64, bool) {
+		return gigabytes(laptop.GetMemory()), laptop.GetMemory() != nil
+	}},
+}
+
+var screenSpecs = []comparedSpec{
+	{"screen.pixels", "px", true, func(laptop *pb.Laptop) (float64, bool) {
+		resolution := laptop.GetScreen().GetResolution()
+		return float64(resolution.GetWidth()) * float64(resolution.GetHeight()), resolution != nil
+	}},
+	{"screen.ppi", "ppi", true, func(laptop *pb.Laptop) (float64, bool) {
+		resolution := laptop.GetScreen().GetResolution()
+		size := float64(laptop.GetScreen().GetSizeInch())
+		return math.Hypot(float64(resolution.GetWidth()), float64(resolution.GetHeight())) / size, resolution != nil && size > 0
+	}},
+	{"weight", "kg", false, func(laptop *pb.Laptop) (float64, bool) {
+		switch weight := laptop.GetWeight().(type) {
+		case *pb.Laptop_WeightKg:
+			return weight.WeightKg, true
+		case *pb.Laptop_WeightLb:
+			return weight.WeightLb * kgPerLb, true
+		default:
+			return 0, false
+		}
+	}},
+}
+
+// Rows comparing the laptops spec by spec. Prices are compared as they are,
+// so the laptops must be priced in the same currency. Specs no laptop has are left out.
+func ComparisonTable(laptops []*pb.Laptop) []*pb.ComparisonRow {
+	currencyCode := BaseCurrency
+	if len(laptops) > 0 && laptops[0].GetListPrice() != nil {
+		currencyCode = laptops[0].GetListPrice().GetCurrencyCode()
+	}
+
+	specs := []comparedSpec{{"price", currencyCode, false, func(laptop *pb.Laptop) (float64, bool) {
+		return laptop.GetPrice(), true
+	}}}
+	specs = append(specs, cpuSpecs...)
+	specs = append(specs, storageSpecs(laptops)...)
+	specs = append(specs, screenSpecs...)
+
+	rows := []*pb.ComparisonRow{}
+	for _, spec := range specs {
+		if row := compareSpec(spec, laptops); row != nil {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// Total storage of each driver some laptop has, like storage.ssd.
+func storageSpecs(laptops []*pb.Laptop) []comparedSpec {
+	specs := []comparedSpec{}
+	drivers := pb.Storage_UNKNOWN.Descriptor().Values()
+	for i := 0; i < drivers.Len(); i++ {
+		driver := pb.Storage_Driver(drivers.Get(i).Number())
+		if driver == pb.Storage_UNKNOWN || !hasStorage(laptops, driver) {
+			continue
+		}
+
+		specs = append(specs, comparedSpec{"storage." + strings.ToLower(driver.String()), "GB", true, func(laptop *pb.Laptop) (float64, bool) {
+			total := 0.0
+			for _, storage := range laptop.GetStorage() {
+				if storage.GetDriver() == driver {
+					total += gigabytes(storage.GetMemory())
+				}
+			}
+			return total, true
+		}})
+	}
+	return specs
+}
+
+func hasStorage(laptops []*pb.Laptop, driver pb.Storage_Driver) bool {
+	for _, laptop := range laptops {
+		for _, storage := range laptop.GetStorage() {
+			if storage.GetDriver() == driver {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func compareSpec(spec comparedSpec, laptops []*pb.Laptop) *pb.ComparisonRow {
+	row := &pb.ComparisonRow{Spec: spec.name, Unit: spec.unit, HigherIsBetter: spec.higherIsBetter}
+
+	best := math.NaN()
+	for _, laptop := range laptops {
+		value, ok := spec.value(laptop)
+		if !ok {
+			value = 0
+		}
+		row.Cells = append(row.Cells, &pb.ComparisonCell{LaptopId: laptop.GetId(), Value: value, Available: ok})
+		if ok && (math.IsNaN(best) || (spec.higherIsBetter && value > best) || (!spec.higherIsBetter && value < best)) {
+			best = value
+		}
+	}
+	if math.IsNaN(best) {
+		return nil
+	}
+
+	for _, cell := range row.Cells {
+		if !cell.Available {
+			continue
+		}
+		cell.Best = cell.Value == best
+		if best != 0 {
+			cell.DifferencePercent = (cell.Value - best) / best * 100
+		}
+	}
+	return row
+}
+
+func gigabytes(memory *pb.Memory) float64 {
+	return float64(toBit(memory)) / bitsPerGigabyte
+}
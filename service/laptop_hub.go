@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-grpc-pcbook/pb"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var (
+	ErrResumeTokenExpired = errors.New("resume token is too old or from another server instance.")
+	ErrInvalidResumeToken = errors.New("invalid resume token.")
+	ErrSubscriberOverflow = errors.New("subscriber is too slow, events were dropped.")
+	ErrSubscriptionClosed = errors.New("subscription closed.")
+)
+
+// In process publish/subscribe hub for laptop changes.
+// Keeps the last events so subscribers can resume after a reconnection.
+type LaptopHub struct {
+	mutex       sync.Mutex
+	epoch       string
+	nextSeq     uint64
+	history     []hubEvent // oldest first, at most historySize
+	historySize int
+	bufferSize  int
+	subscribers map[*LaptopSubscription]bool
+}
+
+func NewLaptopHub(historySize int, bufferSize int) *LaptopHub {
+	return &LaptopHub{
+		epoch:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		nextSeq:     1,
+		historySize: historySize,
+		bufferSize:  bufferSize,
+		subscribers: make(map[*LaptopSubscription]bool),
+	}
+}
+
+// Sends the event to every subscriber whose filter matches the laptop, or its previous state for updates.
+func (h *LaptopHub) Publish(eventType pb.LaptopEvent_Type, laptop *pb.Laptop, previous *pb.Laptop) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	event := &pb.LaptopEvent{
+		Type:        eventType,
+		Laptop:      laptop,
+		ResumeToken: h.token(h.nextSeq),
+		Time:        timestamppb.Now(),
+	}
+	h.nextSeq++
+
+	item := hubEvent{seq: h.nextSeq - 1, event: event, previous: previous}
+	h.history = append(h.history, item)
+	if len(h.history) > h.historySize {
+		h.history = h.history[len(h.history)-h.historySize:]
+	}
+
+	for subscription := range h.subscribers {
+		if item.matches(subscription) {
+			if !subscription.push(event, h.bufferSize) {
+				delete(h.subscribers, subscription)
+			}
+		}
+	}
+}
+
+// Subscribes to the events matching the filter, nil for all of them.
+// Given a resume token, events published after it are replayed first. If they
+// don't fit the buffer, the subscription overflows once they're read.
+func (h *LaptopHub) Subscribe(filter *pb.Filter, resumeToken string) (*LaptopSubscription, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	subscription := &LaptopSubscription{hub: h, filter: filter, notify: make(chan struct{}, 1)}
+
+	if resumeToken != "" {
+		seq, err := h.parseToken(resumeToken)
+		if err != nil {
+			return nil, err
+		}
+		// the first event after the token must still be in history.
+		oldest := h.nextSeq - uint64(len(h.history))
+		if seq+1 < oldest {
+			return nil, ErrResumeTokenExpired
+		}
+		for _, item := range h.history {
+			if item.seq > seq && item.matches(subscription) && !subscription.push(item.event, h.bufferSize) {
+				return subscription, nil
+			}
+		}
+	}
+
+	h.subscribers[subscription] = true
+	return subscription, nil
+}
+
+// Number of active subscriptions.
+func (h *LaptopHub) Subscribers() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return len(h.subscribers)
+}
+
+func (h *LaptopHub) unsubscribe(subscription *LaptopSubscription) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	delete(h.subscribers, subscription)
+}
+
+func (h *LaptopHub) token(seq uint64) string {
+	return fmt.Sprintf("%s.%d", h.epoch, seq)
+}
+
+func (h *LaptopHub) parseToken(token string) (uint64, error) {
+	epoch, seqText, ok := strings.Cut(token, ".")
+	if !ok {
+		return 0, ErrInvalidResumeToken
+	}
+	seq, err := strconv.ParseUint(seqText, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidResumeToken
+	}
+	if epoch != h.epoch || seq >= h.nextSeq {
+		return 0, ErrResumeTokenExpired
+	}
+	return seq, nil
+}
+
+type hubEvent struct {
+	seq   uint64
+	event *pb.LaptopEvent
+	// laptop before an update, nil for other events.
+	previous *pb.Laptop
+}
+
+// Updates also reach subscribers the laptop leaves the filter of.
+func (e hubEvent) matches(subscription *LaptopSubscription) bool {
+	return subscription.matches(e.event.GetLaptop()) || (e.previous != nil && subscription.matches(e.previous))
+}
+
+// Events of a single subscriber, buffered until it reads them.
+type LaptopSubscription struct {
+	hub    *LaptopHub
+	filter *pb.Filter
+	notify chan struct{}
+
+	mutex    sync.Mutex
+	queue    []*pb.LaptopEvent
+	overflow bool
+	closed   bool
+}
+
+// Blocks until the next event is available.
+func (s *LaptopSubscription) Next(ctx context.Context) (*pb.LaptopEvent, error) {
+	for {
+		s.mutex.Lock()
+		if len(s.queue) > 0 {
+			event := s.queue[0]
+			s.queue = s.queue[1:]
+			s.mutex.Unlock()
+			return event, nil
+		}
+		overflow, closed := s.overflow, s.closed
+		s.mutex.Unlock()
+
+		if overflow {
+			return nil, ErrSubscriberOverflow
+		}
+		if closed {
+			return nil, ErrSubscriptionClosed
+		}
+
+		select {
+		case <-s.notify:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (s *LaptopSubscription) Close() {
+	s.hub.unsubscribe(s)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closed = true
+	s.wake()
+}
+
+func (s *LaptopSubscription) matches(laptop *pb.Laptop) bool {
+	return s.filter == nil || isQualified(s.filter, laptop)
+}
+
+// Queues the event. Returns false when the buffer is full and the subscriber gets dropped.
+func (s *LaptopSubscription) push(event *pb.LaptopEvent, bufferSize int) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.queue) >= bufferSize {
+		// already queued events are still delivered before the overflow error.
+		s.overflow = true
+		s.wake()
+		return false
+	}
+	s.queue = append(s.queue, event)
+	s.wake()
+	return true
+}
+
+func (s *LaptopSubscription) wake() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,133 @@
+package service_test
+
+import (
+	"context"
+	"go-grpc-pcbook/pb"
+	"go-grpc-pcbook/sample"
+	"go-grpc-pcbook/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLaptopHubResume(t *testing.T) {
+	hub := service.NewLaptopHub(2, 10)
+
+	tokens := []string{}
+	subscription, err := hub.Subscribe(nil, "")
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		hub.Publish(pb.LaptopEvent_CREATED, sample.NewLaptop(), nil)
+		event, err := subscription.Next(context.Background())
+		require.NoError(t, err)
+		tokens = append(tokens, event.GetResumeToken())
+	}
+	subscription.Close()
+	require.Equal(t, 0, hub.Subscribers())
+
+	// history holds the last two events, so resuming after the first one still works.
+	resumed, err := hub.Subscribe(nil, tokens[0])
+	require.NoError(t, err)
+	defer resumed.Close()
+	for _, token := range tokens[1:] {
+		event, err := resumed.Next(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, token, event.GetResumeToken())
+	}
+
+	hub.Publish(pb.LaptopEvent_CREATED, sample.NewLaptop(), nil)
+	_, err = hub.Subscribe(nil, tokens[0])
+	require.ErrorIs(t, err, service.ErrResumeTokenExpired)
+
+	_, err = hub.Subscribe(nil, "not-a-token")
+	require.ErrorIs(t, err, service.ErrInvalidResumeToken)
+
+	_, err = service.NewLaptopHub(2, 10).Subscribe(nil, tokens[2])
+	require.ErrorIs(t, err, service.ErrResumeTokenExpired)
+}
+
+func TestLaptopHubOverflow(t *testing.T) {
+	hub := service.NewLaptopHub(10, 2)
+	subscription, err := hub.Subscribe(nil, "")
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		hub.Publish(pb.LaptopEvent_CREATED, sample.NewLaptop(), nil)
+	}
+	require.Equal(t, 0, hub.Subscribers())
+
+	for i := 0; i < 2; i++ {
+		_, err := subscription.Next(context.Background())
+		require.NoError(t, err)
+	}
+	_, err = subscription.Next(context.Background())
+	require.ErrorIs(t, err, service.ErrSubscriberOverflow)
+
+	// replayed events must fit the buffer too.
+	subscription, err = hub.Subscribe(nil, "")
+	require.NoError(t, err)
+	defer subscription.Close()
+	hub.Publish(pb.LaptopEvent_CREATED, sample.NewLaptop(), nil)
+	first, err := subscription.Next(context.Background())
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		hub.Publish(pb.LaptopEvent_CREATED, sample.NewLaptop(), nil)
+		_, err := subscription.Next(context.Background())
+		require.NoError(t, err)
+	}
+
+	resumed, err := hub.Subscribe(nil, first.GetResumeToken())
+	require.NoError(t, err)
+	defer resumed.Close()
+	for i := 0; i < 2; i++ {
+		_, err := resumed.Next(context.Background())
+		require.NoError(t, err)
+	}
+	_, err = resumed.Next(context.Background())
+	require.ErrorIs(t, err, service.ErrSubscriberOverflow)
+}
+
+func TestLaptopHubFilter(t *testing.T) {
+	hub := service.NewLaptopHub(10, 10)
+	subscription, err := hub.Subscribe(&pb.Filter{MaxPrice: 2000}, "")
+	require.NoError(t, err)
+	defer subscription.Close()
+	all, err := hub.Subscribe(nil, "")
+	require.NoError(t, err)
+	defer all.Close()
+
+	laptop := sample.NewLaptop()
+	laptop.Price = 3000
+	hub.Publish(pb.LaptopEvent_CREATED, laptop, nil)
+	created, err := all.Next(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = subscription.Next(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// dropping below the max price is an update the watcher cares about, and so is leaving the range.
+	cheaper := sample.NewLaptop()
+	cheaper.Id = laptop.Id
+	cheaper.Price = 1500
+	hub.Publish(pb.LaptopEvent_UPDATED, cheaper, laptop)
+	hub.Publish(pb.LaptopEvent_UPDATED, laptop, cheaper)
+
+	for i := 0; i < 2; i++ {
+		event, err := subscription.Next(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, pb.LaptopEvent_UPDATED, event.GetType())
+	}
+
+	// resumed watchers get the same updates as live ones.
+	resumed, err := hub.Subscribe(&pb.Filter{MaxPrice: 2000}, created.GetResumeToken())
+	require.NoError(t, err)
+	defer resumed.Close()
+	for _, price := range []float64{1500, 3000} {
+		event, err := resumed.Next(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, price, event.GetLaptop().GetPrice())
+	}
+}
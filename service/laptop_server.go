@@ -4,67 +4,86 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"go-grpc-pcbook/pb"
 	"io"
-	"log"
+	"log/slog"
+	"math"
+	"sort"
 
 	"github.com/google/uuid"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-const maxImageSize = 1 << 20
+const (
+	maxImageSize           = 1 << 20
+	defaultSimilarLaptops  = 5
+	maxSimilarLaptops      = 50
+	defaultRecommendations = 10
+	maxRecommendations     = 100
+)
 
 type LaptopServer struct {
 	LaptopStore LaptopStore
 	ImageStore  ImageStore
 	RatingStore RatingStore
+	// Source of WatchLaptops events, the laptop store must publish to it. Watching is disabled if nil.
+	LaptopHub *LaptopHub
+	// Gets image and rating events for webhooks. Optional.
+	Webhooks WebhookNotifier
+	// Rates to show prices in other currencies. Only BaseCurrency is supported if nil.
+	ExchangeRates ExchangeRateProvider
+	// Stock of the laptops, for in stock searches. Optional.
+	Inventory InventoryStore
+	// Index of RecommendSimilar, the laptop store must keep it up to date. Recommendations are disabled if nil.
+	Similarity *SimilarityIndex
+	// Recommendations of RecommendForUser, refreshed from the rating store. Disabled if nil.
+	Recommendations *RecommendationEngine
 }
 
 func NewLaptopServer(laptopStore LaptopStore, imageStore ImageStore, ratingStore RatingStore) *LaptopServer {
-	return &LaptopServer{laptopStore, imageStore, ratingStore}
+	return &LaptopServer{LaptopStore: laptopStore, ImageStore: imageStore, RatingStore: ratingStore}
 }
 
 // Unary RPC to create new laptop.
 func (s *LaptopServer) CreateLaptop(ctx context.Context, req *pb.CreateLaptopRequest) (*pb.CreateLaptopResponse, error) {
 	laptop := req.GetLaptop()
-	log.Println("Received a create-laptop request with id: ", laptop.Id)
+	logger := loggerFromContext(ctx)
+	logger.Info("received create-laptop request")
 
-	if len(laptop.Id) > 0 {
-		// Check valid UUID
-		_, err := uuid.Parse(laptop.Id)
-		if err != nil {
-			return nil, status.Errorf(codes.InvalidArgument, "Lapotop ID is not a valid UUID: %v", err)
-		}
-	} else {
-		// Generate uuid
-		id, err := uuid.NewRandom()
-		if err != nil {
-			return nil, status.Errorf(codes.Internal, "Couldn't generate laptop UUID: %v", err)
-		}
-		laptop.Id = id.String() // conver UUID to string format.
+	err := ValidateLaptop(laptop)
+	if err == nil {
+		err = s.normalizePrice(laptop)
+	}
+	if err != nil {
+		return nil, logError(logger, err)
 	}
 
+	err = s.assignLaptopId(laptop)
+	if err != nil {
+		return nil, err
+	}
+	logger = logger.With("laptop_id", laptop.Id)
+
 	// Supposed heavy processing.
 	//time.Sleep(4 * time.Second)
 
 	// Check ctx deadline exceeded before saving to storage.
-	err := contextError(ctx, laptop.Id)
+	err = contextError(ctx, logger)
 	if err != nil {
 		return nil, err
 	}
 
 	// Save storage.
-	err = s.LaptopStore.Save(laptop)
+	err = s.saveLaptop(ctx, laptop)
 	if err != nil {
-		if errors.Is(err, ErrAlreadyExists) {
-			return nil, status.Errorf(codes.AlreadyExists, "Couldn't save laptop to store. UUID already exists: %v", err)
-		} else {
-			return nil, status.Errorf(codes.Internal, "Couldn't save laptop to store: %v", err)
-		}
+		return nil, err
 	}
 
-	log.Printf("Saved laptop with id: %s", laptop.Id)
+	logger.Info("saved laptop")
 
 	// Create response with laptop id and return it.
 	return &pb.CreateLaptopResponse{
@@ -74,89 +93,145 @@ func (s *LaptopServer) CreateLaptop(ctx context.Context, req *pb.CreateLaptopReq
 
 func (s *LaptopServer) SearchLaptop(req *pb.SearchLaptopRequest, stream pb.LaptopService_SearchLaptopServer) error {
 	filter := req.GetFilter()
-	log.Println("Received a search-laptop request with filter: ", filter)
+	logger := loggerFromContext(stream.Context())
+	logger.Info("received search-laptop request", "filter", filter.String(), "currency", req.GetCurrencyCode(), "sort", req.GetSort().String(), "in_stock_only", req.GetInStockOnly())
 
-	err := s.LaptopStore.Search(stream.Context(), filter, func(laptop *pb.Laptop) error {
-		res := &pb.SearchLaptopResponse{Laptop: laptop}
+	if req.GetInStockOnly() && s.Inventory == nil {
+		return logError(logger, status.Error(codes.FailedPrecondition, "in stock searches need the inventory, which is disabled"))
+	}
 
-		err := stream.Send(res)
+	currencyCode := req.GetCurrencyCode()
+	if currencyCode == "" {
+		currencyCode = BaseCurrency
+	}
+
+	filter, err := baseCurrencyFilter(s.exchangeRates(), filter, currencyCode)
+	if err != nil {
+		return logError(logger, err)
+	}
+
+	send := func(laptop *pb.Laptop) error {
+		err := s.localizePrice(laptop, currencyCode)
 		if err != nil {
 			return err
 		}
 
-		log.Println("sent laptop with id: ", laptop.GetId())
+		res := &pb.SearchLaptopResponse{Laptop: laptop}
+		err = stream.Send(res)
+		if err != nil {
+			return err
+		}
+
+		logger.Debug("sent laptop", "laptop_id", laptop.GetId())
 		return nil
-	})
+	}
+
+	found := send
+	sorted := []*pb.Laptop{}
+	if req.GetSort() != pb.SearchLaptopRequest_UNSORTED {
+		found = func(laptop *pb.Laptop) error {
+			sorted = append(sorted, laptop)
+			return nil
+		}
+	}
+	if req.GetInStockOnly() {
+		inStock := found
+		found = func(laptop *pb.Laptop) error {
+			if !s.Inventory.InStock(laptop.GetId()) {
+				return nil
+			}
+			return inStock(laptop)
+		}
+	}
+
+	err = tenantLaptopStore(stream.Context(), s.LaptopStore).Search(stream.Context(), filter, found)
+	if err == nil && len(sorted) > 0 {
+		// converting is monotonic, so BaseCurrency prices sort the same.
+		sort.SliceStable(sorted, func(i, j int) bool {
+			if req.GetSort() == pb.SearchLaptopRequest_PRICE_DESCENDING {
+				return sorted[i].GetPrice() > sorted[j].GetPrice()
+			}
+			return sorted[i].GetPrice() < sorted[j].GetPrice()
+		})
+		for _, laptop := range sorted {
+			if err = send(laptop); err != nil {
+				break
+			}
+		}
+	}
 
 	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
 		return status.Errorf(codes.Internal, "unexpected error: %v", err)
 	}
 	return nil
 }
 
 func (s *LaptopServer) UploadImage(stream pb.LaptopService_UploadImageServer) error {
+	logger := loggerFromContext(stream.Context())
 	req, err := stream.Recv()
 	if err != nil {
-		logError(status.Errorf(codes.Unknown, "couldn't receive image info"))
+		return logError(logger, status.Errorf(codes.Unknown, "couldn't receive image info: %v", err))
 	}
 
 	laptopId := req.GetInfo().GetLaptopId()
 	imageType := req.GetInfo().GetImageType()
-	log.Println("Received an upload-image request for laptop: ", laptopId)
+	logger = logger.With("laptop_id", laptopId)
+	logger.Info("received upload-image request", "image_type", imageType)
 
-	laptop, err := s.LaptopStore.Find(laptopId)
+	_, err = tenantLaptopStore(stream.Context(), s.LaptopStore).Find(laptopId)
 	if err != nil {
-		logError(status.Errorf(codes.Internal, "couldn't find laptop"))
-	}
-
-	if laptop == nil {
-		return logError(status.Errorf(codes.InvalidArgument, "laptop not found: %s", laptopId))
+		if errors.Is(err, ErrNotFound) {
+			return logError(logger, laptopNotFoundError(laptopId))
+		}
+		return logError(logger, status.Errorf(codes.Internal, "couldn't find laptop: %v", err))
 	}
 
 	//start receiving image chunks.
 	imageData := bytes.Buffer{}
 	imageSize := 0
-	log.Println("Receiving chunks...")
 	for {
 		// testing purposes
 		//time.Sleep(time.Second)
 
-		if err := contextError(stream.Context(), laptopId); err != nil {
+		if err := contextError(stream.Context(), logger); err != nil {
 			return err
 		}
 		req, err := stream.Recv()
 		if err == io.EOF {
-			log.Print("no more chunks to receive")
+			logger.Debug("no more chunks to receive")
 			break
 		}
 
 		if err != nil {
-			return logError(status.Errorf(codes.Unknown, "couldn't receive chunk data: %v", err))
+			return logError(logger, status.Errorf(codes.Unknown, "couldn't receive chunk data: %v", err))
 		}
 
 		// Keep track of image size
 		chunk := req.GetChunkData()
 		size := len(chunk)
 		imageSize += size
-		log.Println("Received chunk with size: ", size)
+		logger.Debug("received chunk", "size", size)
 		// check if image size is greater than the allowed.
 		if imageSize > maxImageSize {
-			return logError(status.Errorf(codes.InvalidArgument, "image is too large: %d > %d", imageSize, maxImageSize))
+			return logError(logger, imageTooLargeError(laptopId, imageSize))
 		}
 
 		// write chunk to buffer.
 		_, err = imageData.Write(chunk)
 		if err != nil {
-			return logError(status.Errorf(codes.Internal, "couldn't write chunk data: %v", err))
+			return logError(logger, status.Errorf(codes.Internal, "couldn't write chunk data: %v", err))
 
 		}
 
 	}
 
 	// flush buffer to store.
-	imageId, err := s.ImageStore.Save(laptopId, imageType, imageData)
+	imageId, err := tenantImageStore(stream.Context(), s.ImageStore).Save(laptopId, imageType, imageData)
 	if err != nil {
-		return logError(status.Errorf(codes.Internal, "couldn't flush data to store: %v", err))
+		return logError(logger, status.Errorf(codes.Internal, "couldn't flush data to store: %v", err))
 	}
 
 	//if image is saved successfully, return image response and clos stream.
@@ -164,44 +239,55 @@ func (s *LaptopServer) UploadImage(stream pb.LaptopService_UploadImageServer) er
 
 	err = stream.SendAndClose(res)
 	if err != nil {
-		return logError(status.Errorf(codes.Unknown, "couldn't send response: %v", err))
+		return logError(logger, status.Errorf(codes.Unknown, "couldn't send response: %v", err))
 	}
 
-	log.Printf("saved image with id: %s and size %d", imageId, imageSize)
+	logger.Info("saved image", "image_id", imageId, "size", imageSize)
+	s.notify(logger, &pb.WebhookPayload{
+		EventType: EventImageUploaded,
+		Data: &pb.WebhookPayload_Image{Image: &pb.UploadedImage{
+			Id:        imageId,
+			LaptopId:  laptopId,
+			ImageType: imageType,
+			Size:      uint32(imageSize),
+		}},
+	})
 	return nil
 
 }
 
 func (s *LaptopServer) RateLaptop(stream pb.LaptopService_RateLaptopServer) error {
-	log.Println("Rating laptops...")
+	logger := loggerFromContext(stream.Context())
+	logger.Info("received rate-laptop request")
 	for {
-		if err := contextError(stream.Context()); err != nil {
+		if err := contextError(stream.Context(), logger); err != nil {
 			return err
 		}
 		req, err := stream.Recv()
 		if err == io.EOF {
-			log.Print("no more laptops to rate")
+			logger.Debug("no more laptops to rate")
 			break
 		}
 
 		if err != nil {
-			return logError(status.Errorf(codes.Unknown, "couldn't receive stream data: %v", err))
+			return logError(logger, status.Errorf(codes.Unknown, "couldn't receive stream data: %v", err))
 		}
 
 		laptopId := req.LaptopId
 		score := req.Score
+		laptopLogger := logger.With("laptop_id", laptopId)
 
-		found, err := s.LaptopStore.Find(laptopId)
+		_, err = tenantLaptopStore(stream.Context(), s.LaptopStore).Find(laptopId)
 		if err != nil {
-			return logError(status.Errorf(codes.Internal, "couldn't find laptop: %v", err))
-		}
-		if found == nil {
-			return logError(status.Errorf(codes.InvalidArgument, "laptop %s is not found", laptopId))
+			if errors.Is(err, ErrNotFound) {
+				return logError(laptopLogger, laptopNotFoundError(laptopId))
+			}
+			return logError(laptopLogger, status.Errorf(codes.Internal, "couldn't find laptop: %v", err))
 		}
 
-		rating, err := s.RatingStore.Add(laptopId, score)
+		rating, err := tenantRatingStore(stream.Context(), s.RatingStore).Add(CallerIdentity(stream.Context()), laptopId, score)
 		if err != nil {
-			return logError(status.Errorf(codes.Internal, "couldn't rate laptop: %v", err))
+			return logError(laptopLogger, status.Errorf(codes.Internal, "couldn't rate laptop: %v", err))
 		}
 
 		res := &pb.RateLaptopResponse{
@@ -212,30 +298,484 @@ func (s *LaptopServer) RateLaptop(stream pb.LaptopService_RateLaptopServer) erro
 
 		err = stream.Send(res)
 		if err != nil {
-			return logError(status.Errorf(codes.Unknown, "couldn't send stream response: %v", err))
+			return logError(laptopLogger, status.Errorf(codes.Unknown, "couldn't send stream response: %v", err))
+		}
+		laptopLogger.Info("rated laptop", "score", score)
+		s.notify(laptopLogger, &pb.WebhookPayload{
+			EventType: EventRatingChanged,
+			Data: &pb.WebhookPayload_Rating{Rating: &pb.LaptopRating{
+				LaptopId:     res.LaptopId,
+				RatedCount:   res.RatedCount,
+				AverageScore: res.AverageScore,
+			}},
+		})
+
+	}
+	return nil
+}
+
+// Unary RPC to replace an existing laptop.
+func (s *LaptopServer) UpdateLaptop(ctx context.Context, req *pb.UpdateLaptopRequest) (*pb.UpdateLaptopResponse, error) {
+	laptop := req.GetLaptop()
+	logger := loggerFromContext(ctx).With("laptop_id", laptop.GetId())
+	logger.Info("received update-laptop request")
+
+	if err := ValidateLaptop(laptop); err != nil {
+		return nil, logError(logger, err)
+	}
+	if err := s.normalizePrice(laptop); err != nil {
+		return nil, logError(logger, err)
+	}
+	if _, err := uuid.Parse(laptop.Id); err != nil {
+		return nil, logError(logger, status.Errorf(codes.InvalidArgument, "Laptop ID is not a valid UUID: %v", err))
+	}
+
+	if err := contextError(ctx, logger); err != nil {
+		return nil, err
+	}
+
+	laptop.UpdatedAt = timestamppb.Now()
+	err := tenantLaptopStore(ctx, s.LaptopStore).Update(laptop)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, logError(logger, laptopNotFoundError(laptop.Id))
+		}
+		return nil, logError(logger, status.Errorf(codes.Internal, "couldn't update laptop: %v", err))
+	}
+
+	logger.Info("updated laptop")
+	return &pb.UpdateLaptopResponse{Id: laptop.Id}, nil
+}
+
+// Unary RPC to remove a laptop from the catalog.
+func (s *LaptopServer) DeleteLaptop(ctx context.Context, req *pb.DeleteLaptopRequest) (*pb.DeleteLaptopResponse, error) {
+	laptopId := req.GetId()
+	logger := loggerFromContext(ctx).With("laptop_id", laptopId)
+	logger.Info("received delete-laptop request")
+
+	if err := contextError(ctx, logger); err != nil {
+		return nil, err
+	}
+
+	err := tenantLaptopStore(ctx, s.LaptopStore).Delete(laptopId)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, logError(logger, laptopNotFoundError(laptopId))
+		}
+		return nil, logError(logger, status.Errorf(codes.Internal, "couldn't delete laptop: %v", err))
+	}
+
+	logger.Info("deleted laptop")
+	return &pb.DeleteLaptopResponse{}, nil
+}
+
+// Server streaming RPC sending laptop changes as they happen.
+func (s *LaptopServer) WatchLaptops(req *pb.WatchLaptopsRequest, stream pb.LaptopService_WatchLaptopsServer) error {
+	logger := loggerFromContext(stream.Context())
+	logger.Info("received watch-laptops request", "filter", req.GetFilter().String(), "resume_token", req.GetResumeToken())
+
+	if s.LaptopHub == nil {
+		return logError(logger, status.Error(codes.Unimplemented, "laptop watching is not enabled"))
+	}
+
+	// an empty filter watches every laptop, one without a max price laptops of any price.
+	filter := req.GetFilter()
+	if proto.Size(filter) == 0 {
+		filter = nil
+	} else {
+		filter = unlimitedPrice(filter)
+	}
+
+	subscription, err := s.LaptopHub.Subscribe(filter, req.GetResumeToken())
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidResumeToken):
+			return logError(logger, status.Errorf(codes.InvalidArgument, "couldn't watch laptops: %v", err))
+		case errors.Is(err, ErrResumeTokenExpired):
+			return logError(logger, statusWithDetails(codes.OutOfRange, fmt.Sprintf("couldn't watch laptops: %v", err),
+				errorInfo(ReasonResumeTokenExpired, map[string]string{"resume_token": req.GetResumeToken()}),
+			))
+		default:
+			return logError(logger, status.Errorf(codes.Internal, "couldn't watch laptops: %v", err))
+		}
+	}
+	defer subscription.Close()
+
+	for {
+		event, err := subscription.Next(stream.Context())
+		if err != nil {
+			if errors.Is(err, ErrSubscriberOverflow) {
+				return logError(logger, statusWithDetails(codes.ResourceExhausted, fmt.Sprintf("%v Resume from the last received token.", err),
+					errorInfo(ReasonWatchOverflow, nil),
+					retryInfo(0),
+				))
+			}
+			if ctxErr := contextError(stream.Context(), logger); ctxErr != nil {
+				return ctxErr
+			}
+			return logError(logger, status.Errorf(codes.Unavailable, "watch stopped: %v", err))
+		}
+		if !tenantOwns(stream.Context(), event.GetLaptop().GetId()) {
+			continue
+		}
+
+		err = stream.Send(&pb.WatchLaptopsResponse{Event: event})
+		if err != nil {
+			return logError(logger, status.Errorf(codes.Unknown, "couldn't send event: %v", err))
+		}
+		logger.Debug("sent laptop event", "laptop_id", event.GetLaptop().GetId(), "type", event.GetType().String())
+	}
+}
+
+// Client streaming RPC creating many laptops at once. A laptop that can't be created
+// doesn't stop the import, its error is reported in the response.
+func (s *LaptopServer) ImportLaptops(stream pb.LaptopService_ImportLaptopsServer) error {
+	logger := loggerFromContext(stream.Context())
+	logger.Info("received import-laptops request")
+
+	res := &pb.ImportLaptopsResponse{}
+	dryRun := false
+	// ids checked so far in a dry run, since nothing is saved to catch duplicates.
+	checked := make(map[string]bool)
+	for index := uint32(1); ; index++ {
+		if err := contextError(stream.Context(), logger); err != nil {
+			return err
+		}
+		req, err := stream.Recv()
+		if err == io.EOF {
+			logger.Debug("no more laptops to import")
+			break
+		}
+		if err != nil {
+			return logError(logger, status.Errorf(codes.Unknown, "couldn't receive laptop: %v", err))
+		}
+
+		laptop := req.GetLaptop()
+		dryRun = req.GetDryRun()
+		err = ValidateLaptop(laptop)
+		if err == nil {
+			err = s.normalizePrice(laptop)
+		}
+		if err == nil {
+			err = s.assignLaptopId(laptop)
+		}
+		if err == nil && dryRun {
+			err = s.checkNewLaptop(stream.Context(), laptop, checked)
+		} else if err == nil {
+			err = s.saveLaptop(stream.Context(), laptop)
+		}
+
+		result := &pb.ImportLaptopResult{Index: index, Id: laptop.GetId()}
+		if err != nil {
+			result.Error = status.Convert(err).Message()
+			result.Code = status.Code(err).String()
+			res.FailedCount++
+			logger.Debug("couldn't import laptop", "index", index, "laptop_id", laptop.GetId(), "error", result.Error)
+		} else {
+			res.ImportedCount++
+			logger.Debug("imported laptop", "index", index, "laptop_id", laptop.GetId())
+		}
+		res.Results = append(res.Results, result)
+	}
+
+	err := stream.SendAndClose(res)
+	if err != nil {
+		return logError(logger, status.Errorf(codes.Unknown, "couldn't send response: %v", err))
+	}
+
+	logger.Info("imported laptops", "imported", res.ImportedCount, "failed", res.FailedCount, "dry_run", dryRun)
+	return nil
+}
+
+// Server streaming RPC sending the whole catalog, or the laptops matching the filter.
+func (s *LaptopServer) ExportLaptops(req *pb.ExportLaptopsRequest, stream pb.LaptopService_ExportLaptopsServer) error {
+	logger := loggerFromContext(stream.Context())
+	logger.Info("received export-laptops request", "filter", req.GetFilter().String())
+
+	count := 0
+	err := tenantLaptopStore(stream.Context(), s.LaptopStore).Search(stream.Context(), unlimitedPrice(req.GetFilter()), func(laptop *pb.Laptop) error {
+		err := stream.Send(&pb.ExportLaptopsResponse{Laptop: laptop})
+		if err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		if ctxErr := contextError(stream.Context(), logger); ctxErr != nil {
+			return ctxErr
+		}
+		return logError(logger, status.Errorf(codes.Internal, "couldn't export laptops: %v", err))
+	}
+
+	logger.Info("exported laptops", "count", count)
+	return nil
+}
+
+// Unary RPC comparing laptops spec by spec, with the best value of each spec.
+func (s *LaptopServer) CompareLaptops(ctx context.Context, req *pb.CompareLaptopsRequest) (*pb.CompareLaptopsResponse, error) {
+	logger := loggerFromContext(ctx)
+	logger.Info("received compare-laptops request", "laptop_ids", req.GetLaptopIds(), "currency_code", req.GetCurrencyCode())
+
+	ids := req.GetLaptopIds()
+	if len(ids) < minComparedLaptops || len(ids) > maxComparedLaptops {
+		return nil, logError(logger, status.Errorf(codes.InvalidArgument, "can compare %d to %d laptops, got %d", minComparedLaptops, maxComparedLaptops, len(ids)))
+	}
+
+	currencyCode := req.GetCurrencyCode()
+	if currencyCode == "" {
+		currencyCode = BaseCurrency
+	}
+
+	seen := make(map[string]bool)
+	res := &pb.CompareLaptopsResponse{}
+	for _, id := range ids {
+		if seen[id] {
+			return nil, logError(logger, status.Errorf(codes.InvalidArgument, "laptop is compared twice: %s", id))
+		}
+		seen[id] = true
+
+		laptop, err := tenantLaptopStore(ctx, s.LaptopStore).Find(id)
+		if errors.Is(err, ErrNotFound) {
+			return nil, logError(logger, laptopNotFoundError(id))
+		}
+		if err != nil {
+			return nil, logError(logger, status.Errorf(codes.Internal, "couldn't find laptop: %v", err))
+		}
+		if err := s.localizePrice(laptop, currencyCode); err != nil {
+			return nil, logError(logger, err)
+		}
+		res.Laptops = append(res.Laptops, laptop)
+	}
+
+	res.Rows = ComparisonTable(res.Laptops)
+	return res, nil
+}
+
+// Unary RPC recommending the laptops with the nearest specs to a laptop.
+func (s *LaptopServer) RecommendSimilar(ctx context.Context, req *pb.RecommendSimilarRequest) (*pb.RecommendSimilarResponse, error) {
+	logger := loggerFromContext(ctx)
+	logger.Info("received recommend-similar request", "laptop_id", req.GetLaptopId(), "k", req.GetK())
+
+	if s.Similarity == nil {
+		return nil, logError(logger, status.Error(codes.Unimplemented, "similar laptop recommendations are disabled"))
+	}
+	k := int(req.GetK())
+	if k == 0 {
+		k = defaultSimilarLaptops
+	}
+	if k > maxSimilarLaptops {
+		return nil, logError(logger, status.Errorf(codes.InvalidArgument, "k must be at most %d", maxSimilarLaptops))
+	}
+	currencyCode := req.GetCurrencyCode()
+	if currencyCode == "" {
+		currencyCode = BaseCurrency
+	}
+
+	// the index holds the laptops of every tenant.
+	if !tenantOwns(ctx, req.GetLaptopId()) {
+		return nil, logError(logger, laptopNotFoundError(req.GetLaptopId()))
+	}
+	owned := func(laptopId string) bool { return tenantOwns(ctx, laptopId) }
+	neighbors, err := s.Similarity.NearestMatching(req.GetLaptopId(), k, req.GetFeatureWeights(), owned)
+	if errors.Is(err, ErrNotFound) {
+		return nil, logError(logger, laptopNotFoundError(req.GetLaptopId()))
+	}
+	if err != nil {
+		return nil, logError(logger, status.Error(codes.InvalidArgument, err.Error()))
+	}
+
+	res := &pb.RecommendSimilarResponse{}
+	for _, neighbor := range neighbors {
+		laptop, err := tenantLaptopStore(ctx, s.LaptopStore).Find(neighbor.LaptopId)
+		if errors.Is(err, ErrNotFound) {
+			// deleted since the query.
+			continue
+		}
+		if err != nil {
+			return nil, logError(logger, status.Errorf(codes.Internal, "couldn't find laptop: %v", err))
+		}
+		if err := s.localizePrice(laptop, currencyCode); err != nil {
+			return nil, logError(logger, err)
+		}
+		res.Laptops = append(res.Laptops, &pb.SimilarLaptop{Laptop: laptop, Distance: neighbor.Distance})
+	}
+	return res, nil
+}
+
+// Unary RPC recommending laptops to the caller from the ratings of users with similar taste.
+func (s *LaptopServer) RecommendForUser(ctx context.Context, req *pb.RecommendForUserRequest) (*pb.RecommendForUserResponse, error) {
+	userId := CallerIdentity(ctx)
+	logger := loggerFromContext(ctx)
+	logger.Info("received recommend-for-user request", "user_id", userId, "limit", req.GetLimit())
+
+	if s.Recommendations == nil {
+		return nil, logError(logger, status.Error(codes.Unimplemented, "user recommendations are disabled"))
+	}
+	limit := int(req.GetLimit())
+	if limit == 0 {
+		limit = defaultRecommendations
+	}
+	if limit > maxRecommendations {
+		return nil, logError(logger, status.Errorf(codes.InvalidArgument, "limit must be at most %d", maxRecommendations))
+	}
+
+	// the model may still know laptops deleted since its last refresh.
+	available := func(laptopId string) bool {
+		_, err := tenantLaptopStore(ctx, s.LaptopStore).Find(laptopId)
+		return err == nil
+	}
+	recommendations, personalized := s.Recommendations.Recommend(userId, limit, available)
+
+	res := &pb.RecommendForUserResponse{Personalized: personalized}
+	for _, recommendation := range recommendations {
+		res.Laptops = append(res.Laptops, &pb.RecommendedLaptop{LaptopId: recommendation.LaptopId, Score: recommendation.Score})
+	}
+	return res, nil
+}
+
+// Keeps Laptop.price the BaseCurrency amount of the list price, which the store filters on.
+// Laptops with only a price are taken as priced in BaseCurrency.
+func (s *LaptopServer) normalizePrice(laptop *pb.Laptop) error {
+	if laptop.ListPrice == nil {
+		listPrice, err := MoneyFromFloat(BaseCurrency, laptop.Price)
+		if err != nil {
+			return moneyError(err)
 		}
-		log.Println("Rated laptop with id", laptopId)
+		laptop.ListPrice = listPrice
+		return nil
+	}
+
+	price, err := ConvertMoney(s.exchangeRates(), laptop.ListPrice, BaseCurrency)
+	if err != nil {
+		return moneyError(err)
+	}
+	laptop.Price = MoneyToFloat(price)
+	return nil
+}
+
+// Shows the laptop prices in the given currency.
+func (s *LaptopServer) localizePrice(laptop *pb.Laptop, currencyCode string) error {
+	return localizeLaptopPrice(s.exchangeRates(), laptop, currencyCode)
+}
 
+func localizeLaptopPrice(rates ExchangeRateProvider, laptop *pb.Laptop, currencyCode string) error {
+	listPrice, err := laptopListPrice(laptop)
+	if err == nil {
+		listPrice, err = ConvertMoney(rates, listPrice, currencyCode)
 	}
+	if err != nil {
+		return moneyError(err)
+	}
+	laptop.ListPrice = listPrice
+	laptop.Price = MoneyToFloat(listPrice)
 	return nil
 }
 
-func logError(err error) error {
+// List price of the laptop, taken from its BaseCurrency price if it has none.
+func laptopListPrice(laptop *pb.Laptop) (*pb.Money, error) {
+	if listPrice := laptop.GetListPrice(); listPrice != nil {
+		return listPrice, nil
+	}
+	return MoneyFromFloat(BaseCurrency, laptop.GetPrice())
+}
+
+// The filter with its max price in BaseCurrency, which the store compares, rather than in currencyCode.
+func baseCurrencyFilter(rates ExchangeRateProvider, filter *pb.Filter, currencyCode string) (*pb.Filter, error) {
+	if maxPrice := filter.GetMaxPrice(); math.IsNaN(maxPrice) || math.IsInf(maxPrice, 0) {
+		return nil, status.Errorf(codes.InvalidArgument, "max price must be a finite number: %v", maxPrice)
+	}
+	if filter.GetMaxPrice() > 0 && currencyCode != BaseCurrency {
+		maxPrice, err := MoneyFromFloat(currencyCode, filter.GetMaxPrice())
+		if err == nil {
+			maxPrice, err = ConvertMoney(rates, maxPrice, BaseCurrency)
+		}
+		if err != nil {
+			return nil, moneyError(err)
+		}
+		filter = proto.Clone(filter).(*pb.Filter)
+		filter.MaxPrice = MoneyToFloat(maxPrice)
+	} else if _, err := rates.Rate(BaseCurrency, currencyCode); err != nil {
+		return nil, unsupportedCurrencyError(err)
+	}
+	return filter, nil
+}
+
+func (s *LaptopServer) exchangeRates() ExchangeRateProvider {
+	if s.ExchangeRates == nil {
+		return NewStaticExchangeRates(BaseCurrency, nil)
+	}
+	return s.ExchangeRates
+}
+
+// Checks the id of a laptop given by the client, or generates one if there's none.
+func (s *LaptopServer) assignLaptopId(laptop *pb.Laptop) error {
+	if laptop == nil {
+		return status.Error(codes.InvalidArgument, "laptop is required")
+	}
+
+	if len(laptop.Id) > 0 {
+		// Check valid UUID
+		_, err := uuid.Parse(laptop.Id)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "Lapotop ID is not a valid UUID: %v", err)
+		}
+	} else {
+		// Generate uuid
+		id, err := uuid.NewRandom()
+		if err != nil {
+			return status.Errorf(codes.Internal, "Couldn't generate laptop UUID: %v", err)
+		}
+		laptop.Id = id.String() // conver UUID to string format.
+	}
+	return nil
+}
+
+func (s *LaptopServer) saveLaptop(ctx context.Context, laptop *pb.Laptop) error {
+	err := tenantLaptopStore(ctx, s.LaptopStore).Save(laptop)
+	if err != nil {
+		if errors.Is(err, ErrAlreadyExists) {
+			return laptopAlreadyExistsError(laptop.Id)
+		} else {
+			return status.Errorf(codes.Internal, "Couldn't save laptop to store: %v", err)
+		}
+	}
+	return nil
+}
+
+// Fails like saveLaptop would, without saving anything.
+func (s *LaptopServer) checkNewLaptop(ctx context.Context, laptop *pb.Laptop, checked map[string]bool) error {
+	if found, _ := tenantLaptopStore(ctx, s.LaptopStore).Find(laptop.Id); found != nil || checked[laptop.Id] {
+		return laptopAlreadyExistsError(laptop.Id)
+	}
+	checked[laptop.Id] = true
+	return nil
+}
+
+// Failing to queue a webhook doesn't fail the call, the change is already saved.
+func (s *LaptopServer) notify(logger *slog.Logger, payload *pb.WebhookPayload) {
+	if s.Webhooks == nil {
+		return
+	}
+	if err := s.Webhooks.Notify(payload); err != nil {
+		logger.Error("couldn't queue webhook", "event_type", payload.GetEventType(), "error", err)
+	}
+}
+
+func logError(logger *slog.Logger, err error) error {
 	if err != nil {
-		log.Print(err)
+		logger.Error(err.Error())
 	}
 	return err
 }
 
-func contextError(ctx context.Context, text ...string) error {
+func contextError(ctx context.Context, logger *slog.Logger) error {
 	switch ctx.Err() {
 	case context.DeadlineExceeded:
-		log.Printf("deadline exceeded. Aborting req with laptop-id %s", text)
-		return logError(status.Error(codes.DeadlineExceeded, "Deadline exceeded."))
+		return logError(logger, status.Error(codes.DeadlineExceeded, "Deadline exceeded."))
 
 	case context.Canceled:
-		log.Printf("context cancelled. Aborting req with laptop-id %s", text)
-		return logError(status.Error(codes.Canceled, "context cancelled."))
+		return logError(logger, status.Error(codes.Canceled, "context cancelled."))
 
 	default:
 		return nil
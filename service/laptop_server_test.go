@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -84,3 +85,104 @@ func TestServerCreateLaptop(t *testing.T) {
 		})
 	}
 }
+
+func TestServerCreateLaptopValidation(t *testing.T) {
+	laptop := sample.NewLaptop()
+	laptop.Brand = ""
+	laptop.Price = -1
+	laptop.Cpu.Cores = 8
+	laptop.Cpu.Threads = 4
+	laptop.Cpu.MaxGhz = laptop.Cpu.MinGhz - 1
+	laptop.Memory.Unit = pb.Memory_UNKNOWN
+	laptop.Storage[1].Memory.Value = 0
+	laptop.Screen.Resolution = nil
+
+	laptopStore := service.NewMemoryLaptopStore()
+	server := service.NewLaptopServer(laptopStore, nil, nil)
+	_, err := server.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: laptop})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.Zero(t, laptopStore.Count())
+
+	// every violation is reported at once.
+	var badRequest *errdetails.BadRequest
+	for _, detail := range status.Convert(err).Details() {
+		if detail, ok := detail.(*errdetails.BadRequest); ok {
+			badRequest = detail
+		}
+	}
+	require.NotNil(t, badRequest)
+
+	fields := []string{}
+	for _, violation := range badRequest.GetFieldViolations() {
+		require.NotEmpty(t, violation.GetDescription())
+		fields = append(fields, violation.GetField())
+	}
+	require.Equal(t, []string{
+		"laptop.brand",
+		"laptop.price",
+		"laptop.cpu.threads",
+		"laptop.cpu.max_ghz",
+		"laptop.memory.unit",
+		"laptop.storage[1].memory.value",
+		"laptop.screen.resolution",
+	}, fields)
+
+	laptop = sample.NewLaptop()
+	laptop.Cpu = nil
+	laptop.Weight = &pb.Laptop_WeightLb{WeightLb: 0}
+	_, err = server.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: laptop})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.Contains(t, status.Convert(err).Message(), "laptop.cpu is required")
+	require.Contains(t, status.Convert(err).Message(), "laptop.weight_lb must be greater than 0")
+}
+
+func TestServerCompareLaptops(t *testing.T) {
+	laptopStore := service.NewMemoryLaptopStore()
+	server := service.NewLaptopServer(laptopStore, nil, nil)
+
+	fast, light := sample.NewLaptop(), sample.NewLaptop()
+	fast.Price, light.Price = 2000, 1000
+	fast.Cpu.Cores, light.Cpu.Cores = 8, 4
+	fast.Memory = &pb.Memory{Value: 32, Unit: pb.Memory_GIGABYTE}
+	light.Memory = &pb.Memory{Value: 16384, Unit: pb.Memory_MEGABYTE}
+	fast.Storage = []*pb.Storage{{Driver: pb.Storage_SSD, Memory: &pb.Memory{Value: 1, Unit: pb.Memory_TERABYTE}}}
+	light.Storage = []*pb.Storage{
+		{Driver: pb.Storage_SSD, Memory: &pb.Memory{Value: 256, Unit: pb.Memory_GIGABYTE}},
+		{Driver: pb.Storage_SSD, Memory: &pb.Memory{Value: 256, Unit: pb.Memory_GIGABYTE}},
+	}
+	fast.Weight = &pb.Laptop_WeightLb{WeightLb: 5}
+	light.Weight = &pb.Laptop_WeightKg{WeightKg: 1.2}
+	require.NoError(t, laptopStore.Save(fast))
+	require.NoError(t, laptopStore.Save(light))
+
+	res, err := server.CompareLaptops(context.Background(), &pb.CompareLaptopsRequest{LaptopIds: []string{fast.Id, light.Id}})
+	require.NoError(t, err)
+	require.Len(t, res.GetLaptops(), 2)
+
+	rows := map[string]*pb.ComparisonRow{}
+	for _, row := range res.GetRows() {
+		rows[row.GetSpec()] = row
+	}
+	require.NotContains(t, rows, "storage.hdd")
+
+	price := rows["price"]
+	require.Equal(t, "USD", price.GetUnit())
+	require.False(t, price.GetHigherIsBetter())
+	require.True(t, price.GetCells()[1].GetBest())
+	require.Equal(t, 100.0, price.GetCells()[0].GetDifferencePercent())
+
+	require.True(t, rows["cpu.cores"].GetCells()[0].GetBest())
+	require.Equal(t, -50.0, rows["cpu.cores"].GetCells()[1].GetDifferencePercent())
+	require.Equal(t, []float64{32, 16}, []float64{rows["memory"].GetCells()[0].GetValue(), rows["memory"].GetCells()[1].GetValue()})
+	require.Equal(t, 512.0, rows["storage.ssd"].GetCells()[1].GetValue())
+	require.InDelta(t, 2.268, rows["weight"].GetCells()[0].GetValue(), 0.001)
+	require.True(t, rows["weight"].GetCells()[1].GetBest())
+
+	invalid := [][]string{{fast.Id}, {fast.Id, fast.Id}}
+	for _, ids := range invalid {
+		_, err := server.CompareLaptops(context.Background(), &pb.CompareLaptopsRequest{LaptopIds: ids})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+	_, err = server.CompareLaptops(context.Background(), &pb.CompareLaptopsRequest{LaptopIds: []string{fast.Id, "missing"}})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
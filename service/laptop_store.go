@@ -5,23 +5,31 @@ import (
 	"errors"
 	"fmt"
 	"go-grpc-pcbook/pb"
-	"log"
+	"math"
 	"sync"
 
 	"github.com/jinzhu/copier"
+	"google.golang.org/protobuf/proto"
 )
 
 var (
 	ErrAlreadyExists = errors.New("UUID already exists.")
+	ErrNotFound      = errors.New("laptop not found.")
 )
 
 type LaptopStore interface {
 	// Saves laptop to the store
 	Save(laptop *pb.Laptop) error
-	// Finds laptop in the store
+	// Finds laptop in the store, ErrNotFound if it's not there
 	Find(id string) (*pb.Laptop, error)
+	// Replaces a laptop already in the store
+	Update(laptop *pb.Laptop) error
+	// Removes laptop from the store
+	Delete(id string) error
 	// Filters laptops from the store. Returns one by one via found func.
 	Search(ctx context.Context, filter *pb.Filter, found func(laptop *pb.Laptop) error) error
+	// Number of laptops in the store
+	Count() int
 }
 
 type MemoryLaptopStore struct {
@@ -59,7 +67,7 @@ func (m *MemoryLaptopStore) Find(id string) (*pb.Laptop, error) {
 	defer m.mutex.RUnlock()
 	laptop, ok := m.data[id]
 	if !ok {
-		return nil, fmt.Errorf("Given laptop it's not in the store")
+		return nil, ErrNotFound
 	}
 
 	// deep copy
@@ -67,6 +75,35 @@ func (m *MemoryLaptopStore) Find(id string) (*pb.Laptop, error) {
 
 }
 
+func (m *MemoryLaptopStore) Update(laptop *pb.Laptop) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.data[laptop.Id] == nil {
+		return ErrNotFound
+	}
+
+	other, err := deepCopy(laptop)
+	if err != nil {
+		return err
+	}
+
+	m.data[laptop.Id] = other
+	return nil
+}
+
+func (m *MemoryLaptopStore) Delete(id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.data[id] == nil {
+		return ErrNotFound
+	}
+
+	delete(m.data, id)
+	return nil
+}
+
 func (m *MemoryLaptopStore) Search(ctx context.Context, filter *pb.Filter, found func(laptop *pb.Laptop) error) error {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
@@ -74,7 +111,7 @@ func (m *MemoryLaptopStore) Search(ctx context.Context, filter *pb.Filter, found
 	for _, laptop := range m.data {
 		// Check ctx deadline exceeded before saving to storage.
 		if ctx.Err() == context.DeadlineExceeded || ctx.Err() == context.Canceled {
-			log.Printf("context cancelled. Aborting search-laptop req with filter %s", filter)
+			loggerFromContext(ctx).Info("context cancelled, aborting search-laptop request", "filter", filter.String())
 			return errors.New("Context cancelled.")
 		}
 		//time.Sleep(time.Second)
@@ -95,6 +132,12 @@ func (m *MemoryLaptopStore) Search(ctx context.Context, filter *pb.Filter, found
 	return nil
 }
 
+func (m *MemoryLaptopStore) Count() int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return len(m.data)
+}
+
 func deepCopy(laptop *pb.Laptop) (*pb.Laptop, error) {
 	other := &pb.Laptop{}
 	err := copier.Copy(other, laptop)
@@ -112,6 +155,18 @@ func isQualified(filter *pb.Filter, laptop *pb.Laptop) bool {
 	return true
 }
 
+// Copy of the filter where max_price 0 means no limit rather than free laptops only.
+func unlimitedPrice(filter *pb.Filter) *pb.Filter {
+	other := &pb.Filter{}
+	if filter != nil {
+		other = proto.Clone(filter).(*pb.Filter)
+	}
+	if other.MaxPrice == 0 {
+		other.MaxPrice = math.Inf(1)
+	}
+	return other
+}
+
 // converts memory to bit for comparing purposes.
 func toBit(memory *pb.Memory) uint64 {
 	val := memory.GetValue()
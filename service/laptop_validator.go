@@ -0,0 +1,231 @@
+package service
+
+import (
+	"fmt"
+	"go-grpc-pcbook/pb"
+	"math"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// A check on one field of a message, named by its proto field name.
+type validationRule struct {
+	field       string
+	description string
+	valid       func(message protoreflect.Message) bool
+}
+
+// Rules of every message found in a laptop. Nested messages are checked when they're set.
+var laptopRules = map[protoreflect.FullName][]validationRule{
+	fullName(&pb.Laptop{}): {
+		required("brand"),
+		required("name"),
+		required("cpu"),
+		required("memory"),
+		notNegative("price"),
+		finite("price"),
+		positiveIfSet("weight_kg"),
+		finite("weight_kg"),
+		positiveIfSet("weight_lb"),
+		finite("weight_lb"),
+		notNegative("release_year"),
+	},
+	fullName(&pb.CPU{}): {
+		required("brand"),
+		required("name"),
+		positive("cores"),
+		atLeast("threads", "cores"),
+		positive("min_ghz"),
+		finite("min_ghz"),
+		atLeast("max_ghz", "min_ghz"),
+		finite("max_ghz"),
+	},
+	fullName(&pb.GPU{}): {
+		required("brand"),
+		required("name"),
+		positive("min_ghz"),
+		finite("min_ghz"),
+		atLeast("max_ghz", "min_ghz"),
+		finite("max_ghz"),
+		required("memory"),
+	},
+	fullName(&pb.Memory{}): {
+		positive("value"),
+		knownEnum("unit"),
+	},
+	fullName(&pb.Storage{}): {
+		knownEnum("driver"),
+		required("memory"),
+	},
+	fullName(&pb.Screen{}): {
+		positive("size_inch"),
+		finite("size_inch"),
+		required("resolution"),
+		knownEnum("panel"),
+	},
+	fullName(&pb.Screen_Resolution{}): {
+		positive("width"),
+		positive("height"),
+	},
+	fullName(&pb.Keyboard{}): {
+		knownEnum("layout"),
+	},
+	fullName(&pb.Money{}): {
+		currencyCode("currency_code"),
+		notNegative("units"),
+		notNegative("nanos"),
+		below("nanos", nanosPerUnit),
+	},
+}
+
+// Returns an InvalidArgument status with every broken rule as a BadRequest field violation.
+func ValidateLaptop(laptop *pb.Laptop) error {
+	if laptop == nil {
+		return status.Error(codes.InvalidArgument, "laptop is required")
+	}
+
+	violations := validateMessage(laptop.ProtoReflect(), "laptop", nil)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return violationsError("invalid laptop", errorInfo(ReasonInvalidLaptop, map[string]string{"laptop_id": laptop.GetId()}), violations)
+}
+
+// InvalidArgument status listing the violations in its message and as BadRequest details.
+func violationsError(message string, info *errdetails.ErrorInfo, violations []*errdetails.BadRequest_FieldViolation) error {
+	messages := make([]string, len(violations))
+	for i, violation := range violations {
+		messages[i] = violation.Field + " " + violation.Description
+	}
+	return statusWithDetails(codes.InvalidArgument, message+": "+strings.Join(messages, ", "),
+		info,
+		&errdetails.BadRequest{FieldViolations: violations},
+	)
+}
+
+// Checks message and the messages set in it, in field order.
+func validateMessage(message protoreflect.Message, path string, violations []*errdetails.BadRequest_FieldViolation) []*errdetails.BadRequest_FieldViolation {
+	for _, rule := range laptopRules[message.Descriptor().FullName()] {
+		if !rule.valid(message) {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{
+				Field:       path + "." + rule.field,
+				Description: rule.description,
+			})
+		}
+	}
+
+	fields := message.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		if field.Message() == nil || field.IsMap() || !message.Has(field) {
+			continue
+		}
+
+		fieldPath := path + "." + string(field.Name())
+		if field.IsList() {
+			list := message.Get(field).List()
+			for j := 0; j < list.Len(); j++ {
+				violations = validateMessage(list.Get(j).Message(), fmt.Sprintf("%s[%d]", fieldPath, j), violations)
+			}
+		} else {
+			violations = validateMessage(message.Get(field).Message(), fieldPath, violations)
+		}
+	}
+	return violations
+}
+
+func required(field string) validationRule {
+	return validationRule{field, "is required", func(message protoreflect.Message) bool {
+		return message.Has(fieldByName(message, field))
+	}}
+}
+
+func positive(field string) validationRule {
+	return validationRule{field, "must be greater than 0", func(message protoreflect.Message) bool {
+		return number(message, field) > 0
+	}}
+}
+
+func positiveIfSet(field string) validationRule {
+	return validationRule{field, "must be greater than 0", func(message protoreflect.Message) bool {
+		return !message.Has(fieldByName(message, field)) || number(message, field) > 0
+	}}
+}
+
+func notNegative(field string) validationRule {
+	return validationRule{field, "must not be negative", func(message protoreflect.Message) bool {
+		return number(message, field) >= 0
+	}}
+}
+
+// NaN and infinities pass comparisons they shouldn't, or can't be converted.
+func finite(field string) validationRule {
+	return validationRule{field, "must be a finite number", func(message protoreflect.Message) bool {
+		value := number(message, field)
+		return !math.IsNaN(value) && !math.IsInf(value, 0)
+	}}
+}
+
+// The field can't be smaller than another one of the same message, like max_ghz and min_ghz.
+func atLeast(field, other string) validationRule {
+	return validationRule{field, "must be at least " + other, func(message protoreflect.Message) bool {
+		return number(message, field) >= number(message, other)
+	}}
+}
+
+func below(field string, limit float64) validationRule {
+	return validationRule{field, fmt.Sprintf("must be less than %v", limit), func(message protoreflect.Message) bool {
+		return number(message, field) < limit
+	}}
+}
+
+func currencyCode(field string) validationRule {
+	return validationRule{field, "must be a 3 letter ISO 4217 code", func(message protoreflect.Message) bool {
+		return validCurrencyCode(message.Get(fieldByName(message, field)).String())
+	}}
+}
+
+// The enum must be set to one of its values other than UNKNOWN.
+func knownEnum(field string) validationRule {
+	return validationRule{field, "must be set to a known value", func(message protoreflect.Message) bool {
+		descriptor := fieldByName(message, field)
+		value := message.Get(descriptor).Enum()
+		return value != 0 && descriptor.Enum().Values().ByNumber(value) != nil
+	}}
+}
+
+func number(message protoreflect.Message, field string) float64 {
+	descriptor := fieldByName(message, field)
+	value := message.Get(descriptor)
+
+	switch descriptor.Kind() {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return float64(value.Int())
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return float64(value.Uint())
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return value.Float()
+	default:
+		panic(fmt.Sprintf("field %s is not a number", descriptor.FullName()))
+	}
+}
+
+// Rules name fields that exist, a typo is a programming error.
+func fieldByName(message protoreflect.Message, field string) protoreflect.FieldDescriptor {
+	descriptor := message.Descriptor().Fields().ByName(protoreflect.Name(field))
+	if descriptor == nil {
+		panic(fmt.Sprintf("%s has no field %s", message.Descriptor().FullName(), field))
+	}
+	return descriptor
+}
+
+func fullName(message proto.Message) protoreflect.FullName {
+	return message.ProtoReflect().Descriptor().FullName()
+}
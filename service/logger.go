@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Metadata key used to correlate every log line of a single RPC.
+const RequestIdKey = "x-request-id"
+
+type loggerKey struct{}
+type requestIdKey struct{}
+
+// Creates a leveled logger writing "json" or "text" (logfmt) lines.
+func NewLogger(w io.Writer, format string, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+
+	options := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(format) {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	case "text", "logfmt":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q", format)
+	}
+}
+
+// Returns the request scoped logger set by RequestLogger, or the default one.
+func loggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// Interceptors that give every RPC a request id and a logger carrying it.
+type RequestLogger struct {
+	logger *slog.Logger
+}
+
+func NewRequestLogger(logger *slog.Logger) *RequestLogger {
+	return &RequestLogger{logger}
+}
+
+func (l *RequestLogger) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, logger := l.newContext(ctx, info.FullMethod)
+		grpc.SetHeader(ctx, metadata.Pairs(RequestIdKey, requestId(ctx)))
+
+		start := time.Now()
+		res, err := handler(ctx, req)
+		logDone(logger, start, err)
+		return res, err
+	}
+}
+
+func (l *RequestLogger) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, logger := l.newContext(stream.Context(), info.FullMethod)
+		stream.SetHeader(metadata.Pairs(RequestIdKey, requestId(ctx)))
+
+		start := time.Now()
+		err := handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+		logDone(logger, start, err)
+		return err
+	}
+}
+
+// Reuses the caller request id if any, so calls can be followed across services.
+func (l *RequestLogger) newContext(ctx context.Context, method string) (context.Context, *slog.Logger) {
+	id := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestIdKey); len(values) > 0 {
+			id = values[0]
+		}
+	}
+	if id == "" {
+		id = uuid.NewString()
+	}
+
+	peerAddress := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		peerAddress = p.Addr.String()
+	}
+
+	logger := l.logger.With("request_id", id, "method", method, "peer", peerAddress)
+	ctx = context.WithValue(ctx, requestIdKey{}, id)
+	return context.WithValue(ctx, loggerKey{}, logger), logger
+}
+
+func requestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+func logDone(logger *slog.Logger, start time.Time, err error) {
+	code := status.Code(err)
+	logger.Info("finished call", "code", code.String(), "duration", time.Since(start))
+}
+
+// Server stream with a replaced context.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
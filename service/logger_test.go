@@ -0,0 +1,106 @@
+package service_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"go-grpc-pcbook/pb"
+	"go-grpc-pcbook/sample"
+	"go-grpc-pcbook/service"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Buffer safe to write from the server goroutines while the test reads it.
+type syncBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) lines(t *testing.T) []map[string]interface{} {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	lines := []map[string]interface{}{}
+	scanner := bufio.NewScanner(bytes.NewReader(b.buffer.Bytes()))
+	for scanner.Scan() {
+		line := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestNewLogger(t *testing.T) {
+	_, err := service.NewLogger(&bytes.Buffer{}, "json", "debug")
+	require.NoError(t, err)
+	_, err = service.NewLogger(&bytes.Buffer{}, "logfmt", "WARN")
+	require.NoError(t, err)
+	_, err = service.NewLogger(&bytes.Buffer{}, "xml", "info")
+	require.Error(t, err)
+	_, err = service.NewLogger(&bytes.Buffer{}, "json", "verbose")
+	require.Error(t, err)
+}
+
+func TestRequestLogger(t *testing.T) {
+	output := &syncBuffer{}
+	logger, err := service.NewLogger(output, "json", "info")
+	require.NoError(t, err)
+	requestLogger := service.NewRequestLogger(logger)
+
+	laptopServer := service.NewLaptopServer(service.NewMemoryLaptopStore(), nil, nil)
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(requestLogger.Unary()),
+		grpc.StreamInterceptor(requestLogger.Stream()),
+	)
+	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+
+	laptopClient := newTestLaptopClient(t, listener.Addr().String())
+
+	t.Run("Propagates the caller request id.", func(t *testing.T) {
+		laptop := sample.NewLaptop()
+		ctx := metadata.AppendToOutgoingContext(context.Background(), service.RequestIdKey, "req-123")
+
+		var header metadata.MD
+		_, err := laptopClient.CreateLaptop(ctx, &pb.CreateLaptopRequest{Laptop: laptop}, grpc.Header(&header))
+		require.NoError(t, err)
+		require.Equal(t, []string{"req-123"}, header.Get(service.RequestIdKey))
+
+		require.Eventually(t, func() bool {
+			for _, line := range output.lines(t) {
+				if line["msg"] == "saved laptop" {
+					return line["request_id"] == "req-123" &&
+						line["laptop_id"] == laptop.Id &&
+						line["method"] == "/pcbook.LaptopService/CreateLaptop" &&
+						line["peer"] != ""
+				}
+			}
+			return false
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Generates a request id.", func(t *testing.T) {
+		var header metadata.MD
+		_, err := laptopClient.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: sample.NewLaptop()}, grpc.Header(&header))
+		require.NoError(t, err)
+		require.Len(t, header.Get(service.RequestIdKey), 1)
+		require.NotEqual(t, "req-123", header.Get(service.RequestIdKey)[0])
+	})
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Records per RPC prometheus metrics through server interceptors.
+type Metrics struct {
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	messages *prometheus.CounterVec
+}
+
+func NewMetrics(registerer prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "pcbook",
+			Name:      "grpc_requests_total",
+			Help:      "Number of finished RPCs by method and status code.",
+		}, []string{"method", "code"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "pcbook",
+			Name:      "grpc_request_duration_seconds",
+			Help:      "Time taken to handle an RPC, streams included.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method"}),
+		messages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "pcbook",
+			Name:      "grpc_stream_messages_total",
+			Help:      "Number of stream messages by method and direction.",
+		}, []string{"method", "direction"}),
+	}
+	registerer.MustRegister(m.requests, m.latency, m.messages)
+	return m
+}
+
+func (m *Metrics) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		res, err := handler(ctx, req)
+		m.observe(info.FullMethod, start, err)
+		return res, err
+	}
+}
+
+func (m *Metrics) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, &monitoredStream{ServerStream: stream, method: info.FullMethod, metrics: m})
+		m.observe(info.FullMethod, start, err)
+		return err
+	}
+}
+
+func (m *Metrics) observe(method string, start time.Time, err error) {
+	m.requests.WithLabelValues(method, status.Code(err).String()).Inc()
+	m.latency.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// Counts messages going through a server stream.
+type monitoredStream struct {
+	grpc.ServerStream
+	method  string
+	metrics *Metrics
+}
+
+func (s *monitoredStream) SendMsg(msg interface{}) error {
+	err := s.ServerStream.SendMsg(msg)
+	if err == nil {
+		s.metrics.messages.WithLabelValues(s.method, "sent").Inc()
+	}
+	return err
+}
+
+func (s *monitoredStream) RecvMsg(msg interface{}) error {
+	err := s.ServerStream.RecvMsg(msg)
+	if err == nil {
+		s.metrics.messages.WithLabelValues(s.method, "received").Inc()
+	}
+	return err
+}
+
+// Registers gauges reporting the size of each store at scrape time.
+// Nil stores are skipped.
+func RegisterStoreMetrics(registerer prometheus.Registerer, laptopStore LaptopStore, imageStore ImageStore, ratingStore RatingStore) {
+	if laptopStore != nil {
+		registerer.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "pcbook",
+			Name:      "laptops",
+			Help:      "Number of laptops in the store.",
+		}, func() float64 { return float64(laptopStore.Count()) }))
+	}
+	if imageStore != nil {
+		registerer.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "pcbook",
+			Name:      "image_bytes",
+			Help:      "Bytes of laptop images saved on disk.",
+		}, func() float64 { return float64(imageStore.TotalBytes()) }))
+	}
+	if ratingStore != nil {
+		registerer.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "pcbook",
+			Name:      "ratings",
+			Help:      "Number of ratings received.",
+		}, func() float64 { return float64(ratingStore.Count()) }))
+	}
+}
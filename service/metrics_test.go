@@ -0,0 +1,86 @@
+package service_test
+
+import (
+	"context"
+	"go-grpc-pcbook/pb"
+	"go-grpc-pcbook/sample"
+	"go-grpc-pcbook/service"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestMetricsInterceptors(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics := service.NewMetrics(registry)
+
+	laptopStore := service.NewMemoryLaptopStore()
+	ratingStore := service.NewMemoryRatingStore()
+	service.RegisterStoreMetrics(registry, laptopStore, nil, ratingStore)
+
+	laptopServer := service.NewLaptopServer(laptopStore, nil, ratingStore)
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(metrics.Unary()),
+		grpc.StreamInterceptor(metrics.Stream()),
+	)
+	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+
+	laptopClient := newTestLaptopClient(t, listener.Addr().String())
+
+	laptop := sample.NewLaptop()
+	_, err = laptopClient.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: laptop})
+	require.NoError(t, err)
+	_, err = laptopClient.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: laptop})
+	require.Error(t, err)
+
+	stream, err := laptopClient.RateLaptop(context.Background())
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, stream.Send(&pb.RateLaptopRequest{LaptopId: laptop.Id, Score: 5}))
+	}
+	require.NoError(t, stream.CloseSend())
+	for {
+		_, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+
+	expected := `
+# HELP pcbook_grpc_requests_total Number of finished RPCs by method and status code.
+# TYPE pcbook_grpc_requests_total counter
+pcbook_grpc_requests_total{code="AlreadyExists",method="/pcbook.LaptopService/CreateLaptop"} 1
+pcbook_grpc_requests_total{code="OK",method="/pcbook.LaptopService/CreateLaptop"} 1
+pcbook_grpc_requests_total{code="OK",method="/pcbook.LaptopService/RateLaptop"} 1
+# HELP pcbook_grpc_stream_messages_total Number of stream messages by method and direction.
+# TYPE pcbook_grpc_stream_messages_total counter
+pcbook_grpc_stream_messages_total{direction="received",method="/pcbook.LaptopService/RateLaptop"} 2
+pcbook_grpc_stream_messages_total{direction="sent",method="/pcbook.LaptopService/RateLaptop"} 2
+# HELP pcbook_laptops Number of laptops in the store.
+# TYPE pcbook_laptops gauge
+pcbook_laptops 1
+# HELP pcbook_ratings Number of ratings received.
+# TYPE pcbook_ratings gauge
+pcbook_ratings 2
+`
+	// the server records metrics after the client already got its response.
+	require.Eventually(t, func() bool {
+		return testutil.GatherAndCompare(registry, strings.NewReader(expected),
+			"pcbook_grpc_requests_total", "pcbook_grpc_stream_messages_total", "pcbook_laptops", "pcbook_ratings") == nil
+	}, time.Second, 10*time.Millisecond)
+	count, err := testutil.GatherAndCount(registry, "pcbook_grpc_request_duration_seconds")
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-grpc-pcbook/pb"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+)
+
+// Currency of Laptop.price in the store, and of requests that don't ask for one.
+const BaseCurrency = "USD"
+
+const nanosPerUnit = 1_000_000_000
+
+var currencyCodePattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+var ErrInvalidMoney = errors.New("amount can't be represented as money.")
+
+// Exact amount of money, for arithmetic without float errors.
+func MoneyToRat(money *pb.Money) *big.Rat {
+	nanos := new(big.Int).Mul(big.NewInt(money.GetUnits()), big.NewInt(nanosPerUnit))
+	nanos.Add(nanos, big.NewInt(int64(money.GetNanos())))
+	return new(big.Rat).SetFrac(nanos, big.NewInt(nanosPerUnit))
+}
+
+// Money for the amount, rounded half away from zero to whole nanos.
+// ErrInvalidMoney if the units don't fit an int64.
+func MoneyFromRat(currencyCode string, amount *big.Rat) (*pb.Money, error) {
+	scaled := new(big.Rat).Mul(amount, new(big.Rat).SetInt64(nanosPerUnit))
+	nanos, remainder := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	// remainder has the sign of the amount.
+	if new(big.Int).Mul(new(big.Int).Abs(remainder), big.NewInt(2)).Cmp(scaled.Denom()) >= 0 {
+		nanos.Add(nanos, big.NewInt(int64(amount.Sign())))
+	}
+
+	units, fraction := new(big.Int).QuoRem(nanos, big.NewInt(nanosPerUnit), new(big.Int))
+	if !units.IsInt64() {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMoney, amount.FloatString(0))
+	}
+	return &pb.Money{CurrencyCode: currencyCode, Units: units.Int64(), Nanos: int32(fraction.Int64())}, nil
+}
+
+// Money for a decimal amount, like the legacy Laptop.price. Goes through the
+// shortest decimal form of the float so 0.1 is one tenth, not its binary approximation.
+// ErrInvalidMoney for NaN, infinities and amounts too large.
+func MoneyFromFloat(currencyCode string, amount float64) (*pb.Money, error) {
+	if math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMoney, amount)
+	}
+	rat, ok := new(big.Rat).SetString(strconv.FormatFloat(amount, 'f', -1, 64))
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMoney, amount)
+	}
+	return MoneyFromRat(currencyCode, rat)
+}
+
+func MoneyToFloat(money *pb.Money) float64 {
+	amount, _ := MoneyToRat(money).Float64()
+	return amount
+}
+
+func FormatMoney(money *pb.Money) string {
+	return fmt.Sprintf("%s %s", MoneyToRat(money).FloatString(2), money.GetCurrencyCode())
+}
+
+func validCurrencyCode(currencyCode string) bool {
+	return currencyCodePattern.MatchString(currencyCode)
+}
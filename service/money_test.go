@@ -0,0 +1,160 @@
+package service_test
+
+import (
+	"context"
+	"go-grpc-pcbook/pb"
+	"go-grpc-pcbook/sample"
+	"go-grpc-pcbook/service"
+	"io"
+	"math"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMoney(t *testing.T) {
+	money := func(money *pb.Money, err error) *pb.Money {
+		require.NoError(t, err)
+		return money
+	}
+
+	// 0.1 + 0.2 is exactly 0.3, unlike with floats.
+	sum := new(big.Rat).Add(service.MoneyToRat(money(service.MoneyFromFloat("USD", 0.1))), service.MoneyToRat(money(service.MoneyFromFloat("USD", 0.2))))
+	require.Equal(t, &pb.Money{CurrencyCode: "USD", Units: 0, Nanos: 300000000}, money(service.MoneyFromRat("USD", sum)))
+
+	// rounds half away from zero.
+	require.EqualValues(t, 1, money(service.MoneyFromRat("EUR", big.NewRat(2000000001, 2000000000))).GetNanos())
+	negative := money(service.MoneyFromRat("EUR", big.NewRat(-2000000001, 2000000000)))
+	require.EqualValues(t, -1, negative.GetUnits())
+	require.EqualValues(t, -1, negative.GetNanos())
+
+	require.Equal(t, "1499.99 USD", service.FormatMoney(&pb.Money{CurrencyCode: "USD", Units: 1499, Nanos: 990000000}))
+
+	for _, amount := range []float64{math.Inf(1), math.Inf(-1), math.NaN(), 1e300} {
+		_, err := service.MoneyFromFloat("USD", amount)
+		require.ErrorIs(t, err, service.ErrInvalidMoney, amount)
+	}
+	_, err := service.MoneyFromRat("USD", new(big.Rat).SetFrac(new(big.Int).Lsh(big.NewInt(1), 64), big.NewInt(1)))
+	require.ErrorIs(t, err, service.ErrInvalidMoney)
+}
+
+func TestExchangeRates(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "rates.json")
+	require.NoError(t, os.WriteFile(filename, []byte(`{"base": "USD", "rates": {"EUR": "0.5", "ARS": 400}}`), 0600))
+	rates, err := service.LoadExchangeRatesFile(filename)
+	require.NoError(t, err)
+
+	rate, err := rates.Rate("EUR", "ARS")
+	require.NoError(t, err)
+	require.Equal(t, big.NewRat(800, 1), rate)
+
+	converted, err := service.ConvertMoney(rates, &pb.Money{CurrencyCode: "USD", Units: 10, Nanos: 500000000}, "ARS")
+	require.NoError(t, err)
+	require.Equal(t, &pb.Money{CurrencyCode: "ARS", Units: 4200}, converted)
+
+	_, err = rates.Rate("USD", "GBP")
+	require.ErrorIs(t, err, service.ErrUnsupportedCurrency)
+
+	require.NoError(t, os.WriteFile(filename, []byte(`{"base": "USD", "rates": {"EUR": "-1"}}`), 0600))
+	_, err = service.LoadExchangeRatesFile(filename)
+	require.Error(t, err)
+
+	require.NoError(t, os.WriteFile(filename, []byte(`{"base": "EUR", "rates": {"ARS": 800}}`), 0600))
+	_, err = service.LoadExchangeRatesFile(filename)
+	require.ErrorContains(t, err, service.BaseCurrency)
+
+	require.NoError(t, os.WriteFile(filename, []byte(`{"base": "EUR", "rates": {"USD": 2, "ARS": 800}}`), 0600))
+	rates, err = service.LoadExchangeRatesFile(filename)
+	require.NoError(t, err)
+	rate, err = rates.Rate("USD", "ARS")
+	require.NoError(t, err)
+	require.Equal(t, big.NewRat(400, 1), rate)
+}
+
+func TestSearchLaptopCurrency(t *testing.T) {
+	laptopServer := service.NewLaptopServer(service.NewMemoryLaptopStore(), nil, nil)
+	laptopServer.ExchangeRates = service.NewStaticExchangeRates("USD", map[string]*big.Rat{
+		"EUR": big.NewRat(1, 2),
+		"ARS": big.NewRat(400, 1),
+	})
+	grpcServer := grpc.NewServer()
+	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+	laptopClient := newTestLaptopClient(t, listener.Addr().String())
+
+	// 1000 USD, 800 EUR = 1600 USD and 200000 ARS = 500 USD.
+	prices := []*pb.Money{
+		{CurrencyCode: "USD", Units: 1000},
+		{CurrencyCode: "EUR", Units: 800},
+		{CurrencyCode: "ARS", Units: 200000},
+	}
+	for _, price := range prices {
+		laptop := sample.NewLaptop()
+		laptop.ListPrice = price
+		_, err := laptopClient.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: laptop})
+		require.NoError(t, err)
+	}
+
+	search := func(req *pb.SearchLaptopRequest) ([]*pb.Laptop, error) {
+		stream, err := laptopClient.SearchLaptop(context.Background(), req)
+		require.NoError(t, err)
+
+		laptops := []*pb.Laptop{}
+		for {
+			res, err := stream.Recv()
+			if err == io.EOF {
+				return laptops, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			laptops = append(laptops, res.GetLaptop())
+		}
+	}
+
+	// max 600 EUR is 1200 USD.
+	laptops, err := search(&pb.SearchLaptopRequest{
+		Filter:       &pb.Filter{MaxPrice: 600},
+		CurrencyCode: "EUR",
+		Sort:         pb.SearchLaptopRequest_PRICE_DESCENDING,
+	})
+	require.NoError(t, err)
+	require.Len(t, laptops, 2)
+	require.Equal(t, &pb.Money{CurrencyCode: "EUR", Units: 500}, laptops[0].GetListPrice())
+	require.Equal(t, 500.0, laptops[0].GetPrice())
+	require.Equal(t, &pb.Money{CurrencyCode: "EUR", Units: 250}, laptops[1].GetListPrice())
+
+	laptops, err = search(&pb.SearchLaptopRequest{Filter: &pb.Filter{MaxPrice: 5000}, Sort: pb.SearchLaptopRequest_PRICE_ASCENDING})
+	require.NoError(t, err)
+	require.Len(t, laptops, 3)
+	require.Equal(t, []float64{500, 1000, 1600}, []float64{laptops[0].GetPrice(), laptops[1].GetPrice(), laptops[2].GetPrice()})
+
+	_, err = search(&pb.SearchLaptopRequest{Filter: &pb.Filter{MaxPrice: 5000}, CurrencyCode: "GBP"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	laptop := sample.NewLaptop()
+	laptop.ListPrice = &pb.Money{CurrencyCode: "GBP", Units: 1000}
+	_, err = laptopClient.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: laptop})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// amounts money can't hold are refused rather than crashing the server.
+	for _, price := range []float64{math.Inf(1), math.NaN(), 1e300} {
+		laptop := sample.NewLaptop()
+		laptop.Price = price
+		_, err = laptopClient.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: laptop})
+		require.Equal(t, codes.InvalidArgument, status.Code(err), price)
+
+		_, err = search(&pb.SearchLaptopRequest{Filter: &pb.Filter{MaxPrice: price}, CurrencyCode: "EUR"})
+		require.Equal(t, codes.InvalidArgument, status.Code(err), price)
+	}
+}
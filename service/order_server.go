@@ -0,0 +1,383 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-grpc-pcbook/pb"
+	"log/slog"
+	"math/big"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Units of one laptop a cart can hold.
+const maxCartItemQuantity = 1000
+
+// Cart and order RPCs of the storefront. Carts and orders belong to the caller, which
+// needs a client certificate.
+type OrderServer struct {
+	LaptopStore LaptopStore
+	OrderStore  OrderStore
+	// Rates to place orders in other currencies. Only BaseCurrency is supported if nil.
+	ExchangeRates ExchangeRateProvider
+	// Commits the reservations of checkouts. Checkouts can't have reservations if nil.
+	Inventory InventoryStore
+	// Grants the operator role, which marks orders paid or shipped. Nobody has it if nil.
+	Authorizer *Authorizer
+}
+
+func NewOrderServer(laptopStore LaptopStore, orderStore OrderStore) *OrderServer {
+	return &OrderServer{LaptopStore: laptopStore, OrderStore: orderStore}
+}
+
+func (s *OrderServer) GetCart(ctx context.Context, req *pb.GetCartRequest) (*pb.GetCartResponse, error) {
+	logger := loggerFromContext(ctx)
+
+	userId, err := orderOwner(ctx)
+	if err != nil {
+		return nil, logError(logger, err)
+	}
+	cart, err := s.OrderStore.Cart(userId)
+	if err != nil {
+		return nil, logError(logger, status.Errorf(codes.Internal, "couldn't get cart: %v", err))
+	}
+	return &pb.GetCartResponse{Cart: cart}, nil
+}
+
+func (s *OrderServer) AddToCart(ctx context.Context, req *pb.AddToCartRequest) (*pb.AddToCartResponse, error) {
+	logger := loggerFromContext(ctx)
+	logger.Info("received add-to-cart request", "laptop_id", req.GetLaptopId(), "quantity", req.GetQuantity())
+
+	userId, err := orderOwner(ctx)
+	if err != nil {
+		return nil, logError(logger, err)
+	}
+	if req.GetQuantity() == 0 || req.GetQuantity() > maxCartItemQuantity {
+		return nil, logError(logger, status.Errorf(codes.InvalidArgument, "quantity must be greater than 0 and at most %d", maxCartItemQuantity))
+	}
+	if _, err := s.findLaptop(ctx, req.GetLaptopId()); err != nil {
+		return nil, logError(logger, err)
+	}
+
+	cart, err := s.OrderStore.UpdateCart(userId, func(cart *pb.Cart) error {
+		cart.UpdatedAt = timestamppb.Now()
+		for _, item := range cart.Items {
+			if item.LaptopId != req.GetLaptopId() {
+				continue
+			}
+			// both are at most maxCartItemQuantity, the sum can't overflow.
+			if item.Quantity+req.GetQuantity() > maxCartItemQuantity {
+				return status.Errorf(codes.InvalidArgument, "the cart can hold at most %d units of a laptop", maxCartItemQuantity)
+			}
+			item.Quantity += req.GetQuantity()
+			return nil
+		}
+		cart.Items = append(cart.Items, &pb.CartItem{LaptopId: req.GetLaptopId(), Quantity: req.GetQuantity()})
+		return nil
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return nil, logError(logger, err)
+		}
+		return nil, logError(logger, status.Errorf(codes.Internal, "couldn't update cart: %v", err))
+	}
+	return &pb.AddToCartResponse{Cart: cart}, nil
+}
+
+func (s *OrderServer) RemoveFromCart(ctx context.Context, req *pb.RemoveFromCartRequest) (*pb.RemoveFromCartResponse, error) {
+	logger := loggerFromContext(ctx)
+	logger.Info("received remove-from-cart request", "laptop_id", req.GetLaptopId(), "quantity", req.GetQuantity())
+
+	userId, err := orderOwner(ctx)
+	if err != nil {
+		return nil, logError(logger, err)
+	}
+	cart, err := s.OrderStore.UpdateCart(userId, func(cart *pb.Cart) error {
+		for i, item := range cart.Items {
+			if item.LaptopId != req.GetLaptopId() {
+				continue
+			}
+			if req.GetQuantity() == 0 || req.GetQuantity() >= item.Quantity {
+				cart.Items = append(cart.Items[:i], cart.Items[i+1:]...)
+			} else {
+				item.Quantity -= req.GetQuantity()
+			}
+			cart.UpdatedAt = timestamppb.Now()
+			return nil
+		}
+		return status.Errorf(codes.NotFound, "laptop is not in the cart: %s", req.GetLaptopId())
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return nil, logError(logger, err)
+		}
+		return nil, logError(logger, status.Errorf(codes.Internal, "couldn't update cart: %v", err))
+	}
+	return &pb.RemoveFromCartResponse{Cart: cart}, nil
+}
+
+// Places a pending order for the cart, priced with the current price of its laptops,
+// and commits the reservations of the request.
+func (s *OrderServer) Checkout(ctx context.Context, req *pb.CheckoutRequest) (*pb.CheckoutResponse, error) {
+	logger := loggerFromContext(ctx)
+	logger.Info("received checkout request", "currency_code", req.GetCurrencyCode(), "reservations", len(req.GetReservationIds()))
+
+	userId, err := orderOwner(ctx)
+	if err != nil {
+		return nil, logError(logger, err)
+	}
+	currencyCode := req.GetCurrencyCode()
+	if currencyCode == "" {
+		currencyCode = BaseCurrency
+	}
+	if _, err := s.exchangeRates().Rate(BaseCurrency, currencyCode); err != nil {
+		return nil, logError(logger, unsupportedCurrencyError(err))
+	}
+	if len(req.GetReservationIds()) > 0 && s.Inventory == nil {
+		return nil, logError(logger, status.Error(codes.FailedPrecondition, "reservations need the inventory, which is disabled"))
+	}
+
+	var committed []*pb.Reservation
+	order, err := s.OrderStore.Checkout(userId, func(cart *pb.Cart) (*pb.Order, error) {
+		order, err := s.newOrder(ctx, cart, currencyCode)
+		if err != nil {
+			return nil, err
+		}
+		committed, err = s.commitReservations(userId, order, req.GetReservationIds())
+		for _, reservation := range committed {
+			order.CommittedUnits = append(order.CommittedUnits, &pb.CommittedUnits{
+				LaptopId:  reservation.GetLaptopId(),
+				Warehouse: reservation.GetWarehouse(),
+				Quantity:  reservation.GetQuantity(),
+			})
+		}
+		return order, err
+	})
+	if err != nil && len(committed) > 0 {
+		// the order wasn't saved, its units are on hand again.
+		for _, reservation := range committed {
+			if _, err := s.Inventory.Adjust(reservation.GetLaptopId(), reservation.GetWarehouse(), reservation.GetQuantity()); err != nil {
+				logger.Error("couldn't restock units of a failed checkout", "reservation_id", reservation.GetId(), "error", err)
+			}
+		}
+	}
+	if errors.Is(err, ErrEmptyCart) {
+		return nil, logError(logger, statusWithDetails(codes.FailedPrecondition, err.Error(), errorInfo(ReasonEmptyCart, nil)))
+	}
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return nil, logError(logger, err)
+		}
+		return nil, logError(logger, status.Errorf(codes.Internal, "couldn't place order: %v", err))
+	}
+
+	logger.Info("placed order", "order_id", order.GetId(), "total", FormatMoney(order.GetTotal()))
+	return &pb.CheckoutResponse{Order: order}, nil
+}
+
+// Moves an order to another status, if the status machine allows it. Customers
+// can only cancel their own orders, operators move any order. Cancelled orders
+// give their committed units back to the inventory.
+func (s *OrderServer) UpdateOrderStatus(ctx context.Context, req *pb.UpdateOrderStatusRequest) (*pb.UpdateOrderStatusResponse, error) {
+	logger := loggerFromContext(ctx)
+	logger.Info("received update-order-status request", "order_id", req.GetOrderId(), "status", req.GetStatus().String())
+
+	// operators may be granted the role by address, customers need a certificate.
+	operator := s.Authorizer.HasRole(ctx, RoleOperator)
+	userId, err := orderOwner(ctx)
+	if err != nil && !operator {
+		return nil, logError(logger, err)
+	}
+	if req.GetStatus() != pb.Order_CANCELLED && !operator {
+		return nil, logError(logger, roleRequiredError(ctx, RoleOperator))
+	}
+
+	order, err := s.OrderStore.UpdateOrder(req.GetOrderId(), func(order *pb.Order) error {
+		// other users' orders don't exist for the caller.
+		if order.UserId != userId && !operator {
+			return ErrOrderNotFound
+		}
+		if err := CheckOrderTransition(order.Status, req.GetStatus()); err != nil {
+			return err
+		}
+		order.Status = req.GetStatus()
+		order.UpdatedAt = timestamppb.Now()
+		return nil
+	})
+	if errors.Is(err, ErrOrderNotFound) {
+		return nil, logError(logger, statusWithDetails(codes.NotFound, fmt.Sprintf("order not found: %s", req.GetOrderId()),
+			errorInfo(ReasonOrderNotFound, map[string]string{"order_id": req.GetOrderId()}),
+		))
+	}
+	if errors.Is(err, ErrInvalidTransition) {
+		return nil, logError(logger, statusWithDetails(codes.FailedPrecondition, err.Error(),
+			errorInfo(ReasonInvalidOrderTransition, map[string]string{"order_id": req.GetOrderId(), "status": req.GetStatus().String()}),
+		))
+	}
+	if err != nil {
+		return nil, logError(logger, status.Errorf(codes.Internal, "couldn't update order: %v", err))
+	}
+
+	if order.GetStatus() == pb.Order_CANCELLED {
+		s.restock(logger, order)
+	}
+
+	logger.Info("updated order status", "order_id", order.GetId(), "status", order.GetStatus().String())
+	return &pb.UpdateOrderStatusResponse{Order: order}, nil
+}
+
+func (s *OrderServer) ListOrders(ctx context.Context, req *pb.ListOrdersRequest) (*pb.ListOrdersResponse, error) {
+	logger := loggerFromContext(ctx)
+
+	userId, err := orderOwner(ctx)
+	if err != nil {
+		return nil, logError(logger, err)
+	}
+	orders, err := s.OrderStore.ListOrders(userId)
+	if err != nil {
+		return nil, logError(logger, status.Errorf(codes.Internal, "couldn't list orders: %v", err))
+	}
+
+	res := &pb.ListOrdersResponse{}
+	for _, order := range orders {
+		if req.GetStatus() == pb.Order_UNKNOWN || order.GetStatus() == req.GetStatus() {
+			res.Orders = append(res.Orders, order)
+		}
+	}
+	return res, nil
+}
+
+// Snapshots the laptops in the cart with their price in the order currency.
+func (s *OrderServer) newOrder(ctx context.Context, cart *pb.Cart, currencyCode string) (*pb.Order, error) {
+	now := timestamppb.Now()
+	order := &pb.Order{
+		Id:        uuid.New().String(),
+		UserId:    cart.GetUserId(),
+		Status:    pb.Order_PENDING,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	total := new(big.Rat)
+	for _, item := range cart.GetItems() {
+		laptop, err := s.findLaptop(ctx, item.GetLaptopId())
+		if err != nil {
+			return nil, err
+		}
+
+		unitPrice, err := laptopListPrice(laptop)
+		if err == nil {
+			unitPrice, err = ConvertMoney(s.exchangeRates(), unitPrice, currencyCode)
+		}
+		if err != nil {
+			return nil, moneyError(err)
+		}
+
+		order.Items = append(order.Items, &pb.OrderItem{
+			LaptopId:  laptop.GetId(),
+			Brand:     laptop.GetBrand(),
+			Name:      laptop.GetName(),
+			Quantity:  item.GetQuantity(),
+			UnitPrice: unitPrice,
+		})
+		total.Add(total, new(big.Rat).Mul(MoneyToRat(unitPrice), new(big.Rat).SetInt64(int64(item.GetQuantity()))))
+	}
+	orderTotal, err := MoneyFromRat(currencyCode, total)
+	if err != nil {
+		return nil, moneyError(err)
+	}
+	order.Total = orderTotal
+	return order, nil
+}
+
+// Commits the reservations, which must be the user's, for laptops of the order,
+// and at most as many units as ordered. Commits none of them on error.
+func (s *OrderServer) commitReservations(userId string, order *pb.Order, reservationIds []string) ([]*pb.Reservation, error) {
+	if len(reservationIds) == 0 {
+		return nil, nil
+	}
+
+	ordered := make(map[string]int64)
+	for _, item := range order.GetItems() {
+		ordered[item.GetLaptopId()] += int64(item.GetQuantity())
+	}
+	reservations := make(map[string]*pb.Reservation)
+	for _, id := range reservationIds {
+		if reservations[id] != nil {
+			continue
+		}
+		reservation, err := s.Inventory.Reservation(id)
+		if errors.Is(err, ErrReservationNotFound) || (err == nil && reservation.GetUserId() != userId) {
+			return nil, reservationNotFoundError(id)
+		}
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "couldn't find reservation: %v", err)
+		}
+
+		ordered[reservation.GetLaptopId()] -= reservation.GetQuantity()
+		if ordered[reservation.GetLaptopId()] < 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "reservations hold more units of laptop %s than the cart", reservation.GetLaptopId())
+		}
+		reservations[id] = reservation
+	}
+
+	ids := make([]string, 0, len(reservations))
+	committed := make([]*pb.Reservation, 0, len(reservations))
+	for id, reservation := range reservations {
+		ids = append(ids, id)
+		committed = append(committed, reservation)
+	}
+	// a reservation can expire since it was found.
+	err := s.Inventory.Commit(ids...)
+	if errors.Is(err, ErrReservationNotFound) {
+		return nil, statusWithDetails(codes.NotFound, err.Error(), errorInfo(ReasonReservationNotFound, nil))
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "couldn't commit reservations: %v", err)
+	}
+	return committed, nil
+}
+
+// Cancelled is a final status, so the units of an order are given back once.
+func (s *OrderServer) restock(logger *slog.Logger, order *pb.Order) {
+	if s.Inventory == nil {
+		return
+	}
+	for _, units := range order.GetCommittedUnits() {
+		if _, err := s.Inventory.Adjust(units.GetLaptopId(), units.GetWarehouse(), units.GetQuantity()); err != nil {
+			logger.Error("couldn't restock units of a cancelled order", "order_id", order.GetId(), "laptop_id", units.GetLaptopId(), "error", err)
+		}
+	}
+}
+
+func (s *OrderServer) findLaptop(ctx context.Context, laptopId string) (*pb.Laptop, error) {
+	laptop, err := tenantLaptopStore(ctx, s.LaptopStore).Find(laptopId)
+	if errors.Is(err, ErrNotFound) {
+		return nil, laptopNotFoundError(laptopId)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "couldn't find laptop: %v", err)
+	}
+	return laptop, nil
+}
+
+// Carts and orders belong to callers with a client certificate. Addresses aren't
+// identities, many clients share one behind a NAT or the gateway.
+func orderOwner(ctx context.Context) (string, error) {
+	userId, ok := authenticatedIdentity(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "carts and orders need a client certificate")
+	}
+	return userId, nil
+}
+
+func (s *OrderServer) exchangeRates() ExchangeRateProvider {
+	if s.ExchangeRates == nil {
+		return NewStaticExchangeRates(BaseCurrency, nil)
+	}
+	return s.ExchangeRates
+}
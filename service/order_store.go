@@ -0,0 +1,260 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go-grpc-pcbook/pb"
+	"go-grpc-pcbook/serializer"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	ErrOrderNotFound     = errors.New("order not found.")
+	ErrEmptyCart         = errors.New("cart is empty.")
+	ErrInvalidTransition = errors.New("invalid order status transition.")
+)
+
+// Statuses an order can move to from each status. Shipped and cancelled orders are final.
+var orderTransitions = map[pb.Order_Status][]pb.Order_Status{
+	pb.Order_PENDING: {pb.Order_PAID, pb.Order_CANCELLED},
+	pb.Order_PAID:    {pb.Order_SHIPPED, pb.Order_CANCELLED},
+}
+
+// ErrInvalidTransition unless an order in status from can move to status to.
+func CheckOrderTransition(from, to pb.Order_Status) error {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w %s -> %s", ErrInvalidTransition, from, to)
+}
+
+// Changes run under the store lock, so a cart or order is never changed by two
+// calls at once. Changes get copies, and are dropped if they return an error.
+type OrderStore interface {
+	// Cart of the user, empty if they have none
+	Cart(userId string) (*pb.Cart, error)
+	// Changes the cart of the user
+	UpdateCart(userId string, change func(cart *pb.Cart) error) (*pb.Cart, error)
+	// Saves the order built from the cart of the user and empties the cart
+	Checkout(userId string, build func(cart *pb.Cart) (*pb.Order, error)) (*pb.Order, error)
+	// Changes an order, ErrOrderNotFound if it's not there
+	UpdateOrder(id string, change func(order *pb.Order) error) (*pb.Order, error)
+	// Orders of the user, oldest first
+	ListOrders(userId string) ([]*pb.Order, error)
+}
+
+type MemoryOrderStore struct {
+	mutex  sync.Mutex
+	carts  map[string]*pb.Cart
+	orders map[string]*pb.Order
+	// user id -> order ids, oldest first.
+	userOrders map[string][]string
+	// makes the changes durable before they're applied, if set.
+	journal func(entries ...*pb.OrderJournalEntry) error
+}
+
+func NewMemoryOrderStore() *MemoryOrderStore {
+	return &MemoryOrderStore{
+		carts:      make(map[string]*pb.Cart),
+		orders:     make(map[string]*pb.Order),
+		userOrders: make(map[string][]string),
+	}
+}
+
+func (m *MemoryOrderStore) Cart(userId string) (*pb.Cart, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.cart(userId), nil
+}
+
+func (m *MemoryOrderStore) UpdateCart(userId string, change func(cart *pb.Cart) error) (*pb.Cart, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	cart := m.cart(userId)
+	if err := change(cart); err != nil {
+		return nil, err
+	}
+	if err := m.write(&pb.OrderJournalEntry{Record: &pb.OrderJournalEntry_Cart{Cart: cart}}); err != nil {
+		return nil, err
+	}
+	m.applyCart(proto.Clone(cart).(*pb.Cart))
+	return cart, nil
+}
+
+func (m *MemoryOrderStore) Checkout(userId string, build func(cart *pb.Cart) (*pb.Order, error)) (*pb.Order, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	cart := m.cart(userId)
+	if len(cart.Items) == 0 {
+		return nil, ErrEmptyCart
+	}
+	order, err := build(proto.Clone(cart).(*pb.Cart))
+	if err != nil {
+		return nil, err
+	}
+	if m.orders[order.Id] != nil {
+		return nil, ErrAlreadyExists
+	}
+
+	cart.Items = nil
+	cart.UpdatedAt = order.CreatedAt
+	err = m.write(
+		&pb.OrderJournalEntry{Record: &pb.OrderJournalEntry_Order{Order: order}},
+		&pb.OrderJournalEntry{Record: &pb.OrderJournalEntry_Cart{Cart: cart}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	m.applyOrder(proto.Clone(order).(*pb.Order))
+	m.applyCart(cart)
+	return order, nil
+}
+
+func (m *MemoryOrderStore) UpdateOrder(id string, change func(order *pb.Order) error) (*pb.Order, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	order := m.orders[id]
+	if order == nil {
+		return nil, ErrOrderNotFound
+	}
+	order = proto.Clone(order).(*pb.Order)
+	if err := change(order); err != nil {
+		return nil, err
+	}
+	if err := m.write(&pb.OrderJournalEntry{Record: &pb.OrderJournalEntry_Order{Order: order}}); err != nil {
+		return nil, err
+	}
+	m.applyOrder(proto.Clone(order).(*pb.Order))
+	return order, nil
+}
+
+func (m *MemoryOrderStore) ListOrders(userId string) ([]*pb.Order, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	orders := []*pb.Order{}
+	for _, id := range m.userOrders[userId] {
+		orders = append(orders, proto.Clone(m.orders[id]).(*pb.Order))
+	}
+	return orders, nil
+}
+
+// Copy of the user's cart, a new one if there's none.
+func (m *MemoryOrderStore) cart(userId string) *pb.Cart {
+	if cart := m.carts[userId]; cart != nil {
+		return proto.Clone(cart).(*pb.Cart)
+	}
+	return &pb.Cart{UserId: userId}
+}
+
+func (m *MemoryOrderStore) applyCart(cart *pb.Cart) {
+	m.carts[cart.UserId] = cart
+}
+
+func (m *MemoryOrderStore) applyOrder(order *pb.Order) {
+	if m.orders[order.Id] == nil {
+		m.userOrders[order.UserId] = append(m.userOrders[order.UserId], order.Id)
+	}
+	m.orders[order.Id] = order
+}
+
+func (m *MemoryOrderStore) write(entries ...*pb.OrderJournalEntry) error {
+	if m.journal == nil {
+		return nil
+	}
+	return m.journal(entries...)
+}
+
+// Durable OrderStore appending every change of a cart or order to a JSON lines
+// journal file, which is replayed on open.
+type FileOrderStore struct {
+	*MemoryOrderStore
+	file *os.File
+}
+
+func NewFileOrderStore(filename string) (*FileOrderStore, error) {
+	store := &FileOrderStore{MemoryOrderStore: NewMemoryOrderStore()}
+	if err := store.replay(filename); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open order journal: %w", err)
+	}
+	store.file = file
+	store.journal = store.append
+	return store, nil
+}
+
+func (f *FileOrderStore) Close() error {
+	return f.file.Close()
+}
+
+// Replays the journal. A crash while appending can leave a torn last line
+// without its newline, which is cut off so later appends start a line of their own.
+func (f *FileOrderStore) replay(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	complete := bytes.LastIndexByte(data, '\n') + 1
+
+	reader := serializer.NewJSONLinesReader(bytes.NewReader(data[:complete]))
+	for {
+		entry := &pb.OrderJournalEntry{}
+		err := reader.Read(entry)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("couldn't read order journal: %w", err)
+		}
+
+		switch record := entry.Record.(type) {
+		case *pb.OrderJournalEntry_Cart:
+			f.applyCart(record.Cart)
+		case *pb.OrderJournalEntry_Order:
+			f.applyOrder(record.Order)
+		}
+	}
+
+	if complete < len(data) {
+		slog.Warn("truncating torn last line of order journal", "file", filename, "size", len(data)-complete)
+		if err := os.Truncate(filename, int64(complete)); err != nil {
+			return fmt.Errorf("couldn't truncate order journal: %w", err)
+		}
+	}
+	return nil
+}
+
+// Writes all the entries at once and syncs them to disk.
+func (f *FileOrderStore) append(entries ...*pb.OrderJournalEntry) error {
+	data := []byte{}
+	for _, entry := range entries {
+		line, err := serializer.ProtobufToCompactJson(entry)
+		if err != nil {
+			return fmt.Errorf("couldn't marshal order journal entry: %w", err)
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	if _, err := f.file.Write(data); err != nil {
+		return fmt.Errorf("couldn't write order journal: %w", err)
+	}
+	if err := f.file.Sync(); err != nil {
+		return fmt.Errorf("couldn't sync order journal: %w", err)
+	}
+	return nil
+}
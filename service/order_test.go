@@ -0,0 +1,253 @@
+package service_test
+
+import (
+	"context"
+	"go-grpc-pcbook/pb"
+	"go-grpc-pcbook/sample"
+	"go-grpc-pcbook/service"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The test client has a certificate for alice, who is an operator too. Anonymous
+// clients are dialed without one.
+func startTestOrderServer(t *testing.T, laptopStore service.LaptopStore, orderStore service.OrderStore) (client pb.OrderServiceClient, anonymous pb.OrderServiceClient) {
+	orderServer := service.NewOrderServer(laptopStore, orderStore)
+	orderServer.ExchangeRates = service.NewStaticExchangeRates("USD", map[string]*big.Rat{"EUR": big.NewRat(1, 2)})
+	orderServer.Authorizer = service.NewAuthorizer(map[string][]string{service.RoleOperator: {"user:alice"}})
+
+	pki := newTestPKI(t)
+	grpcServer := grpc.NewServer(pki.serverOption(t))
+	pb.RegisterOrderServiceServer(grpcServer, orderServer)
+	address := pki.serve(t, grpcServer)
+	return pb.NewOrderServiceClient(pki.dial(t, address, "alice")), pb.NewOrderServiceClient(pki.dial(t, address, ""))
+}
+
+func TestOrderCheckout(t *testing.T) {
+	laptopStore := service.NewMemoryLaptopStore()
+	journal := filepath.Join(t.TempDir(), "orders.jsonl")
+	orderStore, err := service.NewFileOrderStore(journal)
+	require.NoError(t, err)
+	orderClient, anonymousClient := startTestOrderServer(t, laptopStore, orderStore)
+	ctx := context.Background()
+
+	cheap, expensive := sample.NewLaptop(), sample.NewLaptop()
+	cheap.ListPrice = &pb.Money{CurrencyCode: "USD", Units: 500}
+	expensive.ListPrice = &pb.Money{CurrencyCode: "EUR", Units: 1000}
+	require.NoError(t, laptopStore.Save(cheap))
+	require.NoError(t, laptopStore.Save(expensive))
+
+	// carts and orders need a client certificate.
+	_, err = anonymousClient.AddToCart(ctx, &pb.AddToCartRequest{LaptopId: cheap.Id, Quantity: 1})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = anonymousClient.GetCart(ctx, &pb.GetCartRequest{})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = anonymousClient.Checkout(ctx, &pb.CheckoutRequest{})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = anonymousClient.ListOrders(ctx, &pb.ListOrdersRequest{})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = orderClient.Checkout(ctx, &pb.CheckoutRequest{})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = orderClient.AddToCart(ctx, &pb.AddToCartRequest{LaptopId: "missing", Quantity: 1})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = orderClient.AddToCart(ctx, &pb.AddToCartRequest{LaptopId: cheap.Id})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = orderClient.AddToCart(ctx, &pb.AddToCartRequest{LaptopId: cheap.Id, Quantity: math.MaxUint32})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = orderClient.AddToCart(ctx, &pb.AddToCartRequest{LaptopId: cheap.Id, Quantity: 1000})
+	require.NoError(t, err)
+	_, err = orderClient.AddToCart(ctx, &pb.AddToCartRequest{LaptopId: cheap.Id, Quantity: 1})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = orderClient.RemoveFromCart(ctx, &pb.RemoveFromCartRequest{LaptopId: cheap.Id})
+	require.NoError(t, err)
+
+	_, err = orderClient.AddToCart(ctx, &pb.AddToCartRequest{LaptopId: cheap.Id, Quantity: 2})
+	require.NoError(t, err)
+	_, err = orderClient.AddToCart(ctx, &pb.AddToCartRequest{LaptopId: cheap.Id, Quantity: 2})
+	require.NoError(t, err)
+	_, err = orderClient.AddToCart(ctx, &pb.AddToCartRequest{LaptopId: expensive.Id, Quantity: 1})
+	require.NoError(t, err)
+	cart, err := orderClient.RemoveFromCart(ctx, &pb.RemoveFromCartRequest{LaptopId: cheap.Id, Quantity: 1})
+	require.NoError(t, err)
+	require.Len(t, cart.GetCart().GetItems(), 2)
+	require.EqualValues(t, 3, cart.GetCart().GetItems()[0].GetQuantity())
+	_, err = orderClient.RemoveFromCart(ctx, &pb.RemoveFromCartRequest{LaptopId: "missing"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	checkout, err := orderClient.Checkout(ctx, &pb.CheckoutRequest{CurrencyCode: "EUR"})
+	require.NoError(t, err)
+	order := checkout.GetOrder()
+	require.Equal(t, pb.Order_PENDING, order.GetStatus())
+	require.Equal(t, "250.00 EUR", service.FormatMoney(order.GetItems()[0].GetUnitPrice()))
+	require.Equal(t, "1750.00 EUR", service.FormatMoney(order.GetTotal()))
+
+	// the order keeps the price it was placed with.
+	cheap.ListPrice = &pb.Money{CurrencyCode: "USD", Units: 400}
+	require.NoError(t, laptopStore.Update(cheap))
+	getCart, err := orderClient.GetCart(ctx, &pb.GetCartRequest{})
+	require.NoError(t, err)
+	require.Empty(t, getCart.GetCart().GetItems())
+
+	_, err = orderClient.UpdateOrderStatus(ctx, &pb.UpdateOrderStatusRequest{OrderId: order.GetId(), Status: pb.Order_SHIPPED})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = orderClient.UpdateOrderStatus(ctx, &pb.UpdateOrderStatusRequest{OrderId: "missing", Status: pb.Order_PAID})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	for _, next := range []pb.Order_Status{pb.Order_PAID, pb.Order_SHIPPED} {
+		_, err = orderClient.UpdateOrderStatus(ctx, &pb.UpdateOrderStatusRequest{OrderId: order.GetId(), Status: next})
+		require.NoError(t, err)
+	}
+	_, err = orderClient.UpdateOrderStatus(ctx, &pb.UpdateOrderStatusRequest{OrderId: order.GetId(), Status: pb.Order_CANCELLED})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	_, err = orderClient.AddToCart(ctx, &pb.AddToCartRequest{LaptopId: cheap.Id, Quantity: 1})
+	require.NoError(t, err)
+
+	// carts and orders survive a restart, even one torn writing the journal.
+	require.NoError(t, orderStore.Close())
+	file, err := os.OpenFile(journal, os.O_APPEND|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"order":{"id":"torn`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	reopened, err := service.NewFileOrderStore(journal)
+	require.NoError(t, err)
+	data, err := os.ReadFile(journal)
+	require.NoError(t, err)
+	require.Equal(t, byte('\n'), data[len(data)-1])
+	orderClient, _ = startTestOrderServer(t, laptopStore, reopened)
+
+	list, err := orderClient.ListOrders(ctx, &pb.ListOrdersRequest{Status: pb.Order_SHIPPED})
+	require.NoError(t, err)
+	require.Len(t, list.GetOrders(), 1)
+	require.Equal(t, "1750.00 EUR", service.FormatMoney(list.GetOrders()[0].GetTotal()))
+	list, err = orderClient.ListOrders(ctx, &pb.ListOrdersRequest{Status: pb.Order_PENDING})
+	require.NoError(t, err)
+	require.Empty(t, list.GetOrders())
+
+	checkout, err = orderClient.Checkout(ctx, &pb.CheckoutRequest{})
+	require.NoError(t, err)
+	require.Equal(t, "400.00 USD", service.FormatMoney(checkout.GetOrder().GetTotal()))
+}
+
+func TestOrderCheckoutReservations(t *testing.T) {
+	laptopStore := service.NewMemoryLaptopStore()
+	inventoryStore := service.NewMemoryInventoryStore()
+	orderServer := service.NewOrderServer(laptopStore, service.NewMemoryOrderStore())
+	orderServer.Inventory = inventoryStore
+
+	pki := newTestPKI(t)
+	grpcServer := grpc.NewServer(pki.serverOption(t))
+	pb.RegisterOrderServiceServer(grpcServer, orderServer)
+	pb.RegisterInventoryServiceServer(grpcServer, service.NewInventoryServer(laptopStore, inventoryStore))
+	conn := pki.dial(t, pki.serve(t, grpcServer), "alice")
+	orderClient, inventoryClient := pb.NewOrderServiceClient(conn), pb.NewInventoryServiceClient(conn)
+	ctx := context.Background()
+
+	laptop := sample.NewLaptop()
+	laptop.ListPrice = &pb.Money{CurrencyCode: "USD", Units: 500}
+	require.NoError(t, laptopStore.Save(laptop))
+	_, err := inventoryStore.Adjust(laptop.Id, "north", 5)
+	require.NoError(t, err)
+	reserve := func(quantity int64) string {
+		res, err := inventoryClient.ReserveStock(ctx, &pb.ReserveStockRequest{LaptopId: laptop.Id, Quantity: quantity})
+		require.NoError(t, err)
+		return res.GetReservation().GetId()
+	}
+	_, err = orderClient.AddToCart(ctx, &pb.AddToCartRequest{LaptopId: laptop.Id, Quantity: 2})
+	require.NoError(t, err)
+
+	// reservations for more units than the cart, or that aren't there, fail the checkout.
+	tooMany := reserve(3)
+	_, err = orderClient.Checkout(ctx, &pb.CheckoutRequest{ReservationIds: []string{tooMany}})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = orderClient.Checkout(ctx, &pb.CheckoutRequest{ReservationIds: []string{"missing"}})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = inventoryClient.ReleaseReservation(ctx, &pb.ReleaseReservationRequest{ReservationId: tooMany})
+	require.NoError(t, err)
+
+	reserved := reserve(2)
+	checkout, err := orderClient.Checkout(ctx, &pb.CheckoutRequest{ReservationIds: []string{reserved}})
+	require.NoError(t, err)
+	require.Equal(t, []*pb.StockLevel{{LaptopId: laptop.Id, Warehouse: "north", OnHand: 3, Available: 3}}, inventoryStore.Availability(laptop.Id))
+	_, err = inventoryClient.ReleaseReservation(ctx, &pb.ReleaseReservationRequest{ReservationId: reserved})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	// cancelling the order puts its units back on hand.
+	_, err = orderClient.UpdateOrderStatus(ctx, &pb.UpdateOrderStatusRequest{OrderId: checkout.GetOrder().GetId(), Status: pb.Order_CANCELLED})
+	require.NoError(t, err)
+	require.Equal(t, []*pb.StockLevel{{LaptopId: laptop.Id, Warehouse: "north", OnHand: 5, Available: 5}}, inventoryStore.Availability(laptop.Id))
+	_, err = orderClient.UpdateOrderStatus(ctx, &pb.UpdateOrderStatusRequest{OrderId: checkout.GetOrder().GetId(), Status: pb.Order_CANCELLED})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	require.Equal(t, []*pb.StockLevel{{LaptopId: laptop.Id, Warehouse: "north", OnHand: 5, Available: 5}}, inventoryStore.Availability(laptop.Id))
+}
+
+func TestOrderStatusRoles(t *testing.T) {
+	laptopStore := service.NewMemoryLaptopStore()
+	orderServer := service.NewOrderServer(laptopStore, service.NewMemoryOrderStore())
+	orderServer.Authorizer = service.NewAuthorizer(map[string][]string{service.RoleOperator: {"user:operator"}})
+	customer, operator, other := userContext("customer"), userContext("operator"), userContext("other")
+
+	laptop := sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(laptop))
+	checkout := func() string {
+		_, err := orderServer.AddToCart(customer, &pb.AddToCartRequest{LaptopId: laptop.Id, Quantity: 1})
+		require.NoError(t, err)
+		res, err := orderServer.Checkout(customer, &pb.CheckoutRequest{})
+		require.NoError(t, err)
+		return res.GetOrder().GetId()
+	}
+
+	// customers can't mark their orders paid, operators mark anyone's.
+	paid := checkout()
+	_, err := orderServer.UpdateOrderStatus(customer, &pb.UpdateOrderStatusRequest{OrderId: paid, Status: pb.Order_PAID})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = orderServer.UpdateOrderStatus(operator, &pb.UpdateOrderStatusRequest{OrderId: paid, Status: pb.Order_PAID})
+	require.NoError(t, err)
+
+	// customers cancel their own orders only.
+	cancelled := checkout()
+	_, err = orderServer.UpdateOrderStatus(other, &pb.UpdateOrderStatusRequest{OrderId: cancelled, Status: pb.Order_CANCELLED})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = orderServer.UpdateOrderStatus(context.Background(), &pb.UpdateOrderStatusRequest{OrderId: cancelled, Status: pb.Order_CANCELLED})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+	res, err := orderServer.UpdateOrderStatus(customer, &pb.UpdateOrderStatusRequest{OrderId: cancelled, Status: pb.Order_CANCELLED})
+	require.NoError(t, err)
+	require.Equal(t, pb.Order_CANCELLED, res.GetOrder().GetStatus())
+}
+
+func TestOrderTransitions(t *testing.T) {
+	valid := map[pb.Order_Status][]pb.Order_Status{
+		pb.Order_PENDING:   {pb.Order_PAID, pb.Order_CANCELLED},
+		pb.Order_PAID:      {pb.Order_SHIPPED, pb.Order_CANCELLED},
+		pb.Order_SHIPPED:   {},
+		pb.Order_CANCELLED: {},
+	}
+	for from, allowed := range valid {
+		for to := range pb.Order_Status_name {
+			err := service.CheckOrderTransition(from, pb.Order_Status(to))
+			if containsStatus(allowed, pb.Order_Status(to)) {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, service.ErrInvalidTransition, "%s -> %s", from, pb.Order_Status(to))
+			}
+		}
+	}
+}
+
+func containsStatus(statuses []pb.Order_Status, status pb.Order_Status) bool {
+	for _, other := range statuses {
+		if other == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-grpc-pcbook/pb"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RPCs on laptop price history and alerts. The laptop store must record to the tracker,
+// see PriceTrackingLaptopStore.
+type PriceServer struct {
+	LaptopStore LaptopStore
+	Tracker     *PriceTracker
+}
+
+func NewPriceServer(laptopStore LaptopStore, tracker *PriceTracker) *PriceServer {
+	return &PriceServer{laptopStore, tracker}
+}
+
+func (s *PriceServer) GetPriceHistory(ctx context.Context, req *pb.GetPriceHistoryRequest) (*pb.GetPriceHistoryResponse, error) {
+	logger := loggerFromContext(ctx)
+	logger.Info("received get-price-history request", "laptop_id", req.GetLaptopId(), "currency_code", req.GetCurrencyCode())
+
+	currencyCode := req.GetCurrencyCode()
+	if currencyCode == "" {
+		currencyCode = BaseCurrency
+	}
+
+	// the tracker keeps the prices of every tenant.
+	points := s.Tracker.History(req.GetLaptopId())
+	if len(points) == 0 || !tenantOwns(ctx, req.GetLaptopId()) {
+		return nil, logError(logger, laptopNotFoundError(req.GetLaptopId()))
+	}
+
+	res := &pb.GetPriceHistoryResponse{}
+	for _, point := range points {
+		price, err := ConvertMoney(s.Tracker.exchangeRates, point.GetPrice(), currencyCode)
+		if err != nil {
+			return nil, logError(logger, moneyError(err))
+		}
+		res.Points = append(res.Points, &pb.PricePoint{Price: price, Time: point.GetTime()})
+
+		if res.MinPrice == nil || MoneyToRat(price).Cmp(MoneyToRat(res.MinPrice)) < 0 {
+			res.MinPrice = price
+		}
+		if res.MaxPrice == nil || MoneyToRat(price).Cmp(MoneyToRat(res.MaxPrice)) > 0 {
+			res.MaxPrice = price
+		}
+		res.CurrentPrice = price
+	}
+	return res, nil
+}
+
+// Creates an alert owned by the caller, whose matches go to the caller's WatchPriceAlerts streams.
+func (s *PriceServer) CreatePriceAlert(ctx context.Context, req *pb.CreatePriceAlertRequest) (*pb.CreatePriceAlertResponse, error) {
+	logger := loggerFromContext(ctx)
+	logger.Info("received create-price-alert request", "alert", req.GetAlert().String())
+
+	owner, err := alertOwner(ctx)
+	if err != nil {
+		return nil, logError(logger, err)
+	}
+
+	alert := req.GetAlert()
+	if err := validatePriceAlert(alert); err != nil {
+		return nil, logError(logger, err)
+	}
+
+	if _, err := s.Tracker.exchangeRates.Rate(alert.GetTargetPrice().GetCurrencyCode(), BaseCurrency); err != nil {
+		return nil, logError(logger, unsupportedCurrencyError(err))
+	}
+
+	if laptopId := alert.GetLaptopId(); laptopId != "" {
+		_, err := tenantLaptopStore(ctx, s.LaptopStore).Find(laptopId)
+		if errors.Is(err, ErrNotFound) {
+			return nil, logError(logger, laptopNotFoundError(laptopId))
+		}
+		if err != nil {
+			return nil, logError(logger, status.Errorf(codes.Internal, "couldn't find laptop: %v", err))
+		}
+	}
+
+	created, err := s.Tracker.AddAlert(owner, alert)
+	if errors.Is(err, ErrTooManyPriceAlerts) {
+		return nil, logError(logger, statusWithDetails(codes.ResourceExhausted, fmt.Sprintf("%v Delete one first, the limit is %d.", err, s.Tracker.maxAlerts),
+			errorInfo(ReasonTooManyPriceAlerts, map[string]string{"max_alerts": fmt.Sprint(s.Tracker.maxAlerts)}),
+		))
+	}
+	if err != nil {
+		return nil, logError(logger, status.Errorf(codes.Internal, "couldn't create price alert: %v", err))
+	}
+	logger.Info("created price alert", "id", created.GetId())
+	return &pb.CreatePriceAlertResponse{Alert: created}, nil
+}
+
+func (s *PriceServer) ListPriceAlerts(ctx context.Context, req *pb.ListPriceAlertsRequest) (*pb.ListPriceAlertsResponse, error) {
+	logger := loggerFromContext(ctx)
+	logger.Info("received list-price-alerts request")
+
+	owner, err := alertOwner(ctx)
+	if err != nil {
+		return nil, logError(logger, err)
+	}
+	return &pb.ListPriceAlertsResponse{Alerts: s.Tracker.Alerts(owner)}, nil
+}
+
+func (s *PriceServer) DeletePriceAlert(ctx context.Context, req *pb.DeletePriceAlertRequest) (*pb.DeletePriceAlertResponse, error) {
+	logger := loggerFromContext(ctx)
+	logger.Info("received delete-price-alert request", "alert_id", req.GetAlertId())
+
+	owner, err := alertOwner(ctx)
+	if err != nil {
+		return nil, logError(logger, err)
+	}
+
+	err = s.Tracker.DeleteAlert(owner, req.GetAlertId())
+	if errors.Is(err, ErrPriceAlertNotFound) {
+		return nil, logError(logger, statusWithDetails(codes.NotFound, fmt.Sprintf("price alert not found: %s", req.GetAlertId()),
+			errorInfo(ReasonPriceAlertNotFound, map[string]string{"alert_id": req.GetAlertId()}),
+		))
+	}
+	if err != nil {
+		return nil, logError(logger, status.Errorf(codes.Internal, "couldn't delete price alert: %v", err))
+	}
+	return &pb.DeletePriceAlertResponse{}, nil
+}
+
+// Server streaming RPC sending the matches of the caller's alerts until the client leaves.
+func (s *PriceServer) WatchPriceAlerts(req *pb.WatchPriceAlertsRequest, stream pb.PriceService_WatchPriceAlertsServer) error {
+	ctx := stream.Context()
+	logger := loggerFromContext(ctx)
+	owner, err := alertOwner(ctx)
+	if err != nil {
+		return logError(logger, err)
+	}
+	logger.Info("received watch-price-alerts request", "owner", owner)
+
+	for {
+		match, err := s.Tracker.NextMatch(ctx, owner)
+		if err != nil {
+			return contextError(ctx, logger)
+		}
+		// filter alerts match the laptops of every tenant.
+		if !tenantOwns(ctx, match.GetLaptop().GetId()) {
+			continue
+		}
+
+		err = stream.Send(&pb.WatchPriceAlertsResponse{Match: match})
+		if err != nil {
+			return logError(logger, status.Errorf(codes.Unknown, "couldn't send price alert match: %v", err))
+		}
+	}
+}
+
+// Alerts belong to the client certificate of the caller.
+func alertOwner(ctx context.Context) (string, error) {
+	owner, ok := authenticatedIdentity(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "price alerts need a client certificate")
+	}
+	return owner, nil
+}
+
+func validatePriceAlert(alert *pb.PriceAlert) error {
+	if alert == nil {
+		return status.Error(codes.InvalidArgument, "alert is required")
+	}
+
+	var violations []*errdetails.BadRequest_FieldViolation
+	switch alert.GetTarget().(type) {
+	case nil:
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: "alert.target", Description: "must be a laptop_id or a filter"})
+	case *pb.PriceAlert_LaptopId:
+		if alert.GetLaptopId() == "" {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: "alert.laptop_id", Description: "is required"})
+		}
+	}
+	if alert.GetTargetPrice() == nil {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: "alert.target_price", Description: "is required"})
+	}
+	violations = validateMessage(alert.ProtoReflect(), "alert", violations)
+	if len(violations) == 0 {
+		return nil
+	}
+	return violationsError("invalid price alert", errorInfo(ReasonInvalidPriceAlert, nil), violations)
+}
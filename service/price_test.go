@@ -0,0 +1,239 @@
+package service_test
+
+import (
+	"context"
+	"go-grpc-pcbook/pb"
+	"go-grpc-pcbook/sample"
+	"go-grpc-pcbook/service"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Price clients are dialed with a client certificate for the common name, or none if it's empty.
+func startTestPriceServer(t *testing.T) (pb.LaptopServiceClient, func(commonName string) pb.PriceServiceClient) {
+	exchangeRates := service.NewStaticExchangeRates("USD", map[string]*big.Rat{"EUR": big.NewRat(1, 2)})
+	tracker := service.NewPriceTracker(exchangeRates, 10, 3)
+	laptopStore := service.NewPriceTrackingLaptopStore(service.NewMemoryLaptopStore(), tracker)
+	laptopServer := service.NewLaptopServer(laptopStore, nil, nil)
+	laptopServer.ExchangeRates = exchangeRates
+
+	pki := newTestPKI(t)
+	grpcServer := grpc.NewServer(pki.serverOption(t))
+	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+	pb.RegisterPriceServiceServer(grpcServer, service.NewPriceServer(laptopStore, tracker))
+	address := pki.serve(t, grpcServer)
+
+	dial := func(commonName string) pb.PriceServiceClient {
+		return pb.NewPriceServiceClient(pki.dial(t, address, commonName))
+	}
+	return pb.NewLaptopServiceClient(pki.dial(t, address, "")), dial
+}
+
+func setTestPrice(t *testing.T, laptopClient pb.LaptopServiceClient, laptop *pb.Laptop, price *pb.Money) {
+	laptop.ListPrice = price
+	_, err := laptopClient.UpdateLaptop(context.Background(), &pb.UpdateLaptopRequest{Laptop: laptop})
+	require.NoError(t, err)
+}
+
+func TestPriceHistory(t *testing.T) {
+	laptopClient, dial := startTestPriceServer(t)
+	priceClient := dial("")
+
+	laptop := sample.NewLaptop()
+	laptop.ListPrice = &pb.Money{CurrencyCode: "USD", Units: 1000}
+	_, err := laptopClient.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: laptop})
+	require.NoError(t, err)
+
+	setTestPrice(t, laptopClient, laptop, &pb.Money{CurrencyCode: "USD", Units: 800})
+	// an update that doesn't change the price isn't a new point.
+	setTestPrice(t, laptopClient, laptop, &pb.Money{CurrencyCode: "USD", Units: 800})
+	setTestPrice(t, laptopClient, laptop, &pb.Money{CurrencyCode: "EUR", Units: 450})
+
+	res, err := priceClient.GetPriceHistory(context.Background(), &pb.GetPriceHistoryRequest{LaptopId: laptop.Id})
+	require.NoError(t, err)
+	require.Len(t, res.GetPoints(), 3)
+	require.Equal(t, "1000.00 USD", service.FormatMoney(res.GetPoints()[0].GetPrice()))
+	require.Equal(t, "800.00 USD", service.FormatMoney(res.GetMinPrice()))
+	require.Equal(t, "1000.00 USD", service.FormatMoney(res.GetMaxPrice()))
+	require.Equal(t, "900.00 USD", service.FormatMoney(res.GetCurrentPrice()))
+	require.False(t, res.GetPoints()[1].GetTime().AsTime().Before(res.GetPoints()[0].GetTime().AsTime()))
+
+	res, err = priceClient.GetPriceHistory(context.Background(), &pb.GetPriceHistoryRequest{LaptopId: laptop.Id, CurrencyCode: "EUR"})
+	require.NoError(t, err)
+	require.Equal(t, "400.00 EUR", service.FormatMoney(res.GetMinPrice()))
+	require.Equal(t, "450.00 EUR", service.FormatMoney(res.GetCurrentPrice()))
+
+	_, err = priceClient.GetPriceHistory(context.Background(), &pb.GetPriceHistoryRequest{LaptopId: laptop.Id, CurrencyCode: "JPY"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = priceClient.GetPriceHistory(context.Background(), &pb.GetPriceHistoryRequest{LaptopId: "missing"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	// the history goes with the laptop.
+	_, err = laptopClient.DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{Id: laptop.Id})
+	require.NoError(t, err)
+	_, err = priceClient.GetPriceHistory(context.Background(), &pb.GetPriceHistoryRequest{LaptopId: laptop.Id})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestPriceHistoryLimit(t *testing.T) {
+	tracker := service.NewPriceTracker(nil, 1, 1)
+	laptop := sample.NewLaptop()
+	for i := 1; i <= 1005; i++ {
+		laptop.ListPrice = &pb.Money{CurrencyCode: "USD", Units: int64(i)}
+		tracker.Record(laptop)
+	}
+
+	points := tracker.History(laptop.Id)
+	require.Len(t, points, 1000)
+	require.Equal(t, int64(6), points[0].GetPrice().GetUnits())
+	require.Equal(t, int64(1005), points[999].GetPrice().GetUnits())
+}
+
+func TestPriceAlerts(t *testing.T) {
+	laptopClient, dial := startTestPriceServer(t)
+	priceClient := dial("alice")
+
+	laptop := sample.NewLaptop()
+	laptop.ListPrice = &pb.Money{CurrencyCode: "USD", Units: 1000}
+	_, err := laptopClient.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: laptop})
+	require.NoError(t, err)
+
+	invalid := []*pb.PriceAlert{
+		{TargetPrice: &pb.Money{CurrencyCode: "USD", Units: 900}},
+		{Target: &pb.PriceAlert_LaptopId{LaptopId: laptop.Id}},
+		{Target: &pb.PriceAlert_LaptopId{LaptopId: laptop.Id}, TargetPrice: &pb.Money{CurrencyCode: "usd", Units: 900}},
+		{Target: &pb.PriceAlert_LaptopId{LaptopId: laptop.Id}, TargetPrice: &pb.Money{CurrencyCode: "JPY", Units: 900}},
+	}
+	for _, alert := range invalid {
+		_, err := priceClient.CreatePriceAlert(context.Background(), &pb.CreatePriceAlertRequest{Alert: alert})
+		require.Equal(t, codes.InvalidArgument, status.Code(err), alert.String())
+	}
+	_, err = priceClient.CreatePriceAlert(context.Background(), &pb.CreatePriceAlertRequest{Alert: &pb.PriceAlert{
+		Target:      &pb.PriceAlert_LaptopId{LaptopId: "missing"},
+		TargetPrice: &pb.Money{CurrencyCode: "USD", Units: 900},
+	}})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	byId, err := priceClient.CreatePriceAlert(context.Background(), &pb.CreatePriceAlertRequest{Alert: &pb.PriceAlert{
+		Target:      &pb.PriceAlert_LaptopId{LaptopId: laptop.Id},
+		TargetPrice: &pb.Money{CurrencyCode: "EUR", Units: 450},
+	}})
+	require.NoError(t, err)
+	require.NotEmpty(t, byId.GetAlert().GetId())
+	byFilter, err := priceClient.CreatePriceAlert(context.Background(), &pb.CreatePriceAlertRequest{Alert: &pb.PriceAlert{
+		Target:      &pb.PriceAlert_Filter{Filter: &pb.Filter{MinCores: laptop.GetCpu().GetCores()}},
+		TargetPrice: &pb.Money{CurrencyCode: "USD", Units: 700},
+	}})
+	require.NoError(t, err)
+
+	// 950 USD isn't below the target, 900 USD = 450 EUR is, 850 USD was already below it.
+	setTestPrice(t, laptopClient, laptop, &pb.Money{CurrencyCode: "USD", Units: 950})
+	setTestPrice(t, laptopClient, laptop, &pb.Money{CurrencyCode: "USD", Units: 900})
+	setTestPrice(t, laptopClient, laptop, &pb.Money{CurrencyCode: "USD", Units: 850})
+
+	// matches wait for the watcher.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := priceClient.WatchPriceAlerts(ctx, &pb.WatchPriceAlertsRequest{})
+	require.NoError(t, err)
+
+	res, err := stream.Recv()
+	require.NoError(t, err)
+	match := res.GetMatch()
+	require.Equal(t, byId.GetAlert().GetId(), match.GetAlert().GetId())
+	require.Equal(t, laptop.Id, match.GetLaptop().GetId())
+	require.Equal(t, "475.00 EUR", service.FormatMoney(match.GetPreviousPrice()))
+	require.Equal(t, "450.00 EUR", service.FormatMoney(match.GetPrice()))
+
+	// going back up and down again crosses the threshold of both alerts.
+	setTestPrice(t, laptopClient, laptop, &pb.Money{CurrencyCode: "USD", Units: 1000})
+	setTestPrice(t, laptopClient, laptop, &pb.Money{CurrencyCode: "USD", Units: 600})
+
+	alertIds := []string{}
+	for i := 0; i < 2; i++ {
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, laptop.Id, res.GetMatch().GetLaptop().GetId())
+		alertIds = append(alertIds, res.GetMatch().GetAlert().GetId())
+	}
+	require.ElementsMatch(t, []string{byId.GetAlert().GetId(), byFilter.GetAlert().GetId()}, alertIds)
+
+	// a new laptop below the target matches the filter.
+	other := sample.NewLaptop()
+	other.Cpu = laptop.Cpu
+	other.ListPrice = &pb.Money{CurrencyCode: "USD", Units: 500}
+	_, err = laptopClient.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: other})
+	require.NoError(t, err)
+
+	res, err = stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, byFilter.GetAlert().GetId(), res.GetMatch().GetAlert().GetId())
+	require.Equal(t, other.Id, res.GetMatch().GetLaptop().GetId())
+	require.Nil(t, res.GetMatch().GetPreviousPrice())
+}
+
+func TestPriceAlertOwners(t *testing.T) {
+	laptopClient, dial := startTestPriceServer(t)
+	alice, bob := dial("alice"), dial("bob")
+
+	laptop := sample.NewLaptop()
+	laptop.ListPrice = &pb.Money{CurrencyCode: "USD", Units: 1000}
+	_, err := laptopClient.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: laptop})
+	require.NoError(t, err)
+	alert := &pb.PriceAlert{
+		Target:      &pb.PriceAlert_LaptopId{LaptopId: laptop.Id},
+		TargetPrice: &pb.Money{CurrencyCode: "USD", Units: 900},
+	}
+
+	// callers without a certificate are only an address, which many clients can share.
+	_, err = dial("").CreatePriceAlert(context.Background(), &pb.CreatePriceAlertRequest{Alert: alert})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	var alertIds []string
+	for i := 0; i < 3; i++ {
+		res, err := alice.CreatePriceAlert(context.Background(), &pb.CreatePriceAlertRequest{Alert: alert})
+		require.NoError(t, err)
+		alertIds = append(alertIds, res.GetAlert().GetId())
+	}
+	_, err = alice.CreatePriceAlert(context.Background(), &pb.CreatePriceAlertRequest{Alert: alert})
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	bobAlert, err := bob.CreatePriceAlert(context.Background(), &pb.CreatePriceAlertRequest{Alert: alert})
+	require.NoError(t, err)
+
+	// alerts can only be deleted by their owner, with their pending matches.
+	_, err = bob.DeletePriceAlert(context.Background(), &pb.DeletePriceAlertRequest{AlertId: alertIds[0]})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	setTestPrice(t, laptopClient, laptop, &pb.Money{CurrencyCode: "USD", Units: 800})
+	for _, alertId := range alertIds[:2] {
+		_, err = alice.DeletePriceAlert(context.Background(), &pb.DeletePriceAlertRequest{AlertId: alertId})
+		require.NoError(t, err)
+	}
+	alerts, err := alice.ListPriceAlerts(context.Background(), &pb.ListPriceAlertsRequest{})
+	require.NoError(t, err)
+	require.Len(t, alerts.GetAlerts(), 1)
+	require.Equal(t, alertIds[2], alerts.GetAlerts()[0].GetId())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for client, alertId := range map[pb.PriceServiceClient]string{alice: alertIds[2], bob: bobAlert.GetAlert().GetId()} {
+		stream, err := client.WatchPriceAlerts(ctx, &pb.WatchPriceAlertsRequest{})
+		require.NoError(t, err)
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, alertId, res.GetMatch().GetAlert().GetId())
+	}
+
+	// the deleted alerts' matches are gone, nothing else is waiting.
+	shortCtx, shortCancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer shortCancel()
+	stream, err := alice.WatchPriceAlerts(shortCtx, &pb.WatchPriceAlertsRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
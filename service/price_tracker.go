@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-grpc-pcbook/pb"
+	"log/slog"
+	"sync"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Price points kept per laptop, the oldest are dropped first.
+const maxPricePoints = 1000
+
+var (
+	ErrPriceAlertNotFound = errors.New("price alert not found.")
+	ErrTooManyPriceAlerts = errors.New("too many price alerts.")
+)
+
+// Keeps the price history of every laptop and fires the price alerts whose
+// threshold a price change crosses.
+type PriceTracker struct {
+	mutex         sync.Mutex
+	exchangeRates ExchangeRateProvider
+	backlog       int
+	maxAlerts     int
+	history       map[string][]*pb.PricePoint
+	alerts        []*ownedAlert
+	inboxes       map[string]*alertInbox
+}
+
+type ownedAlert struct {
+	owner string
+	alert *pb.PriceAlert
+}
+
+// Matches of one owner waiting to be watched.
+type alertInbox struct {
+	matches []*pb.PriceAlertMatch
+	// closed and replaced when a match arrives.
+	ready chan struct{}
+}
+
+// Keeps up to backlog matches per owner while nobody watches them, dropping the oldest,
+// and up to maxAlerts alerts per owner. Both are at least 1.
+// Only BaseCurrency is supported if exchangeRates is nil.
+func NewPriceTracker(exchangeRates ExchangeRateProvider, backlog int, maxAlerts int) *PriceTracker {
+	if exchangeRates == nil {
+		exchangeRates = NewStaticExchangeRates(BaseCurrency, nil)
+	}
+	return &PriceTracker{
+		exchangeRates: exchangeRates,
+		backlog:       max(backlog, 1),
+		maxAlerts:     max(maxAlerts, 1),
+		history:       make(map[string][]*pb.PricePoint),
+		inboxes:       make(map[string]*alertInbox),
+	}
+}
+
+// Adds a point to the history of the laptop if its price changed, and fires
+// the alerts the new price reaches.
+func (t *PriceTracker) Record(laptop *pb.Laptop) {
+	price, err := laptopListPrice(laptop)
+	if err != nil {
+		// laptops are validated before they're saved.
+		slog.Warn("price tracker skipped laptop", "laptop_id", laptop.GetId(), "error", err)
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	points := t.history[laptop.GetId()]
+	var previous *pb.Money
+	if len(points) > 0 {
+		previous = points[len(points)-1].GetPrice()
+		if proto.Equal(previous, price) {
+			return
+		}
+	}
+
+	now := timestamppb.Now()
+	if len(points) >= maxPricePoints {
+		points = append(points[:0:0], points[len(points)-maxPricePoints+1:]...)
+	}
+	t.history[laptop.GetId()] = append(points, &pb.PricePoint{Price: proto.Clone(price).(*pb.Money), Time: now})
+
+	for _, owned := range t.alerts {
+		if match := t.match(owned.alert, laptop, previous, price); match != nil {
+			match.Time = now
+			t.deliver(owned.owner, match)
+		}
+	}
+}
+
+// Last maxPricePoints price points of the laptop, oldest first. Empty if it was never
+// recorded or was forgotten.
+func (t *PriceTracker) History(laptopId string) []*pb.PricePoint {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]*pb.PricePoint(nil), t.history[laptopId]...)
+}
+
+// Drops the history of a deleted laptop.
+func (t *PriceTracker) Forget(laptopId string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.history, laptopId)
+}
+
+// Saves a copy of the alert with a new id. It fires on the next price change
+// that drops to the target price, not for prices already below it.
+// Returns ErrTooManyPriceAlerts if the owner already has maxAlerts alerts.
+func (t *PriceTracker) AddAlert(owner string, alert *pb.PriceAlert) (*pb.PriceAlert, error) {
+	other := proto.Clone(alert).(*pb.PriceAlert)
+	other.Id = uuid.New().String()
+	other.CreatedAt = timestamppb.Now()
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if len(t.ownerAlerts(owner)) >= t.maxAlerts {
+		return nil, ErrTooManyPriceAlerts
+	}
+	t.alerts = append(t.alerts, &ownedAlert{owner, other})
+	return proto.Clone(other).(*pb.PriceAlert), nil
+}
+
+// Alerts of the owner, oldest first.
+func (t *PriceTracker) Alerts(owner string) []*pb.PriceAlert {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	alerts := []*pb.PriceAlert{}
+	for _, alert := range t.ownerAlerts(owner) {
+		alerts = append(alerts, proto.Clone(alert).(*pb.PriceAlert))
+	}
+	return alerts
+}
+
+// Deletes the alert of the owner with its matches not watched yet.
+// Returns ErrPriceAlertNotFound if the owner has no such alert.
+func (t *PriceTracker) DeleteAlert(owner string, alertId string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for i, owned := range t.alerts {
+		if owned.owner != owner || owned.alert.GetId() != alertId {
+			continue
+		}
+		t.alerts = append(t.alerts[:i], t.alerts[i+1:]...)
+
+		if inbox, ok := t.inboxes[owner]; ok {
+			var matches []*pb.PriceAlertMatch
+			for _, match := range inbox.matches {
+				if match.GetAlert().GetId() != alertId {
+					matches = append(matches, match)
+				}
+			}
+			inbox.matches = matches
+		}
+		return nil
+	}
+	return ErrPriceAlertNotFound
+}
+
+// Waits for the next match of the owner's alerts. Each match goes to only one
+// of the owner's watchers.
+func (t *PriceTracker) NextMatch(ctx context.Context, owner string) (*pb.PriceAlertMatch, error) {
+	for {
+		t.mutex.Lock()
+		inbox := t.inbox(owner)
+		if len(inbox.matches) > 0 {
+			match := inbox.matches[0]
+			inbox.matches = inbox.matches[1:]
+			t.mutex.Unlock()
+			return match, nil
+		}
+		ready := inbox.ready
+		t.mutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ready:
+		}
+	}
+}
+
+// A match if the laptop is a target of the alert and the price went from above
+// the target price to at or below it. New laptops have no previous price.
+func (t *PriceTracker) match(alert *pb.PriceAlert, laptop *pb.Laptop, previous *pb.Money, price *pb.Money) *pb.PriceAlertMatch {
+	switch target := alert.GetTarget().(type) {
+	case *pb.PriceAlert_LaptopId:
+		if target.LaptopId != laptop.GetId() {
+			return nil
+		}
+	case *pb.PriceAlert_Filter:
+		if !isQualified(unlimitedPrice(target.Filter), laptop) {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	targetPrice := MoneyToRat(alert.GetTargetPrice())
+	converted, err := ConvertMoney(t.exchangeRates, price, alert.GetTargetPrice().GetCurrencyCode())
+	if err != nil || MoneyToRat(converted).Cmp(targetPrice) > 0 {
+		return nil
+	}
+
+	var previousConverted *pb.Money
+	if previous != nil {
+		previousConverted, err = ConvertMoney(t.exchangeRates, previous, alert.GetTargetPrice().GetCurrencyCode())
+		if err != nil || MoneyToRat(previousConverted).Cmp(targetPrice) <= 0 {
+			return nil
+		}
+	}
+
+	return &pb.PriceAlertMatch{
+		Alert:         proto.Clone(alert).(*pb.PriceAlert),
+		Laptop:        proto.Clone(laptop).(*pb.Laptop),
+		PreviousPrice: previousConverted,
+		Price:         converted,
+	}
+}
+
+func (t *PriceTracker) deliver(owner string, match *pb.PriceAlertMatch) {
+	inbox := t.inbox(owner)
+	if len(inbox.matches) >= t.backlog {
+		inbox.matches = inbox.matches[1:]
+	}
+	inbox.matches = append(inbox.matches, match)
+
+	close(inbox.ready)
+	inbox.ready = make(chan struct{})
+}
+
+func (t *PriceTracker) ownerAlerts(owner string) []*pb.PriceAlert {
+	var alerts []*pb.PriceAlert
+	for _, owned := range t.alerts {
+		if owned.owner == owner {
+			alerts = append(alerts, owned.alert)
+		}
+	}
+	return alerts
+}
+
+func (t *PriceTracker) inbox(owner string) *alertInbox {
+	inbox, ok := t.inboxes[owner]
+	if !ok {
+		inbox = &alertInbox{ready: make(chan struct{})}
+		t.inboxes[owner] = inbox
+	}
+	return inbox
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"go-grpc-pcbook/pb"
+)
+
+// LaptopStore that records the price of every saved or updated laptop in a PriceTracker,
+// and drops the history of deleted laptops.
+type PriceTrackingLaptopStore struct {
+	// serializes the writes to each laptop so its history follows the order prices hit the store.
+	locks   *keyedMutex
+	store   LaptopStore
+	tracker *PriceTracker
+}
+
+func NewPriceTrackingLaptopStore(store LaptopStore, tracker *PriceTracker) *PriceTrackingLaptopStore {
+	return &PriceTrackingLaptopStore{locks: newKeyedMutex(), store: store, tracker: tracker}
+}
+
+func (p *PriceTrackingLaptopStore) Save(laptop *pb.Laptop) error {
+	defer p.locks.Lock(laptop.GetId())()
+
+	if err := p.store.Save(laptop); err != nil {
+		return err
+	}
+	p.tracker.Record(laptop)
+	return nil
+}
+
+func (p *PriceTrackingLaptopStore) Find(id string) (*pb.Laptop, error) {
+	return p.store.Find(id)
+}
+
+func (p *PriceTrackingLaptopStore) Update(laptop *pb.Laptop) error {
+	defer p.locks.Lock(laptop.GetId())()
+
+	if err := p.store.Update(laptop); err != nil {
+		return err
+	}
+	p.tracker.Record(laptop)
+	return nil
+}
+
+func (p *PriceTrackingLaptopStore) Delete(id string) error {
+	defer p.locks.Lock(id)()
+
+	if err := p.store.Delete(id); err != nil {
+		return err
+	}
+	p.tracker.Forget(id)
+	return nil
+}
+
+func (p *PriceTrackingLaptopStore) Search(ctx context.Context, filter *pb.Filter, found func(laptop *pb.Laptop) error) error {
+	return p.store.Search(ctx, filter, found)
+}
+
+func (p *PriceTrackingLaptopStore) Count() int {
+	return p.store.Count()
+}
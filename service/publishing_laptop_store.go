@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"go-grpc-pcbook/pb"
+)
+
+// LaptopStore that publishes every change to a LaptopHub.
+type PublishingLaptopStore struct {
+	// serializes the writes to each laptop so its events reach the hub in the same
+	// order they hit the store.
+	locks *keyedMutex
+	store LaptopStore
+	hub   *LaptopHub
+}
+
+func NewPublishingLaptopStore(store LaptopStore, hub *LaptopHub) *PublishingLaptopStore {
+	return &PublishingLaptopStore{locks: newKeyedMutex(), store: store, hub: hub}
+}
+
+func (p *PublishingLaptopStore) Save(laptop *pb.Laptop) error {
+	defer p.locks.Lock(laptop.GetId())()
+
+	if err := p.store.Save(laptop); err != nil {
+		return err
+	}
+	return p.publish(pb.LaptopEvent_CREATED, laptop, nil)
+}
+
+func (p *PublishingLaptopStore) Find(id string) (*pb.Laptop, error) {
+	return p.store.Find(id)
+}
+
+func (p *PublishingLaptopStore) Update(laptop *pb.Laptop) error {
+	defer p.locks.Lock(laptop.GetId())()
+
+	previous, _ := p.store.Find(laptop.GetId())
+	if err := p.store.Update(laptop); err != nil {
+		return err
+	}
+	return p.publish(pb.LaptopEvent_UPDATED, laptop, previous)
+}
+
+func (p *PublishingLaptopStore) Delete(id string) error {
+	defer p.locks.Lock(id)()
+
+	previous, err := p.store.Find(id)
+	if err != nil {
+		return err
+	}
+	if err := p.store.Delete(id); err != nil {
+		return err
+	}
+	p.hub.Publish(pb.LaptopEvent_DELETED, previous, nil)
+	return nil
+}
+
+func (p *PublishingLaptopStore) Search(ctx context.Context, filter *pb.Filter, found func(laptop *pb.Laptop) error) error {
+	return p.store.Search(ctx, filter, found)
+}
+
+func (p *PublishingLaptopStore) Count() int {
+	return p.store.Count()
+}
+
+// events keep their own copy, the caller may still modify the laptop.
+func (p *PublishingLaptopStore) publish(eventType pb.LaptopEvent_Type, laptop *pb.Laptop, previous *pb.Laptop) error {
+	other, err := deepCopy(laptop)
+	if err != nil {
+		return err
+	}
+	p.hub.Publish(eventType, other, previous)
+	return nil
+}
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-grpc-pcbook/pb"
+	"go-grpc-pcbook/raft"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+)
+
+// Laptop and rating stores of a Raft cluster node. Writes are committed through the
+// cluster log then applied to the local stores of every node, only the leader takes
+// them. Reads are served by the local stores, so followers may lag behind the leader.
+type RaftStores struct {
+	Node *raft.Node
+	// How long a write waits to be committed.
+	Timeout time.Duration
+
+	laptopStore LaptopStore
+	ratingStore RatingStore
+}
+
+// Stores of the node with the given config, keeping the cluster state in the local stores.
+// The node must be started.
+func NewRaftStores(config raft.Config, transport raft.Transport, laptopStore LaptopStore, ratingStore RatingStore) *RaftStores {
+	stores := &RaftStores{Timeout: 5 * time.Second, laptopStore: laptopStore, ratingStore: ratingStore}
+	stores.Node = raft.NewNode(config, &raftStateMachine{laptopStore, ratingStore}, transport)
+	return stores
+}
+
+// Stores of a node keeping its raft state in the storage, restarted from it if it
+// has some. The local stores must start empty. The node must be started.
+func NewDurableRaftStores(config raft.Config, storage raft.Storage, transport raft.Transport, laptopStore LaptopStore, ratingStore RatingStore) (*RaftStores, error) {
+	stores := &RaftStores{Timeout: 5 * time.Second, laptopStore: laptopStore, ratingStore: ratingStore}
+	node, err := raft.NewDurableNode(config, storage, &raftStateMachine{laptopStore, ratingStore}, transport)
+	if err != nil {
+		return nil, err
+	}
+	stores.Node = node
+	return stores, nil
+}
+
+func (r *RaftStores) LaptopStore() LaptopStore {
+	return &raftLaptopStore{r}
+}
+
+func (r *RaftStores) RatingStore() RatingStore {
+	return &raftRatingStore{r}
+}
+
+// Commits the command, and returns the result of applying it.
+func (r *RaftStores) commit(command *pb.ClusterCommand) (interface{}, error) {
+	data, err := proto.Marshal(command)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.Timeout)
+	defer cancel()
+	result, err := r.Node.Propose(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	if err, ok := result.(error); ok {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Rejects the calls only the leader of the Raft cluster can serve while the node
+// isn't the leader, telling the client where the leader is. Followers serve the same
+// reads as replication followers, and every node takes the messages of the others.
+type RaftInterceptor struct {
+	node *raft.Node
+}
+
+func NewRaftInterceptor(node *raft.Node) *RaftInterceptor {
+	return &RaftInterceptor{node}
+}
+
+func (r *RaftInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := r.check(info.FullMethod); err != nil {
+			return nil, logError(loggerFromContext(ctx), err)
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (r *RaftInterceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := r.check(info.FullMethod); err != nil {
+			return logError(loggerFromContext(stream.Context()), err)
+		}
+		return handler(srv, stream)
+	}
+}
+
+func (r *RaftInterceptor) check(method string) error {
+	if followerMethods[method] || strings.HasPrefix(method, "/"+pb.RaftService_ServiceDesc.ServiceName+"/") {
+		return nil
+	}
+	status := r.node.Status()
+	if status.Role == raft.Leader {
+		return nil
+	}
+	if status.Leader == "" {
+		return statusWithDetails(codes.Unavailable, "this server is a raft follower and the cluster has no leader, retry later",
+			errorInfo(ReasonNotLeader, map[string]string{"leader": ""}),
+		)
+	}
+	return statusWithDetails(codes.FailedPrecondition, fmt.Sprintf("this server is a raft follower, send writes to the leader at %s", status.Leader),
+		errorInfo(ReasonNotLeader, map[string]string{"leader": status.Leader}),
+	)
+}
+
+type raftLaptopStore struct {
+	stores *RaftStores
+}
+
+func (r *raftLaptopStore) Save(laptop *pb.Laptop) error {
+	_, err := r.stores.commit(&pb.ClusterCommand{Command: &pb.ClusterCommand_SaveLaptop{SaveLaptop: laptop}})
+	return err
+}
+
+func (r *raftLaptopStore) Find(id string) (*pb.Laptop, error) {
+	return r.stores.laptopStore.Find(id)
+}
+
+func (r *raftLaptopStore) Update(laptop *pb.Laptop) error {
+	_, err := r.stores.commit(&pb.ClusterCommand{Command: &pb.ClusterCommand_UpdateLaptop{UpdateLaptop: laptop}})
+	return err
+}
+
+func (r *raftLaptopStore) Delete(id string) error {
+	_, err := r.stores.commit(&pb.ClusterCommand{Command: &pb.ClusterCommand_DeleteLaptopId{DeleteLaptopId: id}})
+	return err
+}
+
+func (r *raftLaptopStore) Search(ctx context.Context, filter *pb.Filter, found func(laptop *pb.Laptop) error) error {
+	return r.stores.laptopStore.Search(ctx, filter, found)
+}
+
+func (r *raftLaptopStore) Count() int {
+	return r.stores.laptopStore.Count()
+}
+
+type raftRatingStore struct {
+	stores *RaftStores
+}
+
+func (r *raftRatingStore) Add(userId string, laptopId string, score float64) (*Rating, error) {
+	rated := &pb.LaptopRated{UserId: userId, LaptopId: laptopId, Score: score}
+	result, err := r.stores.commit(&pb.ClusterCommand{Command: &pb.ClusterCommand_RateLaptop{RateLaptop: rated}})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*Rating), nil
+}
+
+func (r *raftRatingStore) UserScores() (map[string]map[string]float64, error) {
+	return r.stores.ratingStore.UserScores()
+}
+
+func (r *raftRatingStore) Count() int {
+	return r.stores.ratingStore.Count()
+}
+
+func (r *raftRatingStore) Snapshot() ([]*pb.LaptopRatingState, error) {
+	return r.stores.ratingStore.Snapshot()
+}
+
+func (r *raftRatingStore) Restore(states []*pb.LaptopRatingState) error {
+	restored := &pb.RatingsRestored{Ratings: states}
+	_, err := r.stores.commit(&pb.ClusterCommand{Command: &pb.ClusterCommand_RestoreRatings{RestoreRatings: restored}})
+	return err
+}
+
+// Applies the cluster commands to the local stores. Results are the store errors,
+// or the rating of a laptop once rated.
+type raftStateMachine struct {
+	laptopStore LaptopStore
+	ratingStore RatingStore
+}
+
+func (m *raftStateMachine) Apply(data []byte) interface{} {
+	command := &pb.ClusterCommand{}
+	if err := proto.Unmarshal(data, command); err != nil {
+		return fmt.Errorf("cannot decode cluster command: %w", err)
+	}
+
+	switch command := command.GetCommand().(type) {
+	case *pb.ClusterCommand_SaveLaptop:
+		return m.laptopStore.Save(command.SaveLaptop)
+	case *pb.ClusterCommand_UpdateLaptop:
+		return m.laptopStore.Update(command.UpdateLaptop)
+	case *pb.ClusterCommand_DeleteLaptopId:
+		return m.laptopStore.Delete(command.DeleteLaptopId)
+	case *pb.ClusterCommand_RateLaptop:
+		rated := command.RateLaptop
+		rating, err := m.ratingStore.Add(rated.GetUserId(), rated.GetLaptopId(), rated.GetScore())
+		if err != nil {
+			return err
+		}
+		// the store keeps changing its rating, the proposer gets a copy.
+		return &Rating{count: rating.count, score: rating.score}
+	case *pb.ClusterCommand_RestoreRatings:
+		return m.ratingStore.Restore(command.RestoreRatings.GetRatings())
+	default:
+		return fmt.Errorf("unknown cluster command %T", command)
+	}
+}
+
+func (m *raftStateMachine) Snapshot() ([]byte, error) {
+	snapshot := &pb.ClusterSnapshot{}
+	err := m.laptopStore.Search(context.Background(), unlimitedPrice(nil), func(laptop *pb.Laptop) error {
+		snapshot.Laptops = append(snapshot.Laptops, laptop)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if snapshot.Ratings, err = m.ratingStore.Snapshot(); err != nil {
+		return nil, err
+	}
+	return proto.Marshal(snapshot)
+}
+
+func (m *raftStateMachine) Restore(data []byte) error {
+	snapshot := &pb.ClusterSnapshot{}
+	if err := proto.Unmarshal(data, snapshot); err != nil {
+		return fmt.Errorf("cannot decode cluster snapshot: %w", err)
+	}
+
+	// only the differences go through the stores, so the laptops the node already has
+	// keep their wishlist entries and publish no events.
+	current := map[string]*pb.Laptop{}
+	err := m.laptopStore.Search(context.Background(), unlimitedPrice(nil), func(laptop *pb.Laptop) error {
+		current[laptop.GetId()] = laptop
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	kept := map[string]bool{}
+	for _, laptop := range snapshot.GetLaptops() {
+		kept[laptop.GetId()] = true
+	}
+	for id := range current {
+		if kept[id] {
+			continue
+		}
+		if err := m.laptopStore.Delete(id); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	for _, laptop := range snapshot.GetLaptops() {
+		existing, ok := current[laptop.GetId()]
+		switch {
+		case !ok:
+			err = m.laptopStore.Save(laptop)
+		case !proto.Equal(existing, laptop):
+			err = m.laptopStore.Update(laptop)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return m.ratingStore.Restore(snapshot.GetRatings())
+}
@@ -0,0 +1,269 @@
+package service_test
+
+import (
+	"context"
+	"crypto/tls"
+	"go-grpc-pcbook/pb"
+	"go-grpc-pcbook/raft"
+	"go-grpc-pcbook/sample"
+	"go-grpc-pcbook/service"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+func startTestRaftCluster(t *testing.T, network *raft.Network, ids ...string) map[string]*service.RaftStores {
+	cluster := map[string]*service.RaftStores{}
+	for _, id := range ids {
+		config := raft.Config{Id: id, Members: ids, TickInterval: 2 * time.Millisecond}
+		cluster[id] = service.NewRaftStores(config, network.Transport(), service.NewMemoryLaptopStore(), service.NewMemoryRatingStore())
+		network.Add(cluster[id].Node)
+		cluster[id].Node.Start()
+		t.Cleanup(cluster[id].Node.Stop)
+	}
+	return cluster
+}
+
+func raftLeader(t *testing.T, cluster map[string]*service.RaftStores, ids ...string) *service.RaftStores {
+	var leader *service.RaftStores
+	require.Eventually(t, func() bool {
+		for _, id := range ids {
+			if status := cluster[id].Node.Status(); status.Role == raft.Leader {
+				leader = cluster[id]
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 5*time.Millisecond)
+	return leader
+}
+
+func TestRaftStores(t *testing.T) {
+	network := raft.NewNetwork()
+	cluster := startTestRaftCluster(t, network, "a", "b", "c")
+	leader := raftLeader(t, cluster, "a", "b", "c")
+
+	laptop := sample.NewLaptop()
+	require.NoError(t, leader.LaptopStore().Save(laptop))
+	require.ErrorIs(t, leader.LaptopStore().Save(laptop), service.ErrAlreadyExists)
+	rating, err := leader.RatingStore().Add("user:a", laptop.Id, 8)
+	require.NoError(t, err)
+	require.NotNil(t, rating)
+
+	for _, stores := range cluster {
+		stores := stores
+		require.Eventually(t, func() bool {
+			found, _ := stores.LaptopStore().Find(laptop.Id)
+			return found != nil && stores.RatingStore().Count() == 1
+		}, 5*time.Second, 5*time.Millisecond)
+
+		if stores != leader {
+			var notLeader *raft.NotLeaderError
+			require.ErrorAs(t, stores.LaptopStore().Save(sample.NewLaptop()), &notLeader)
+			require.Equal(t, leader.Node.Id(), notLeader.Leader)
+		}
+	}
+
+	// the majority carries on without the leader, which catches up once back.
+	others := []string{}
+	for id := range cluster {
+		if cluster[id] != leader {
+			others = append(others, id)
+		}
+	}
+	network.Partition([]string{leader.Node.Id()}, others)
+	newLeader := raftLeader(t, cluster, others...)
+	laptop.Price = 1234
+	require.NoError(t, newLeader.LaptopStore().Update(laptop))
+	require.ErrorIs(t, newLeader.LaptopStore().Delete("missing"), service.ErrNotFound)
+
+	network.Heal()
+	require.Eventually(t, func() bool {
+		found, _ := leader.LaptopStore().Find(laptop.Id)
+		return found.GetPrice() == 1234
+	}, 5*time.Second, 5*time.Millisecond)
+}
+
+// Laptop store recording the writes it gets.
+type recordingLaptopStore struct {
+	service.LaptopStore
+	mutex  sync.Mutex
+	writes []string
+}
+
+func (s *recordingLaptopStore) Save(laptop *pb.Laptop) error {
+	s.record("save " + laptop.GetId())
+	return s.LaptopStore.Save(laptop)
+}
+
+func (s *recordingLaptopStore) Update(laptop *pb.Laptop) error {
+	s.record("update " + laptop.GetId())
+	return s.LaptopStore.Update(laptop)
+}
+
+func (s *recordingLaptopStore) Delete(id string) error {
+	s.record("delete " + id)
+	return s.LaptopStore.Delete(id)
+}
+
+func (s *recordingLaptopStore) record(write string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.writes = append(s.writes, write)
+}
+
+func (s *recordingLaptopStore) reset() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	writes := s.writes
+	s.writes = nil
+	return writes
+}
+
+func TestRaftSnapshotRestoresDifferences(t *testing.T) {
+	network := raft.NewNetwork()
+	ids := []string{"a", "b", "c"}
+	cluster := map[string]*service.RaftStores{}
+	stores := map[string]*recordingLaptopStore{}
+	for _, id := range ids {
+		config := raft.Config{Id: id, Members: ids, TickInterval: 2 * time.Millisecond, SnapshotThreshold: 5}
+		stores[id] = &recordingLaptopStore{LaptopStore: service.NewMemoryLaptopStore()}
+		cluster[id] = service.NewRaftStores(config, network.Transport(), stores[id], service.NewMemoryRatingStore())
+		network.Add(cluster[id].Node)
+		cluster[id].Node.Start()
+		t.Cleanup(cluster[id].Node.Stop)
+	}
+	leader := raftLeader(t, cluster, ids...)
+
+	kept, changed, removed := sample.NewLaptop(), sample.NewLaptop(), sample.NewLaptop()
+	for _, laptop := range []*pb.Laptop{kept, changed, removed} {
+		require.NoError(t, leader.LaptopStore().Save(laptop))
+	}
+	lagging := "a"
+	if leader.Node.Id() == lagging {
+		lagging = "b"
+	}
+	require.Eventually(t, func() bool { return stores[lagging].Count() == 3 }, 5*time.Second, 5*time.Millisecond)
+	stores[lagging].reset()
+
+	online := []string{}
+	for _, id := range ids {
+		if id != lagging {
+			online = append(online, id)
+		}
+	}
+	network.Partition(online)
+	changed.Price = 1234
+	require.NoError(t, leader.LaptopStore().Update(changed))
+	require.NoError(t, leader.LaptopStore().Delete(removed.Id))
+	added := []*pb.Laptop{}
+	for i := 0; i < 10; i++ {
+		laptop := sample.NewLaptop()
+		require.NoError(t, leader.LaptopStore().Save(laptop))
+		added = append(added, laptop)
+	}
+	require.Eventually(t, func() bool { return leader.Node.Status().SnapshotIndex > 5 }, 5*time.Second, 5*time.Millisecond)
+
+	// the lagging node catches up from a snapshot, the laptop it has as is isn't written.
+	network.Heal()
+	require.Eventually(t, func() bool { return stores[lagging].Count() == 12 }, 5*time.Second, 5*time.Millisecond)
+	require.Greater(t, cluster[lagging].Node.Status().SnapshotIndex, uint64(0))
+	writes := stores[lagging].reset()
+	require.NotContains(t, writes, "delete "+kept.Id)
+	require.NotContains(t, writes, "save "+kept.Id)
+	require.NotContains(t, writes, "update "+kept.Id)
+	require.Contains(t, writes, "update "+changed.Id)
+	require.Contains(t, writes, "delete "+removed.Id)
+	for _, laptop := range added {
+		require.Contains(t, writes, "save "+laptop.Id)
+	}
+}
+
+func TestRaftTransport(t *testing.T) {
+	pki := newTestPKI(t)
+	// nodes serve the raft service on the same port of their own host, apart from the public services.
+	listeners := map[string]net.Listener{}
+	raftListeners := map[string]net.Listener{}
+	ids := []string{}
+	raftPort := ""
+	for _, host := range []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"} {
+		listener, err := net.Listen("tcp", host+":0")
+		require.NoError(t, err)
+		raftListener, err := net.Listen("tcp", net.JoinHostPort(host, raftPort))
+		require.NoError(t, err)
+		_, raftPort, _ = net.SplitHostPort(raftListener.Addr().String())
+		listeners[listener.Addr().String()] = listener
+		raftListeners[listener.Addr().String()] = raftListener
+		ids = append(ids, listener.Addr().String())
+	}
+
+	// nodes present the certificate of the cluster peers, the only callers of the raft service.
+	nodeCredentials := credentials.NewTLS(&tls.Config{RootCAs: pki.pool, Certificates: []tls.Certificate{pki.issue(t, "node")}})
+	cluster := map[string]*service.RaftStores{}
+	for _, id := range ids {
+		config := raft.Config{Id: id, Members: ids, TickInterval: 5 * time.Millisecond}
+		transport := service.NewRaftTransport(grpc.WithTransportCredentials(nodeCredentials))
+		transport.Port = raftPort
+		t.Cleanup(transport.Close)
+		cluster[id] = service.NewRaftStores(config, transport, service.NewMemoryLaptopStore(), service.NewMemoryRatingStore())
+
+		authorizer := service.NewAuthorizer(map[string][]string{service.RoleCluster: {"user:node"}})
+		authorizer.Restrict("/pcbook.RaftService/", service.RoleCluster)
+		raftInterceptor := service.NewRaftInterceptor(cluster[id].Node)
+		grpcServer := grpc.NewServer(
+			pki.serverOption(t),
+			grpc.ChainUnaryInterceptor(raftInterceptor.Unary(), authorizer.Unary()),
+			grpc.ChainStreamInterceptor(raftInterceptor.Stream(), authorizer.Stream()),
+		)
+		raftServer := grpc.NewServer(pki.serverOption(t), grpc.UnaryInterceptor(authorizer.Unary()))
+		pb.RegisterRaftServiceServer(raftServer, service.NewRaftServer(cluster[id].Node))
+		go raftServer.Serve(raftListeners[id])
+		t.Cleanup(raftServer.Stop)
+		pb.RegisterLaptopServiceServer(grpcServer, service.NewLaptopServer(cluster[id].LaptopStore(), nil, cluster[id].RatingStore()))
+		go grpcServer.Serve(listeners[id])
+		t.Cleanup(grpcServer.Stop)
+		cluster[id].Node.Start()
+		t.Cleanup(cluster[id].Node.Stop)
+	}
+	leader := raftLeader(t, cluster, ids...)
+
+	laptopClient := pb.NewLaptopServiceClient(pki.dial(t, leader.Node.Id(), "alice"))
+	laptop := sample.NewLaptop()
+	_, err := laptopClient.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: laptop})
+	require.NoError(t, err)
+
+	for _, id := range ids {
+		if cluster[id] == leader {
+			continue
+		}
+		require.Eventually(t, func() bool {
+			found, _ := cluster[id].LaptopStore().Find(laptop.Id)
+			return found != nil
+		}, 5*time.Second, 5*time.Millisecond)
+
+		// followers send clients writing to them to the leader.
+		conn := pki.dial(t, id, "alice")
+		_, err := pb.NewLaptopServiceClient(conn).CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: sample.NewLaptop()})
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+		details := status.Convert(err).Details()
+		require.Len(t, details, 1)
+		require.Equal(t, leader.Node.Id(), details[0].(*errdetails.ErrorInfo).GetMetadata()["leader"])
+
+		// other callers can't pose as a node.
+		raftConn := pki.dial(t, raftListeners[id].Addr().String(), "alice")
+		_, err = pb.NewRaftServiceClient(raftConn).Step(context.Background(), &pb.RaftMessage{Type: pb.RaftMessage_APPEND, From: leader.Node.Id(), To: id, Term: 100})
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		// the public server, keeping the default message size limit, doesn't take raft messages.
+		_, err = pb.NewRaftServiceClient(conn).Step(context.Background(), &pb.RaftMessage{Type: pb.RaftMessage_APPEND, From: leader.Node.Id(), To: id, Term: 100})
+		require.Equal(t, codes.Unimplemented, status.Code(err))
+	}
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"go-grpc-pcbook/pb"
+	"go-grpc-pcbook/raft"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Messages queued for a node before new ones are dropped.
+const raftQueueSize = 256
+
+// Transport sending the messages of a Raft node to the RaftService of the other
+// nodes, whose ids are their addresses. Each node has its own queue, sent one message
+// at a time, so a slow or unreachable node doesn't hold up the others. Messages are
+// dropped when the queue is full, Raft sends them again.
+type RaftTransport struct {
+	// How long sending a message may take.
+	Timeout time.Duration
+	// Port the nodes serve the RaftService on, dialed at the host of their id. The id
+	// is dialed as is if empty.
+	Port string
+
+	dialOptions []grpc.DialOption
+	mutex       sync.Mutex
+	peers       map[string]*raftPeer
+	closed      bool
+}
+
+type raftPeer struct {
+	conn  *grpc.ClientConn
+	queue chan *pb.RaftMessage
+}
+
+func NewRaftTransport(dialOptions ...grpc.DialOption) *RaftTransport {
+	return &RaftTransport{Timeout: time.Second, dialOptions: dialOptions, peers: make(map[string]*raftPeer)}
+}
+
+func (t *RaftTransport) Send(msg raft.Message) {
+	// converted right away, the node keeps the entries and snapshot.
+	message := raftMessageToProto(msg)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.closed {
+		return
+	}
+	peer, ok := t.peers[msg.To]
+	if !ok {
+		conn, err := grpc.Dial(t.address(msg.To), t.dialOptions...)
+		if err != nil {
+			slog.Error("couldn't dial raft node", "node", msg.To, "error", err)
+			return
+		}
+		peer = &raftPeer{conn: conn, queue: make(chan *pb.RaftMessage, raftQueueSize)}
+		t.peers[msg.To] = peer
+		go t.run(peer)
+	}
+
+	select {
+	case peer.queue <- message:
+	default:
+	}
+}
+
+func (t *RaftTransport) address(id string) string {
+	if t.Port == "" {
+		return id
+	}
+	host, _, err := net.SplitHostPort(id)
+	if err != nil {
+		return id
+	}
+	return net.JoinHostPort(host, t.Port)
+}
+
+// Stops sending messages and closes the connections.
+func (t *RaftTransport) Close() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.closed = true
+	for _, peer := range t.peers {
+		close(peer.queue)
+		peer.conn.Close()
+	}
+}
+
+func (t *RaftTransport) run(peer *raftPeer) {
+	client := pb.NewRaftServiceClient(peer.conn)
+	for message := range peer.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), t.Timeout)
+		_, err := client.Step(ctx, message)
+		cancel()
+		if err != nil {
+			slog.Debug("couldn't send raft message", "node", message.GetTo(), "error", err)
+		}
+	}
+}
+
+// Hands the messages of the other nodes to the local Raft node.
+type RaftServer struct {
+	node *raft.Node
+}
+
+func NewRaftServer(node *raft.Node) *RaftServer {
+	return &RaftServer{node}
+}
+
+func (s *RaftServer) Step(ctx context.Context, req *pb.RaftMessage) (*pb.StepRaftResponse, error) {
+	logger := loggerFromContext(ctx)
+	if req.GetTo() != s.node.Id() {
+		return nil, logError(logger, status.Errorf(codes.InvalidArgument, "message for node %q reached node %q", req.GetTo(), s.node.Id()))
+	}
+	if req.GetType() == pb.RaftMessage_SNAPSHOT && req.GetSnapshot() == nil {
+		return nil, logError(logger, status.Errorf(codes.InvalidArgument, "snapshot message without a snapshot"))
+	}
+
+	s.node.Step(raftMessageFromProto(req))
+	return &pb.StepRaftResponse{}, nil
+}
+
+func raftMessageToProto(msg raft.Message) *pb.RaftMessage {
+	message := &pb.RaftMessage{
+		Type:         pb.RaftMessage_Type(msg.Type),
+		From:         msg.From,
+		To:           msg.To,
+		Term:         msg.Term,
+		LastLogIndex: msg.LastLogIndex,
+		LastLogTerm:  msg.LastLogTerm,
+		PrevLogIndex: msg.PrevLogIndex,
+		PrevLogTerm:  msg.PrevLogTerm,
+		Commit:       msg.Commit,
+		Success:      msg.Success,
+		MatchIndex:   msg.MatchIndex,
+	}
+	for _, entry := range msg.Entries {
+		message.Entries = append(message.Entries, &pb.RaftEntry{
+			Index:   entry.Index,
+			Term:    entry.Term,
+			Type:    pb.RaftEntry_Type(entry.Type),
+			Data:    entry.Data,
+			Members: entry.Members,
+		})
+	}
+	if snapshot := msg.Snapshot; snapshot != nil {
+		message.Snapshot = &pb.RaftSnapshot{Index: snapshot.Index, Term: snapshot.Term, Members: snapshot.Members, Data: snapshot.Data}
+	}
+	return message
+}
+
+func raftMessageFromProto(message *pb.RaftMessage) raft.Message {
+	msg := raft.Message{
+		Type:         raft.MessageType(message.GetType()),
+		From:         message.GetFrom(),
+		To:           message.GetTo(),
+		Term:         message.GetTerm(),
+		LastLogIndex: message.GetLastLogIndex(),
+		LastLogTerm:  message.GetLastLogTerm(),
+		PrevLogIndex: message.GetPrevLogIndex(),
+		PrevLogTerm:  message.GetPrevLogTerm(),
+		Commit:       message.GetCommit(),
+		Success:      message.GetSuccess(),
+		MatchIndex:   message.GetMatchIndex(),
+	}
+	for _, entry := range message.GetEntries() {
+		msg.Entries = append(msg.Entries, raft.Entry{
+			Index:   entry.GetIndex(),
+			Term:    entry.GetTerm(),
+			Type:    raft.EntryType(entry.GetType()),
+			Data:    entry.GetData(),
+			Members: entry.GetMembers(),
+		})
+	}
+	if snapshot := message.GetSnapshot(); snapshot != nil {
+		msg.Snapshot = &raft.Snapshot{Index: snapshot.GetIndex(), Term: snapshot.GetTerm(), Members: snapshot.GetMembers(), Data: snapshot.GetData()}
+	}
+	return msg
+}
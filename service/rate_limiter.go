@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// Trailer telling rate limited clients how many seconds to wait before retrying.
+const RetryAfterKey = "retry-after"
+
+// buckets untouched for this long are dropped.
+const bucketIdleTimeout = 10 * time.Minute
+
+// Token bucket refilled at Rate tokens per second, holding at most Burst tokens.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+type RateLimiterConfig struct {
+	// Limit for methods without their own entry. Zero rate means unlimited.
+	Default RateLimit
+	// Limits by method name, like "RateLaptop".
+	Methods map[string]RateLimit
+	// Max streams a client can keep open at once. Zero means unlimited.
+	MaxStreams int
+}
+
+// Per client rate and concurrency limiting through server interceptors.
+type RateLimiter struct {
+	mutex     sync.Mutex
+	config    RateLimiterConfig
+	buckets   map[string]*tokenBucket
+	streams   map[string]int
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewRateLimiter(config RateLimiterConfig) *RateLimiter {
+	return &RateLimiter{
+		config:    config,
+		buckets:   make(map[string]*tokenBucket),
+		streams:   make(map[string]int),
+		lastSweep: time.Now(),
+	}
+}
+
+func (l *RateLimiter) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		// tenants don't multiply the limits of a client.
+		if wait, ok := l.allow(peerIdentity(ctx), info.FullMethod); !ok {
+			grpc.SetTrailer(ctx, retryAfter(wait))
+			return nil, rateLimitError(info.FullMethod, wait)
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (l *RateLimiter) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		client := peerIdentity(stream.Context())
+		if wait, ok := l.allow(client, info.FullMethod); !ok {
+			stream.SetTrailer(retryAfter(wait))
+			return rateLimitError(info.FullMethod, wait)
+		}
+
+		if !l.openStream(client) {
+			stream.SetTrailer(retryAfter(time.Second))
+			return statusWithDetails(codes.ResourceExhausted, fmt.Sprintf("too many concurrent streams: limit is %d", l.config.MaxStreams),
+				errorInfo(ReasonTooManyStreams, map[string]string{"max_streams": fmt.Sprint(l.config.MaxStreams)}),
+				retryInfo(time.Second),
+			)
+		}
+		defer l.closeStream(client)
+
+		return handler(srv, stream)
+	}
+}
+
+// Takes a token from the client bucket for the method.
+// When empty, returns how long until the next token is available.
+func (l *RateLimiter) allow(client string, fullMethod string) (time.Duration, bool) {
+	limit := l.limitFor(fullMethod)
+	if limit.Rate <= 0 {
+		return 0, true
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	key := client + " " + fullMethod
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(limit.Burst), last: now}
+		l.buckets[key] = bucket
+	}
+
+	elapsed := now.Sub(bucket.last).Seconds()
+	bucket.tokens = math.Min(float64(limit.Burst), bucket.tokens+elapsed*limit.Rate)
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		missing := 1 - bucket.tokens
+		return time.Duration(missing / limit.Rate * float64(time.Second)), false
+	}
+	bucket.tokens--
+	return 0, true
+}
+
+func (l *RateLimiter) limitFor(fullMethod string) RateLimit {
+	name := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	if limit, ok := l.config.Methods[name]; ok {
+		return limit
+	}
+	return l.config.Default
+}
+
+// Drops idle buckets so the map doesn't grow with every client ever seen. Must hold the lock.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < bucketIdleTimeout {
+		return
+	}
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.last) >= bucketIdleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+func (l *RateLimiter) openStream(client string) bool {
+	if l.config.MaxStreams <= 0 {
+		return true
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.streams[client] >= l.config.MaxStreams {
+		return false
+	}
+	l.streams[client]++
+	return true
+}
+
+func (l *RateLimiter) closeStream(client string) {
+	if l.config.MaxStreams <= 0 {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.streams[client]--
+	if l.streams[client] <= 0 {
+		delete(l.streams, client)
+	}
+}
+
+func retryAfter(wait time.Duration) metadata.MD {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return metadata.Pairs(RetryAfterKey, strconv.Itoa(seconds))
+}
+
+func rateLimitError(fullMethod string, wait time.Duration) error {
+	return statusWithDetails(codes.ResourceExhausted, fmt.Sprintf("rate limit exceeded for %s, retry in %v", fullMethod, wait.Round(time.Millisecond)),
+		errorInfo(ReasonRateLimited, map[string]string{"method": fullMethod}),
+		retryInfo(wait),
+	)
+}
+
+// Parses limits written as "RateLaptop=5:10,CreateLaptop=1:2" where each
+// value is rate per second and burst.
+func ParseRateLimits(text string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit)
+	if strings.TrimSpace(text) == "" {
+		return limits, nil
+	}
+
+	for _, entry := range strings.Split(text, ",") {
+		method, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || method == "" {
+			return nil, fmt.Errorf("invalid rate limit %q: expected method=rate:burst", entry)
+		}
+		limit, err := ParseRateLimit(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate limit for %s: %w", method, err)
+		}
+		limits[method] = limit
+	}
+	return limits, nil
+}
+
+// Parses a single "rate:burst" value. Burst defaults to the rounded up rate.
+func ParseRateLimit(text string) (RateLimit, error) {
+	rateText, burstText, hasBurst := strings.Cut(text, ":")
+	rate, err := strconv.ParseFloat(rateText, 64)
+	if err != nil || rate < 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate %q", rateText)
+	}
+
+	burst := int(math.Ceil(rate))
+	if hasBurst {
+		burst, err = strconv.Atoi(burstText)
+		if err != nil || burst < 1 {
+			return RateLimit{}, fmt.Errorf("invalid burst %q", burstText)
+		}
+	}
+	if rate > 0 && burst < 1 {
+		burst = 1
+	}
+	return RateLimit{Rate: rate, Burst: burst}, nil
+}
@@ -0,0 +1,128 @@
+package service_test
+
+import (
+	"context"
+	"go-grpc-pcbook/pb"
+	"go-grpc-pcbook/sample"
+	"go-grpc-pcbook/service"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	limits, err := service.ParseRateLimits("RateLaptop=5:10, CreateLaptop=0.5")
+	require.NoError(t, err)
+	require.Equal(t, service.RateLimit{Rate: 5, Burst: 10}, limits["RateLaptop"])
+	require.Equal(t, service.RateLimit{Rate: 0.5, Burst: 1}, limits["CreateLaptop"])
+
+	for _, invalid := range []string{"RateLaptop", "=5", "RateLaptop=fast", "RateLaptop=5:0", "RateLaptop=-1"} {
+		_, err := service.ParseRateLimits(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestRateLimiterUnary(t *testing.T) {
+	rateLimiter := service.NewRateLimiter(service.RateLimiterConfig{
+		Methods: map[string]service.RateLimit{"CreateLaptop": {Rate: 0.001, Burst: 2}},
+	})
+	laptopClient := startTestRateLimitedServer(t, rateLimiter, service.NewMemoryLaptopStore())
+
+	for i := 0; i < 2; i++ {
+		_, err := laptopClient.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: sample.NewLaptop()})
+		require.NoError(t, err)
+	}
+
+	var trailer metadata.MD
+	_, err := laptopClient.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: sample.NewLaptop()}, grpc.Trailer(&trailer))
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.Len(t, trailer.Get(service.RetryAfterKey), 1)
+	require.NotEqual(t, "0", trailer.Get(service.RetryAfterKey)[0])
+
+	details := status.Convert(err).Details()
+	require.Len(t, details, 2)
+	require.Equal(t, service.ReasonRateLimited, details[0].(*errdetails.ErrorInfo).GetReason())
+	require.Positive(t, details[1].(*errdetails.RetryInfo).GetRetryDelay().AsDuration())
+}
+
+func TestRateLimiterMaxStreams(t *testing.T) {
+	laptopStore := service.NewMemoryLaptopStore()
+	laptop := sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(laptop))
+
+	rateLimiter := service.NewRateLimiter(service.RateLimiterConfig{MaxStreams: 1})
+	laptopClient := startTestRateLimitedServer(t, rateLimiter, laptopStore)
+
+	// first stream stays open until we close it.
+	first, err := laptopClient.RateLaptop(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, first.Send(&pb.RateLaptopRequest{LaptopId: laptop.Id, Score: 5}))
+	_, err = first.Recv()
+	require.NoError(t, err)
+
+	second, err := laptopClient.RateLaptop(context.Background())
+	require.NoError(t, err)
+	_, err = second.Recv()
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.NotEmpty(t, second.Trailer().Get(service.RetryAfterKey))
+
+	require.NoError(t, first.CloseSend())
+	_, err = first.Recv()
+	require.Error(t, err)
+
+	// slot is free again once the first stream is done.
+	require.Eventually(t, func() bool {
+		third, err := laptopClient.RateLaptop(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, third.CloseSend())
+		_, err = third.Recv()
+		return status.Code(err) != codes.ResourceExhausted
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestRateLimiterForwardedClients(t *testing.T) {
+	rateLimiter := service.NewRateLimiter(service.RateLimiterConfig{
+		Methods: map[string]service.RateLimit{"CreateLaptop": {Rate: 0.001, Burst: 1}},
+	})
+	forwardedInterceptor := service.NewForwardedInterceptor("gateway-secret")
+	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(forwardedInterceptor.Unary(), rateLimiter.Unary()))
+	pb.RegisterLaptopServiceServer(grpcServer, service.NewLaptopServer(service.NewMemoryLaptopStore(), nil, service.NewMemoryRatingStore()))
+	laptopClient := pb.NewLaptopServiceClient(serveTest(t, grpcServer))
+
+	create := func(token string, client string) error {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), service.GatewayTokenKey, token, service.ForwardedForKey, client)
+		_, err := laptopClient.CreateLaptop(ctx, &pb.CreateLaptopRequest{Laptop: sample.NewLaptop()})
+		return err
+	}
+
+	// clients of the gateway each get their own bucket.
+	require.NoError(t, create("gateway-secret", "10.0.0.1"))
+	require.Equal(t, codes.ResourceExhausted, status.Code(create("gateway-secret", "10.0.0.1")))
+	require.NoError(t, create("gateway-secret", "10.0.0.2"))
+
+	// without the token, the forwarded address is ignored.
+	require.NoError(t, create("guess", "10.0.0.3"))
+	require.Equal(t, codes.ResourceExhausted, status.Code(create("guess", "10.0.0.4")))
+}
+
+func startTestRateLimitedServer(t *testing.T, rateLimiter *service.RateLimiter, laptopStore service.LaptopStore) pb.LaptopServiceClient {
+	laptopServer := service.NewLaptopServer(laptopStore, nil, service.NewMemoryRatingStore())
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(rateLimiter.Unary()),
+		grpc.StreamInterceptor(rateLimiter.Stream()),
+	)
+	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	return newTestLaptopClient(t, listener.Addr().String())
+}
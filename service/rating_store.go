@@ -1,14 +1,29 @@
 package service
 
-import "sync"
+import (
+	"go-grpc-pcbook/pb"
+	"sync"
+)
 
 type RatingStore interface {
-	Add(laptopId string, score float64) (*Rating, error)
+	// Adds the score a user gave to a laptop. Every score counts in the laptop rating,
+	// a user rating a laptop again replaces their score in UserScores
+	Add(userId string, laptopId string, score float64) (*Rating, error)
+	// Latest score of each user for each laptop they rated, by user then laptop id
+	UserScores() (map[string]map[string]float64, error)
+	// Number of ratings received for all laptops
+	Count() int
+	// Ratings of every rated laptop, to copy the store
+	Snapshot() ([]*pb.LaptopRatingState, error)
+	// Replaces every rating in the store with the given ones
+	Restore(states []*pb.LaptopRatingState) error
 }
 
 type MemoryRatingStore struct {
-	mutex   sync.RWMutex
-	ratings map[string]*Rating
+	mutex      sync.RWMutex
+	ratings    map[string]*Rating
+	userScores map[string]map[string]float64
+	count      int
 }
 
 type Rating struct {
@@ -17,10 +32,13 @@ type Rating struct {
 }
 
 func NewMemoryRatingStore() *MemoryRatingStore {
-	return &MemoryRatingStore{ratings: make(map[string]*Rating)}
+	return &MemoryRatingStore{
+		ratings:    make(map[string]*Rating),
+		userScores: make(map[string]map[string]float64),
+	}
 }
 
-func (m *MemoryRatingStore) Add(laptopId string, score float64) (*Rating, error) {
+func (m *MemoryRatingStore) Add(userId string, laptopId string, score float64) (*Rating, error) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
@@ -33,6 +51,79 @@ func (m *MemoryRatingStore) Add(laptopId string, score float64) (*Rating, error)
 	}
 
 	m.ratings[laptopId] = rating
+	m.count++
+
+	if m.userScores[userId] == nil {
+		m.userScores[userId] = make(map[string]float64)
+	}
+	m.userScores[userId][laptopId] = score
 
 	return rating, nil
 }
+
+func (m *MemoryRatingStore) UserScores() (map[string]map[string]float64, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	userScores := make(map[string]map[string]float64, len(m.userScores))
+	for userId, scores := range m.userScores {
+		userScores[userId] = make(map[string]float64, len(scores))
+		for laptopId, score := range scores {
+			userScores[userId][laptopId] = score
+		}
+	}
+	return userScores, nil
+}
+
+func (m *MemoryRatingStore) Count() int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.count
+}
+
+func (m *MemoryRatingStore) Snapshot() ([]*pb.LaptopRatingState, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	states := make(map[string]*pb.LaptopRatingState, len(m.ratings))
+	for laptopId, rating := range m.ratings {
+		states[laptopId] = &pb.LaptopRatingState{
+			LaptopId:   laptopId,
+			Count:      uint32(rating.count),
+			ScoreSum:   rating.score,
+			UserScores: make(map[string]float64),
+		}
+	}
+	for userId, scores := range m.userScores {
+		for laptopId, score := range scores {
+			states[laptopId].UserScores[userId] = score
+		}
+	}
+
+	snapshot := make([]*pb.LaptopRatingState, 0, len(states))
+	for _, state := range states {
+		snapshot = append(snapshot, state)
+	}
+	return snapshot, nil
+}
+
+func (m *MemoryRatingStore) Restore(states []*pb.LaptopRatingState) error {
+	ratings := make(map[string]*Rating, len(states))
+	userScores := make(map[string]map[string]float64)
+	count := 0
+	for _, state := range states {
+		ratings[state.GetLaptopId()] = &Rating{count: int32(state.GetCount()), score: state.GetScoreSum()}
+		count += int(state.GetCount())
+		for userId, score := range state.GetUserScores() {
+			if userScores[userId] == nil {
+				userScores[userId] = make(map[string]float64)
+			}
+			userScores[userId][state.GetLaptopId()] = score
+		}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.ratings, m.userScores, m.count = ratings, userScores, count
+	return nil
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// most similar laptops kept for each laptop.
+	maxItemNeighbors = 50
+	// ratings a top rated laptop needs to weigh as much as the global mean.
+	topRatedDamping = 5
+)
+
+// Laptop recommended to a user, with the score they're expected to give it.
+type Recommendation struct {
+	LaptopId string
+	Score    float64
+}
+
+// Source of the scores the engine learns from, like a RatingStore.
+type UserScorer interface {
+	// Latest score of each user for each laptop they rated, by user then laptop id
+	UserScores() (map[string]map[string]float64, error)
+}
+
+// Item-item collaborative filtering over the user scores of a RatingStore.
+// The model is computed by Refresh, usually in the background with Run, so
+// recommending doesn't depend on the number of ratings.
+type RecommendationEngine struct {
+	scorer UserScorer
+	mutex  sync.RWMutex
+	model  *recommendationModel
+}
+
+type recommendationModel struct {
+	userScores map[string]map[string]float64
+	userMeans  map[string]float64
+	// laptop id -> most similar laptops, by adjusted cosine similarity.
+	neighbors map[string][]itemNeighbor
+	// by damped mean score, for users the engine knows nothing about.
+	topRated []Recommendation
+}
+
+type itemNeighbor struct {
+	laptopId   string
+	similarity float64
+}
+
+func NewRecommendationEngine(scorer UserScorer) *RecommendationEngine {
+	return &RecommendationEngine{scorer: scorer, model: &recommendationModel{}}
+}
+
+// Refreshes the model now and then every interval until ctx is done.
+// The interval must be positive.
+func (e *RecommendationEngine) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := e.Refresh(); err != nil {
+			slog.Error("couldn't refresh recommendations", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Recomputes the model from the current ratings.
+func (e *RecommendationEngine) Refresh() error {
+	userScores, err := e.scorer.UserScores()
+	if err != nil {
+		return err
+	}
+
+	model := &recommendationModel{
+		userScores: userScores,
+		userMeans:  make(map[string]float64, len(userScores)),
+		neighbors:  make(map[string][]itemNeighbor),
+	}
+
+	// scores centered on the mean of each user, so harsh and generous users compare.
+	laptopScores := make(map[string]map[string]float64)
+	for userId, scores := range userScores {
+		sum := 0.0
+		for _, score := range scores {
+			sum += score
+		}
+		mean := sum / float64(len(scores))
+		model.userMeans[userId] = mean
+
+		for laptopId, score := range scores {
+			if laptopScores[laptopId] == nil {
+				laptopScores[laptopId] = make(map[string]float64)
+			}
+			laptopScores[laptopId][userId] = score - mean
+		}
+	}
+
+	laptopIds := make([]string, 0, len(laptopScores))
+	for laptopId := range laptopScores {
+		laptopIds = append(laptopIds, laptopId)
+	}
+	for i, laptopId := range laptopIds {
+		for _, otherId := range laptopIds[i+1:] {
+			similarity := cosineSimilarity(laptopScores[laptopId], laptopScores[otherId])
+			if similarity > 0 {
+				model.neighbors[laptopId] = append(model.neighbors[laptopId], itemNeighbor{otherId, similarity})
+				model.neighbors[otherId] = append(model.neighbors[otherId], itemNeighbor{laptopId, similarity})
+			}
+		}
+	}
+	for laptopId, neighbors := range model.neighbors {
+		sort.Slice(neighbors, func(i, j int) bool { return neighbors[i].similarity > neighbors[j].similarity })
+		if len(neighbors) > maxItemNeighbors {
+			model.neighbors[laptopId] = neighbors[:maxItemNeighbors]
+		}
+	}
+
+	model.topRated = topRated(userScores)
+
+	e.mutex.Lock()
+	e.model = model
+	e.mutex.Unlock()
+	return nil
+}
+
+// Up to limit available laptops the user hasn't rated, best first. personalized is false
+// when the user's ratings say nothing yet and the top rated laptops are returned.
+func (e *RecommendationEngine) Recommend(userId string, limit int, available func(laptopId string) bool) (recommendations []Recommendation, personalized bool) {
+	e.mutex.RLock()
+	model := e.model
+	e.mutex.RUnlock()
+
+	rated := model.userScores[userId]
+	weighted := make(map[string]float64)
+	weights := make(map[string]float64)
+	for laptopId, score := range rated {
+		centered := score - model.userMeans[userId]
+		for _, neighbor := range model.neighbors[laptopId] {
+			if _, ok := rated[neighbor.laptopId]; ok {
+				continue
+			}
+			weighted[neighbor.laptopId] += neighbor.similarity * centered
+			weights[neighbor.laptopId] += neighbor.similarity
+		}
+	}
+
+	recommendations = []Recommendation{}
+	for laptopId, weight := range weights {
+		if !available(laptopId) {
+			continue
+		}
+		recommendations = append(recommendations, Recommendation{laptopId, model.userMeans[userId] + weighted[laptopId]/weight})
+	}
+	sortRecommendations(recommendations)
+	if len(recommendations) > limit {
+		return recommendations[:limit], true
+	}
+	personalized = len(recommendations) > 0
+
+	// top rated laptops fill the rest.
+	for _, top := range model.topRated {
+		if len(recommendations) >= limit {
+			break
+		}
+		if _, ok := rated[top.LaptopId]; ok || weights[top.LaptopId] > 0 || !available(top.LaptopId) {
+			continue
+		}
+		recommendations = append(recommendations, top)
+	}
+	return recommendations, personalized
+}
+
+func cosineSimilarity(a, b map[string]float64) float64 {
+	dot, normA, normB := 0.0, 0.0, 0.0
+	for userId, x := range a {
+		normA += x * x
+		if y, ok := b[userId]; ok {
+			dot += x * y
+		}
+	}
+	for _, y := range b {
+		normB += y * y
+	}
+	if dot == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+// Laptops by mean score, pulled towards the global mean when they have few ratings.
+func topRated(userScores map[string]map[string]float64) []Recommendation {
+	sums := make(map[string]float64)
+	counts := make(map[string]int)
+	total, count := 0.0, 0
+	for _, scores := range userScores {
+		for laptopId, score := range scores {
+			sums[laptopId] += score
+			counts[laptopId]++
+			total += score
+			count++
+		}
+	}
+	if count == 0 {
+		return nil
+	}
+
+	mean := total / float64(count)
+	recommendations := make([]Recommendation, 0, len(sums))
+	for laptopId, sum := range sums {
+		score := (sum + topRatedDamping*mean) / float64(counts[laptopId]+topRatedDamping)
+		recommendations = append(recommendations, Recommendation{laptopId, score})
+	}
+	sortRecommendations(recommendations)
+	return recommendations
+}
+
+// Best first, ties by laptop id so results are stable.
+func sortRecommendations(recommendations []Recommendation) {
+	sort.Slice(recommendations, func(i, j int) bool {
+		if recommendations[i].Score != recommendations[j].Score {
+			return recommendations[i].Score > recommendations[j].Score
+		}
+		return recommendations[i].LaptopId < recommendations[j].LaptopId
+	})
+}
@@ -0,0 +1,73 @@
+package service_test
+
+import (
+	"context"
+	"go-grpc-pcbook/pb"
+	"go-grpc-pcbook/sample"
+	"go-grpc-pcbook/service"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestServerRecommendForUser(t *testing.T) {
+	laptopStore := service.NewMemoryLaptopStore()
+	ratingStore := service.NewMemoryRatingStore()
+	engine := service.NewRecommendationEngine(ratingStore)
+	server := service.NewLaptopServer(laptopStore, nil, ratingStore)
+	server.Recommendations = engine
+
+	laptops := map[string]*pb.Laptop{}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		laptops[name] = sample.NewLaptop()
+		require.NoError(t, laptopStore.Save(laptops[name]))
+	}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1234}})
+	scores := map[string]map[string]float64{
+		"user:first":                {"a": 9, "b": 9, "c": 2, "d": 5},
+		"user:second":               {"a": 8, "b": 10, "c": 3, "d": 6},
+		service.CallerIdentity(ctx): {"a": 10, "c": 2},
+	}
+	for userId, userScores := range scores {
+		for name, score := range userScores {
+			_, err := ratingStore.Add(userId, laptops[name].GetId(), score)
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, engine.Refresh())
+
+	// users who liked a also liked b, and those who disliked c disliked d.
+	res, err := server.RecommendForUser(ctx, &pb.RecommendForUserRequest{})
+	require.NoError(t, err)
+	require.True(t, res.GetPersonalized())
+	require.Len(t, res.GetLaptops(), 2)
+	require.Equal(t, laptops["b"].GetId(), res.GetLaptops()[0].GetLaptopId())
+	require.InDelta(t, 10, res.GetLaptops()[0].GetScore(), 1e-9)
+	require.Equal(t, laptops["d"].GetId(), res.GetLaptops()[1].GetLaptopId())
+	require.InDelta(t, 2, res.GetLaptops()[1].GetScore(), 1e-9)
+
+	// a user without ratings gets the top rated laptops, the well rated ones first.
+	stranger := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1234}})
+	res, err = server.RecommendForUser(stranger, &pb.RecommendForUserRequest{Limit: 3})
+	require.NoError(t, err)
+	require.False(t, res.GetPersonalized())
+	ids := []string{}
+	for _, recommended := range res.GetLaptops() {
+		ids = append(ids, recommended.GetLaptopId())
+	}
+	require.Equal(t, []string{laptops["a"].GetId(), laptops["b"].GetId(), laptops["d"].GetId()}, ids)
+
+	// a deleted laptop isn't recommended before the next refresh.
+	require.NoError(t, laptopStore.Delete(laptops["b"].GetId()))
+	res, err = server.RecommendForUser(ctx, &pb.RecommendForUserRequest{})
+	require.NoError(t, err)
+	require.Len(t, res.GetLaptops(), 1)
+	require.Equal(t, laptops["d"].GetId(), res.GetLaptops()[0].GetLaptopId())
+
+	_, err = server.RecommendForUser(ctx, &pb.RecommendForUserRequest{Limit: 1000})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-grpc-pcbook/pb"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// Keeps local stores a copy of a leader's, through its ReplicationService.
+// The first connection starts from a snapshot of the leader, later ones resume
+// after the last change applied, or start over if the leader can't resume it.
+type Follower struct {
+	client      pb.ReplicationServiceClient
+	laptopStore LaptopStore
+	ratingStore RatingStore
+
+	mutex    sync.Mutex
+	logId    string // of the leader log the sequence is from
+	sequence uint64 // of the last change applied
+}
+
+func NewFollower(client pb.ReplicationServiceClient, laptopStore LaptopStore, ratingStore RatingStore) *Follower {
+	return &Follower{client: client, laptopStore: laptopStore, ratingStore: ratingStore}
+}
+
+// Follows the leader until the context is done, reconnecting after the given wait.
+func (f *Follower) Run(ctx context.Context, retry time.Duration) {
+	for {
+		err := f.follow(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Warn("lost the replication stream", "error", err, "retry_in", retry)
+
+		select {
+		case <-time.After(retry):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Sequence of the last leader change applied, 0 before the first snapshot.
+func (f *Follower) Sequence() uint64 {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.sequence
+}
+
+func (f *Follower) follow(ctx context.Context) error {
+	f.mutex.Lock()
+	req := &pb.ReplicateRequest{LogId: f.logId, FromSequence: f.sequence}
+	f.mutex.Unlock()
+	stream, err := f.client.Replicate(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	snapshot := map[string]bool{}
+	ratings := []*pb.LaptopRatingState{}
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return errors.New("leader closed the stream")
+		}
+		if err != nil {
+			return err
+		}
+
+		switch message := res.GetMessage().(type) {
+		case *pb.ReplicateResponse_SnapshotLaptop:
+			snapshot[message.SnapshotLaptop.GetId()] = true
+			err = f.saveLaptop(message.SnapshotLaptop)
+		case *pb.ReplicateResponse_SnapshotRating:
+			ratings = append(ratings, message.SnapshotRating)
+		case *pb.ReplicateResponse_SnapshotSequence:
+			if err = f.restore(ctx, snapshot, ratings); err == nil {
+				f.setSequence(res.GetLogId(), message.SnapshotSequence)
+				slog.Info("replicated leader snapshot", "laptops", len(snapshot), "sequence", message.SnapshotSequence)
+			}
+		case *pb.ReplicateResponse_Change:
+			if err = f.apply(message.Change); err == nil {
+				f.setSequence(f.logId, message.Change.GetSequence())
+			}
+		}
+		if err != nil {
+			// the next connection starts over, the stores may have half a snapshot.
+			f.setSequence("", 0)
+			return fmt.Errorf("cannot apply leader changes: %w", err)
+		}
+	}
+}
+
+// Drops the local laptops missing from the snapshot, and takes its ratings.
+func (f *Follower) restore(ctx context.Context, snapshot map[string]bool, ratings []*pb.LaptopRatingState) error {
+	stale := []string{}
+	err := f.laptopStore.Search(ctx, unlimitedPrice(nil), func(laptop *pb.Laptop) error {
+		if !snapshot[laptop.GetId()] {
+			stale = append(stale, laptop.GetId())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, id := range stale {
+		if err := f.laptopStore.Delete(id); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	return f.ratingStore.Restore(ratings)
+}
+
+func (f *Follower) apply(change *pb.ReplicationChange) error {
+	switch change := change.GetChange().(type) {
+	case *pb.ReplicationChange_SavedLaptop:
+		return f.saveLaptop(change.SavedLaptop)
+	case *pb.ReplicationChange_DeletedLaptopId:
+		if err := f.laptopStore.Delete(change.DeletedLaptopId); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		return nil
+	case *pb.ReplicationChange_LaptopRated:
+		rated := change.LaptopRated
+		_, err := f.ratingStore.Add(rated.GetUserId(), rated.GetLaptopId(), rated.GetScore())
+		return err
+	case *pb.ReplicationChange_RatingsRestored:
+		return f.ratingStore.Restore(change.RatingsRestored.GetRatings())
+	default:
+		return fmt.Errorf("unknown change %T", change)
+	}
+}
+
+// Saves or replaces the laptop, whether or not the follower already has it.
+func (f *Follower) saveLaptop(laptop *pb.Laptop) error {
+	if _, err := f.laptopStore.Find(laptop.GetId()); errors.Is(err, ErrNotFound) {
+		return f.laptopStore.Save(laptop)
+	} else if err != nil {
+		return err
+	}
+	return f.laptopStore.Update(laptop)
+}
+
+func (f *Follower) setSequence(logId string, sequence uint64) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.logId = logId
+	f.sequence = sequence
+}
+
+// Calls a follower serves from its copy of the catalog.
+var followerMethods = map[string]bool{
+	"/" + pb.LaptopService_ServiceDesc.ServiceName + "/SearchLaptop":     true,
+	"/" + pb.LaptopService_ServiceDesc.ServiceName + "/WatchLaptops":     true,
+	"/" + pb.LaptopService_ServiceDesc.ServiceName + "/ExportLaptops":    true,
+	"/" + pb.LaptopService_ServiceDesc.ServiceName + "/CompareLaptops":   true,
+	"/" + pb.LaptopService_ServiceDesc.ServiceName + "/RecommendSimilar": true,
+	"/" + pb.LaptopService_ServiceDesc.ServiceName + "/RecommendForUser": true,
+	"/" + pb.ReplicationService_ServiceDesc.ServiceName + "/Replicate":   true,
+}
+
+// Rejects every call a follower can't serve, telling the client where the leader is.
+type FollowerInterceptor struct {
+	leaderAddress string
+}
+
+func NewFollowerInterceptor(leaderAddress string) *FollowerInterceptor {
+	return &FollowerInterceptor{leaderAddress}
+}
+
+func (f *FollowerInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !followerMethods[info.FullMethod] {
+			return nil, logError(loggerFromContext(ctx), f.notLeaderError())
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (f *FollowerInterceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !followerMethods[info.FullMethod] {
+			return logError(loggerFromContext(stream.Context()), f.notLeaderError())
+		}
+		return handler(srv, stream)
+	}
+}
+
+func (f *FollowerInterceptor) notLeaderError() error {
+	return statusWithDetails(codes.FailedPrecondition, fmt.Sprintf("this server is a read-only follower, send writes to the leader at %s", f.leaderAddress),
+		errorInfo(ReasonNotLeader, map[string]string{"leader": f.leaderAddress}),
+	)
+}
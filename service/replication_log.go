@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-grpc-pcbook/pb"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+var ErrReplicationLag = errors.New("follower fell behind the replication history.")
+
+// Ordered log of the writes to the leader stores, so followers can copy the stores
+// then apply every later change. Writes to different laptops run together, those to
+// the same laptop go through the log one at a time. Snapshots wait for the writes
+// under way and hold off new ones, so they never see half of one.
+type ReplicationLog struct {
+	id string
+	// held shared by writes, and exclusively by snapshots and writes to every laptop.
+	writes sync.RWMutex
+	locks  *keyedMutex
+	// guards the fields below, never held while writing to the stores.
+	mutex       sync.Mutex
+	sequence    uint64                  // of the last change
+	history     []*pb.ReplicationChange // oldest first, at most historySize
+	historySize int
+	// closed and replaced whenever a change is appended.
+	appended chan struct{}
+}
+
+func NewReplicationLog(historySize int) *ReplicationLog {
+	return &ReplicationLog{id: uuid.New().String(), locks: newKeyedMutex(), historySize: historySize, appended: make(chan struct{})}
+}
+
+// Unique to this log, so followers don't resume from the sequence of another one.
+func (l *ReplicationLog) Id() string {
+	return l.id
+}
+
+// Tells if the changes after the sequence are all kept, for now.
+func (l *ReplicationLog) Retains(after uint64) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	first := l.sequence - uint64(len(l.history)) + 1
+	return after <= l.sequence && after+1 >= first
+}
+
+// Runs the copy between two writes, and returns the sequence of the last change it includes.
+func (l *ReplicationLog) Snapshot(copy func() error) (uint64, error) {
+	l.writes.Lock()
+	defer l.writes.Unlock()
+
+	if err := copy(); err != nil {
+		return 0, err
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.sequence, nil
+}
+
+// Changes after the sequence, waiting until there's one.
+// ErrReplicationLag if some of them are no longer kept.
+func (l *ReplicationLog) Next(ctx context.Context, after uint64) ([]*pb.ReplicationChange, error) {
+	for {
+		l.mutex.Lock()
+		if after < l.sequence {
+			first := l.sequence - uint64(len(l.history)) + 1
+			if after+1 < first {
+				l.mutex.Unlock()
+				return nil, ErrReplicationLag
+			}
+			changes := append([]*pb.ReplicationChange(nil), l.history[after+1-first:]...)
+			l.mutex.Unlock()
+			return changes, nil
+		}
+		appended := l.appended
+		l.mutex.Unlock()
+
+		select {
+		case <-appended:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Runs the write to the laptop and appends the change it returns, unless it fails.
+// Changes are shared by every follower, they must not be modified afterwards.
+func (l *ReplicationLog) record(laptopId string, write func() (*pb.ReplicationChange, error)) error {
+	l.writes.RLock()
+	defer l.writes.RUnlock()
+	// the change is appended before the next write to the laptop, so followers apply them in order.
+	defer l.locks.Lock(laptopId)()
+
+	change, err := write()
+	if err != nil {
+		return err
+	}
+	l.append(change)
+	return nil
+}
+
+// Runs a write to every laptop, once the others are done, and appends its change.
+func (l *ReplicationLog) recordAll(write func() (*pb.ReplicationChange, error)) error {
+	l.writes.Lock()
+	defer l.writes.Unlock()
+
+	change, err := write()
+	if err != nil {
+		return err
+	}
+	l.append(change)
+	return nil
+}
+
+func (l *ReplicationLog) append(change *pb.ReplicationChange) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.sequence++
+	change.Sequence = l.sequence
+
+	l.history = append(l.history, change)
+	if len(l.history) > l.historySize {
+		l.history = l.history[len(l.history)-l.historySize:]
+	}
+	close(l.appended)
+	l.appended = make(chan struct{})
+}
+
+// LaptopStore recording every change to a ReplicationLog.
+type ReplicatingLaptopStore struct {
+	store LaptopStore
+	log   *ReplicationLog
+}
+
+func NewReplicatingLaptopStore(store LaptopStore, log *ReplicationLog) *ReplicatingLaptopStore {
+	return &ReplicatingLaptopStore{store: store, log: log}
+}
+
+func (r *ReplicatingLaptopStore) Save(laptop *pb.Laptop) error {
+	return r.log.record(laptop.GetId(), func() (*pb.ReplicationChange, error) {
+		if err := r.store.Save(laptop); err != nil {
+			return nil, err
+		}
+		return savedLaptopChange(laptop)
+	})
+}
+
+func (r *ReplicatingLaptopStore) Find(id string) (*pb.Laptop, error) {
+	return r.store.Find(id)
+}
+
+func (r *ReplicatingLaptopStore) Update(laptop *pb.Laptop) error {
+	return r.log.record(laptop.GetId(), func() (*pb.ReplicationChange, error) {
+		if err := r.store.Update(laptop); err != nil {
+			return nil, err
+		}
+		return savedLaptopChange(laptop)
+	})
+}
+
+func (r *ReplicatingLaptopStore) Delete(id string) error {
+	return r.log.record(id, func() (*pb.ReplicationChange, error) {
+		if err := r.store.Delete(id); err != nil {
+			return nil, err
+		}
+		return &pb.ReplicationChange{Change: &pb.ReplicationChange_DeletedLaptopId{DeletedLaptopId: id}}, nil
+	})
+}
+
+func (r *ReplicatingLaptopStore) Search(ctx context.Context, filter *pb.Filter, found func(laptop *pb.Laptop) error) error {
+	return r.store.Search(ctx, filter, found)
+}
+
+func (r *ReplicatingLaptopStore) Count() int {
+	return r.store.Count()
+}
+
+// changes keep their own copy, the caller may still modify the laptop.
+func savedLaptopChange(laptop *pb.Laptop) (*pb.ReplicationChange, error) {
+	other, err := deepCopy(laptop)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ReplicationChange{Change: &pb.ReplicationChange_SavedLaptop{SavedLaptop: other}}, nil
+}
+
+// RatingStore recording every change to a ReplicationLog.
+type ReplicatingRatingStore struct {
+	store RatingStore
+	log   *ReplicationLog
+}
+
+func NewReplicatingRatingStore(store RatingStore, log *ReplicationLog) *ReplicatingRatingStore {
+	return &ReplicatingRatingStore{store: store, log: log}
+}
+
+func (r *ReplicatingRatingStore) Add(userId string, laptopId string, score float64) (*Rating, error) {
+	var rating *Rating
+	err := r.log.record(laptopId, func() (*pb.ReplicationChange, error) {
+		var err error
+		if rating, err = r.store.Add(userId, laptopId, score); err != nil {
+			return nil, err
+		}
+		rated := &pb.LaptopRated{UserId: userId, LaptopId: laptopId, Score: score}
+		return &pb.ReplicationChange{Change: &pb.ReplicationChange_LaptopRated{LaptopRated: rated}}, nil
+	})
+	return rating, err
+}
+
+func (r *ReplicatingRatingStore) UserScores() (map[string]map[string]float64, error) {
+	return r.store.UserScores()
+}
+
+func (r *ReplicatingRatingStore) Count() int {
+	return r.store.Count()
+}
+
+func (r *ReplicatingRatingStore) Snapshot() ([]*pb.LaptopRatingState, error) {
+	return r.store.Snapshot()
+}
+
+func (r *ReplicatingRatingStore) Restore(states []*pb.LaptopRatingState) error {
+	return r.log.recordAll(func() (*pb.ReplicationChange, error) {
+		if err := r.store.Restore(states); err != nil {
+			return nil, err
+		}
+		// states are the caller's, the change needs its own.
+		snapshot, err := r.store.Snapshot()
+		if err != nil {
+			return nil, err
+		}
+		restored := &pb.RatingsRestored{Ratings: snapshot}
+		return &pb.ReplicationChange{Change: &pb.ReplicationChange_RatingsRestored{RatingsRestored: restored}}, nil
+	})
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"errors"
+	"go-grpc-pcbook/pb"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Streams the leader stores to followers: a snapshot, then every change in order.
+// Followers that reconnect skip the snapshot if the log still has the changes they missed.
+type ReplicationServer struct {
+	pb.UnimplementedReplicationServiceServer
+	LaptopStore LaptopStore
+	RatingStore RatingStore
+	Log         *ReplicationLog
+}
+
+func NewReplicationServer(laptopStore LaptopStore, ratingStore RatingStore, log *ReplicationLog) *ReplicationServer {
+	return &ReplicationServer{LaptopStore: laptopStore, RatingStore: ratingStore, Log: log}
+}
+
+func (s *ReplicationServer) Replicate(req *pb.ReplicateRequest, stream pb.ReplicationService_ReplicateServer) error {
+	ctx := stream.Context()
+	logger := loggerFromContext(ctx)
+
+	sequence := req.GetFromSequence()
+	if req.GetLogId() == s.Log.Id() && s.Log.Retains(sequence) {
+		logger.Info("follower resumed", "sequence", sequence)
+	} else {
+		var err error
+		if sequence, err = s.sendSnapshot(stream); err != nil {
+			return err
+		}
+	}
+
+	for {
+		changes, err := s.Log.Next(ctx, sequence)
+		if errors.Is(err, ErrReplicationLag) {
+			return logError(logger, status.Error(codes.Aborted, "follower fell behind the change history, reconnect to get a new snapshot"))
+		}
+		if err != nil {
+			return contextError(ctx, logger)
+		}
+
+		for _, change := range changes {
+			if err := stream.Send(&pb.ReplicateResponse{Message: &pb.ReplicateResponse_Change{Change: change}}); err != nil {
+				return logError(logger, status.Errorf(codes.Unknown, "cannot send change: %v", err))
+			}
+			sequence = change.GetSequence()
+		}
+	}
+}
+
+// Sends a copy of the stores and returns the sequence of the last change it includes.
+func (s *ReplicationServer) sendSnapshot(stream pb.ReplicationService_ReplicateServer) (uint64, error) {
+	ctx := stream.Context()
+	logger := loggerFromContext(ctx)
+
+	laptops := []*pb.Laptop{}
+	var ratings []*pb.LaptopRatingState
+	sequence, err := s.Log.Snapshot(func() error {
+		err := s.LaptopStore.Search(ctx, unlimitedPrice(nil), func(laptop *pb.Laptop) error {
+			laptops = append(laptops, laptop)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		ratings, err = s.RatingStore.Snapshot()
+		return err
+	})
+	if err != nil {
+		if err := contextError(ctx, logger); err != nil {
+			return 0, err
+		}
+		return 0, logError(logger, status.Errorf(codes.Internal, "cannot snapshot stores: %v", err))
+	}
+
+	for _, laptop := range laptops {
+		if err := stream.Send(&pb.ReplicateResponse{Message: &pb.ReplicateResponse_SnapshotLaptop{SnapshotLaptop: laptop}}); err != nil {
+			return 0, logError(logger, status.Errorf(codes.Unknown, "cannot send snapshot: %v", err))
+		}
+	}
+	for _, rating := range ratings {
+		if err := stream.Send(&pb.ReplicateResponse{Message: &pb.ReplicateResponse_SnapshotRating{SnapshotRating: rating}}); err != nil {
+			return 0, logError(logger, status.Errorf(codes.Unknown, "cannot send snapshot: %v", err))
+		}
+	}
+	end := &pb.ReplicateResponse{Message: &pb.ReplicateResponse_SnapshotSequence{SnapshotSequence: sequence}, LogId: s.Log.Id()}
+	if err := stream.Send(end); err != nil {
+		return 0, logError(logger, status.Errorf(codes.Unknown, "cannot send snapshot: %v", err))
+	}
+	logger.Info("follower got snapshot", "laptops", len(laptops), "sequence", sequence)
+	return sequence, nil
+}
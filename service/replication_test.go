@@ -0,0 +1,160 @@
+package service_test
+
+import (
+	"context"
+	"go-grpc-pcbook/pb"
+	"go-grpc-pcbook/sample"
+	"go-grpc-pcbook/service"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func serveTest(t *testing.T, grpcServer *grpc.Server) *grpc.ClientConn {
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	return conn
+}
+
+func TestReplication(t *testing.T) {
+	replicationLog := service.NewReplicationLog(100)
+	leaderLaptops := service.NewReplicatingLaptopStore(service.NewMemoryLaptopStore(), replicationLog)
+	leaderRatings := service.NewReplicatingRatingStore(service.NewMemoryRatingStore(), replicationLog)
+
+	leader := grpc.NewServer()
+	pb.RegisterLaptopServiceServer(leader, service.NewLaptopServer(leaderLaptops, nil, leaderRatings))
+	pb.RegisterReplicationServiceServer(leader, service.NewReplicationServer(leaderLaptops, leaderRatings, replicationLog))
+	leaderConn := serveTest(t, leader)
+	leaderClient := pb.NewLaptopServiceClient(leaderConn)
+
+	// written before the follower starts, it comes with the snapshot.
+	first := sample.NewLaptop()
+	require.NoError(t, leaderLaptops.Save(first))
+	_, err := leaderRatings.Add("user:a", first.Id, 8)
+	require.NoError(t, err)
+
+	followerLaptops := service.NewMemoryLaptopStore()
+	followerRatings := service.NewMemoryRatingStore()
+	// a laptop the leader doesn't have goes away with the snapshot.
+	require.NoError(t, followerLaptops.Save(sample.NewLaptop()))
+	followerInterceptor := service.NewFollowerInterceptor("leader:8080")
+	follower := grpc.NewServer(grpc.UnaryInterceptor(followerInterceptor.Unary()), grpc.StreamInterceptor(followerInterceptor.Stream()))
+	pb.RegisterLaptopServiceServer(follower, service.NewLaptopServer(followerLaptops, nil, followerRatings))
+	followerClient := pb.NewLaptopServiceClient(serveTest(t, follower))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	replicator := service.NewFollower(pb.NewReplicationServiceClient(leaderConn), followerLaptops, followerRatings)
+	go replicator.Run(ctx, 10*time.Millisecond)
+
+	require.Eventually(t, func() bool { return replicator.Sequence() == 2 }, time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{first.Id}, searchTenant(t, followerClient, context.Background()))
+
+	// later changes follow in order.
+	second := sample.NewLaptop()
+	_, err = leaderClient.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: second})
+	require.NoError(t, err)
+	first.Price = 1234
+	_, err = leaderClient.UpdateLaptop(context.Background(), &pb.UpdateLaptopRequest{Laptop: first})
+	require.NoError(t, err)
+	_, err = leaderRatings.Add("user:b", first.Id, 6)
+	require.NoError(t, err)
+	_, err = leaderClient.DeleteLaptop(context.Background(), &pb.DeleteLaptopRequest{Id: second.Id})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return replicator.Sequence() == 6 }, time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{first.Id}, searchTenant(t, followerClient, context.Background()))
+	replicated, err := followerLaptops.Find(first.Id)
+	require.NoError(t, err)
+	require.EqualValues(t, 1234, replicated.GetPrice())
+	scores, err := followerRatings.UserScores()
+	require.NoError(t, err)
+	require.Equal(t, map[string]map[string]float64{"user:a": {first.Id: 8}, "user:b": {first.Id: 6}}, scores)
+	require.Equal(t, 2, followerRatings.Count())
+
+	// writes go to the leader.
+	_, err = followerClient.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: sample.NewLaptop()})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+	details := status.Convert(err).Details()
+	require.Len(t, details, 1)
+	require.Equal(t, service.ReasonNotLeader, details[0].(*errdetails.ErrorInfo).GetReason())
+	require.Equal(t, "leader:8080", details[0].(*errdetails.ErrorInfo).GetMetadata()["leader"])
+
+	rate, err := followerClient.RateLaptop(context.Background())
+	require.NoError(t, err)
+	_, err = rate.Recv()
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func TestReplicationResume(t *testing.T) {
+	replicationLog := service.NewReplicationLog(100)
+	leaderLaptops := service.NewReplicatingLaptopStore(service.NewMemoryLaptopStore(), replicationLog)
+	leaderRatings := service.NewReplicatingRatingStore(service.NewMemoryRatingStore(), replicationLog)
+	leader := grpc.NewServer()
+	pb.RegisterReplicationServiceServer(leader, service.NewReplicationServer(leaderLaptops, leaderRatings, replicationLog))
+	require.NoError(t, leaderLaptops.Save(sample.NewLaptop()))
+
+	followerLaptops := service.NewMemoryLaptopStore()
+	replicator := service.NewFollower(pb.NewReplicationServiceClient(serveTest(t, leader)), followerLaptops, service.NewMemoryRatingStore())
+	follow := func(sequence uint64) {
+		ctx, cancel := context.WithCancel(context.Background())
+		stopped := make(chan struct{})
+		go func() {
+			replicator.Run(ctx, 10*time.Millisecond)
+			close(stopped)
+		}()
+		require.Eventually(t, func() bool { return replicator.Sequence() == sequence }, time.Second, 10*time.Millisecond)
+		cancel()
+		<-stopped
+	}
+	follow(1)
+
+	// a snapshot would drop the laptop the leader doesn't have, resuming only
+	// brings the changes missed while disconnected.
+	local := sample.NewLaptop()
+	require.NoError(t, followerLaptops.Save(local))
+	missed := sample.NewLaptop()
+	require.NoError(t, leaderLaptops.Save(missed))
+	follow(2)
+	_, err := followerLaptops.Find(local.Id)
+	require.NoError(t, err)
+	_, err = followerLaptops.Find(missed.Id)
+	require.NoError(t, err)
+	require.Equal(t, 3, followerLaptops.Count())
+}
+
+func TestReplicationLogLag(t *testing.T) {
+	replicationLog := service.NewReplicationLog(2)
+	laptopStore := service.NewReplicatingLaptopStore(service.NewMemoryLaptopStore(), replicationLog)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, laptopStore.Save(sample.NewLaptop()))
+	}
+
+	changes, err := replicationLog.Next(context.Background(), 1)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	require.EqualValues(t, 2, changes[0].GetSequence())
+
+	_, err = replicationLog.Next(context.Background(), 0)
+	require.ErrorIs(t, err, service.ErrReplicationLag)
+	require.False(t, replicationLog.Retains(0))
+	require.True(t, replicationLog.Retains(1))
+	require.True(t, replicationLog.Retains(3))
+	require.False(t, replicationLog.Retains(4))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = replicationLog.Next(ctx, 3)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
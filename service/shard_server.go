@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-grpc-pcbook/pb"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Serves a laptop store to other servers using it as a shard, see RemoteLaptopStore.
+// Saved and updated laptops are validated like the LaptopService does, the servers
+// calling it must be given the cluster role.
+type ShardServer struct {
+	pb.UnimplementedShardServiceServer
+	LaptopStore LaptopStore
+}
+
+func NewShardServer(laptopStore LaptopStore) *ShardServer {
+	return &ShardServer{LaptopStore: laptopStore}
+}
+
+func (s *ShardServer) Save(ctx context.Context, req *pb.ShardSaveRequest) (*pb.ShardSaveResponse, error) {
+	logger := loggerFromContext(ctx)
+	if err := validateShardLaptop(req.GetLaptop()); err != nil {
+		return nil, logError(logger, err)
+	}
+	if err := s.LaptopStore.Save(req.GetLaptop()); err != nil {
+		return nil, logError(logger, shardError(err))
+	}
+	return &pb.ShardSaveResponse{}, nil
+}
+
+func (s *ShardServer) Find(ctx context.Context, req *pb.ShardFindRequest) (*pb.ShardFindResponse, error) {
+	laptop, err := s.LaptopStore.Find(req.GetId())
+	if err != nil {
+		return nil, logError(loggerFromContext(ctx), shardError(err))
+	}
+	return &pb.ShardFindResponse{Laptop: laptop}, nil
+}
+
+func (s *ShardServer) Update(ctx context.Context, req *pb.ShardUpdateRequest) (*pb.ShardUpdateResponse, error) {
+	logger := loggerFromContext(ctx)
+	if err := validateShardLaptop(req.GetLaptop()); err != nil {
+		return nil, logError(logger, err)
+	}
+	if err := s.LaptopStore.Update(req.GetLaptop()); err != nil {
+		return nil, logError(logger, shardError(err))
+	}
+	return &pb.ShardUpdateResponse{}, nil
+}
+
+func (s *ShardServer) Delete(ctx context.Context, req *pb.ShardDeleteRequest) (*pb.ShardDeleteResponse, error) {
+	if err := s.LaptopStore.Delete(req.GetId()); err != nil {
+		return nil, logError(loggerFromContext(ctx), shardError(err))
+	}
+	return &pb.ShardDeleteResponse{}, nil
+}
+
+func (s *ShardServer) Search(req *pb.ShardSearchRequest, stream pb.ShardService_SearchServer) error {
+	ctx := stream.Context()
+	logger := loggerFromContext(ctx)
+	err := s.LaptopStore.Search(ctx, req.GetFilter(), func(laptop *pb.Laptop) error {
+		return stream.Send(&pb.ShardSearchResponse{Laptop: laptop})
+	})
+	if err != nil {
+		if err := contextError(ctx, logger); err != nil {
+			return err
+		}
+		return logError(logger, status.Errorf(codes.Internal, "unexpected error: %v", err))
+	}
+	return nil
+}
+
+func (s *ShardServer) Count(ctx context.Context, req *pb.ShardCountRequest) (*pb.ShardCountResponse, error) {
+	return &pb.ShardCountResponse{Count: int64(s.LaptopStore.Count())}, nil
+}
+
+// The laptop must be valid and have the UUID the calling server gave it.
+func validateShardLaptop(laptop *pb.Laptop) error {
+	if err := ValidateLaptop(laptop); err != nil {
+		return err
+	}
+	if _, err := uuid.Parse(laptop.GetId()); err != nil {
+		return status.Errorf(codes.InvalidArgument, "laptop ID is not a valid UUID: %v", err)
+	}
+	return nil
+}
+
+// store errors travel as status codes, RemoteLaptopStore turns them back.
+func shardError(err error) error {
+	switch {
+	case errors.Is(err, ErrAlreadyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	default:
+		return status.Errorf(codes.Internal, "shard store error: %v", err)
+	}
+}
+
+// LaptopStore kept by another server through its ShardService.
+type RemoteLaptopStore struct {
+	client pb.ShardServiceClient
+	// How long a call but Search may take.
+	Timeout time.Duration
+	// last count the remote store answered.
+	count atomic.Int64
+}
+
+func NewRemoteLaptopStore(client pb.ShardServiceClient) *RemoteLaptopStore {
+	return &RemoteLaptopStore{client: client, Timeout: 5 * time.Second}
+}
+
+func (r *RemoteLaptopStore) Save(laptop *pb.Laptop) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.Timeout)
+	defer cancel()
+	_, err := r.client.Save(ctx, &pb.ShardSaveRequest{Laptop: laptop})
+	return remoteStoreError(err)
+}
+
+func (r *RemoteLaptopStore) Find(id string) (*pb.Laptop, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.Timeout)
+	defer cancel()
+	res, err := r.client.Find(ctx, &pb.ShardFindRequest{Id: id})
+	if err != nil {
+		return nil, remoteStoreError(err)
+	}
+	return res.GetLaptop(), nil
+}
+
+func (r *RemoteLaptopStore) Update(laptop *pb.Laptop) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.Timeout)
+	defer cancel()
+	_, err := r.client.Update(ctx, &pb.ShardUpdateRequest{Laptop: laptop})
+	return remoteStoreError(err)
+}
+
+func (r *RemoteLaptopStore) Delete(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.Timeout)
+	defer cancel()
+	_, err := r.client.Delete(ctx, &pb.ShardDeleteRequest{Id: id})
+	return remoteStoreError(err)
+}
+
+func (r *RemoteLaptopStore) Search(ctx context.Context, filter *pb.Filter, found func(laptop *pb.Laptop) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := r.client.Search(ctx, &pb.ShardSearchRequest{Filter: filter})
+	if err != nil {
+		return err
+	}
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := found(res.GetLaptop()); err != nil {
+			return err
+		}
+	}
+}
+
+// Number of laptops of the remote store. If it can't be reached, the error is logged
+// and the last count it answered is returned, rather than the store looking empty.
+func (r *RemoteLaptopStore) Count() int {
+	ctx, cancel := context.WithTimeout(context.Background(), r.Timeout)
+	defer cancel()
+	res, err := r.client.Count(ctx, &pb.ShardCountRequest{})
+	if err != nil {
+		slog.Error("couldn't count laptops of remote shard, using the last count", "count", r.count.Load(), "error", err)
+		return int(r.count.Load())
+	}
+	r.count.Store(res.GetCount())
+	return int(res.GetCount())
+}
+
+func remoteStoreError(err error) error {
+	switch status.Code(err) {
+	case codes.OK:
+		return nil
+	case codes.AlreadyExists:
+		return ErrAlreadyExists
+	case codes.NotFound:
+		return ErrNotFound
+	default:
+		return err
+	}
+}
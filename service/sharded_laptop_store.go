@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"go-grpc-pcbook/pb"
+	"hash/fnv"
+	"sort"
+)
+
+// Points each shard takes on the hash ring, so laptops spread evenly.
+const shardVirtualNodes = 128
+
+// LaptopStore spreading laptops over several stores by consistent hashing of their
+// id: adding a shard only moves the laptops it takes from the others. Searches run
+// on every shard at once.
+type ShardedLaptopStore struct {
+	shards map[string]LaptopStore
+	ring   []ringPoint // sorted by hash
+}
+
+type ringPoint struct {
+	hash  uint64
+	shard string
+}
+
+// Store over the shards, by name, at least one. Names place shards on the ring, a
+// shard must keep its name to keep its laptops.
+func NewShardedLaptopStore(shards map[string]LaptopStore) *ShardedLaptopStore {
+	s := &ShardedLaptopStore{shards: shards}
+	for name := range shards {
+		for i := 0; i < shardVirtualNodes; i++ {
+			s.ring = append(s.ring, ringPoint{hash: ringHash(fmt.Sprintf("%s#%d", name, i)), shard: name})
+		}
+	}
+	sort.Slice(s.ring, func(i, j int) bool {
+		if s.ring[i].hash == s.ring[j].hash {
+			return s.ring[i].shard < s.ring[j].shard
+		}
+		return s.ring[i].hash < s.ring[j].hash
+	})
+	return s
+}
+
+// Name of the shard keeping the laptop: the first one on the ring after its id.
+func (s *ShardedLaptopStore) ShardOf(laptopId string) string {
+	hash := ringHash(laptopId)
+	i := sort.Search(len(s.ring), func(i int) bool { return s.ring[i].hash >= hash })
+	if i == len(s.ring) {
+		i = 0
+	}
+	return s.ring[i].shard
+}
+
+func (s *ShardedLaptopStore) Save(laptop *pb.Laptop) error {
+	return s.shard(laptop.GetId()).Save(laptop)
+}
+
+func (s *ShardedLaptopStore) Find(id string) (*pb.Laptop, error) {
+	return s.shard(id).Find(id)
+}
+
+func (s *ShardedLaptopStore) Update(laptop *pb.Laptop) error {
+	return s.shard(laptop.GetId()).Update(laptop)
+}
+
+func (s *ShardedLaptopStore) Delete(id string) error {
+	return s.shard(id).Delete(id)
+}
+
+// Searches every shard in parallel. Found is called by the caller's goroutine, one
+// laptop at a time, and the other shards stop as soon as it or a shard fails.
+func (s *ShardedLaptopStore) Search(ctx context.Context, filter *pb.Filter, found func(laptop *pb.Laptop) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	laptops := make(chan *pb.Laptop)
+	done := make(chan error, len(s.shards))
+	for _, shard := range s.shards {
+		go func(shard LaptopStore) {
+			done <- shard.Search(ctx, filter, func(laptop *pb.Laptop) error {
+				select {
+				case laptops <- laptop:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+		}(shard)
+	}
+
+	// a shard is done once all its laptops were handed over.
+	for pending := len(s.shards); pending > 0; {
+		select {
+		case laptop := <-laptops:
+			if err := found(laptop); err != nil {
+				return err
+			}
+		case err := <-done:
+			if err != nil {
+				return err
+			}
+			pending--
+		}
+	}
+	return nil
+}
+
+func (s *ShardedLaptopStore) Count() int {
+	count := 0
+	for _, shard := range s.shards {
+		count += shard.Count()
+	}
+	return count
+}
+
+func (s *ShardedLaptopStore) shard(laptopId string) LaptopStore {
+	return s.shards[s.ShardOf(laptopId)]
+}
+
+func ringHash(key string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+	return hash.Sum64()
+}
@@ -0,0 +1,202 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"go-grpc-pcbook/pb"
+	"go-grpc-pcbook/sample"
+	"go-grpc-pcbook/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestShardServerRequiresClusterRole(t *testing.T) {
+	authorizer := service.NewAuthorizer(map[string][]string{service.RoleCluster: {"user:node"}})
+	authorizer.Restrict("/pcbook.ShardService/", service.RoleCluster)
+	backend := grpc.NewServer(grpc.UnaryInterceptor(authorizer.Unary()), grpc.StreamInterceptor(authorizer.Stream()))
+	pb.RegisterShardServiceServer(backend, service.NewShardServer(service.NewMemoryLaptopStore()))
+	remote := service.NewRemoteLaptopStore(pb.NewShardServiceClient(serveTest(t, backend)))
+
+	require.Equal(t, codes.PermissionDenied, status.Code(remote.Save(sample.NewLaptop())))
+	_, err := remote.Find("laptop")
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestShardedLaptopStore(t *testing.T) {
+	authorizer := service.NewAuthorizer(map[string][]string{service.RoleCluster: {"peer:127.0.0.1", "peer:::1"}})
+	authorizer.Restrict("/pcbook.ShardService/", service.RoleCluster)
+	backend := grpc.NewServer(grpc.UnaryInterceptor(authorizer.Unary()), grpc.StreamInterceptor(authorizer.Stream()))
+	remoteShard := service.NewMemoryLaptopStore()
+	pb.RegisterShardServiceServer(backend, service.NewShardServer(remoteShard))
+	conn := serveTest(t, backend)
+
+	shards := map[string]service.LaptopStore{
+		"a":      service.NewMemoryLaptopStore(),
+		"b":      service.NewMemoryLaptopStore(),
+		"remote": service.NewRemoteLaptopStore(pb.NewShardServiceClient(conn)),
+	}
+	store := service.NewShardedLaptopStore(shards)
+
+	laptops := map[string]*pb.Laptop{}
+	for i := 0; i < 30; i++ {
+		laptop := sample.NewLaptop()
+		require.NoError(t, store.Save(laptop))
+		laptops[laptop.Id] = laptop
+	}
+	require.Equal(t, 30, store.Count())
+	for name, shard := range shards {
+		require.Positive(t, shard.Count(), name)
+	}
+
+	for id, laptop := range laptops {
+		found, err := shards[store.ShardOf(id)].Find(id)
+		require.NoError(t, err)
+		require.Equal(t, laptop.GetPrice(), found.GetPrice())
+		require.ErrorIs(t, store.Save(laptop), service.ErrAlreadyExists)
+	}
+
+	found := map[string]bool{}
+	err := store.Search(context.Background(), &pb.Filter{MaxPrice: 1e6}, func(laptop *pb.Laptop) error {
+		found[laptop.GetId()] = true
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, found, 30)
+
+	// the search stops at the first error.
+	stop := errors.New("enough")
+	seen := 0
+	err = store.Search(context.Background(), &pb.Filter{MaxPrice: 1e6}, func(laptop *pb.Laptop) error {
+		seen++
+		if seen == 5 {
+			return stop
+		}
+		return nil
+	})
+	require.ErrorIs(t, err, stop)
+	require.Equal(t, 5, seen)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.Error(t, store.Search(ctx, &pb.Filter{MaxPrice: 1e6}, func(laptop *pb.Laptop) error { return nil }))
+
+	for id, laptop := range laptops {
+		laptop.Price = 1
+		require.NoError(t, store.Update(laptop))
+		require.NoError(t, store.Delete(id))
+		require.ErrorIs(t, store.Delete(id), service.ErrNotFound)
+		_, err := store.Find(id)
+		require.ErrorIs(t, err, service.ErrNotFound)
+	}
+	require.Zero(t, remoteShard.Count())
+
+	// the backend validates the laptops it's given.
+	invalid := sample.NewLaptop()
+	invalid.Cpu = nil
+	require.Equal(t, codes.InvalidArgument, status.Code(shards["remote"].Save(invalid)))
+	invalid = sample.NewLaptop()
+	invalid.Id = "not-a-uuid"
+	require.Equal(t, codes.InvalidArgument, status.Code(shards["remote"].Save(invalid)))
+
+	// an unreachable shard keeps its last count.
+	require.NoError(t, shards["remote"].Save(sample.NewLaptop()))
+	require.Equal(t, 1, shards["remote"].Count())
+	conn.Close()
+	require.Equal(t, 1, shards["remote"].Count())
+
+	// a new shard only takes laptops from the others.
+	shards["c"] = service.NewMemoryLaptopStore()
+	grown := service.NewShardedLaptopStore(shards)
+	moved := 0
+	for id := range laptops {
+		if grown.ShardOf(id) != store.ShardOf(id) {
+			require.Equal(t, "c", grown.ShardOf(id))
+			moved++
+		}
+	}
+	require.Less(t, moved, len(laptops))
+}
+
+// Laptop store whose writes wait until the gate is closed.
+type gatedLaptopStore struct {
+	service.LaptopStore
+	entered chan string
+	gate    chan struct{}
+}
+
+func (s *gatedLaptopStore) Save(laptop *pb.Laptop) error {
+	s.entered <- laptop.GetId()
+	<-s.gate
+	return s.LaptopStore.Save(laptop)
+}
+
+func TestShardedWritesRunConcurrently(t *testing.T) {
+	slow := &gatedLaptopStore{LaptopStore: service.NewMemoryLaptopStore(), entered: make(chan string, 1), gate: make(chan struct{})}
+	sharded := service.NewShardedLaptopStore(map[string]service.LaptopStore{"slow": slow, "fast": service.NewMemoryLaptopStore()})
+	tracker := service.NewPriceTracker(service.NewStaticExchangeRates("USD", nil), 10, 3)
+	replicationLog := service.NewReplicationLog(100)
+	laptopStore := service.NewReplicatingLaptopStore(
+		service.NewPublishingLaptopStore(
+			service.NewWishlistPruningLaptopStore(
+				service.NewIndexingLaptopStore(service.NewPriceTrackingLaptopStore(sharded, tracker), service.NewSimilarityIndex()),
+				service.NewMemoryWishlistStore(),
+			),
+			service.NewLaptopHub(100, 10),
+		),
+		replicationLog,
+	)
+
+	laptopOn := func(shard string) *pb.Laptop {
+		for {
+			laptop := sample.NewLaptop()
+			if sharded.ShardOf(laptop.Id) == shard {
+				return laptop
+			}
+		}
+	}
+	slowLaptop, fastLaptop := laptopOn("slow"), laptopOn("fast")
+
+	saved := make(chan error, 1)
+	go func() { saved <- laptopStore.Save(slowLaptop) }()
+	require.Equal(t, slowLaptop.Id, <-slow.entered)
+
+	// the writes to the other shard don't wait for the slow one.
+	written := make(chan error, 1)
+	go func() {
+		err := laptopStore.Save(fastLaptop)
+		if err == nil {
+			fastLaptop.Price = 1234
+			err = laptopStore.Update(fastLaptop)
+		}
+		if err == nil {
+			err = laptopStore.Delete(fastLaptop.Id)
+		}
+		written <- err
+	}()
+	select {
+	case err := <-written:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("writes to the fast shard waited for the slow one")
+	}
+	select {
+	case err := <-saved:
+		t.Fatalf("slow write returned before its shard did: %v", err)
+	default:
+	}
+
+	close(slow.gate)
+	require.NoError(t, <-saved)
+	found, err := laptopStore.Find(slowLaptop.Id)
+	require.NoError(t, err)
+	require.Equal(t, slowLaptop.Id, found.Id)
+	changes, err := replicationLog.Next(context.Background(), 0)
+	require.NoError(t, err)
+	require.Len(t, changes, 4)
+	require.Equal(t, slowLaptop.Id, changes[3].GetSavedLaptop().GetId())
+}
@@ -0,0 +1,336 @@
+package service
+
+import (
+	"container/heap"
+	"fmt"
+	"go-grpc-pcbook/pb"
+	"math"
+	"math/bits"
+	"sort"
+	"sync"
+)
+
+// Features of the similarity space, in vector order.
+var SimilarityFeatures = []string{"price", "cores", "ghz", "ram", "storage", "screen_size", "weight", "gpu_memory"}
+
+const featureCount = 8
+
+type featureVector [featureCount]float64
+
+// Laptop nearby in the similarity space.
+type Neighbor struct {
+	LaptopId string
+	Distance float64
+}
+
+// k-d tree of laptop features answering nearest neighbour queries without
+// scanning every laptop. Features are normalized by their range in the catalog,
+// then weighted per query.
+//
+// Laptops are inserted as they're saved. Replaced and removed laptops stay in the
+// tree as tombstones until they outnumber the live ones, then the tree is rebuilt
+// balanced and the ranges are recomputed; in between ranges only grow. The tree is
+// rebuilt too when inserts, like laptops saved in price order, make it about twice
+// as high as a balanced one.
+type SimilarityIndex struct {
+	mutex sync.RWMutex
+	root  *kdNode
+	// live node of each laptop.
+	nodes   map[string]*kdNode
+	removed int
+	// levels of the tree, and of the tree as it was last rebuilt.
+	height, builtHeight int
+	// range of each feature, set by the first laptop.
+	min, max featureVector
+	ranged   bool
+}
+
+type kdNode struct {
+	laptopId    string
+	vector      featureVector
+	axis        int
+	removed     bool
+	left, right *kdNode
+}
+
+func NewSimilarityIndex() *SimilarityIndex {
+	return &SimilarityIndex{nodes: make(map[string]*kdNode)}
+}
+
+// Adds the laptop, or replaces its features if it's already indexed.
+func (x *SimilarityIndex) Put(laptop *pb.Laptop) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	x.remove(laptop.GetId())
+	node := &kdNode{laptopId: laptop.GetId(), vector: laptopFeatures(laptop)}
+	x.nodes[node.laptopId] = node
+	x.expandRange(node.vector)
+	x.insert(node)
+	x.compact()
+}
+
+func (x *SimilarityIndex) Remove(laptopId string) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	x.remove(laptopId)
+	x.compact()
+}
+
+func (x *SimilarityIndex) Len() int {
+	x.mutex.RLock()
+	defer x.mutex.RUnlock()
+	return len(x.nodes)
+}
+
+// Levels of the tree, nearest neighbour queries visit about that many nodes at best.
+func (x *SimilarityIndex) Height() int {
+	x.mutex.RLock()
+	defer x.mutex.RUnlock()
+	return x.height
+}
+
+// The k laptops nearest to the given one, nearest first and without it.
+// weights are by feature name, missing features weigh 1. ErrNotFound if the laptop isn't indexed.
+func (x *SimilarityIndex) Nearest(laptopId string, k int, weights map[string]float64) ([]Neighbor, error) {
+	return x.NearestMatching(laptopId, k, weights, nil)
+}
+
+// Nearest among the laptops accepted by match, all of them if it's nil.
+func (x *SimilarityIndex) NearestMatching(laptopId string, k int, weights map[string]float64, match func(laptopId string) bool) ([]Neighbor, error) {
+	weightVector, err := featureWeights(weights)
+	if err != nil {
+		return nil, err
+	}
+
+	x.mutex.RLock()
+	defer x.mutex.RUnlock()
+
+	node, ok := x.nodes[laptopId]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if k <= 0 {
+		return []Neighbor{}, nil
+	}
+
+	// per axis factor turning a raw difference into a normalized, weighted one.
+	var scale featureVector
+	for i := range scale {
+		if spread := x.max[i] - x.min[i]; spread > 0 {
+			scale[i] = weightVector[i] / spread
+		}
+	}
+
+	search := &kdSearch{query: node.vector, exclude: laptopId, match: match, k: k, scale: scale}
+	search.visit(x.root)
+
+	neighbors := make([]Neighbor, len(search.found))
+	for i := len(neighbors) - 1; i >= 0; i-- {
+		found := heap.Pop(&search.found).(Neighbor)
+		neighbors[i] = Neighbor{LaptopId: found.LaptopId, Distance: math.Sqrt(found.Distance)}
+	}
+	return neighbors, nil
+}
+
+func (x *SimilarityIndex) remove(laptopId string) {
+	if node, ok := x.nodes[laptopId]; ok {
+		node.removed = true
+		delete(x.nodes, laptopId)
+		x.removed++
+	}
+}
+
+func (x *SimilarityIndex) insert(node *kdNode) {
+	link := &x.root
+	depth := 0
+	for *link != nil {
+		parent := *link
+		if node.vector[parent.axis] < parent.vector[parent.axis] {
+			link = &parent.left
+		} else {
+			link = &parent.right
+		}
+		depth++
+	}
+	node.axis = depth % featureCount
+	*link = node
+	x.height = max(x.height, depth+1)
+}
+
+// Rebuilds the tree from the live laptops once tombstones outnumber them, or once
+// it's higher than maxHeight.
+func (x *SimilarityIndex) compact() {
+	if x.removed <= len(x.nodes) && x.height <= x.maxHeight() {
+		return
+	}
+
+	nodes := make([]*kdNode, 0, len(x.nodes))
+	x.ranged = false
+	for _, node := range x.nodes {
+		node.left, node.right = nil, nil
+		nodes = append(nodes, node)
+		x.expandRange(node.vector)
+	}
+	x.root = buildKdTree(nodes, 0)
+	x.removed = 0
+	x.height = kdHeight(x.root)
+	x.builtHeight = x.height
+}
+
+// Twice the height of a balanced tree, with some slack for small ones. Laptops with
+// equal features can't be balanced, then it's twice the height the tree was built with,
+// so they don't rebuild it on every insert.
+func (x *SimilarityIndex) maxHeight() int {
+	balanced := bits.Len(uint(len(x.nodes)))
+	return 2*max(balanced, x.builtHeight) + featureCount
+}
+
+func (x *SimilarityIndex) expandRange(vector featureVector) {
+	if !x.ranged {
+		x.min, x.max, x.ranged = vector, vector, true
+		return
+	}
+	for i, value := range vector {
+		x.min[i] = math.Min(x.min[i], value)
+		x.max[i] = math.Max(x.max[i], value)
+	}
+}
+
+// Balanced tree splitting on the median of each axis in turn.
+func buildKdTree(nodes []*kdNode, depth int) *kdNode {
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	axis := depth % featureCount
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].vector[axis] < nodes[j].vector[axis] })
+	median := len(nodes) / 2
+	// equal values go right, like on insert.
+	for median > 0 && nodes[median-1].vector[axis] == nodes[median].vector[axis] {
+		median--
+	}
+
+	root := nodes[median]
+	root.axis = axis
+	root.left = buildKdTree(nodes[:median], depth+1)
+	root.right = buildKdTree(nodes[median+1:], depth+1)
+	return root
+}
+
+func kdHeight(node *kdNode) int {
+	if node == nil {
+		return 0
+	}
+	return 1 + max(kdHeight(node.left), kdHeight(node.right))
+}
+
+type kdSearch struct {
+	query   featureVector
+	exclude string
+	match   func(laptopId string) bool
+	k       int
+	scale   featureVector
+	// max heap on the squared distance, holding the k nearest so far.
+	found neighborHeap
+}
+
+func (s *kdSearch) visit(node *kdNode) {
+	if node == nil {
+		return
+	}
+
+	if !node.removed && node.laptopId != s.exclude && (s.match == nil || s.match(node.laptopId)) {
+		distance := 0.0
+		for i := range node.vector {
+			diff := (node.vector[i] - s.query[i]) * s.scale[i]
+			distance += diff * diff
+		}
+		if len(s.found) < s.k {
+			heap.Push(&s.found, Neighbor{node.laptopId, distance})
+		} else if distance < s.found[0].Distance {
+			s.found[0] = Neighbor{node.laptopId, distance}
+			heap.Fix(&s.found, 0)
+		}
+	}
+
+	near, far := node.left, node.right
+	if s.query[node.axis] >= node.vector[node.axis] {
+		near, far = far, near
+	}
+	s.visit(near)
+
+	// the far side can only hold closer laptops if the splitting plane is closer.
+	diff := (s.query[node.axis] - node.vector[node.axis]) * s.scale[node.axis]
+	if len(s.found) < s.k || diff*diff < s.found[0].Distance {
+		s.visit(far)
+	}
+}
+
+type neighborHeap []Neighbor
+
+func (h neighborHeap) Len() int            { return len(h) }
+func (h neighborHeap) Less(i, j int) bool  { return h[i].Distance > h[j].Distance }
+func (h neighborHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *neighborHeap) Push(x interface{}) { *h = append(*h, x.(Neighbor)) }
+func (h *neighborHeap) Pop() interface{} {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+func featureWeights(weights map[string]float64) (featureVector, error) {
+	var vector featureVector
+	for i := range vector {
+		vector[i] = 1
+	}
+	for name, weight := range weights {
+		i := featureIndex(name)
+		if i < 0 {
+			return vector, fmt.Errorf("unknown feature %q, expected one of %v", name, SimilarityFeatures)
+		}
+		if weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+			return vector, fmt.Errorf("weight of %s must be a non negative number", name)
+		}
+		vector[i] = weight
+	}
+	return vector, nil
+}
+
+func featureIndex(name string) int {
+	for i, feature := range SimilarityFeatures {
+		if feature == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Features in SimilarityFeatures order. Price is Laptop.price, in BaseCurrency in the store.
+func laptopFeatures(laptop *pb.Laptop) featureVector {
+	storage := 0.0
+	for _, drive := range laptop.GetStorage() {
+		storage += gigabytes(drive.GetMemory())
+	}
+	gpuMemory := 0.0
+	for _, gpu := range laptop.GetGpu() {
+		gpuMemory += gigabytes(gpu.GetMemory())
+	}
+	weight := laptop.GetWeightKg()
+	if laptop.GetWeightLb() > 0 {
+		weight = laptop.GetWeightLb() * kgPerLb
+	}
+
+	return featureVector{
+		laptop.GetPrice(),
+		float64(laptop.GetCpu().GetCores()),
+		laptop.GetCpu().GetMaxGhz(),
+		gigabytes(laptop.GetMemory()),
+		storage,
+		float64(laptop.GetScreen().GetSizeInch()),
+		weight,
+		gpuMemory,
+	}
+}
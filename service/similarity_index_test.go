@@ -0,0 +1,139 @@
+package service_test
+
+import (
+	"context"
+	"go-grpc-pcbook/pb"
+	"go-grpc-pcbook/sample"
+	"go-grpc-pcbook/service"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSimilarityIndexMatchesFullScan(t *testing.T) {
+	index := service.NewSimilarityIndex()
+	laptops := map[string]*pb.Laptop{}
+	random := rand.New(rand.NewSource(1))
+
+	// inserts, updates and deletes, enough to rebuild the tree a few times.
+	for i := 0; i < 2000; i++ {
+		switch {
+		case len(laptops) > 0 && random.Intn(4) == 0:
+			for id := range laptops {
+				index.Remove(id)
+				delete(laptops, id)
+				break
+			}
+		case len(laptops) > 0 && random.Intn(3) == 0:
+			for _, laptop := range laptops {
+				laptop.Price = float64(random.Intn(3000))
+				index.Put(laptop)
+				break
+			}
+		default:
+			laptop := sample.NewLaptop()
+			laptop.Price = float64(random.Intn(3000))
+			laptops[laptop.Id] = laptop
+			index.Put(laptop)
+		}
+	}
+	require.Equal(t, len(laptops), index.Len())
+
+	// with price as the only feature, the nearest laptops have the closest prices.
+	weights := map[string]float64{}
+	for _, feature := range service.SimilarityFeatures {
+		weights[feature] = 0
+	}
+	weights["price"] = 1
+
+	for id, query := range laptops {
+		neighbors, err := index.Nearest(id, 10, weights)
+		require.NoError(t, err)
+
+		expected := []float64{}
+		for otherId, other := range laptops {
+			if otherId != id {
+				expected = append(expected, math.Abs(other.Price-query.Price))
+			}
+		}
+		sort.Float64s(expected)
+		if len(expected) > 10 {
+			expected = expected[:10]
+		}
+
+		found := []float64{}
+		for _, neighbor := range neighbors {
+			found = append(found, math.Abs(laptops[neighbor.LaptopId].Price-query.Price))
+		}
+		require.Equal(t, expected, found)
+	}
+}
+
+func TestSimilarityIndexStaysBalanced(t *testing.T) {
+	index := service.NewSimilarityIndex()
+	gigabytes := func(value int) *pb.Memory { return &pb.Memory{Value: uint64(value), Unit: pb.Memory_GIGABYTE} }
+
+	// every feature grows with the laptop, inserting them in order makes a chain.
+	for i := 1; i <= 4096; i++ {
+		laptop := sample.NewLaptop()
+		laptop.Price = float64(i)
+		laptop.Cpu.Cores = uint32(i)
+		laptop.Cpu.MaxGhz = float64(i)
+		laptop.Memory = gigabytes(i)
+		laptop.Storage = []*pb.Storage{{Memory: gigabytes(i)}}
+		laptop.Screen.SizeInch = float32(i)
+		laptop.Weight = &pb.Laptop_WeightKg{WeightKg: float64(i)}
+		laptop.Gpu = []*pb.GPU{{Memory: gigabytes(i)}}
+		index.Put(laptop)
+	}
+
+	// a balanced tree of 4096 laptops has 13 levels.
+	require.Equal(t, 4096, index.Len())
+	require.LessOrEqual(t, index.Height(), 2*13+8)
+}
+
+func TestServerRecommendSimilar(t *testing.T) {
+	index := service.NewSimilarityIndex()
+	laptopStore := service.NewIndexingLaptopStore(service.NewMemoryLaptopStore(), index)
+	server := service.NewLaptopServer(laptopStore, nil, nil)
+	server.Similarity = index
+
+	base := sample.NewLaptop()
+	twin := sample.NewLaptop()
+	twin.Price, twin.Cpu, twin.Memory, twin.Storage, twin.Screen, twin.Weight, twin.Gpu = base.Price, base.Cpu, base.Memory, base.Storage, base.Screen, base.Weight, base.Gpu
+	cheaper := sample.NewLaptop()
+	cheaper.Price = base.Price / 2
+	for _, laptop := range []*pb.Laptop{base, twin, cheaper} {
+		require.NoError(t, laptopStore.Save(laptop))
+	}
+
+	res, err := server.RecommendSimilar(context.Background(), &pb.RecommendSimilarRequest{LaptopId: base.Id, K: 1})
+	require.NoError(t, err)
+	require.Len(t, res.GetLaptops(), 1)
+	require.Equal(t, twin.Id, res.GetLaptops()[0].GetLaptop().GetId())
+	require.Zero(t, res.GetLaptops()[0].GetDistance())
+
+	// a deleted laptop isn't recommended anymore.
+	require.NoError(t, laptopStore.Delete(twin.Id))
+	res, err = server.RecommendSimilar(context.Background(), &pb.RecommendSimilarRequest{LaptopId: base.Id})
+	require.NoError(t, err)
+	require.Len(t, res.GetLaptops(), 1)
+	require.Equal(t, cheaper.Id, res.GetLaptops()[0].GetLaptop().GetId())
+
+	invalid := []*pb.RecommendSimilarRequest{
+		{LaptopId: base.Id, K: 100},
+		{LaptopId: base.Id, FeatureWeights: map[string]float64{"color": 1}},
+		{LaptopId: base.Id, FeatureWeights: map[string]float64{"price": -1}},
+	}
+	for _, req := range invalid {
+		_, err := server.RecommendSimilar(context.Background(), req)
+		require.Equal(t, codes.InvalidArgument, status.Code(err), req.String())
+	}
+	_, err = server.RecommendSimilar(context.Background(), &pb.RecommendSimilarRequest{LaptopId: twin.Id})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
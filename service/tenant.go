@@ -0,0 +1,297 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-grpc-pcbook/pb"
+	"regexp"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Request metadata naming the organization whose catalog the call works on.
+const TenantMetadataKey = "x-tenant-id"
+
+// Tenant ids are used as folder names, so they're kept to lowercase letters, digits and dashes.
+var tenantIdPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// Stores of a tenant's catalog.
+type TenantStores struct {
+	LaptopStore LaptopStore
+	ImageStore  ImageStore
+	RatingStore RatingStore
+}
+
+// Stores of every tenant, created the first time a tenant calls.
+//
+// Laptop ids are unique across tenants: an id saved by a tenant stays theirs, even
+// once the laptop is deleted, so state kept by laptop id, like price history or
+// inventory, never mixes tenants.
+type TenantPartitions struct {
+	mutex     sync.RWMutex
+	newStores func(tenantId string) (*TenantStores, error)
+	stores    map[string]*TenantStores
+	// laptop id -> tenant who saved it first.
+	owners map[string]string
+}
+
+func NewTenantPartitions(newStores func(tenantId string) (*TenantStores, error)) *TenantPartitions {
+	return &TenantPartitions{
+		newStores: newStores,
+		stores:    make(map[string]*TenantStores),
+		owners:    make(map[string]string),
+	}
+}
+
+// Stores of the tenant. Its laptop store refuses laptop ids of other tenants.
+func (p *TenantPartitions) Stores(tenantId string) (*TenantStores, error) {
+	p.mutex.RLock()
+	stores, ok := p.stores[tenantId]
+	p.mutex.RUnlock()
+	if ok {
+		return stores, nil
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if stores, ok := p.stores[tenantId]; ok {
+		return stores, nil
+	}
+	stores, err := p.newStores(tenantId)
+	if err != nil {
+		return nil, err
+	}
+	stores.LaptopStore = &claimingLaptopStore{store: stores.LaptopStore, tenantId: tenantId, partitions: p}
+	p.stores[tenantId] = stores
+	return stores, nil
+}
+
+// Tenant who saved the laptop, empty if no tenant did.
+func (p *TenantPartitions) Owner(laptopId string) string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.owners[laptopId]
+}
+
+// User scores of every tenant's rating store. User ids are qualified by their
+// tenant, see CallerIdentity, and laptop ids are unique, so scores never collide.
+func (p *TenantPartitions) UserScores() (map[string]map[string]float64, error) {
+	p.mutex.RLock()
+	ratingStores := make([]RatingStore, 0, len(p.stores))
+	for _, stores := range p.stores {
+		ratingStores = append(ratingStores, stores.RatingStore)
+	}
+	p.mutex.RUnlock()
+
+	userScores := make(map[string]map[string]float64)
+	for _, ratingStore := range ratingStores {
+		scores, err := ratingStore.UserScores()
+		if err != nil {
+			return nil, err
+		}
+		for userId, laptopScores := range scores {
+			userScores[userId] = laptopScores
+		}
+	}
+	return userScores, nil
+}
+
+// Takes the laptop id for the tenant, false if another tenant has it. Also
+// tells if the id is new to the tenant, so a failed save can give it back.
+func (p *TenantPartitions) claim(tenantId string, laptopId string) (ok bool, claimed bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	owner, ok := p.owners[laptopId]
+	if !ok {
+		p.owners[laptopId] = tenantId
+		return true, true
+	}
+	return owner == tenantId, false
+}
+
+func (p *TenantPartitions) unclaim(laptopId string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.owners, laptopId)
+}
+
+// LaptopStore of a tenant, saving only laptops whose id the tenant can claim.
+type claimingLaptopStore struct {
+	store      LaptopStore
+	tenantId   string
+	partitions *TenantPartitions
+}
+
+func (t *claimingLaptopStore) Save(laptop *pb.Laptop) error {
+	ok, claimed := t.partitions.claim(t.tenantId, laptop.GetId())
+	if !ok {
+		return ErrAlreadyExists
+	}
+	// a laptop already in the store keeps the claim of the save that put it there.
+	err := t.store.Save(laptop)
+	if err != nil && claimed && !errors.Is(err, ErrAlreadyExists) {
+		t.partitions.unclaim(laptop.GetId())
+	}
+	return err
+}
+
+func (t *claimingLaptopStore) Find(id string) (*pb.Laptop, error) {
+	return t.store.Find(id)
+}
+
+func (t *claimingLaptopStore) Update(laptop *pb.Laptop) error {
+	return t.store.Update(laptop)
+}
+
+func (t *claimingLaptopStore) Delete(id string) error {
+	return t.store.Delete(id)
+}
+
+func (t *claimingLaptopStore) Search(ctx context.Context, filter *pb.Filter, found func(laptop *pb.Laptop) error) error {
+	return t.store.Search(ctx, filter, found)
+}
+
+func (t *claimingLaptopStore) Count() int {
+	return t.store.Count()
+}
+
+type tenantKey struct{}
+
+type tenantScope struct {
+	tenantId   string
+	stores     *TenantStores
+	partitions *TenantPartitions
+}
+
+// Tenant of the call, empty if the server isn't multi-tenant.
+func TenantFromContext(ctx context.Context) string {
+	if scope, ok := ctx.Value(tenantKey{}).(*tenantScope); ok {
+		return scope.tenantId
+	}
+	return ""
+}
+
+// Laptop store of the call's tenant, or the given one if there's no tenant.
+func tenantLaptopStore(ctx context.Context, laptopStore LaptopStore) LaptopStore {
+	if scope, ok := ctx.Value(tenantKey{}).(*tenantScope); ok {
+		return scope.stores.LaptopStore
+	}
+	return laptopStore
+}
+
+// Image store of the call's tenant, or the given one if there's no tenant.
+func tenantImageStore(ctx context.Context, imageStore ImageStore) ImageStore {
+	if scope, ok := ctx.Value(tenantKey{}).(*tenantScope); ok {
+		return scope.stores.ImageStore
+	}
+	return imageStore
+}
+
+// Rating store of the call's tenant, or the given one if there's no tenant.
+func tenantRatingStore(ctx context.Context, ratingStore RatingStore) RatingStore {
+	if scope, ok := ctx.Value(tenantKey{}).(*tenantScope); ok {
+		return scope.stores.RatingStore
+	}
+	return ratingStore
+}
+
+// Tells if the call can see the laptop: it's its tenant's, or there's no tenant.
+func tenantOwns(ctx context.Context, laptopId string) bool {
+	if scope, ok := ctx.Value(tenantKey{}).(*tenantScope); ok {
+		return scope.partitions.Owner(laptopId) == scope.tenantId
+	}
+	return true
+}
+
+// Requires every call to name its tenant in the x-tenant-id metadata, and gives the
+// handlers the stores of that tenant. Clients with a certificate naming organizations
+// may only call as one of them, other clients only as an allowed tenant, so callers
+// can't create tenants at will.
+type TenantInterceptor struct {
+	partitions *TenantPartitions
+	allowed    map[string]bool
+}
+
+func NewTenantInterceptor(partitions *TenantPartitions, allowed []string) *TenantInterceptor {
+	t := &TenantInterceptor{partitions: partitions, allowed: make(map[string]bool)}
+	for _, tenantId := range allowed {
+		t.allowed[tenantId] = true
+	}
+	return t
+}
+
+func (t *TenantInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := t.scope(ctx)
+		if err != nil {
+			return nil, logError(loggerFromContext(ctx), err)
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (t *TenantInterceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := t.scope(stream.Context())
+		if err != nil {
+			return logError(loggerFromContext(ctx), err)
+		}
+		return handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+func (t *TenantInterceptor) scope(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(TenantMetadataKey)
+	if len(values) != 1 || values[0] == "" {
+		return ctx, status.Errorf(codes.InvalidArgument, "exactly one %s metadata is required", TenantMetadataKey)
+	}
+	tenantId := values[0]
+	if !tenantIdPattern.MatchString(tenantId) {
+		return ctx, status.Errorf(codes.InvalidArgument, "invalid tenant id %q, expected lowercase letters, digits and dashes", tenantId)
+	}
+	if organizations := callerOrganizations(ctx); len(organizations) > 0 {
+		if !containsId(organizations, tenantId) {
+			return ctx, status.Errorf(codes.PermissionDenied, "client certificate doesn't allow tenant %q", tenantId)
+		}
+	} else if !t.allowed[tenantId] {
+		return ctx, status.Errorf(codes.PermissionDenied, "tenant %q isn't allowed without a client certificate naming it", tenantId)
+	}
+
+	stores, err := t.partitions.Stores(tenantId)
+	if err != nil {
+		return ctx, status.Errorf(codes.Internal, "couldn't open tenant stores: %v", err)
+	}
+	logger := loggerFromContext(ctx).With("tenant_id", tenantId)
+	ctx = context.WithValue(ctx, loggerKey{}, logger)
+	return context.WithValue(ctx, tenantKey{}, &tenantScope{tenantId, stores, t.partitions}), nil
+}
+
+// Organizations of the verified client certificate, if the connection uses mutual TLS.
+// Calls the gateway forwards have the gateway's certificate, not their client's.
+func callerOrganizations(ctx context.Context) []string {
+	if _, ok := ctx.Value(forwardedKey{}).(string); ok {
+		return nil
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil
+	}
+	chains := tlsInfo.State.VerifiedChains
+	if len(chains) == 0 || len(chains[0]) == 0 {
+		return nil
+	}
+	return chains[0][0].Subject.Organization
+}
@@ -0,0 +1,173 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"go-grpc-pcbook/pb"
+	"go-grpc-pcbook/sample"
+	"go-grpc-pcbook/service"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// acme and globex are allowed tenants. The rate limits apply after the tenant is known.
+func startTestTenantServer(t *testing.T, imageFolder string, rateLimits service.RateLimiterConfig) pb.LaptopServiceClient {
+	tenants := service.NewTenantPartitions(func(tenantId string) (*service.TenantStores, error) {
+		folder := filepath.Join(imageFolder, tenantId)
+		if err := os.MkdirAll(folder, 0755); err != nil {
+			return nil, err
+		}
+		return &service.TenantStores{
+			LaptopStore: service.NewMemoryLaptopStore(),
+			ImageStore:  service.NewDiskImageStore(folder),
+			RatingStore: service.NewMemoryRatingStore(),
+		}, nil
+	})
+	tenantInterceptor := service.NewTenantInterceptor(tenants, []string{"acme", "globex"})
+	rateLimiter := service.NewRateLimiter(rateLimits)
+	laptopServer := service.NewLaptopServer(service.NewMemoryLaptopStore(), service.NewDiskImageStore(imageFolder), service.NewMemoryRatingStore())
+
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(tenantInterceptor.Unary(), rateLimiter.Unary()),
+		grpc.ChainStreamInterceptor(tenantInterceptor.Stream(), rateLimiter.Stream()),
+	)
+	pb.RegisterLaptopServiceServer(grpcServer, laptopServer)
+	listener, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	return pb.NewLaptopServiceClient(conn)
+}
+
+func tenantContext(tenantId string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), service.TenantMetadataKey, tenantId)
+}
+
+func searchTenant(t *testing.T, laptopClient pb.LaptopServiceClient, ctx context.Context) []string {
+	stream, err := laptopClient.SearchLaptop(ctx, &pb.SearchLaptopRequest{Filter: &pb.Filter{MaxPrice: 1e6}})
+	require.NoError(t, err)
+	ids := []string{}
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return ids
+		}
+		require.NoError(t, err)
+		ids = append(ids, res.GetLaptop().GetId())
+	}
+}
+
+func TestTenantIsolation(t *testing.T) {
+	imageFolder := t.TempDir()
+	laptopClient := startTestTenantServer(t, imageFolder, service.RateLimiterConfig{})
+	acme, globex := tenantContext("acme"), tenantContext("globex")
+
+	laptop := sample.NewLaptop()
+	_, err := laptopClient.CreateLaptop(context.Background(), &pb.CreateLaptopRequest{Laptop: laptop})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = laptopClient.CreateLaptop(tenantContext("../acme"), &pb.CreateLaptopRequest{Laptop: laptop})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	// clients without a certificate can't make up tenants.
+	_, err = laptopClient.CreateLaptop(tenantContext("initech"), &pb.CreateLaptopRequest{Laptop: laptop})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = laptopClient.CreateLaptop(acme, &pb.CreateLaptopRequest{Laptop: laptop})
+	require.NoError(t, err)
+	require.Equal(t, []string{laptop.Id}, searchTenant(t, laptopClient, acme))
+	require.Empty(t, searchTenant(t, laptopClient, globex))
+
+	// ids stay unique across tenants.
+	_, err = laptopClient.CreateLaptop(globex, &pb.CreateLaptopRequest{Laptop: laptop})
+	require.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	// other tenants can't change the laptop.
+	_, err = laptopClient.UpdateLaptop(globex, &pb.UpdateLaptopRequest{Laptop: laptop})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = laptopClient.DeleteLaptop(globex, &pb.DeleteLaptopRequest{Id: laptop.Id})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = laptopClient.CompareLaptops(globex, &pb.CompareLaptopsRequest{LaptopIds: []string{laptop.Id, laptop.Id}})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	rate, err := laptopClient.RateLaptop(globex)
+	require.NoError(t, err)
+	require.NoError(t, rate.Send(&pb.RateLaptopRequest{LaptopId: laptop.Id, Score: 1}))
+	_, err = rate.Recv()
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	rate, err = laptopClient.RateLaptop(acme)
+	require.NoError(t, err)
+	require.NoError(t, rate.Send(&pb.RateLaptopRequest{LaptopId: laptop.Id, Score: 8}))
+	rated, err := rate.Recv()
+	require.NoError(t, err)
+	require.EqualValues(t, 1, rated.GetRatedCount())
+
+	// images go to the folder of their tenant.
+	upload, err := laptopClient.UploadImage(globex)
+	require.NoError(t, err)
+	require.NoError(t, upload.Send(&pb.UploadImageRequest{Data: &pb.UploadImageRequest_Info{Info: &pb.ImageInfo{LaptopId: laptop.Id, ImageType: ".jpg"}}}))
+	_, err = upload.CloseAndRecv()
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	upload, err = laptopClient.UploadImage(acme)
+	require.NoError(t, err)
+	require.NoError(t, upload.Send(&pb.UploadImageRequest{Data: &pb.UploadImageRequest_Info{Info: &pb.ImageInfo{LaptopId: laptop.Id, ImageType: ".jpg"}}}))
+	require.NoError(t, upload.Send(&pb.UploadImageRequest{Data: &pb.UploadImageRequest_ChunkData{ChunkData: []byte("image")}}))
+	uploaded, err := upload.CloseAndRecv()
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(imageFolder, "acme", uploaded.GetId()+".jpg"))
+}
+
+func TestTenantRateLimits(t *testing.T) {
+	laptopClient := startTestTenantServer(t, t.TempDir(), service.RateLimiterConfig{
+		Methods: map[string]service.RateLimit{"CreateLaptop": {Rate: 0.001, Burst: 1}},
+	})
+
+	// switching tenants doesn't give a client more calls.
+	_, err := laptopClient.CreateLaptop(tenantContext("acme"), &pb.CreateLaptopRequest{Laptop: sample.NewLaptop()})
+	require.NoError(t, err)
+	_, err = laptopClient.CreateLaptop(tenantContext("globex"), &pb.CreateLaptopRequest{Laptop: sample.NewLaptop()})
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+// Refuses to save anything.
+type brokenLaptopStore struct {
+	service.LaptopStore
+}
+
+func (s brokenLaptopStore) Save(laptop *pb.Laptop) error {
+	return errors.New("disk full")
+}
+
+func TestTenantFailedSaveKeepsNoClaim(t *testing.T) {
+	tenants := service.NewTenantPartitions(func(tenantId string) (*service.TenantStores, error) {
+		stores := &service.TenantStores{LaptopStore: service.NewMemoryLaptopStore()}
+		if tenantId == "broken" {
+			stores.LaptopStore = brokenLaptopStore{stores.LaptopStore}
+		}
+		return stores, nil
+	})
+	broken, err := tenants.Stores("broken")
+	require.NoError(t, err)
+	acme, err := tenants.Stores("acme")
+	require.NoError(t, err)
+
+	laptop := sample.NewLaptop()
+	require.Error(t, broken.LaptopStore.Save(laptop))
+	require.Empty(t, tenants.Owner(laptop.Id))
+	require.NoError(t, acme.LaptopStore.Save(laptop))
+	require.Equal(t, "acme", tenants.Owner(laptop.Id))
+	require.ErrorIs(t, acme.LaptopStore.Save(laptop), service.ErrAlreadyExists)
+	require.Equal(t, "acme", tenants.Owner(laptop.Id))
+}
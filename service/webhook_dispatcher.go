@@ -0,0 +1,387 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-grpc-pcbook/pb"
+	"go-grpc-pcbook/serializer"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Webhook event types.
+const (
+	EventLaptopCreated = "laptop.created"
+	EventLaptopUpdated = "laptop.updated"
+	EventLaptopDeleted = "laptop.deleted"
+	EventImageUploaded = "image.uploaded"
+	EventRatingChanged = "rating.changed"
+)
+
+// Headers sent with every webhook call.
+const (
+	WebhookSignatureHeader = "X-Pcbook-Signature"
+	WebhookEventHeader     = "X-Pcbook-Event"
+	WebhookDeliveryHeader  = "X-Pcbook-Delivery"
+)
+
+var WebhookEventTypes = []string{EventLaptopCreated, EventLaptopUpdated, EventLaptopDeleted, EventImageUploaded, EventRatingChanged}
+
+// Receives catalog events to be sent to webhooks.
+type WebhookNotifier interface {
+	Notify(payload *pb.WebhookPayload) error
+}
+
+type WebhookDispatcherConfig struct {
+	// Attempts before a delivery goes to the dead letter list.
+	MaxAttempts int
+	// Wait after the first failure, doubled on each retry up to MaxBackoff.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// How often the outbox is checked for due deliveries.
+	PollInterval time.Duration
+	// Timeout of a single webhook call.
+	Timeout time.Duration
+	// Deliveries attempted at once for each webhook, so a slow webhook doesn't hold
+	// up the others. One if 0.
+	WorkersPerWebhook int
+	// Tenant of a laptop, so tenants' webhooks only get events of their laptops. Optional.
+	LaptopTenant func(laptopId string) string
+	// Lets webhooks call loopback, private and link-local addresses, which are
+	// refused by default so webhooks can't reach the server's own network.
+	AllowPrivateNetworks bool
+}
+
+var ErrPrivateDestination = errors.New("webhook destination is a private address.")
+
+// Signs and delivers webhook payloads from a durable outbox, retrying with exponential backoff.
+type WebhookDispatcher struct {
+	store  WebhookStore
+	outbox *FileOutbox
+	client *http.Client
+	config WebhookDispatcherConfig
+	wakeup chan struct{}
+
+	mutex sync.Mutex
+	// deliveries being attempted, and how many per webhook.
+	inFlight map[string]bool
+	busy     map[string]int
+	workers  sync.WaitGroup
+}
+
+func NewWebhookDispatcher(store WebhookStore, outbox *FileOutbox, config WebhookDispatcherConfig) *WebhookDispatcher {
+	config.WorkersPerWebhook = max(config.WorkersPerWebhook, 1)
+	return &WebhookDispatcher{
+		store:    store,
+		outbox:   outbox,
+		client:   newWebhookClient(config),
+		config:   config,
+		wakeup:   make(chan struct{}, 1),
+		inFlight: make(map[string]bool),
+		busy:     make(map[string]int),
+	}
+}
+
+// Puts a delivery in the outbox for every webhook listening to the payload event type.
+func (d *WebhookDispatcher) Notify(payload *pb.WebhookPayload) error {
+	webhooks, err := d.store.List()
+	if err != nil {
+		return fmt.Errorf("couldn't list webhooks: %w", err)
+	}
+
+	payload.Id = uuid.NewString()
+	if payload.Time == nil {
+		payload.Time = timestamppb.Now()
+	}
+	body, err := serializer.ProtobufToJson(payload)
+	if err != nil {
+		return fmt.Errorf("couldn't marshal webhook payload: %w", err)
+	}
+
+	queued := false
+	for _, webhook := range webhooks {
+		if !webhook.Accepts(payload.GetEventType()) || !d.visible(webhook, payload) {
+			continue
+		}
+		delivery := &WebhookDelivery{
+			Id:          uuid.NewString(),
+			WebhookId:   webhook.Id,
+			Url:         webhook.Url,
+			Secret:      webhook.Secret,
+			EventType:   payload.GetEventType(),
+			Body:        body,
+			NextAttempt: time.Now(),
+			Tenant:      webhook.Tenant,
+		}
+		if err := d.outbox.Put(delivery); err != nil {
+			return err
+		}
+		queued = true
+	}
+
+	if queued {
+		d.wake()
+	}
+	return nil
+}
+
+func (d *WebhookDispatcher) wake() {
+	select {
+	case d.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (d *WebhookDispatcher) visible(webhook *WebhookEndpoint, payload *pb.WebhookPayload) bool {
+	if webhook.Tenant == "" || d.config.LaptopTenant == nil {
+		return true
+	}
+	laptopId := payload.GetLaptop().GetId()
+	if image := payload.GetImage(); image != nil {
+		laptopId = image.GetLaptopId()
+	} else if rating := payload.GetRating(); rating != nil {
+		laptopId = rating.GetLaptopId()
+	}
+	return d.config.LaptopTenant(laptopId) == webhook.Tenant
+}
+
+// Turns laptop hub events into webhook notifications until ctx is done.
+func (d *WebhookDispatcher) WatchLaptops(ctx context.Context, hub *LaptopHub) {
+	resumeToken := ""
+	for ctx.Err() == nil {
+		subscription, err := hub.Subscribe(nil, resumeToken)
+		if err != nil {
+			// events after the token are gone, carry on from now.
+			slog.Warn("webhooks missed laptop events", "error", err)
+			resumeToken = ""
+			continue
+		}
+
+		for {
+			event, err := subscription.Next(ctx)
+			if err != nil {
+				if !errors.Is(err, ErrSubscriberOverflow) && ctx.Err() == nil {
+					slog.Error("webhooks stopped watching laptops", "error", err)
+				}
+				break
+			}
+			resumeToken = event.GetResumeToken()
+
+			payload := &pb.WebhookPayload{
+				EventType: laptopEventType(event.GetType()),
+				Time:      event.GetTime(),
+				Data:      &pb.WebhookPayload_Laptop{Laptop: event.GetLaptop()},
+			}
+			if err := d.Notify(payload); err != nil {
+				slog.Error("couldn't queue laptop webhook", "laptop_id", event.GetLaptop().GetId(), "error", err)
+			}
+		}
+		subscription.Close()
+	}
+}
+
+// Delivers due webhooks until ctx is done, and the attempts under way are over.
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.deliverDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			d.workers.Wait()
+			return
+		case <-ticker.C:
+		case <-d.wakeup:
+		}
+	}
+}
+
+func (d *WebhookDispatcher) DeadLetters() ([]*WebhookDelivery, error) {
+	return d.outbox.DeadLetters()
+}
+
+func (d *WebhookDispatcher) deliverDue(ctx context.Context) {
+	deliveries, err := d.outbox.Due(time.Now())
+	if err != nil {
+		slog.Error("couldn't read webhook outbox", "error", err)
+		return
+	}
+
+	for _, delivery := range deliveries {
+		if ctx.Err() != nil {
+			return
+		}
+		if !d.start(delivery) {
+			continue
+		}
+		d.workers.Add(1)
+		go func(delivery *WebhookDelivery) {
+			defer d.workers.Done()
+			d.attempt(ctx, delivery)
+			d.finish(delivery)
+		}(delivery)
+	}
+}
+
+// Takes a worker of the delivery's webhook, false if there's none free or the
+// delivery is already being attempted.
+func (d *WebhookDispatcher) start(delivery *WebhookDelivery) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.inFlight[delivery.Id] || d.busy[delivery.WebhookId] >= d.config.WorkersPerWebhook {
+		return false
+	}
+	d.inFlight[delivery.Id] = true
+	d.busy[delivery.WebhookId]++
+	return true
+}
+
+func (d *WebhookDispatcher) finish(delivery *WebhookDelivery) {
+	d.mutex.Lock()
+	delete(d.inFlight, delivery.Id)
+	if d.busy[delivery.WebhookId]--; d.busy[delivery.WebhookId] == 0 {
+		delete(d.busy, delivery.WebhookId)
+	}
+	d.mutex.Unlock()
+	// the webhook's next delivery may be waiting for the worker.
+	d.wake()
+}
+
+func (d *WebhookDispatcher) attempt(ctx context.Context, delivery *WebhookDelivery) {
+	logger := slog.With("delivery_id", delivery.Id, "webhook_id", delivery.WebhookId, "event_type", delivery.EventType)
+
+	err := d.post(ctx, delivery)
+	if err != nil && ctx.Err() != nil {
+		// stopped by a shutdown, not the webhook's fault. The delivery stays due.
+		logger.Debug("webhook delivery interrupted", "error", err)
+		return
+	}
+	if err == nil {
+		logger.Debug("delivered webhook")
+		if err := d.outbox.Remove(delivery.Id); err != nil {
+			logger.Error("couldn't remove delivered webhook", "error", err)
+		}
+		return
+	}
+
+	delivery.Attempts++
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= d.config.MaxAttempts {
+		logger.Warn("webhook moved to dead letters", "attempts", delivery.Attempts, "error", err)
+		if err := d.outbox.Kill(delivery); err != nil {
+			logger.Error("couldn't move webhook to dead letters", "error", err)
+		}
+		return
+	}
+
+	delivery.NextAttempt = time.Now().Add(d.backoff(delivery.Attempts))
+	logger.Info("webhook failed, will retry", "attempts", delivery.Attempts, "next_attempt", delivery.NextAttempt, "error", err)
+	if err := d.outbox.Put(delivery); err != nil {
+		logger.Error("couldn't reschedule webhook", "error", err)
+	}
+}
+
+func (d *WebhookDispatcher) post(ctx context.Context, delivery *WebhookDelivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(delivery.Body))
+	if err != nil {
+		return fmt.Errorf("couldn't create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, delivery.EventType)
+	req.Header.Set(WebhookDeliveryHeader, delivery.Id)
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(delivery.Secret, delivery.Body))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", res.Status)
+	}
+	return nil
+}
+
+// Client checking every address it dials, after name resolution and on redirects too.
+func newWebhookClient(config WebhookDispatcherConfig) *http.Client {
+	dialer := &net.Dialer{Timeout: config.Timeout}
+	if !config.AllowPrivateNetworks {
+		dialer.Control = func(network string, address string, conn syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isPrivateAddress(ip) {
+				return fmt.Errorf("%w: %s", ErrPrivateDestination, host)
+			}
+			return nil
+		}
+	}
+	// no proxy from the environment, it would dial on the webhook's behalf.
+	transport := &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: config.Timeout}
+	return &http.Client{Timeout: config.Timeout, Transport: transport}
+}
+
+// Shared address space of carrier grade NAT, where some clouds serve instance metadata,
+// and "this network".
+var otherPrivateNetworks = []*net.IPNet{
+	{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)},
+	{IP: net.IPv4(0, 0, 0, 0), Mask: net.CIDRMask(8, 32)},
+}
+
+func isPrivateAddress(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, network := range otherPrivateNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Waits BaseBackoff after the first failure and doubles it on each retry.
+func (d *WebhookDispatcher) backoff(attempts int) time.Duration {
+	wait := d.config.BaseBackoff
+	for i := 1; i < attempts && wait < d.config.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > d.config.MaxBackoff {
+		wait = d.config.MaxBackoff
+	}
+	return wait
+}
+
+// Value of the signature header: hex HMAC-SHA256 of the body keyed by the webhook secret.
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func laptopEventType(eventType pb.LaptopEvent_Type) string {
+	switch eventType {
+	case pb.LaptopEvent_UPDATED:
+		return EventLaptopUpdated
+	case pb.LaptopEvent_DELETED:
+		return EventLaptopDeleted
+	default:
+		return EventLaptopCreated
+	}
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Pending webhook call. It carries the endpoint url and secret so it can be
+// delivered after a restart even if the endpoint was removed meanwhile.
+type WebhookDelivery struct {
+	Id          string    `json:"id"`
+	WebhookId   string    `json:"webhook_id"`
+	Url         string    `json:"url"`
+	Secret      string    `json:"secret"`
+	EventType   string    `json:"event_type"`
+	Body        []byte    `json:"body"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error,omitempty"`
+	Tenant      string    `json:"tenant,omitempty"`
+}
+
+// Durable outbox keeping one JSON file per delivery, so nothing is lost
+// if the server stops before a webhook was delivered. Pending deliveries are
+// also kept in memory, the files are only read when the outbox is opened.
+type FileOutbox struct {
+	mutex      sync.Mutex
+	pendingDir string
+	deadDir    string
+	pending    map[string]*WebhookDelivery
+}
+
+func NewFileOutbox(folder string) (*FileOutbox, error) {
+	outbox := &FileOutbox{
+		pendingDir: filepath.Join(folder, "pending"),
+		deadDir:    filepath.Join(folder, "dead"),
+	}
+	for _, dir := range []string{outbox.pendingDir, outbox.deadDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf("couldn't create outbox folder: %w", err)
+		}
+	}
+
+	pending, err := readDeliveries(outbox.pendingDir)
+	if err != nil {
+		return nil, err
+	}
+	outbox.pending = make(map[string]*WebhookDelivery, len(pending))
+	for _, delivery := range pending {
+		outbox.pending[delivery.Id] = delivery
+	}
+	return outbox, nil
+}
+
+// Adds or replaces a pending delivery.
+func (o *FileOutbox) Put(delivery *WebhookDelivery) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if err := writeDelivery(o.pendingDir, delivery); err != nil {
+		return err
+	}
+	kept := *delivery
+	o.pending[delivery.Id] = &kept
+	return nil
+}
+
+// Removes a delivered webhook call.
+func (o *FileOutbox) Remove(id string) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	err := os.Remove(filepath.Join(o.pendingDir, id+".json"))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("couldn't remove delivery: %w", err)
+	}
+	delete(o.pending, id)
+	return nil
+}
+
+// Moves a delivery that won't be retried anymore to the dead letter list.
+func (o *FileOutbox) Kill(delivery *WebhookDelivery) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if err := writeDelivery(o.deadDir, delivery); err != nil {
+		return err
+	}
+	err := os.Remove(filepath.Join(o.pendingDir, delivery.Id+".json"))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("couldn't remove delivery: %w", err)
+	}
+	delete(o.pending, delivery.Id)
+	return nil
+}
+
+// Returns pending deliveries whose next attempt is due, oldest first.
+func (o *FileOutbox) Due(now time.Time) ([]*WebhookDelivery, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	due := []*WebhookDelivery{}
+	for _, delivery := range o.pending {
+		if !delivery.NextAttempt.After(now) {
+			kept := *delivery
+			due = append(due, &kept)
+		}
+	}
+	sortDeliveries(due)
+	return due, nil
+}
+
+func (o *FileOutbox) Pending() ([]*WebhookDelivery, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	pending := make([]*WebhookDelivery, 0, len(o.pending))
+	for _, delivery := range o.pending {
+		kept := *delivery
+		pending = append(pending, &kept)
+	}
+	sortDeliveries(pending)
+	return pending, nil
+}
+
+func (o *FileOutbox) DeadLetters() ([]*WebhookDelivery, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return readDeliveries(o.deadDir)
+}
+
+// Writes to a temp file first so a crash never leaves half a delivery behind.
+func writeDelivery(dir string, delivery *WebhookDelivery) error {
+	data, err := json.Marshal(delivery)
+	if err != nil {
+		return fmt.Errorf("couldn't marshal delivery: %w", err)
+	}
+
+	tmp := filepath.Join(dir, delivery.Id+".tmp")
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("couldn't write delivery: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, delivery.Id+".json")); err != nil {
+		return fmt.Errorf("couldn't write delivery: %w", err)
+	}
+	return nil
+}
+
+func readDeliveries(dir string) ([]*WebhookDelivery, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read outbox: %w", err)
+	}
+
+	deliveries := []*WebhookDelivery{}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read delivery: %w", err)
+		}
+		delivery := &WebhookDelivery{}
+		if err := json.Unmarshal(data, delivery); err != nil {
+			return nil, fmt.Errorf("couldn't parse delivery %s: %w", entry.Name(), err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	sortDeliveries(deliveries)
+	return deliveries, nil
+}
+
+// Oldest next attempt first.
+func sortDeliveries(deliveries []*WebhookDelivery) {
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].NextAttempt.Before(deliveries[j].NextAttempt)
+	})
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-grpc-pcbook/pb"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Admin RPCs to manage webhook endpoints.
+type WebhookServer struct {
+	WebhookStore WebhookStore
+	Dispatcher   *WebhookDispatcher
+}
+
+func NewWebhookServer(webhookStore WebhookStore, dispatcher *WebhookDispatcher) *WebhookServer {
+	return &WebhookServer{webhookStore, dispatcher}
+}
+
+func (s *WebhookServer) RegisterWebhook(ctx context.Context, req *pb.RegisterWebhookRequest) (*pb.RegisterWebhookResponse, error) {
+	logger := loggerFromContext(ctx)
+	logger.Info("received register-webhook request", "url", req.GetUrl())
+
+	endpoint, err := url.Parse(req.GetUrl())
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, logError(logger, status.Errorf(codes.InvalidArgument, "webhook url must be an absolute http(s) url: %q", req.GetUrl()))
+	}
+	if req.GetSecret() == "" {
+		return nil, logError(logger, status.Error(codes.InvalidArgument, "webhook secret is required"))
+	}
+	for _, eventType := range req.GetEventTypes() {
+		if !isWebhookEventType(eventType) {
+			return nil, logError(logger, status.Errorf(codes.InvalidArgument, "unknown event type %q, expected one of %v", eventType, WebhookEventTypes))
+		}
+	}
+
+	webhook := &WebhookEndpoint{
+		Id:         uuid.NewString(),
+		Url:        req.GetUrl(),
+		EventTypes: req.GetEventTypes(),
+		Secret:     req.GetSecret(),
+		CreatedAt:  time.Now(),
+		Tenant:     TenantFromContext(ctx),
+	}
+	if err := s.WebhookStore.Save(webhook); err != nil {
+		return nil, logError(logger, status.Errorf(codes.Internal, "couldn't save webhook: %v", err))
+	}
+
+	logger.Info("registered webhook", "webhook_id", webhook.Id)
+	return &pb.RegisterWebhookResponse{Webhook: toWebhookMessage(webhook)}, nil
+}
+
+func (s *WebhookServer) ListWebhooks(ctx context.Context, req *pb.ListWebhooksRequest) (*pb.ListWebhooksResponse, error) {
+	webhooks, err := s.WebhookStore.List()
+	if err != nil {
+		return nil, logError(loggerFromContext(ctx), status.Errorf(codes.Internal, "couldn't list webhooks: %v", err))
+	}
+
+	res := &pb.ListWebhooksResponse{}
+	for _, webhook := range webhooks {
+		if webhook.Tenant == TenantFromContext(ctx) {
+			res.Webhooks = append(res.Webhooks, toWebhookMessage(webhook))
+		}
+	}
+	return res, nil
+}
+
+func (s *WebhookServer) DeleteWebhook(ctx context.Context, req *pb.DeleteWebhookRequest) (*pb.DeleteWebhookResponse, error) {
+	logger := loggerFromContext(ctx).With("webhook_id", req.GetId())
+
+	err := s.checkTenant(ctx, req.GetId())
+	if err == nil {
+		err = s.WebhookStore.Delete(req.GetId())
+	}
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, logError(logger, status.Errorf(codes.NotFound, "webhook not found: %s", req.GetId()))
+		}
+		return nil, logError(logger, status.Errorf(codes.Internal, "couldn't delete webhook: %v", err))
+	}
+
+	logger.Info("deleted webhook")
+	return &pb.DeleteWebhookResponse{}, nil
+}
+
+func (s *WebhookServer) ListDeadLetters(ctx context.Context, req *pb.ListDeadLettersRequest) (*pb.ListDeadLettersResponse, error) {
+	deliveries, err := s.Dispatcher.DeadLetters()
+	if err != nil {
+		return nil, logError(loggerFromContext(ctx), status.Errorf(codes.Internal, "couldn't list dead letters: %v", err))
+	}
+
+	res := &pb.ListDeadLettersResponse{}
+	for _, delivery := range deliveries {
+		if delivery.Tenant != TenantFromContext(ctx) {
+			continue
+		}
+		res.DeadLetters = append(res.DeadLetters, &pb.DeadLetter{
+			Id:        delivery.Id,
+			WebhookId: delivery.WebhookId,
+			Url:       delivery.Url,
+			EventType: delivery.EventType,
+			Attempts:  uint32(delivery.Attempts),
+			LastError: delivery.LastError,
+			Payload:   string(delivery.Body),
+		})
+	}
+	return res, nil
+}
+
+// ErrNotFound if the webhook is another tenant's.
+func (s *WebhookServer) checkTenant(ctx context.Context, webhookId string) error {
+	webhooks, err := s.WebhookStore.List()
+	if err != nil {
+		return err
+	}
+	for _, webhook := range webhooks {
+		if webhook.Id == webhookId && webhook.Tenant != TenantFromContext(ctx) {
+			return ErrNotFound
+		}
+	}
+	return nil
+}
+
+// The secret is never sent back.
+func toWebhookMessage(webhook *WebhookEndpoint) *pb.Webhook {
+	return &pb.Webhook{
+		Id:         webhook.Id,
+		Url:        webhook.Url,
+		EventTypes: webhook.EventTypes,
+		CreatedAt:  timestamppb.New(webhook.CreatedAt),
+	}
+}
+
+func isWebhookEventType(eventType string) bool {
+	for _, known := range WebhookEventTypes {
+		if known == eventType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type WebhookStore interface {
+	// Saves a webhook endpoint
+	Save(webhook *WebhookEndpoint) error
+	// Removes a webhook endpoint
+	Delete(id string) error
+	// Lists webhook endpoints, oldest first
+	List() ([]*WebhookEndpoint, error)
+}
+
+// Registered webhook receiver.
+type WebhookEndpoint struct {
+	Id         string
+	Url        string
+	EventTypes []string // all events if empty
+	Secret     string
+	CreatedAt  time.Time
+	// Tenant who registered the endpoint, which only gets events of their laptops. Empty if the server isn't multi-tenant.
+	Tenant string
+}
+
+// Tells if the endpoint subscribed to the event type.
+func (w *WebhookEndpoint) Accepts(eventType string) bool {
+	if len(w.EventTypes) == 0 {
+		return true
+	}
+	for _, accepted := range w.EventTypes {
+		if accepted == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+type MemoryWebhookStore struct {
+	mutex    sync.RWMutex
+	webhooks map[string]*WebhookEndpoint
+}
+
+func NewMemoryWebhookStore() *MemoryWebhookStore {
+	return &MemoryWebhookStore{webhooks: make(map[string]*WebhookEndpoint)}
+}
+
+func (m *MemoryWebhookStore) Save(webhook *WebhookEndpoint) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.webhooks[webhook.Id] != nil {
+		return ErrAlreadyExists
+	}
+
+	other := *webhook
+	other.EventTypes = append([]string(nil), webhook.EventTypes...)
+	m.webhooks[webhook.Id] = &other
+	return nil
+}
+
+func (m *MemoryWebhookStore) Delete(id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.webhooks[id] == nil {
+		return ErrNotFound
+	}
+	delete(m.webhooks, id)
+	return nil
+}
+
+func (m *MemoryWebhookStore) List() ([]*WebhookEndpoint, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	webhooks := make([]*WebhookEndpoint, 0, len(m.webhooks))
+	for _, webhook := range m.webhooks {
+		other := *webhook
+		webhooks = append(webhooks, &other)
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+	})
+	return webhooks, nil
+}
@@ -0,0 +1,290 @@
+package service_test
+
+import (
+	"context"
+	"go-grpc-pcbook/pb"
+	"go-grpc-pcbook/sample"
+	"go-grpc-pcbook/service"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Local webhook endpoint recording the payloads it accepted.
+type testReceiver struct {
+	mutex    sync.Mutex
+	secret   string
+	status   int
+	calls    int
+	payloads []*pb.WebhookPayload
+}
+
+func (r *testReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.calls++
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil || req.Header.Get(service.WebhookSignatureHeader) != service.SignWebhookPayload(r.secret, body) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.status != http.StatusOK {
+		w.WriteHeader(r.status)
+		return
+	}
+
+	payload := &pb.WebhookPayload{}
+	if err := protojson.Unmarshal(body, payload); err != nil || payload.GetEventType() != req.Header.Get(service.WebhookEventHeader) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.payloads = append(r.payloads, payload)
+}
+
+func (r *testReceiver) received() []*pb.WebhookPayload {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]*pb.WebhookPayload(nil), r.payloads...)
+}
+
+func newTestDispatcher(t *testing.T, folder string) (*service.WebhookDispatcher, *service.WebhookServer) {
+	outbox, err := service.NewFileOutbox(folder)
+	require.NoError(t, err)
+
+	webhookStore := service.NewMemoryWebhookStore()
+	dispatcher := service.NewWebhookDispatcher(webhookStore, outbox, service.WebhookDispatcherConfig{
+		MaxAttempts:  3,
+		BaseBackoff:  time.Millisecond,
+		MaxBackoff:   5 * time.Millisecond,
+		PollInterval: time.Millisecond,
+		Timeout:      time.Second,
+
+		AllowPrivateNetworks: true,
+	})
+	return dispatcher, service.NewWebhookServer(webhookStore, dispatcher)
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	receiver := &testReceiver{secret: "s3cret", status: http.StatusOK}
+	endpoint := httptest.NewServer(receiver)
+	defer endpoint.Close()
+
+	dispatcher, webhookServer := newTestDispatcher(t, t.TempDir())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dispatcher.Run(ctx)
+
+	laptopHub := service.NewLaptopHub(10, 10)
+	laptopStore := service.NewPublishingLaptopStore(service.NewMemoryLaptopStore(), laptopHub)
+	go dispatcher.WatchLaptops(ctx, laptopHub)
+	require.Eventually(t, func() bool { return laptopHub.Subscribers() == 1 }, time.Second, time.Millisecond)
+
+	_, err := webhookServer.RegisterWebhook(context.Background(), &pb.RegisterWebhookRequest{
+		Url:        endpoint.URL,
+		EventTypes: []string{service.EventLaptopCreated, service.EventRatingChanged},
+		Secret:     receiver.secret,
+	})
+	require.NoError(t, err)
+
+	laptop := sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(laptop))
+	require.NoError(t, dispatcher.Notify(&pb.WebhookPayload{
+		EventType: service.EventImageUploaded,
+		Data:      &pb.WebhookPayload_Image{Image: &pb.UploadedImage{LaptopId: laptop.Id}},
+	}))
+	require.NoError(t, dispatcher.Notify(&pb.WebhookPayload{
+		EventType: service.EventRatingChanged,
+		Data:      &pb.WebhookPayload_Rating{Rating: &pb.LaptopRating{LaptopId: laptop.Id, RatedCount: 1, AverageScore: 9}},
+	}))
+
+	require.Eventually(t, func() bool { return len(receiver.received()) == 2 }, time.Second, time.Millisecond)
+	types := map[string]*pb.WebhookPayload{}
+	for _, payload := range receiver.received() {
+		require.NotEmpty(t, payload.GetId())
+		types[payload.GetEventType()] = payload
+	}
+	require.Equal(t, laptop.Id, types[service.EventLaptopCreated].GetLaptop().GetId())
+	require.Equal(t, 9.0, types[service.EventRatingChanged].GetRating().GetAverageScore())
+}
+
+func TestWebhookDeadLetters(t *testing.T) {
+	receiver := &testReceiver{secret: "s3cret", status: http.StatusInternalServerError}
+	endpoint := httptest.NewServer(receiver)
+	defer endpoint.Close()
+
+	dispatcher, webhookServer := newTestDispatcher(t, t.TempDir())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dispatcher.Run(ctx)
+
+	_, err := webhookServer.RegisterWebhook(context.Background(), &pb.RegisterWebhookRequest{Url: endpoint.URL, Secret: receiver.secret})
+	require.NoError(t, err)
+	require.NoError(t, dispatcher.Notify(&pb.WebhookPayload{
+		EventType: service.EventRatingChanged,
+		Data:      &pb.WebhookPayload_Rating{Rating: &pb.LaptopRating{LaptopId: "laptop"}},
+	}))
+
+	var res *pb.ListDeadLettersResponse
+	require.Eventually(t, func() bool {
+		res, err = webhookServer.ListDeadLetters(context.Background(), &pb.ListDeadLettersRequest{})
+		require.NoError(t, err)
+		return len(res.GetDeadLetters()) == 1
+	}, time.Second, time.Millisecond)
+
+	deadLetter := res.GetDeadLetters()[0]
+	require.EqualValues(t, 3, deadLetter.GetAttempts())
+	require.Contains(t, deadLetter.GetLastError(), "500")
+	require.Contains(t, deadLetter.GetPayload(), "rating.changed")
+}
+
+func TestWebhookPrivateDestination(t *testing.T) {
+	receiver := &testReceiver{secret: "s3cret", status: http.StatusOK}
+	endpoint := httptest.NewServer(receiver)
+	defer endpoint.Close()
+
+	outbox, err := service.NewFileOutbox(t.TempDir())
+	require.NoError(t, err)
+	webhookStore := service.NewMemoryWebhookStore()
+	dispatcher := service.NewWebhookDispatcher(webhookStore, outbox, service.WebhookDispatcherConfig{
+		MaxAttempts:  1,
+		PollInterval: time.Millisecond,
+		Timeout:      time.Second,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dispatcher.Run(ctx)
+
+	_, err = service.NewWebhookServer(webhookStore, dispatcher).RegisterWebhook(context.Background(), &pb.RegisterWebhookRequest{Url: endpoint.URL, Secret: receiver.secret})
+	require.NoError(t, err)
+	require.NoError(t, dispatcher.Notify(&pb.WebhookPayload{EventType: service.EventLaptopDeleted}))
+
+	require.Eventually(t, func() bool {
+		deadLetters, err := dispatcher.DeadLetters()
+		require.NoError(t, err)
+		return len(deadLetters) == 1
+	}, time.Second, time.Millisecond)
+	deadLetters, err := dispatcher.DeadLetters()
+	require.NoError(t, err)
+	require.Contains(t, deadLetters[0].LastError, "private address")
+	require.Zero(t, receiver.calls)
+}
+
+func TestWebhookSlowEndpoint(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+	receiver := &testReceiver{secret: "s3cret", status: http.StatusOK}
+	fast := httptest.NewServer(receiver)
+	defer fast.Close()
+
+	dispatcher, webhookServer := newTestDispatcher(t, t.TempDir())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dispatcher.Run(ctx)
+
+	for _, url := range []string{slow.URL, fast.URL} {
+		_, err := webhookServer.RegisterWebhook(context.Background(), &pb.RegisterWebhookRequest{Url: url, Secret: receiver.secret})
+		require.NoError(t, err)
+	}
+	for i := 0; i < 3; i++ {
+		require.NoError(t, dispatcher.Notify(&pb.WebhookPayload{EventType: service.EventLaptopDeleted}))
+	}
+
+	// the slow webhook holds its own deliveries only.
+	require.Eventually(t, func() bool { return len(receiver.received()) == 3 }, time.Second, time.Millisecond)
+}
+
+func TestWebhookShutdownIsNotAnAttempt(t *testing.T) {
+	called := make(chan struct{})
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the connection is only watched for a cancellation once the body is read.
+		io.ReadAll(r.Body)
+		close(called)
+		<-r.Context().Done()
+	}))
+	defer endpoint.Close()
+
+	folder := t.TempDir()
+	dispatcher, webhookServer := newTestDispatcher(t, folder)
+	_, err := webhookServer.RegisterWebhook(context.Background(), &pb.RegisterWebhookRequest{Url: endpoint.URL, Secret: "s3cret"})
+	require.NoError(t, err)
+	require.NoError(t, dispatcher.Notify(&pb.WebhookPayload{EventType: service.EventLaptopDeleted}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		dispatcher.Run(ctx)
+		close(done)
+	}()
+	<-called
+	cancel()
+	<-done
+
+	outbox, err := service.NewFileOutbox(folder)
+	require.NoError(t, err)
+	pending, err := outbox.Pending()
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.Zero(t, pending[0].Attempts)
+}
+
+func TestWebhookOutboxSurvivesRestart(t *testing.T) {
+	receiver := &testReceiver{secret: "s3cret", status: http.StatusOK}
+	endpoint := httptest.NewServer(receiver)
+	defer endpoint.Close()
+
+	folder := t.TempDir()
+	dispatcher, webhookServer := newTestDispatcher(t, folder)
+	_, err := webhookServer.RegisterWebhook(context.Background(), &pb.RegisterWebhookRequest{Url: endpoint.URL, Secret: receiver.secret})
+	require.NoError(t, err)
+
+	// queued while the dispatcher isn't running, then delivered by a fresh one.
+	require.NoError(t, dispatcher.Notify(&pb.WebhookPayload{EventType: service.EventLaptopDeleted}))
+
+	restarted, _ := newTestDispatcher(t, folder)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go restarted.Run(ctx)
+
+	require.Eventually(t, func() bool { return len(receiver.received()) == 1 }, time.Second, time.Millisecond)
+}
+
+func TestWebhookServerValidation(t *testing.T) {
+	_, webhookServer := newTestDispatcher(t, t.TempDir())
+
+	invalid := []*pb.RegisterWebhookRequest{
+		{Url: "not a url", Secret: "s"},
+		{Url: "ftp://example.com", Secret: "s"},
+		{Url: "https://example.com"},
+		{Url: "https://example.com", Secret: "s", EventTypes: []string{"laptop.exploded"}},
+	}
+	for _, req := range invalid {
+		_, err := webhookServer.RegisterWebhook(context.Background(), req)
+		require.Equal(t, codes.InvalidArgument, status.Code(err), req.String())
+	}
+
+	res, err := webhookServer.RegisterWebhook(context.Background(), &pb.RegisterWebhookRequest{Url: "https://example.com", Secret: "s"})
+	require.NoError(t, err)
+
+	list, err := webhookServer.ListWebhooks(context.Background(), &pb.ListWebhooksRequest{})
+	require.NoError(t, err)
+	require.Len(t, list.GetWebhooks(), 1)
+
+	_, err = webhookServer.DeleteWebhook(context.Background(), &pb.DeleteWebhookRequest{Id: res.GetWebhook().GetId()})
+	require.NoError(t, err)
+	_, err = webhookServer.DeleteWebhook(context.Background(), &pb.DeleteWebhookRequest{Id: res.GetWebhook().GetId()})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
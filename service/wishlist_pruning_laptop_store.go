@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"go-grpc-pcbook/pb"
+)
+
+// LaptopStore taking deleted laptops out of the wishlists of a WishlistStore.
+type WishlistPruningLaptopStore struct {
+	store     LaptopStore
+	wishlists WishlistStore
+}
+
+func NewWishlistPruningLaptopStore(store LaptopStore, wishlists WishlistStore) *WishlistPruningLaptopStore {
+	return &WishlistPruningLaptopStore{store: store, wishlists: wishlists}
+}
+
+func (w *WishlistPruningLaptopStore) Save(laptop *pb.Laptop) error {
+	return w.store.Save(laptop)
+}
+
+func (w *WishlistPruningLaptopStore) Find(id string) (*pb.Laptop, error) {
+	return w.store.Find(id)
+}
+
+func (w *WishlistPruningLaptopStore) Update(laptop *pb.Laptop) error {
+	return w.store.Update(laptop)
+}
+
+func (w *WishlistPruningLaptopStore) Delete(id string) error {
+	if err := w.store.Delete(id); err != nil {
+		return err
+	}
+	return w.wishlists.RemoveLaptop(id)
+}
+
+func (w *WishlistPruningLaptopStore) Search(ctx context.Context, filter *pb.Filter, found func(laptop *pb.Laptop) error) error {
+	return w.store.Search(ctx, filter, found)
+}
+
+func (w *WishlistPruningLaptopStore) Count() int {
+	return w.store.Count()
+}
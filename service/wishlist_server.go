@@ -0,0 +1,330 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-grpc-pcbook/pb"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Wishlist and saved search RPCs. Wishlists and saved searches belong to the caller,
+// which needs a client certificate.
+// The laptop store must prune the wishlists, see WishlistPruningLaptopStore.
+type WishlistServer struct {
+	LaptopStore   LaptopStore
+	WishlistStore WishlistStore
+	// Rates of saved searches in other currencies. Only BaseCurrency is supported if nil.
+	ExchangeRates ExchangeRateProvider
+}
+
+func NewWishlistServer(laptopStore LaptopStore, wishlistStore WishlistStore) *WishlistServer {
+	return &WishlistServer{LaptopStore: laptopStore, WishlistStore: wishlistStore}
+}
+
+func (s *WishlistServer) CreateWishlist(ctx context.Context, req *pb.CreateWishlistRequest) (*pb.CreateWishlistResponse, error) {
+	logger := loggerFromContext(ctx)
+	logger.Info("received create-wishlist request", "name", req.GetName(), "laptops", len(req.GetLaptopIds()))
+
+	userId, err := wishlistOwner(ctx)
+	if err != nil {
+		return nil, logError(logger, err)
+	}
+	if req.GetName() == "" {
+		return nil, logError(logger, status.Error(codes.InvalidArgument, "name is required"))
+	}
+
+	now := timestamppb.Now()
+	wishlist := &pb.Wishlist{Id: uuid.New().String(), Name: req.GetName(), CreatedAt: now, UpdatedAt: now}
+	for _, laptopId := range req.GetLaptopIds() {
+		if err := s.checkLaptop(ctx, laptopId); err != nil {
+			return nil, logError(logger, err)
+		}
+		if !containsId(wishlist.LaptopIds, laptopId) {
+			wishlist.LaptopIds = append(wishlist.LaptopIds, laptopId)
+		}
+	}
+
+	if err := s.WishlistStore.CreateWishlist(userId, wishlist); err != nil {
+		return nil, logError(logger, status.Errorf(codes.Internal, "couldn't create wishlist: %v", err))
+	}
+	deleted, err := s.pruneDeleted(ctx, wishlist.LaptopIds)
+	if err != nil {
+		return nil, logError(logger, err)
+	}
+	for _, laptopId := range deleted {
+		wishlist.LaptopIds = removeId(wishlist.LaptopIds, laptopId)
+	}
+
+	logger.Info("created wishlist", "wishlist_id", wishlist.Id)
+	return &pb.CreateWishlistResponse{Wishlist: wishlist}, nil
+}
+
+func (s *WishlistServer) ListWishlists(ctx context.Context, req *pb.ListWishlistsRequest) (*pb.ListWishlistsResponse, error) {
+	logger := loggerFromContext(ctx)
+
+	userId, err := wishlistOwner(ctx)
+	if err != nil {
+		return nil, logError(logger, err)
+	}
+	wishlists, err := s.WishlistStore.ListWishlists(userId)
+	if err != nil {
+		return nil, logError(logger, status.Errorf(codes.Internal, "couldn't list wishlists: %v", err))
+	}
+	return &pb.ListWishlistsResponse{Wishlists: wishlists}, nil
+}
+
+func (s *WishlistServer) DeleteWishlist(ctx context.Context, req *pb.DeleteWishlistRequest) (*pb.DeleteWishlistResponse, error) {
+	logger := loggerFromContext(ctx)
+	logger.Info("received delete-wishlist request", "wishlist_id", req.GetWishlistId())
+
+	userId, err := wishlistOwner(ctx)
+	if err != nil {
+		return nil, logError(logger, err)
+	}
+	if err := s.WishlistStore.DeleteWishlist(userId, req.GetWishlistId()); err != nil {
+		return nil, logError(logger, wishlistStoreError(err, req.GetWishlistId()))
+	}
+	return &pb.DeleteWishlistResponse{}, nil
+}
+
+func (s *WishlistServer) AddToWishlist(ctx context.Context, req *pb.AddToWishlistRequest) (*pb.AddToWishlistResponse, error) {
+	logger := loggerFromContext(ctx)
+	logger.Info("received add-to-wishlist request", "wishlist_id", req.GetWishlistId(), "laptop_id", req.GetLaptopId())
+
+	userId, err := wishlistOwner(ctx)
+	if err != nil {
+		return nil, logError(logger, err)
+	}
+	if err := s.checkLaptop(ctx, req.GetLaptopId()); err != nil {
+		return nil, logError(logger, err)
+	}
+
+	wishlist, err := s.WishlistStore.UpdateWishlist(userId, req.GetWishlistId(), func(wishlist *pb.Wishlist) error {
+		if !containsId(wishlist.LaptopIds, req.GetLaptopId()) {
+			wishlist.LaptopIds = append(wishlist.LaptopIds, req.GetLaptopId())
+			wishlist.UpdatedAt = timestamppb.Now()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, logError(logger, wishlistStoreError(err, req.GetWishlistId()))
+	}
+	deleted, err := s.pruneDeleted(ctx, []string{req.GetLaptopId()})
+	if err != nil {
+		return nil, logError(logger, err)
+	}
+	if len(deleted) > 0 {
+		return nil, logError(logger, laptopNotFoundError(req.GetLaptopId()))
+	}
+	return &pb.AddToWishlistResponse{Wishlist: wishlist}, nil
+}
+
+func (s *WishlistServer) RemoveFromWishlist(ctx context.Context, req *pb.RemoveFromWishlistRequest) (*pb.RemoveFromWishlistResponse, error) {
+	logger := loggerFromContext(ctx)
+	logger.Info("received remove-from-wishlist request", "wishlist_id", req.GetWishlistId(), "laptop_id", req.GetLaptopId())
+
+	userId, err := wishlistOwner(ctx)
+	if err != nil {
+		return nil, logError(logger, err)
+	}
+	wishlist, err := s.WishlistStore.UpdateWishlist(userId, req.GetWishlistId(), func(wishlist *pb.Wishlist) error {
+		if containsId(wishlist.LaptopIds, req.GetLaptopId()) {
+			wishlist.LaptopIds = removeId(wishlist.LaptopIds, req.GetLaptopId())
+			wishlist.UpdatedAt = timestamppb.Now()
+			return nil
+		}
+		return status.Errorf(codes.NotFound, "laptop is not in the wishlist: %s", req.GetLaptopId())
+	})
+	if err != nil {
+		return nil, logError(logger, wishlistStoreError(err, req.GetWishlistId()))
+	}
+	return &pb.RemoveFromWishlistResponse{Wishlist: wishlist}, nil
+}
+
+func (s *WishlistServer) SaveSearch(ctx context.Context, req *pb.SaveSearchRequest) (*pb.SaveSearchResponse, error) {
+	logger := loggerFromContext(ctx)
+	logger.Info("received save-search request", "name", req.GetName(), "filter", req.GetFilter().String(), "currency", req.GetCurrencyCode())
+
+	userId, err := wishlistOwner(ctx)
+	if err != nil {
+		return nil, logError(logger, err)
+	}
+	if req.GetName() == "" {
+		return nil, logError(logger, status.Error(codes.InvalidArgument, "name is required"))
+	}
+	currencyCode := req.GetCurrencyCode()
+	if currencyCode == "" {
+		currencyCode = BaseCurrency
+	}
+	if _, err := baseCurrencyFilter(s.exchangeRates(), req.GetFilter(), currencyCode); err != nil {
+		return nil, logError(logger, err)
+	}
+
+	search := &pb.SavedSearch{
+		Id:           uuid.New().String(),
+		Name:         req.GetName(),
+		Filter:       req.GetFilter(),
+		CurrencyCode: currencyCode,
+		CreatedAt:    timestamppb.Now(),
+	}
+	if search.Filter == nil {
+		search.Filter = &pb.Filter{}
+	}
+	if err := s.WishlistStore.SaveSearch(userId, search); err != nil {
+		return nil, logError(logger, status.Errorf(codes.Internal, "couldn't save search: %v", err))
+	}
+
+	logger.Info("saved search", "saved_search_id", search.Id)
+	return &pb.SaveSearchResponse{SavedSearch: search}, nil
+}
+
+func (s *WishlistServer) ListSavedSearches(ctx context.Context, req *pb.ListSavedSearchesRequest) (*pb.ListSavedSearchesResponse, error) {
+	logger := loggerFromContext(ctx)
+
+	userId, err := wishlistOwner(ctx)
+	if err != nil {
+		return nil, logError(logger, err)
+	}
+	searches, err := s.WishlistStore.ListSavedSearches(userId)
+	if err != nil {
+		return nil, logError(logger, status.Errorf(codes.Internal, "couldn't list saved searches: %v", err))
+	}
+	return &pb.ListSavedSearchesResponse{SavedSearches: searches}, nil
+}
+
+func (s *WishlistServer) DeleteSavedSearch(ctx context.Context, req *pb.DeleteSavedSearchRequest) (*pb.DeleteSavedSearchResponse, error) {
+	logger := loggerFromContext(ctx)
+	logger.Info("received delete-saved-search request", "saved_search_id", req.GetSavedSearchId())
+
+	userId, err := wishlistOwner(ctx)
+	if err != nil {
+		return nil, logError(logger, err)
+	}
+	if err := s.WishlistStore.DeleteSavedSearch(userId, req.GetSavedSearchId()); err != nil {
+		return nil, logError(logger, savedSearchStoreError(err, req.GetSavedSearchId()))
+	}
+	return &pb.DeleteSavedSearchResponse{}, nil
+}
+
+// Server streaming RPC sending the laptops matching a saved search, priced in its currency.
+func (s *WishlistServer) RunSavedSearch(req *pb.RunSavedSearchRequest, stream pb.WishlistService_RunSavedSearchServer) error {
+	ctx := stream.Context()
+	logger := loggerFromContext(ctx)
+	logger.Info("received run-saved-search request", "saved_search_id", req.GetSavedSearchId())
+
+	userId, err := wishlistOwner(ctx)
+	if err != nil {
+		return logError(logger, err)
+	}
+	search, err := s.WishlistStore.FindSavedSearch(userId, req.GetSavedSearchId())
+	if err != nil {
+		return logError(logger, savedSearchStoreError(err, req.GetSavedSearchId()))
+	}
+	filter, err := baseCurrencyFilter(s.exchangeRates(), search.GetFilter(), search.GetCurrencyCode())
+	if err != nil {
+		return logError(logger, err)
+	}
+
+	err = tenantLaptopStore(ctx, s.LaptopStore).Search(ctx, filter, func(laptop *pb.Laptop) error {
+		if err := localizeLaptopPrice(s.exchangeRates(), laptop, search.GetCurrencyCode()); err != nil {
+			return err
+		}
+		return stream.Send(&pb.RunSavedSearchResponse{Laptop: laptop})
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return logError(logger, err)
+		}
+		return logError(logger, status.Errorf(codes.Internal, "unexpected error: %v", err))
+	}
+	return nil
+}
+
+func (s *WishlistServer) checkLaptop(ctx context.Context, laptopId string) error {
+	_, err := tenantLaptopStore(ctx, s.LaptopStore).Find(laptopId)
+	if errors.Is(err, ErrNotFound) {
+		return laptopNotFoundError(laptopId)
+	}
+	if err != nil {
+		return status.Errorf(codes.Internal, "couldn't find laptop: %v", err)
+	}
+	return nil
+}
+
+// Takes the laptops deleted while they were being added back out of the wishlists,
+// since the store may have pruned the wishlists before they were added. Returns their ids.
+func (s *WishlistServer) pruneDeleted(ctx context.Context, laptopIds []string) ([]string, error) {
+	deleted := []string{}
+	for _, laptopId := range laptopIds {
+		_, err := tenantLaptopStore(ctx, s.LaptopStore).Find(laptopId)
+		if !errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err := s.WishlistStore.RemoveLaptop(laptopId); err != nil {
+			return nil, status.Errorf(codes.Internal, "couldn't update wishlists: %v", err)
+		}
+		deleted = append(deleted, laptopId)
+	}
+	return deleted, nil
+}
+
+func (s *WishlistServer) exchangeRates() ExchangeRateProvider {
+	if s.ExchangeRates == nil {
+		return NewStaticExchangeRates(BaseCurrency, nil)
+	}
+	return s.ExchangeRates
+}
+
+// Wishlists and saved searches belong to callers with a client certificate, an
+// address is shared by every client behind a NAT or the gateway.
+func wishlistOwner(ctx context.Context) (string, error) {
+	userId, ok := authenticatedIdentity(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "wishlists and saved searches need a client certificate")
+	}
+	return userId, nil
+}
+
+func wishlistStoreError(err error, wishlistId string) error {
+	if errors.Is(err, ErrWishlistNotFound) {
+		return statusWithDetails(codes.NotFound, fmt.Sprintf("wishlist not found: %s", wishlistId),
+			errorInfo(ReasonWishlistNotFound, map[string]string{"wishlist_id": wishlistId}),
+		)
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return status.Errorf(codes.Internal, "couldn't update wishlist: %v", err)
+}
+
+func savedSearchStoreError(err error, savedSearchId string) error {
+	if errors.Is(err, ErrSavedSearchNotFound) {
+		return statusWithDetails(codes.NotFound, fmt.Sprintf("saved search not found: %s", savedSearchId),
+			errorInfo(ReasonSavedSearchNotFound, map[string]string{"saved_search_id": savedSearchId}),
+		)
+	}
+	return status.Errorf(codes.Internal, "couldn't find saved search: %v", err)
+}
+
+func containsId(ids []string, id string) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
+
+func removeId(ids []string, id string) []string {
+	for i, other := range ids {
+		if other == id {
+			return append(ids[:i], ids[i+1:]...)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"errors"
+	"go-grpc-pcbook/pb"
+	"sort"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	ErrWishlistNotFound    = errors.New("wishlist not found.")
+	ErrSavedSearchNotFound = errors.New("saved search not found.")
+)
+
+// Wishlists and saved searches of each user. Other users' wishlists and saved
+// searches are not found. Changes run under the store lock on a copy, and are
+// dropped if they return an error.
+type WishlistStore interface {
+	// Saves a new wishlist of the user
+	CreateWishlist(userId string, wishlist *pb.Wishlist) error
+	// Wishlists of the user, oldest first
+	ListWishlists(userId string) ([]*pb.Wishlist, error)
+	// Changes a wishlist of the user, ErrWishlistNotFound if it's not there
+	UpdateWishlist(userId string, id string, change func(wishlist *pb.Wishlist) error) (*pb.Wishlist, error)
+	// Removes a wishlist of the user, ErrWishlistNotFound if it's not there
+	DeleteWishlist(userId string, id string) error
+	// Takes a laptop out of every wishlist holding it
+	RemoveLaptop(laptopId string) error
+	// Saves a new search of the user
+	SaveSearch(userId string, search *pb.SavedSearch) error
+	// Saved search of the user, ErrSavedSearchNotFound if it's not there
+	FindSavedSearch(userId string, id string) (*pb.SavedSearch, error)
+	// Saved searches of the user, oldest first
+	ListSavedSearches(userId string) ([]*pb.SavedSearch, error)
+	// Removes a saved search of the user, ErrSavedSearchNotFound if it's not there
+	DeleteSavedSearch(userId string, id string) error
+}
+
+type MemoryWishlistStore struct {
+	mutex sync.RWMutex
+	// user id -> wishlist id -> wishlist.
+	wishlists map[string]map[string]*pb.Wishlist
+	// user id -> saved search id -> saved search.
+	searches map[string]map[string]*pb.SavedSearch
+	// laptop id -> wishlist id -> user id, for the wishlists holding the laptop.
+	laptopWishlists map[string]map[string]string
+}
+
+func NewMemoryWishlistStore() *MemoryWishlistStore {
+	return &MemoryWishlistStore{
+		wishlists:       make(map[string]map[string]*pb.Wishlist),
+		searches:        make(map[string]map[string]*pb.SavedSearch),
+		laptopWishlists: make(map[string]map[string]string),
+	}
+}
+
+func (m *MemoryWishlistStore) CreateWishlist(userId string, wishlist *pb.Wishlist) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.wishlists[userId] == nil {
+		m.wishlists[userId] = make(map[string]*pb.Wishlist)
+	}
+	if m.wishlists[userId][wishlist.GetId()] != nil {
+		return ErrAlreadyExists
+	}
+	m.putWishlist(userId, proto.Clone(wishlist).(*pb.Wishlist))
+	return nil
+}
+
+func (m *MemoryWishlistStore) ListWishlists(userId string) ([]*pb.Wishlist, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	wishlists := make([]*pb.Wishlist, 0, len(m.wishlists[userId]))
+	for _, wishlist := range m.wishlists[userId] {
+		wishlists = append(wishlists, proto.Clone(wishlist).(*pb.Wishlist))
+	}
+	sort.Slice(wishlists, func(i, j int) bool {
+		return wishlists[i].GetCreatedAt().AsTime().Before(wishlists[j].GetCreatedAt().AsTime())
+	})
+	return wishlists, nil
+}
+
+func (m *MemoryWishlistStore) UpdateWishlist(userId string, id string, change func(wishlist *pb.Wishlist) error) (*pb.Wishlist, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	wishlist, ok := m.wishlists[userId][id]
+	if !ok {
+		return nil, ErrWishlistNotFound
+	}
+
+	changed := proto.Clone(wishlist).(*pb.Wishlist)
+	if err := change(changed); err != nil {
+		return nil, err
+	}
+	changed.Id = id
+	m.dropWishlist(userId, wishlist)
+	m.putWishlist(userId, changed)
+	return proto.Clone(changed).(*pb.Wishlist), nil
+}
+
+func (m *MemoryWishlistStore) DeleteWishlist(userId string, id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	wishlist, ok := m.wishlists[userId][id]
+	if !ok {
+		return ErrWishlistNotFound
+	}
+	m.dropWishlist(userId, wishlist)
+	return nil
+}
+
+func (m *MemoryWishlistStore) RemoveLaptop(laptopId string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for wishlistId, userId := range m.laptopWishlists[laptopId] {
+		wishlist := m.wishlists[userId][wishlistId]
+		wishlist.LaptopIds = removeId(wishlist.LaptopIds, laptopId)
+	}
+	delete(m.laptopWishlists, laptopId)
+	return nil
+}
+
+func (m *MemoryWishlistStore) SaveSearch(userId string, search *pb.SavedSearch) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.searches[userId] == nil {
+		m.searches[userId] = make(map[string]*pb.SavedSearch)
+	}
+	if m.searches[userId][search.GetId()] != nil {
+		return ErrAlreadyExists
+	}
+	m.searches[userId][search.GetId()] = proto.Clone(search).(*pb.SavedSearch)
+	return nil
+}
+
+func (m *MemoryWishlistStore) FindSavedSearch(userId string, id string) (*pb.SavedSearch, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	search, ok := m.searches[userId][id]
+	if !ok {
+		return nil, ErrSavedSearchNotFound
+	}
+	return proto.Clone(search).(*pb.SavedSearch), nil
+}
+
+func (m *MemoryWishlistStore) ListSavedSearches(userId string) ([]*pb.SavedSearch, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	searches := make([]*pb.SavedSearch, 0, len(m.searches[userId]))
+	for _, search := range m.searches[userId] {
+		searches = append(searches, proto.Clone(search).(*pb.SavedSearch))
+	}
+	sort.Slice(searches, func(i, j int) bool {
+		return searches[i].GetCreatedAt().AsTime().Before(searches[j].GetCreatedAt().AsTime())
+	})
+	return searches, nil
+}
+
+func (m *MemoryWishlistStore) DeleteSavedSearch(userId string, id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.searches[userId][id]; !ok {
+		return ErrSavedSearchNotFound
+	}
+	delete(m.searches[userId], id)
+	return nil
+}
+
+func (m *MemoryWishlistStore) putWishlist(userId string, wishlist *pb.Wishlist) {
+	m.wishlists[userId][wishlist.Id] = wishlist
+	for _, laptopId := range wishlist.LaptopIds {
+		if m.laptopWishlists[laptopId] == nil {
+			m.laptopWishlists[laptopId] = make(map[string]string)
+		}
+		m.laptopWishlists[laptopId][wishlist.Id] = userId
+	}
+}
+
+func (m *MemoryWishlistStore) dropWishlist(userId string, wishlist *pb.Wishlist) {
+	delete(m.wishlists[userId], wishlist.Id)
+	for _, laptopId := range wishlist.LaptopIds {
+		delete(m.laptopWishlists[laptopId], wishlist.Id)
+		if len(m.laptopWishlists[laptopId]) == 0 {
+			delete(m.laptopWishlists, laptopId)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package service_test
+
+import (
+	"context"
+	"go-grpc-pcbook/pb"
+	"go-grpc-pcbook/sample"
+	"go-grpc-pcbook/service"
+	"io"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The test client has a certificate for alice. Anonymous clients are dialed without one.
+func startTestWishlistServer(t *testing.T, laptopStore service.LaptopStore, wishlistStore service.WishlistStore) (client pb.WishlistServiceClient, anonymous pb.WishlistServiceClient) {
+	wishlistServer := service.NewWishlistServer(laptopStore, wishlistStore)
+	wishlistServer.ExchangeRates = service.NewStaticExchangeRates("USD", map[string]*big.Rat{"EUR": big.NewRat(1, 2)})
+
+	pki := newTestPKI(t)
+	grpcServer := grpc.NewServer(pki.serverOption(t))
+	pb.RegisterWishlistServiceServer(grpcServer, wishlistServer)
+	address := pki.serve(t, grpcServer)
+	return pb.NewWishlistServiceClient(pki.dial(t, address, "alice")), pb.NewWishlistServiceClient(pki.dial(t, address, ""))
+}
+
+func TestWishlists(t *testing.T) {
+	wishlistStore := service.NewMemoryWishlistStore()
+	laptopStore := service.NewWishlistPruningLaptopStore(service.NewMemoryLaptopStore(), wishlistStore)
+	wishlistClient, anonymousClient := startTestWishlistServer(t, laptopStore, wishlistStore)
+	ctx := context.Background()
+
+	first, second := sample.NewLaptop(), sample.NewLaptop()
+	require.NoError(t, laptopStore.Save(first))
+	require.NoError(t, laptopStore.Save(second))
+
+	// wishlists need a client certificate.
+	_, err := anonymousClient.CreateWishlist(ctx, &pb.CreateWishlistRequest{Name: "gaming"})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = anonymousClient.ListWishlists(ctx, &pb.ListWishlistsRequest{})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = wishlistClient.CreateWishlist(ctx, &pb.CreateWishlistRequest{LaptopIds: []string{first.Id}})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = wishlistClient.CreateWishlist(ctx, &pb.CreateWishlistRequest{Name: "gaming", LaptopIds: []string{"missing"}})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	created, err := wishlistClient.CreateWishlist(ctx, &pb.CreateWishlistRequest{Name: "gaming", LaptopIds: []string{first.Id, first.Id}})
+	require.NoError(t, err)
+	wishlistId := created.GetWishlist().GetId()
+	require.Equal(t, []string{first.Id}, created.GetWishlist().GetLaptopIds())
+	_, err = wishlistClient.CreateWishlist(ctx, &pb.CreateWishlistRequest{Name: "office"})
+	require.NoError(t, err)
+
+	added, err := wishlistClient.AddToWishlist(ctx, &pb.AddToWishlistRequest{WishlistId: wishlistId, LaptopId: second.Id})
+	require.NoError(t, err)
+	require.Equal(t, []string{first.Id, second.Id}, added.GetWishlist().GetLaptopIds())
+	_, err = wishlistClient.AddToWishlist(ctx, &pb.AddToWishlistRequest{WishlistId: "missing", LaptopId: second.Id})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	// deleting a laptop from the catalog takes it out of the wishlists.
+	require.NoError(t, laptopStore.Delete(first.Id))
+	list, err := wishlistClient.ListWishlists(ctx, &pb.ListWishlistsRequest{})
+	require.NoError(t, err)
+	require.Len(t, list.GetWishlists(), 2)
+	require.Equal(t, "gaming", list.GetWishlists()[0].GetName())
+	require.Equal(t, []string{second.Id}, list.GetWishlists()[0].GetLaptopIds())
+
+	removed, err := wishlistClient.RemoveFromWishlist(ctx, &pb.RemoveFromWishlistRequest{WishlistId: wishlistId, LaptopId: second.Id})
+	require.NoError(t, err)
+	require.Empty(t, removed.GetWishlist().GetLaptopIds())
+	_, err = wishlistClient.RemoveFromWishlist(ctx, &pb.RemoveFromWishlistRequest{WishlistId: wishlistId, LaptopId: second.Id})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = wishlistClient.DeleteWishlist(ctx, &pb.DeleteWishlistRequest{WishlistId: wishlistId})
+	require.NoError(t, err)
+	_, err = wishlistClient.DeleteWishlist(ctx, &pb.DeleteWishlistRequest{WishlistId: wishlistId})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	list, err = wishlistClient.ListWishlists(ctx, &pb.ListWishlistsRequest{})
+	require.NoError(t, err)
+	require.Len(t, list.GetWishlists(), 1)
+
+	// other users' wishlists don't exist for the caller.
+	_, err = wishlistStore.UpdateWishlist("user:other", list.GetWishlists()[0].GetId(), func(wishlist *pb.Wishlist) error { return nil })
+	require.ErrorIs(t, err, service.ErrWishlistNotFound)
+}
+
+func TestSavedSearches(t *testing.T) {
+	wishlistStore := service.NewMemoryWishlistStore()
+	laptopStore := service.NewMemoryLaptopStore()
+	wishlistClient, anonymousClient := startTestWishlistServer(t, laptopStore, wishlistStore)
+	ctx := context.Background()
+
+	cheap, expensive := sample.NewLaptop(), sample.NewLaptop()
+	cheap.Price, expensive.Price = 1000, 3000
+	require.NoError(t, laptopStore.Save(cheap))
+	require.NoError(t, laptopStore.Save(expensive))
+
+	_, err := anonymousClient.SaveSearch(ctx, &pb.SaveSearchRequest{Name: "cheap"})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = wishlistClient.SaveSearch(ctx, &pb.SaveSearchRequest{Name: "cheap", CurrencyCode: "XXX"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// 1000 EUR is 2000 USD.
+	saved, err := wishlistClient.SaveSearch(ctx, &pb.SaveSearchRequest{Name: "cheap", Filter: &pb.Filter{MaxPrice: 1000}, CurrencyCode: "EUR"})
+	require.NoError(t, err)
+	searchId := saved.GetSavedSearch().GetId()
+
+	anonymous, err := anonymousClient.RunSavedSearch(ctx, &pb.RunSavedSearchRequest{SavedSearchId: searchId})
+	require.NoError(t, err)
+	_, err = anonymous.Recv()
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	stream, err := wishlistClient.RunSavedSearch(ctx, &pb.RunSavedSearchRequest{SavedSearchId: searchId})
+	require.NoError(t, err)
+	found := []*pb.Laptop{}
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		found = append(found, res.GetLaptop())
+	}
+	require.Len(t, found, 1)
+	require.Equal(t, cheap.Id, found[0].GetId())
+	require.Equal(t, "500.00 EUR", service.FormatMoney(found[0].GetListPrice()))
+
+	list, err := wishlistClient.ListSavedSearches(ctx, &pb.ListSavedSearchesRequest{})
+	require.NoError(t, err)
+	require.Len(t, list.GetSavedSearches(), 1)
+	require.Equal(t, "cheap", list.GetSavedSearches()[0].GetName())
+
+	_, err = wishlistClient.DeleteSavedSearch(ctx, &pb.DeleteSavedSearchRequest{SavedSearchId: searchId})
+	require.NoError(t, err)
+	stream, err = wishlistClient.RunSavedSearch(ctx, &pb.RunSavedSearchRequest{SavedSearchId: searchId})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.NotFound, status.Code(err))
+}